@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Embed the time zone database for user time zones
 
 	"github.com/todomaster-2010/backend/internal/api"
 	"github.com/todomaster-2010/backend/internal/database"
@@ -31,6 +32,16 @@ func main() {
 		dbPath = "./data/taskmaster.db"
 	}
 
+	trashRetention := 30 * 24 * time.Hour
+	if v := os.Getenv("TRASH_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			slog.Error("invalid TRASH_RETENTION, expected a positive duration such as 720h", "value", v)
+			os.Exit(1)
+		}
+		trashRetention = d
+	}
+
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		jwtSecret = "dev-secret-change-in-production" // Default for development only
@@ -45,15 +56,36 @@ func main() {
 	}
 	defer db.Close()
 
+	// Handle "migrate" subcommands before starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+			slog.Error("migrate command failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Run migrations
 	if err := db.Migrate(); err != nil {
 		slog.Error("failed to run migrations", "error", err)
 		os.Exit(1)
 	}
 
+	// Imports that were running when the server last stopped will never finish
+	if n, err := db.FailInterruptedImportJobs(context.Background()); err != nil {
+		slog.Error("failed to mark interrupted import jobs", "error", err)
+	} else if n > 0 {
+		slog.Warn("marked interrupted import jobs as failed", "count", n)
+	}
+
 	// Create API handler
 	handler := api.New(db, jwtSecret)
 
+	// Periodically purge items that have been in the trash longer than the retention period
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go runTrashPurge(purgeCtx, db, trashRetention)
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         ":" + port,
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// runMigrateCommand handles "migrate status", "migrate up" and "migrate down <version>".
+func runMigrateCommand(db *database.DB, args []string) error {
+	ctx := context.Background()
+
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "status":
+		applied, err := db.AppliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		pending, err := db.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range applied {
+			fmt.Printf("applied  %04d  %s  (%s)\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		for _, m := range pending {
+			fmt.Printf("pending  %04d  %s\n", m.Version, m.Name)
+		}
+		if len(pending) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return nil
+
+	case "up":
+		return db.Migrate()
+
+	case "down":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate down <version>")
+		}
+		target, err := strconv.Atoi(args[1])
+		if err != nil || target < 0 {
+			return fmt.Errorf("invalid target version %q", args[1])
+		}
+		return db.MigrateDown(ctx, target)
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected status, up or down)", cmd)
+	}
+}
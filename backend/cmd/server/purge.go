@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// trashPurgeInterval is how often the trash is checked for expired items.
+const trashPurgeInterval = time.Hour
+
+// runTrashPurge permanently deletes trashed items older than the retention period,
+// once at startup and then every trashPurgeInterval until ctx is cancelled.
+func runTrashPurge(ctx context.Context, store database.TrashStore, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		count, err := store.PurgeTrash(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("failed to purge trash", "error", err)
+		} else if count > 0 {
+			slog.Info("purged expired trash", "count", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// basicAuthRealm is the realm app clients are asked to sign in to.
+const basicAuthRealm = "TodoMaster"
+
+// CreateAppPasswordRequest is the request body for creating an app password.
+type CreateAppPasswordRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateAppPasswordResponse is a new app password. Password is only returned here.
+type CreateAppPasswordResponse struct {
+	*database.AppPassword
+	Password string `json:"password"`
+}
+
+// handleGetAppPasswords returns the current user's app passwords.
+func (h *Handler) handleGetAppPasswords(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	passwords, err := h.db.GetAppPasswords(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get app passwords")
+		return
+	}
+
+	// Return empty array instead of null
+	if passwords == nil {
+		passwords = []*database.AppPassword{}
+	}
+
+	h.jsonResponse(w, http.StatusOK, passwords)
+}
+
+// handleCreateAppPassword generates a password that an app such as a CalDAV
+// client signs in with, together with the user's email.
+func (h *Handler) handleCreateAppPassword(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var req CreateAppPasswordRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	password, err := generateAppPassword()
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create app password")
+		return
+	}
+
+	created, err := h.db.CreateAppPassword(r.Context(), userID, password, strings.TrimSpace(req.Name))
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create app password")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusCreated, CreateAppPasswordResponse{
+		AppPassword: created,
+		Password:    password,
+	})
+}
+
+// handleDeleteAppPassword revokes an app password; apps using it are signed out.
+func (h *Handler) handleDeleteAppPassword(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	passwordID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid app password id")
+		return
+	}
+
+	if err := h.db.DeleteAppPassword(r.Context(), userID, passwordID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "app password not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to delete app password")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "app password deleted successfully",
+	})
+}
+
+// generateAppPassword returns a random password of 24 lowercase letters and
+// digits in groups of four, to be easy to type into a device.
+func generateAppPassword() (string, error) {
+	b := make([]byte, 15)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// requireBasicAuth is middleware for endpoints used by third-party apps. The
+// user signs in with HTTP Basic auth, giving their email and an app password;
+// their real password is not accepted.
+func (h *Handler) requireBasicAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email, password, ok := r.BasicAuth()
+		if !ok {
+			h.basicAuthChallenge(w, "authorization required")
+			return
+		}
+
+		user, err := h.db.GetUserByEmail(r.Context(), email)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				h.basicAuthChallenge(w, "invalid credentials")
+				return
+			}
+			h.errorResponse(w, http.StatusInternalServerError, "failed to authenticate")
+			return
+		}
+
+		if _, err := h.db.CheckAppPassword(r.Context(), user.ID, password); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				h.basicAuthChallenge(w, "invalid credentials")
+				return
+			}
+			h.errorResponse(w, http.StatusInternalServerError, "failed to authenticate")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey, user.ID)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// basicAuthChallenge rejects a request with a 401 that asks for Basic auth.
+func (h *Handler) basicAuthChallenge(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+basicAuthRealm+`", charset="UTF-8"`)
+	h.errorResponse(w, http.StatusUnauthorized, message)
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// maxArchiveSize caps the size of an uploaded archive, in bytes.
+const maxArchiveSize = 50 << 20
+
+// Import modes.
+const (
+	importModeMerge   = "merge"
+	importModeReplace = "replace"
+)
+
+// ImportResponse is the result of an import.
+type ImportResponse struct {
+	Mode   string                 `json:"mode"`
+	DryRun bool                   `json:"dryRun"`
+	Report *database.ImportReport `json:"report"`
+}
+
+// handleExport streams the current user's lists, tasks, subtasks, tags, smart
+// lists and settings as an archive download.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+	ctx := r.Context()
+
+	user, err := h.db.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "user not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	lists, err := h.db.GetLists(ctx, userID, database.ListFilter{})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get lists")
+		return
+	}
+	tags, err := h.db.GetTags(ctx, userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tags")
+		return
+	}
+	tasks, err := h.db.GetUserTasks(ctx, userID, database.TaskFilter{Sort: database.TaskSortOrder})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+		return
+	}
+	smartLists, err := h.db.GetSmartLists(ctx, userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get smart lists")
+		return
+	}
+
+	archive := database.NewArchive(user, lists, tags, tasks, smartLists)
+	filename := fmt.Sprintf("todomaster-export-%s.json", archive.ExportedAt.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		slog.Error("failed to encode export", "error", err)
+	}
+}
+
+// handleImport restores an archive into the current user's account. The mode
+// parameter is "merge" (the default) or "replace", and dryRun=true reports what
+// the import would do without changing anything.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	params := r.URL.Query()
+	mode := params.Get("mode")
+	switch mode {
+	case "":
+		mode = importModeMerge
+	case importModeMerge, importModeReplace:
+	default:
+		h.errorResponse(w, http.StatusBadRequest, "invalid mode: want merge or replace")
+		return
+	}
+	var dryRun bool
+	if v := params.Get("dryRun"); v != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "invalid dryRun")
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxArchiveSize)
+	var archive database.Archive
+	if err := h.decodeJSON(r, &archive); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.errorResponse(w, http.StatusRequestEntityTooLarge, "archive is too large")
+			return
+		}
+		h.errorResponse(w, http.StatusBadRequest, "invalid archive")
+		return
+	}
+	if err := validateArchive(&archive); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid archive: "+err.Error())
+		return
+	}
+
+	report, err := h.db.ImportArchive(r.Context(), userID, &archive, database.ImportOptions{
+		Replace: mode == importModeReplace,
+		DryRun:  dryRun,
+	})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to import archive")
+		return
+	}
+
+	if !dryRun {
+		// Too much may have changed to send piecemeal; clients resync
+		h.hub.BroadcastToUser(userID, WebSocketEvent{
+			Type:    "data_imported",
+			Payload: map[string]string{"mode": mode},
+		})
+	}
+
+	h.jsonResponse(w, http.StatusOK, ImportResponse{Mode: mode, DryRun: dryRun, Report: report})
+}
+
+// validateArchive checks an archive's structure and the values the API
+// validates elsewhere, rewriting recurrence rules in canonical form.
+func validateArchive(archive *database.Archive) error {
+	if err := archive.Validate(); err != nil {
+		return err
+	}
+	if tz := archive.Settings.Timezone; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil || tz == "Local" {
+			return errors.New("settings: invalid timezone")
+		}
+	}
+	for i, tag := range archive.Tags {
+		if tag.Color != "" && !tagColorPattern.MatchString(tag.Color) {
+			return fmt.Errorf("tags[%d]: invalid color", i)
+		}
+	}
+	for i, task := range archive.Tasks {
+		rule, err := normalizeRecurrence(task.Recurrence, task.RepeatFrom)
+		if err != nil {
+			return fmt.Errorf("tasks[%d]: %v", i, err)
+		}
+		task.Recurrence = rule
+	}
+	for i, list := range archive.SmartLists {
+		if _, err := parseQuery(list.Query); err != nil {
+			return fmt.Errorf("smartLists[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// maxBatchOperations is the most operations a single batch request may carry.
+const maxBatchOperations = 100
+
+// Outcomes of an operation in a batch.
+const (
+	batchOK      = "ok"
+	batchFailed  = "failed"
+	batchSkipped = "skipped" // Not applied because another operation failed
+)
+
+// BatchTasksRequest is the request body for applying operations to many tasks at
+// once.
+type BatchTasksRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is one operation in a batch. Which of ListID, Tags and Important
+// it takes depends on Op.
+type BatchOperation struct {
+	Op        string   `json:"op"` // complete, uncomplete, delete, move, addTags, removeTags or setImportant
+	TaskID    int64    `json:"taskId"`
+	Version   int64    `json:"version,omitempty"`   // Expected version of the task, if set
+	ListID    *int64   `json:"listId,omitempty"`    // move; null or omitted moves the task out of its list
+	Tags      []string `json:"tags,omitempty"`      // addTags and removeTags
+	Important *bool    `json:"important,omitempty"` // setImportant
+}
+
+// BatchResult reports the outcome of one operation in a batch.
+type BatchResult struct {
+	Index  int            `json:"index"`
+	TaskID int64          `json:"taskId"`
+	Status string         `json:"status"` // "ok", "failed" or "skipped"
+	Error  string         `json:"error,omitempty"`
+	Task   *database.Task `json:"task,omitempty"` // The task after the batch, unless it was deleted
+}
+
+// BatchTasksResponse is the response to a batch, with a result for every
+// operation. Error is set when the batch was not applied.
+type BatchTasksResponse struct {
+	Error   string        `json:"error,omitempty"`
+	Results []BatchResult `json:"results"`
+}
+
+// TasksBatchEvent is the payload of the tasks_batch_updated event: every task a
+// batch changed, each listed once.
+type TasksBatchEvent struct {
+	Created []*database.Task `json:"created"` // Next occurrences of completed recurring tasks
+	Updated []*database.Task `json:"updated"`
+	Deleted []int64          `json:"deleted"`
+}
+
+// handleBatchTasks applies a list of operations to the user's tasks in one
+// transaction. Either every operation is applied or none is; the response has a
+// result for each of them either way.
+func (h *Handler) handleBatchTasks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var req BatchTasksRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if len(req.Operations) == 0 {
+		h.errorResponse(w, http.StatusBadRequest, "operations is required")
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		h.errorResponse(w, http.StatusBadRequest, "too many operations")
+		return
+	}
+
+	results := make([]BatchResult, len(req.Operations))
+	ops := make([]database.TaskOperation, len(req.Operations))
+	invalid := false
+	for i, op := range req.Operations {
+		results[i] = BatchResult{Index: i, TaskID: op.TaskID, Status: batchSkipped}
+
+		var err error
+		ops[i], err = taskOperation(op)
+		if err != nil {
+			results[i].Status = batchFailed
+			results[i].Error = err.Error()
+			invalid = true
+		}
+	}
+	if invalid {
+		h.jsonResponse(w, http.StatusBadRequest, BatchTasksResponse{
+			Error:   "invalid operations",
+			Results: results,
+		})
+		return
+	}
+
+	schedule, err := h.occurrenceScheduler(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to update tasks")
+		return
+	}
+
+	batch, err := h.db.BatchUpdateTasks(r.Context(), userID, ops, schedule)
+	if err != nil {
+		var batchErr *database.BatchError
+		if !errors.As(err, &batchErr) {
+			h.errorResponse(w, http.StatusInternalServerError, "failed to update tasks")
+			return
+		}
+
+		status, message := http.StatusInternalServerError, "failed to update task"
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			status, message = http.StatusNotFound, "task not found"
+		case errors.Is(err, database.ErrListNotFound):
+			status, message = http.StatusNotFound, "list not found"
+		case errors.Is(err, database.ErrVersionConflict):
+			status, message = http.StatusConflict, "version conflict"
+		}
+		results[batchErr.Index].Status = batchFailed
+		results[batchErr.Index].Error = message
+		h.jsonResponse(w, status, BatchTasksResponse{
+			Error:   "batch not applied",
+			Results: results,
+		})
+		return
+	}
+
+	event := TasksBatchEvent{
+		Created: []*database.Task{},
+		Updated: []*database.Task{},
+		Deleted: []int64{},
+	}
+	event.Created = append(event.Created, batch.Created...)
+	seen := make(map[int64]bool)
+	for i, op := range req.Operations {
+		if seen[op.TaskID] {
+			continue
+		}
+		seen[op.TaskID] = true
+		if batch.Tasks[i] == nil {
+			event.Deleted = append(event.Deleted, op.TaskID)
+		} else {
+			event.Updated = append(event.Updated, batch.Tasks[i])
+		}
+	}
+
+	for i := range results {
+		results[i].Status = batchOK
+		results[i].Task = batch.Tasks[i]
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "tasks_batch_updated",
+		Payload: event,
+	})
+
+	h.jsonResponse(w, http.StatusOK, BatchTasksResponse{Results: results})
+}
+
+// taskOperation validates a batch operation and converts it into the change the
+// store applies. The store checks the list a move names, along with the task.
+func taskOperation(op BatchOperation) (database.TaskOperation, error) {
+	taskOp := database.TaskOperation{TaskID: op.TaskID, Version: op.Version}
+	if op.TaskID <= 0 {
+		return taskOp, errors.New("taskId is required")
+	}
+	if op.Version < 0 {
+		return taskOp, errors.New("invalid version")
+	}
+
+	switch op.Op {
+	case "complete", "uncomplete":
+		taskOp.Updates = map[string]interface{}{"completed": op.Op == "complete"}
+	case "delete":
+		taskOp.Delete = true
+	case "move":
+		var listID interface{}
+		if op.ListID != nil {
+			listID = *op.ListID
+		}
+		taskOp.Updates = map[string]interface{}{"listId": listID}
+	case "addTags", "removeTags":
+		if len(op.Tags) == 0 {
+			return taskOp, errors.New("tags is required")
+		}
+		for _, tag := range op.Tags {
+			if tag == "" {
+				return taskOp, errors.New("invalid tag")
+			}
+		}
+		if op.Op == "addTags" {
+			taskOp.AddTags = op.Tags
+		} else {
+			taskOp.RemoveTags = op.Tags
+		}
+	case "setImportant":
+		if op.Important == nil {
+			return taskOp, errors.New("important is required")
+		}
+		taskOp.Updates = map[string]interface{}{"important": *op.Important}
+	default:
+		return taskOp, errors.New("unknown op")
+	}
+	return taskOp, nil
+}
@@ -0,0 +1,1310 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/ical"
+)
+
+// CalDAV (RFC 4791) lets to-do apps sync a user's tasks both ways. The user's
+// principal is /dav/ and their calendar home /dav/calendars/, which holds one
+// VTODO calendar collection per list plus "inbox" for the tasks in no list.
+// Every task and subtask is a calendar object resource in its task's list.
+// Writes run through the REST handlers, so they are validated, recorded and
+// broadcast as if they had been made in the app.
+const (
+	davRoot  = "/dav/"
+	davHome  = "/dav/calendars/"
+	davInbox = "inbox"
+
+	// davCompliance is the DAV header: the WebDAV classes and CalDAV.
+	davCompliance = "1, 3, calendar-access"
+	davAllow      = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT"
+
+	// caldavProductID identifies the server in the PRODID of CalDAV resources.
+	caldavProductID = "-//TodoMaster 2010//CalDAV//EN"
+
+	// syncTokenPrefix starts the sync tokens of collections; the sync cursor
+	// follows it.
+	syncTokenPrefix = "urn:todomaster:sync:"
+
+	maxCalendarObjectSize = 1 << 20
+	maxDAVRequestSize     = 1 << 20
+)
+
+// calendarContentType is the content type of calendar object resources.
+const calendarContentType = "text/calendar; charset=utf-8; component=vtodo"
+
+// davCollection is a calendar collection: a list, or the inbox when list is nil.
+type davCollection struct {
+	segment string
+	list    *database.List
+}
+
+func (c *davCollection) href() string {
+	return davHome + c.segment + "/"
+}
+
+func (c *davCollection) title() string {
+	if c.list == nil {
+		return "Inbox"
+	}
+	return c.list.Title
+}
+
+func (c *davCollection) listID() *int64 {
+	if c.list == nil {
+		return nil
+	}
+	return &c.list.ID
+}
+
+// davObject is a calendar object resource: a task, or a subtask with the task it
+// belongs to.
+type davObject struct {
+	name      string
+	uid       string
+	task      *database.Task
+	subtask   *database.Subtask
+	parentUID string
+
+	data []byte
+	etag string
+}
+
+// davNames holds the resource names and UIDs clients gave the tasks and
+// subtasks they created. Others get names and UIDs derived from their IDs.
+type davNames struct {
+	tasks    map[int64]*database.CalDAVObject
+	subtasks map[int64]*database.CalDAVObject
+}
+
+func (n *davNames) task(id int64) (name, uid string) {
+	if obj, ok := n.tasks[id]; ok {
+		return obj.Name, obj.UID
+	}
+	return "task-" + strconv.FormatInt(id, 10) + ".ics", taskUID(id)
+}
+
+func (n *davNames) subtask(id int64) (name, uid string) {
+	if obj, ok := n.subtasks[id]; ok {
+		return obj.Name, obj.UID
+	}
+	return "subtask-" + strconv.FormatInt(id, 10) + ".ics", subtaskUID(id)
+}
+
+// davMembers are the resources of a collection. Names and UIDs are unique
+// within a collection; should two members share one, the first is used.
+type davMembers struct {
+	objects []*davObject
+	byName  map[string]*davObject
+	byUID   map[string]*davObject
+}
+
+// davPropRequest is which properties a PROPFIND or REPORT asks for.
+type davPropRequest struct {
+	all      bool
+	propName bool
+	names    []xml.Name
+}
+
+// newPropRequest returns the properties asked for by a request's DAV:allprop,
+// DAV:propname or DAV:prop element, all of them if it has none.
+func newPropRequest(allProp, propName *struct{}, prop *davPropNames) davPropRequest {
+	switch {
+	case propName != nil:
+		return davPropRequest{propName: true}
+	case prop != nil && allProp == nil:
+		return davPropRequest{names: prop.names()}
+	}
+	return davPropRequest{all: true}
+}
+
+// handleDAV serves the CalDAV tree under /dav/.
+func (h *Handler) handleDAV(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", davCompliance)
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", davAllow)
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		h.handleDAVPropfind(w, r)
+	case "PROPPATCH":
+		h.handleDAVProppatch(w, r)
+	case "REPORT":
+		h.handleDAVReport(w, r)
+	case http.MethodGet, http.MethodHead:
+		h.handleDAVGet(w, r)
+	case http.MethodPut:
+		h.handleDAVPut(w, r)
+	case http.MethodDelete:
+		h.handleDAVDelete(w, r)
+	default:
+		w.Header().Set("Allow", davAllow)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWellKnownCalDAV points clients discovering the CalDAV server at it (RFC 6764).
+func (h *Handler) handleWellKnownCalDAV(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, davRoot, http.StatusMovedPermanently)
+}
+
+// davPath is a parsed path in the CalDAV tree. collection is set for
+// collections and resources, and name for resources.
+type davPath struct {
+	principal  bool
+	home       bool
+	collection string
+	name       string
+}
+
+// parseDAVPath parses a request path, returning false if it isn't in the tree.
+func parseDAVPath(p string) (davPath, bool) {
+	rest, ok := strings.CutPrefix(p, davRoot)
+	if !ok {
+		return davPath{}, false
+	}
+	if rest == "" {
+		return davPath{principal: true}, true
+	}
+
+	parts := strings.Split(strings.TrimSuffix(rest, "/"), "/")
+	if parts[0] != "calendars" {
+		return davPath{}, false
+	}
+	switch {
+	case len(parts) == 1:
+		return davPath{home: true}, true
+	case len(parts) == 2 && parts[1] != "":
+		return davPath{collection: parts[1]}, true
+	case len(parts) == 3 && parts[1] != "" && parts[2] != "" && !strings.HasSuffix(rest, "/"):
+		return davPath{collection: parts[1], name: parts[2]}, true
+	}
+	return davPath{}, false
+}
+
+// davCollection loads the collection a path segment names.
+// Returns database.ErrNotFound if there is none.
+func (h *Handler) davCollection(ctx context.Context, userID int64, segment string) (*davCollection, error) {
+	if segment == davInbox {
+		return &davCollection{segment: davInbox}, nil
+	}
+	listID, err := strconv.ParseInt(segment, 10, 64)
+	if err != nil || strconv.FormatInt(listID, 10) != segment {
+		return nil, database.ErrNotFound
+	}
+	list, err := h.db.GetList(ctx, userID, listID)
+	if err != nil {
+		return nil, err
+	}
+	return &davCollection{segment: segment, list: list}, nil
+}
+
+// davCollections loads all of the user's collections, the inbox first.
+func (h *Handler) davCollections(ctx context.Context, userID int64) ([]*davCollection, error) {
+	lists, err := h.db.GetLists(ctx, userID, database.ListFilter{})
+	if err != nil {
+		return nil, err
+	}
+	collections := []*davCollection{{segment: davInbox}}
+	for _, list := range lists {
+		collections = append(collections, &davCollection{segment: strconv.FormatInt(list.ID, 10), list: list})
+	}
+	return collections, nil
+}
+
+// davNames loads the names and UIDs clients gave the user's tasks and subtasks.
+func (h *Handler) davNames(ctx context.Context, userID int64) (*davNames, error) {
+	objects, err := h.db.GetCalDAVObjects(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	names := &davNames{
+		tasks:    make(map[int64]*database.CalDAVObject),
+		subtasks: make(map[int64]*database.CalDAVObject),
+	}
+	for _, obj := range objects {
+		if obj.TaskID != nil {
+			names.tasks[*obj.TaskID] = obj
+		} else if obj.SubtaskID != nil {
+			names.subtasks[*obj.SubtaskID] = obj
+		}
+	}
+	return names, nil
+}
+
+// davMembers loads and renders a collection's tasks and subtasks.
+func (h *Handler) davMembers(ctx context.Context, userID int64, coll *davCollection, names *davNames) (*davMembers, error) {
+	filter := database.TaskFilter{ListID: coll.listID(), NoList: coll.list == nil}
+	tasks, err := h.db.GetUserTasks(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	members := &davMembers{
+		byName: make(map[string]*davObject),
+		byUID:  make(map[string]*davObject),
+	}
+	add := func(obj *davObject) error {
+		if err := obj.render(); err != nil {
+			return err
+		}
+		members.objects = append(members.objects, obj)
+		if _, ok := members.byName[obj.name]; !ok {
+			members.byName[obj.name] = obj
+		}
+		if _, ok := members.byUID[obj.uid]; !ok {
+			members.byUID[obj.uid] = obj
+		}
+		return nil
+	}
+
+	for _, task := range tasks {
+		name, uid := names.task(task.ID)
+		if err := add(&davObject{name: name, uid: uid, task: task}); err != nil {
+			return nil, err
+		}
+		for _, subtask := range task.Subtasks {
+			subtaskName, subtaskUID := names.subtask(subtask.ID)
+			obj := &davObject{name: subtaskName, uid: subtaskUID, task: task, subtask: subtask, parentUID: uid}
+			if err := add(obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return members, nil
+}
+
+// render renders the object as an iCalendar file and computes its ETag.
+func (obj *davObject) render() error {
+	var buf bytes.Buffer
+	cal := ical.NewWriter(&buf)
+	cal.Begin("VCALENDAR")
+	cal.Property("VERSION", "2.0")
+	cal.Property("PRODID", caldavProductID)
+	if obj.subtask != nil {
+		writeSubtaskTodo(cal, obj.subtask, obj.uid, obj.parentUID)
+	} else {
+		writeTaskTodo(cal, obj.task, obj.uid)
+	}
+	cal.End("VCALENDAR")
+	if err := cal.Err(); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	obj.data = buf.Bytes()
+	obj.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	return nil
+}
+
+// davState loads a collection and its members for a request on a resource or
+// collection path, writing a 404 if the collection doesn't exist.
+func (h *Handler) davState(w http.ResponseWriter, r *http.Request, segment string) (*davCollection, *davNames, *davMembers, bool) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	coll, err := h.davCollection(r.Context(), userID, segment)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			http.Error(w, "collection not found", http.StatusNotFound)
+			return nil, nil, nil, false
+		}
+		http.Error(w, "failed to get collection", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+	names, err := h.davNames(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to get resources", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+	members, err := h.davMembers(r.Context(), userID, coll, names)
+	if err != nil {
+		http.Error(w, "failed to get resources", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+	return coll, names, members, true
+}
+
+// readDAVBody reads a request body of at most limit bytes, writing a 413 if it
+// is larger.
+func readDAVBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// --- Properties ---
+
+// davPrincipalProps returns the properties shared by every resource: where the
+// user's principal and calendars are.
+func davPrincipalProps(props map[xml.Name]string) {
+	props[davName(nsDAV, "current-user-principal")] = davHref(davRoot)
+	props[davName(nsDAV, "owner")] = davHref(davRoot)
+	props[davName(nsCalDAV, "calendar-home-set")] = davHref(davHome)
+	props[davName(nsDAV, "current-user-privilege-set")] = davPrivileges("read", "write-content", "bind", "unbind")
+}
+
+func davPrivileges(privileges ...string) string {
+	var b strings.Builder
+	for _, p := range privileges {
+		b.WriteString(davElement(davName(nsDAV, "privilege"), davElement(davName(nsDAV, p), "")))
+	}
+	return b.String()
+}
+
+// principalProps returns the properties of the user's principal.
+func principalProps(user *database.User) map[xml.Name]string {
+	props := make(map[xml.Name]string)
+	davPrincipalProps(props)
+	name := user.DisplayName
+	if name == "" {
+		name = user.Email
+	}
+	props[davName(nsDAV, "resourcetype")] = davElement(davName(nsDAV, "collection"), "") + davElement(davName(nsDAV, "principal"), "")
+	props[davName(nsDAV, "displayname")] = davText(name)
+	props[davName(nsDAV, "principal-URL")] = davHref(davRoot)
+	props[davName(nsCalDAV, "calendar-user-address-set")] = davHref("mailto:" + user.Email)
+	return props
+}
+
+// homeProps returns the properties of the user's calendar home.
+func homeProps() map[xml.Name]string {
+	props := make(map[xml.Name]string)
+	davPrincipalProps(props)
+	props[davName(nsDAV, "resourcetype")] = davElement(davName(nsDAV, "collection"), "")
+	props[davName(nsDAV, "displayname")] = "Calendars"
+	return props
+}
+
+// collectionProps returns the properties of a calendar collection.
+func collectionProps(coll *davCollection, syncToken string) map[xml.Name]string {
+	props := make(map[xml.Name]string)
+	davPrincipalProps(props)
+
+	var reports strings.Builder
+	for _, report := range []xml.Name{
+		davName(nsCalDAV, "calendar-multiget"),
+		davName(nsCalDAV, "calendar-query"),
+		davName(nsDAV, "sync-collection"),
+	} {
+		reports.WriteString(davElement(davName(nsDAV, "supported-report"),
+			davElement(davName(nsDAV, "report"), davElement(report, ""))))
+	}
+
+	props[davName(nsDAV, "resourcetype")] = davElement(davName(nsDAV, "collection"), "") + davElement(davName(nsCalDAV, "calendar"), "")
+	props[davName(nsDAV, "displayname")] = davText(coll.title())
+	props[davName(nsCalDAV, "supported-calendar-component-set")] = `<comp xmlns="` + nsCalDAV + `" name="VTODO"/>`
+	props[davName(nsCalDAV, "supported-calendar-data")] = `<calendar-data xmlns="` + nsCalDAV + `" content-type="text/calendar" version="2.0"/>`
+	props[davName(nsCalDAV, "max-resource-size")] = strconv.Itoa(maxCalendarObjectSize)
+	props[davName(nsDAV, "supported-report-set")] = reports.String()
+	props[davName(nsDAV, "sync-token")] = davText(syncToken)
+	props[davName(nsCalendarServer, "getctag")] = davText(syncToken)
+	return props
+}
+
+// objectProps returns the properties of a calendar object resource.
+func objectProps(obj *davObject) map[xml.Name]string {
+	props := make(map[xml.Name]string)
+	davPrincipalProps(props)
+	props[davName(nsDAV, "resourcetype")] = ""
+	props[davName(nsDAV, "getetag")] = davText(obj.etag)
+	props[davName(nsDAV, "getcontenttype")] = davText(calendarContentType)
+	props[davName(nsDAV, "getcontentlength")] = strconv.Itoa(len(obj.data))
+	props[davName(nsCalDAV, "calendar-data")] = davText(string(obj.data))
+	return props
+}
+
+// davAllPropExcluded are properties left out of DAV:allprop responses; clients
+// must ask for them by name.
+var davAllPropExcluded = map[xml.Name]bool{
+	davName(nsCalDAV, "calendar-data"):           true,
+	davName(nsDAV, "current-user-privilege-set"): true,
+	davName(nsDAV, "supported-report-set"):       true,
+	davName(nsDAV, "sync-token"):                 true,
+}
+
+// propResponse builds the response for a resource with the properties asked
+// for, reporting the ones it doesn't have as not found.
+func propResponse(href string, props map[xml.Name]string, req davPropRequest) davResponse {
+	var found, missing []davProperty
+	switch {
+	case req.all || req.propName:
+		for name, value := range props {
+			if req.all && davAllPropExcluded[name] {
+				continue
+			}
+			if req.propName {
+				value = ""
+			}
+			found = append(found, davProperty{XMLName: name, Inner: value})
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].XMLName.Space != found[j].XMLName.Space {
+				return found[i].XMLName.Space < found[j].XMLName.Space
+			}
+			return found[i].XMLName.Local < found[j].XMLName.Local
+		})
+	default:
+		for _, name := range req.names {
+			if value, ok := props[name]; ok {
+				found = append(found, davProperty{XMLName: name, Inner: value})
+			} else {
+				missing = append(missing, davProperty{XMLName: name})
+			}
+		}
+	}
+
+	resp := davResponse{Href: href}
+	if len(found) > 0 {
+		resp.Propstats = append(resp.Propstats, davPropstat{Prop: davPropValues{found}, Status: davStatus(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		resp.Propstats = append(resp.Propstats, davPropstat{Prop: davPropValues{missing}, Status: davStatus(http.StatusNotFound)})
+	}
+	return resp
+}
+
+// notFoundResponse is the response for a resource that doesn't exist.
+func notFoundResponse(href string) davResponse {
+	return davResponse{Href: href, Status: davStatus(http.StatusNotFound)}
+}
+
+// syncToken returns the sync token of the current sync cursor.
+func (h *Handler) syncToken(ctx context.Context) (string, error) {
+	cursor, err := h.db.GetSyncCursor(ctx)
+	if err != nil {
+		return "", err
+	}
+	return syncTokenPrefix + strconv.FormatInt(cursor, 10), nil
+}
+
+// --- PROPFIND and PROPPATCH ---
+
+// handleDAVPropfind returns the properties of a resource and, with a Depth of 1
+// or infinity, of its children.
+func (h *Handler) handleDAVPropfind(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	path, ok := parseDAVPath(r.URL.Path)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	body, ok := readDAVBody(w, r, maxDAVRequestSize)
+	if !ok {
+		return
+	}
+	req := davPropRequest{all: true}
+	if len(bytes.TrimSpace(body)) > 0 {
+		var find davPropfind
+		if err := xml.Unmarshal(body, &find); err != nil {
+			http.Error(w, "invalid propfind body", http.StatusBadRequest)
+			return
+		}
+		req = newPropRequest(find.AllProp, find.PropName, find.Prop)
+	}
+	depth := r.Header.Get("Depth")
+	children := depth != "0"
+
+	ms := &davMultistatus{}
+	switch {
+	case path.principal:
+		user, err := h.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "failed to get user", http.StatusInternalServerError)
+			return
+		}
+		ms.Responses = append(ms.Responses, propResponse(davRoot, principalProps(user), req))
+	case path.home:
+		ms.Responses = append(ms.Responses, propResponse(davHome, homeProps(), req))
+		if children {
+			collections, err := h.davCollections(r.Context(), userID)
+			if err != nil {
+				http.Error(w, "failed to get collections", http.StatusInternalServerError)
+				return
+			}
+			token, err := h.syncToken(r.Context())
+			if err != nil {
+				http.Error(w, "failed to get sync token", http.StatusInternalServerError)
+				return
+			}
+			for _, coll := range collections {
+				ms.Responses = append(ms.Responses, propResponse(coll.href(), collectionProps(coll, token), req))
+			}
+		}
+	default:
+		// The token is read first so that changes made while the members load
+		// are picked up by the next sync.
+		token, err := h.syncToken(r.Context())
+		if err != nil {
+			http.Error(w, "failed to get sync token", http.StatusInternalServerError)
+			return
+		}
+		coll, _, members, ok := h.davState(w, r, path.collection)
+		if !ok {
+			return
+		}
+		if path.name != "" {
+			obj, ok := members.byName[path.name]
+			if !ok {
+				http.Error(w, "resource not found", http.StatusNotFound)
+				return
+			}
+			ms.Responses = append(ms.Responses, propResponse(coll.href()+url.PathEscape(obj.name), objectProps(obj), req))
+			break
+		}
+		ms.Responses = append(ms.Responses, propResponse(coll.href(), collectionProps(coll, token), req))
+		if children {
+			for _, obj := range members.objects {
+				ms.Responses = append(ms.Responses, propResponse(coll.href()+url.PathEscape(obj.name), objectProps(obj), req))
+			}
+		}
+	}
+
+	writeMultistatus(w, ms)
+}
+
+// handleDAVProppatch refuses to change properties. Clients set things such as
+// a calendar's color this way; lists are only renamed in the app.
+func (h *Handler) handleDAVProppatch(w http.ResponseWriter, r *http.Request) {
+	if _, ok := parseDAVPath(r.URL.Path); !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	body, ok := readDAVBody(w, r, maxDAVRequestSize)
+	if !ok {
+		return
+	}
+	var update davPropertyUpdate
+	if err := xml.Unmarshal(body, &update); err != nil {
+		http.Error(w, "invalid proppatch body", http.StatusBadRequest)
+		return
+	}
+
+	var props []davProperty
+	for _, group := range append(update.Set, update.Remove...) {
+		for _, name := range group.names() {
+			props = append(props, davProperty{XMLName: name})
+		}
+	}
+	resp := davResponse{Href: r.URL.Path}
+	if len(props) > 0 {
+		resp.Propstats = []davPropstat{{Prop: davPropValues{props}, Status: davStatus(http.StatusForbidden)}}
+	}
+	writeMultistatus(w, &davMultistatus{Responses: []davResponse{resp}})
+}
+
+// --- REPORT ---
+
+// handleDAVReport runs a calendar-multiget, calendar-query or sync-collection
+// report on a calendar collection.
+func (h *Handler) handleDAVReport(w http.ResponseWriter, r *http.Request) {
+	path, ok := parseDAVPath(r.URL.Path)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if path.collection == "" || path.name != "" {
+		writeDAVError(w, http.StatusForbidden, davName(nsDAV, "supported-report"))
+		return
+	}
+
+	body, ok := readDAVBody(w, r, maxDAVRequestSize)
+	if !ok {
+		return
+	}
+	root, err := davRootName(body)
+	if err != nil {
+		http.Error(w, "invalid report body", http.StatusBadRequest)
+		return
+	}
+
+	switch root {
+	case davName(nsCalDAV, "calendar-multiget"):
+		var report davMultiget
+		if err := xml.Unmarshal(body, &report); err != nil {
+			http.Error(w, "invalid report body", http.StatusBadRequest)
+			return
+		}
+		h.davMultiget(w, r, path.collection, &report)
+	case davName(nsCalDAV, "calendar-query"):
+		var report davQuery
+		if err := xml.Unmarshal(body, &report); err != nil {
+			http.Error(w, "invalid report body", http.StatusBadRequest)
+			return
+		}
+		h.davQuery(w, r, path.collection, &report)
+	case davName(nsDAV, "sync-collection"):
+		var report davSyncCollection
+		if err := xml.Unmarshal(body, &report); err != nil {
+			http.Error(w, "invalid report body", http.StatusBadRequest)
+			return
+		}
+		h.davSyncCollection(w, r, path.collection, &report)
+	default:
+		writeDAVError(w, http.StatusForbidden, davName(nsDAV, "supported-report"))
+	}
+}
+
+// davMultiget returns the resources at the given hrefs.
+func (h *Handler) davMultiget(w http.ResponseWriter, r *http.Request, segment string, report *davMultiget) {
+	coll, _, members, ok := h.davState(w, r, segment)
+	if !ok {
+		return
+	}
+	req := newPropRequest(report.AllProp, report.PropName, report.Prop)
+
+	ms := &davMultistatus{}
+	for _, href := range report.Hrefs {
+		href = strings.TrimSpace(href)
+		obj := members.lookupHref(coll, href)
+		if obj == nil {
+			ms.Responses = append(ms.Responses, notFoundResponse(href))
+			continue
+		}
+		ms.Responses = append(ms.Responses, propResponse(href, objectProps(obj), req))
+	}
+	writeMultistatus(w, ms)
+}
+
+// lookupHref finds the member an href, a path or a URL, names.
+func (m *davMembers) lookupHref(coll *davCollection, href string) *davObject {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil
+	}
+	name, ok := strings.CutPrefix(u.Path, coll.href())
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return nil
+	}
+	return m.byName[name]
+}
+
+// davQuery returns the resources matching a calendar-query's filter.
+func (h *Handler) davQuery(w http.ResponseWriter, r *http.Request, segment string, report *davQuery) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	coll, _, members, ok := h.davState(w, r, segment)
+	if !ok {
+		return
+	}
+	loc, err := h.userLocation(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to get user", http.StatusInternalServerError)
+		return
+	}
+	req := newPropRequest(report.AllProp, report.PropName, report.Prop)
+
+	ms := &davMultistatus{}
+	for _, obj := range members.objects {
+		if report.Filter != nil {
+			cal, err := ical.Parse(bytes.NewReader(obj.data))
+			if err != nil {
+				http.Error(w, "failed to render resource", http.StatusInternalServerError)
+				return
+			}
+			root := &ical.Component{Children: []*ical.Component{cal}}
+			match, err := matchCompFilter(root, &report.Filter.CompFilter, loc)
+			if errors.Is(err, errUnsupportedCollation) {
+				writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "supported-collation"))
+				return
+			}
+			if err != nil {
+				writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "valid-filter"))
+				return
+			}
+			if !match {
+				continue
+			}
+		}
+		ms.Responses = append(ms.Responses, propResponse(coll.href()+url.PathEscape(obj.name), objectProps(obj), req))
+	}
+	writeMultistatus(w, ms)
+}
+
+// davSyncCollection returns the members that changed since a sync token, and
+// reports the resources that left the collection as not found (RFC 6578).
+// Without a token every member is returned.
+func (h *Handler) davSyncCollection(w http.ResponseWriter, r *http.Request, segment string, report *davSyncCollection) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var cursor int64
+	if report.SyncToken != "" {
+		value, ok := strings.CutPrefix(report.SyncToken, syncTokenPrefix)
+		n, err := strconv.ParseInt(value, 10, 64)
+		if !ok || err != nil || n <= 0 {
+			writeDAVError(w, http.StatusForbidden, davName(nsDAV, "valid-sync-token"))
+			return
+		}
+		cursor = n
+	}
+
+	// Changes are read before the members, so that anything changed in between
+	// is seen again by the next sync rather than missed.
+	var changes *database.SyncChanges
+	var err error
+	if cursor > 0 {
+		changes, err = h.db.GetChanges(r.Context(), userID, cursor)
+		if err != nil {
+			http.Error(w, "failed to get changes", http.StatusInternalServerError)
+			return
+		}
+		if changes.Reset {
+			writeDAVError(w, http.StatusForbidden, davName(nsDAV, "valid-sync-token"))
+			return
+		}
+	} else {
+		cursor, err = h.db.GetSyncCursor(r.Context())
+		if err != nil {
+			http.Error(w, "failed to get sync token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	coll, names, members, ok := h.davState(w, r, segment)
+	if !ok {
+		return
+	}
+	req := newPropRequest(nil, nil, report.Prop)
+
+	ms := &davMultistatus{SyncToken: syncTokenPrefix + strconv.FormatInt(cursor, 10)}
+	if changes == nil {
+		for _, obj := range members.objects {
+			ms.Responses = append(ms.Responses, propResponse(coll.href()+url.PathEscape(obj.name), objectProps(obj), req))
+		}
+		writeMultistatus(w, ms)
+		return
+	}
+	ms.SyncToken = syncTokenPrefix + strconv.FormatInt(changes.Cursor, 10)
+
+	// The names of every resource that changed, in or out of this collection
+	var changed []string
+	addTask := func(task *database.Task) {
+		name, _ := names.task(task.ID)
+		changed = append(changed, name)
+		for _, subtask := range task.Subtasks {
+			name, _ := names.subtask(subtask.ID)
+			changed = append(changed, name)
+		}
+	}
+	for _, task := range changes.Tasks {
+		addTask(task)
+	}
+	for _, subtask := range changes.Subtasks {
+		name, _ := names.subtask(subtask.ID)
+		changed = append(changed, name)
+	}
+	if len(changes.Deleted.Tasks) > 0 {
+		// Subtasks go to the trash with their task without changing themselves
+		trash, err := h.db.GetTrash(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "failed to get changes", http.StatusInternalServerError)
+			return
+		}
+		for _, task := range trash.Tasks {
+			if slices.Contains(changes.Deleted.Tasks, task.ID) {
+				addTask(task)
+			}
+		}
+		for _, id := range changes.Deleted.Tasks {
+			name, _ := names.task(id)
+			changed = append(changed, name)
+		}
+	}
+	for _, id := range changes.Deleted.Subtasks {
+		name, _ := names.subtask(id)
+		changed = append(changed, name)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range changed {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		href := coll.href() + url.PathEscape(name)
+		if obj, ok := members.byName[name]; ok {
+			ms.Responses = append(ms.Responses, propResponse(href, objectProps(obj), req))
+		} else {
+			ms.Responses = append(ms.Responses, notFoundResponse(href))
+		}
+	}
+	writeMultistatus(w, ms)
+}
+
+// --- Calendar object resources ---
+
+// davObjectFor resolves a request for a calendar object resource, writing an
+// error response if it can't.
+func (h *Handler) davObjectFor(w http.ResponseWriter, r *http.Request) (*davCollection, *davMembers, davPath, bool) {
+	path, ok := parseDAVPath(r.URL.Path)
+	if !ok || path.collection == "" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, nil, path, false
+	}
+	if path.name == "" {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, PROPPATCH, REPORT")
+		http.Error(w, "method not allowed on a collection", http.StatusMethodNotAllowed)
+		return nil, nil, path, false
+	}
+	coll, _, members, ok := h.davState(w, r, path.collection)
+	return coll, members, path, ok
+}
+
+// handleDAVGet returns a task or subtask as an iCalendar file.
+func (h *Handler) handleDAVGet(w http.ResponseWriter, r *http.Request) {
+	_, members, path, ok := h.davObjectFor(w, r)
+	if !ok {
+		return
+	}
+	obj, ok := members.byName[path.name]
+	if !ok {
+		http.Error(w, "resource not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", calendarContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+	w.Header().Set("ETag", obj.etag)
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(obj.data)
+	}
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists etag
+// or "*". If-Match uses the strong comparison, so a weak tag never matches it;
+// If-None-Match uses the weak one (RFC 9110, section 8.8.3.2).
+func etagMatches(header, etag string, weak bool) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || value == etag || weak && strings.TrimPrefix(value, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreconditions applies a request's If-Match and If-None-Match headers to
+// a resource, which may not exist, writing a 412 if they fail.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, obj *davObject) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if obj == nil || !etagMatches(ifMatch, obj.etag, false) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && obj != nil {
+		if etagMatches(ifNoneMatch, obj.etag, true) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	return true
+}
+
+// handleDAVDelete moves a task or subtask to the trash.
+func (h *Handler) handleDAVDelete(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	_, members, path, ok := h.davObjectFor(w, r)
+	if !ok {
+		return
+	}
+	obj, ok := members.byName[path.name]
+	if !ok {
+		http.Error(w, "resource not found", http.StatusNotFound)
+		return
+	}
+	if !checkPreconditions(w, r, obj) {
+		return
+	}
+
+	var resp *capturedResponse
+	var err error
+	if obj.subtask != nil {
+		resp, err = h.runAction(r.Context(), userID, wsActions["subtask.delete"], obj.subtask.ID, 0, nil)
+	} else {
+		resp, err = h.runAction(r.Context(), userID, wsActions["task.delete"], obj.task.ID, 0, nil)
+	}
+	if !davActionSucceeded(w, resp, err) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDAVPut creates or updates a task or subtask from an iCalendar file with
+// a single VTODO. A new VTODO whose RELATED-TO parent is a task in the same
+// collection becomes a subtask of it. Changes are applied only to the fields
+// that differ from what the server would return, so anything a client can't
+// represent is left alone.
+func (h *Handler) handleDAVPut(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	coll, members, path, ok := h.davObjectFor(w, r)
+	if !ok {
+		return
+	}
+	existing := members.byName[path.name]
+	if !checkPreconditions(w, r, existing) {
+		return
+	}
+
+	body, ok := readDAVBody(w, r, maxCalendarObjectSize)
+	if !ok {
+		return
+	}
+	cal, err := ical.Parse(bytes.NewReader(body))
+	if err != nil || cal.Name != "VCALENDAR" {
+		writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "valid-calendar-data"))
+		return
+	}
+	todo, ok := singleTodo(w, cal)
+	if !ok {
+		return
+	}
+
+	loc, err := h.userLocation(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to get user", http.StatusInternalServerError)
+		return
+	}
+	fields, err := readTodo(todo, loc)
+	if err != nil {
+		writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "valid-calendar-object-resource"))
+		return
+	}
+
+	if existing != nil {
+		if fields.uid != existing.uid {
+			writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "no-uid-conflict"))
+			return
+		}
+		h.davUpdate(w, r, existing, fields, loc)
+		return
+	}
+
+	if _, ok := members.byUID[fields.uid]; ok {
+		writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "no-uid-conflict"))
+		return
+	}
+	h.davCreate(w, r, coll, members, path.name, fields)
+}
+
+// singleTodo returns the VTODO of a calendar object, writing a 403 if it holds
+// another kind of component or more than one task. Overridden instances of a
+// recurring VTODO, which carry a RECURRENCE-ID, are ignored.
+func singleTodo(w http.ResponseWriter, cal *ical.Component) (*ical.Component, bool) {
+	var todo *ical.Component
+	for _, c := range cal.Children {
+		switch c.Name {
+		case "VTIMEZONE":
+		case "VTODO":
+			if c.Prop("RECURRENCE-ID") != nil {
+				continue
+			}
+			if todo != nil {
+				writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "valid-calendar-object-resource"))
+				return nil, false
+			}
+			todo = c
+		default:
+			writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "supported-calendar-component"))
+			return nil, false
+		}
+	}
+	if todo == nil {
+		writeDAVError(w, http.StatusForbidden, davName(nsCalDAV, "supported-calendar-component"))
+		return nil, false
+	}
+	return todo, true
+}
+
+// davCreate creates the task or subtask for a new resource and records the
+// client's name and UID for it, together so a failure leaves neither behind.
+func (h *Handler) davCreate(w http.ResponseWriter, r *http.Request, coll *davCollection, members *davMembers, name string, fields *todoFields) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	if fields.text == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+
+	obj := &database.CalDAVObject{UserID: userID, UID: fields.uid, Name: name}
+	if parent, ok := members.byUID[fields.parentUID]; ok && fields.parentUID != "" && parent.subtask == nil {
+		subtask, err := h.db.CreateCalDAVSubtask(r.Context(), obj, parent.task.ID, fields.text, fields.completed)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				http.Error(w, "task not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to create subtask", http.StatusInternalServerError)
+			return
+		}
+
+		h.hub.BroadcastToUser(userID, WebSocketEvent{
+			Type:    "subtask_created",
+			Payload: subtask,
+		})
+	} else {
+		rule, err := normalizeRecurrence(fields.recurrence, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		task, err := h.db.CreateCalDAVTask(r.Context(), obj, &database.Task{
+			ListID:     coll.listID(),
+			Text:       fields.text,
+			Tags:       fields.tags,
+			Important:  fields.important,
+			Completed:  fields.completed,
+			DueAt:      fields.dueAt,
+			StartAt:    fields.startAt,
+			Recurrence: rule,
+		})
+		if err != nil {
+			http.Error(w, "failed to create task", http.StatusInternalServerError)
+			return
+		}
+
+		h.hub.BroadcastToUser(userID, WebSocketEvent{
+			Type:    "task_created",
+			Payload: task,
+		})
+	}
+
+	// No ETag: the stored resource is not byte-for-byte what the client sent
+	w.WriteHeader(http.StatusCreated)
+}
+
+// davUpdate applies the fields of a resource that changed to its task or
+// subtask. The write only applies to the version that was compared, so a
+// concurrent change makes it fail with 412 like a stale ETag.
+func (h *Handler) davUpdate(w http.ResponseWriter, r *http.Request, obj *davObject, fields *todoFields, loc *time.Location) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	cal, err := ical.Parse(bytes.NewReader(obj.data))
+	if err != nil {
+		http.Error(w, "failed to render resource", http.StatusInternalServerError)
+		return
+	}
+	current, err := readTodo(cal.Children[0], loc)
+	if err != nil {
+		http.Error(w, "failed to render resource", http.StatusInternalServerError)
+		return
+	}
+
+	var resp *capturedResponse
+	if obj.subtask != nil {
+		updates := make(map[string]interface{})
+		if fields.text != current.text {
+			updates["text"] = fields.text
+		}
+		if fields.completed != current.completed {
+			updates["completed"] = fields.completed
+		}
+		if len(updates) > 0 {
+			data, _ := json.Marshal(updates)
+			resp, err = h.runAction(r.Context(), userID, wsActions["subtask.update"], obj.subtask.ID, obj.subtask.Version, data)
+		}
+	} else {
+		updates := taskUpdates(current, fields)
+		if len(updates) > 0 {
+			data, _ := json.Marshal(updates)
+			resp, err = h.runAction(r.Context(), userID, wsActions["task.update"], obj.task.ID, obj.task.Version, data)
+		}
+	}
+	if resp != nil || err != nil {
+		if !davActionSucceeded(w, resp, err) {
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// davActionSucceeded reports whether a REST handler run for a CalDAV request
+// succeeded, and writes an error response if it didn't. A version conflict is
+// reported as a failed precondition.
+func davActionSucceeded(w http.ResponseWriter, resp *capturedResponse, err error) bool {
+	if err != nil {
+		http.Error(w, "failed to handle request", http.StatusInternalServerError)
+		return false
+	}
+	if resp.status < 300 {
+		return true
+	}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(resp.body.Bytes(), &body)
+	status := resp.status
+	if status == http.StatusConflict {
+		status = http.StatusPreconditionFailed
+	}
+	if body.Error == "" {
+		body.Error = http.StatusText(status)
+	}
+	http.Error(w, body.Error, status)
+	return false
+}
+
+// --- VTODO fields ---
+
+// todoFields are the parts of a VTODO that map onto a task or subtask.
+type todoFields struct {
+	uid        string
+	text       string
+	completed  bool
+	important  bool
+	tags       []string
+	dueAt      *database.TaskDate
+	startAt    *database.TaskDate
+	recurrence string
+	parentUID  string
+}
+
+// readTodo reads the fields of a VTODO, undoing what writeTaskTodo does: a
+// recurring task without a DUE is due at its DTSTART, and a DTSTART that
+// doesn't fit DUE is left out. PRIORITY 1 to 4, the high priorities, marks a
+// task important. Floating times are in loc.
+func readTodo(todo *ical.Component, loc *time.Location) (*todoFields, error) {
+	fields := &todoFields{tags: []string{}}
+
+	if p := todo.Prop("UID"); p != nil {
+		fields.uid = ical.ParseText(p.Value)
+	}
+	if fields.uid == "" {
+		return nil, errors.New("missing UID")
+	}
+	if p := todo.Prop("SUMMARY"); p != nil {
+		fields.text = strings.TrimSpace(ical.ParseText(p.Value))
+	}
+
+	if p := todo.Prop("STATUS"); p != nil {
+		fields.completed = strings.EqualFold(p.Value, "COMPLETED")
+	} else {
+		fields.completed = todo.Prop("COMPLETED") != nil
+	}
+	if p := todo.Prop("PRIORITY"); p != nil {
+		priority, err := strconv.Atoi(strings.TrimSpace(p.Value))
+		fields.important = err == nil && priority >= 1 && priority <= 4
+	}
+
+	for _, p := range todo.All("CATEGORIES") {
+		for _, tag := range ical.ParseTextList(p.Value) {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !slices.Contains(fields.tags, tag) {
+				fields.tags = append(fields.tags, tag)
+			}
+		}
+	}
+
+	for _, field := range []struct {
+		name string
+		date **database.TaskDate
+	}{{"DUE", &fields.dueAt}, {"DTSTART", &fields.startAt}} {
+		if p := todo.Prop(field.name); p != nil {
+			t, allDay, err := ical.ParseDate(p, loc)
+			if err != nil {
+				return nil, err
+			}
+			*field.date = &database.TaskDate{Time: t, AllDay: allDay}
+		}
+	}
+	if p := todo.Prop("RRULE"); p != nil {
+		fields.recurrence = p.Value
+		if fields.dueAt == nil {
+			fields.dueAt, fields.startAt = fields.startAt, nil
+		}
+	}
+	start, due := fields.startAt, fields.dueAt
+	if start != nil && due != nil && (start.AllDay != due.AllDay || !start.Time.Before(due.Time)) {
+		fields.startAt = nil
+	}
+
+	for _, p := range todo.All("RELATED-TO") {
+		if reltype := p.Param("RELTYPE"); reltype == "" || strings.EqualFold(reltype, "PARENT") {
+			fields.parentUID = ical.ParseText(p.Value)
+			break
+		}
+	}
+	return fields, nil
+}
+
+// taskUpdates returns the REST updates that change a task's current fields to
+// the new ones.
+func taskUpdates(current, fields *todoFields) map[string]interface{} {
+	updates := make(map[string]interface{})
+	if fields.text != current.text {
+		updates["text"] = fields.text
+	}
+	if fields.completed != current.completed {
+		updates["completed"] = fields.completed
+	}
+	if fields.important != current.important {
+		updates["important"] = fields.important
+	}
+
+	oldTags, newTags := slices.Clone(current.tags), slices.Clone(fields.tags)
+	slices.Sort(oldTags)
+	slices.Sort(newTags)
+	if !slices.Equal(oldTags, newTags) {
+		updates["tags"] = fields.tags
+	}
+
+	for _, field := range []struct {
+		key           string
+		current, next *database.TaskDate
+	}{{"dueAt", current.dueAt, fields.dueAt}, {"startAt", current.startAt, fields.startAt}} {
+		if sameTaskDate(field.current, field.next) {
+			continue
+		}
+		if field.next == nil {
+			updates[field.key] = nil
+		} else {
+			updates[field.key] = field.next.String()
+		}
+	}
+
+	if fields.recurrence != current.recurrence {
+		updates["recurrence"] = fields.recurrence
+	}
+	return updates
+}
+
+func sameTaskDate(a, b *database.TaskDate) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.AllDay == b.AllDay && a.Time.Equal(b.Time)
+}
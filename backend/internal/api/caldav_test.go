@@ -0,0 +1,406 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// davClient signs in to CalDAV as the test user with an app password.
+type davClient struct {
+	th       *testHandler
+	password string
+}
+
+// davClient creates an app password for the test user and returns a client
+// that signs in with it.
+func (th *testHandler) davClient() *davClient {
+	th.t.Helper()
+	var created CreateAppPasswordResponse
+	th.call("POST", "/api/app-passwords", map[string]string{"name": "Tasks app"}, http.StatusCreated, &created)
+	return &davClient{th: th, password: created.Password}
+}
+
+// do sends a CalDAV request with a body and headers given as name, value pairs.
+func (c *davClient) do(method, path, body string, header ...string) *httptest.ResponseRecorder {
+	c.th.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.SetBasicAuth(c.th.user.Email, c.password)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	c.th.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// want sends a CalDAV request like do, failing the test unless it returns status.
+func (c *davClient) want(status int, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	c.th.t.Helper()
+	rec := c.do(method, path, body, header...)
+	if rec.Code != status {
+		c.th.t.Fatalf("%s %s = %d %s, want %d", method, path, rec.Code, rec.Body, status)
+	}
+	return rec
+}
+
+// testMultistatus is a 207 Multi-Status body as a client reads it.
+type testMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Status    string `xml:"DAV: status"`
+		Propstats []struct {
+			Prop struct {
+				Inner string `xml:",innerxml"`
+			} `xml:"DAV: prop"`
+			Status string `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+	SyncToken string `xml:"DAV: sync-token"`
+}
+
+// multistatus sends a PROPFIND or REPORT and returns its responses by href, as
+// the status of the resource or of each propstat followed by its properties.
+func (c *davClient) multistatus(method, path, body string, header ...string) (map[string]string, string) {
+	c.th.t.Helper()
+	rec := c.want(http.StatusMultiStatus, method, path, body, header...)
+	var ms testMultistatus
+	if err := xml.Unmarshal(rec.Body.Bytes(), &ms); err != nil {
+		c.th.t.Fatalf("%s %s: failed to decode multistatus: %v\n%s", method, path, err, rec.Body)
+	}
+	responses := make(map[string]string)
+	for _, resp := range ms.Responses {
+		if _, ok := responses[resp.Href]; ok {
+			c.th.t.Fatalf("%s %s returned %s twice", method, path, resp.Href)
+		}
+		text := resp.Status
+		for _, ps := range resp.Propstats {
+			text += ps.Status + " " + ps.Prop.Inner + "\n"
+		}
+		responses[resp.Href] = text
+	}
+	return responses, ms.SyncToken
+}
+
+// wantHrefs fails the test unless responses are for exactly hrefs.
+func wantHrefs(t *testing.T, what string, responses map[string]string, hrefs ...string) {
+	t.Helper()
+	var got []string
+	for href := range responses {
+		got = append(got, href)
+	}
+	slices.Sort(got)
+	slices.Sort(hrefs)
+	if !slices.Equal(got, hrefs) {
+		t.Fatalf("%s returned %v, want %v", what, got, hrefs)
+	}
+}
+
+// vtodo returns a calendar object holding one VTODO with the given properties.
+func vtodo(props ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Test//EN\r\nBEGIN:VTODO\r\n" +
+		strings.Join(props, "\r\n") + "\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+}
+
+func TestCalDAVDiscovery(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	var list database.List
+	th.call("POST", "/api/lists", map[string]string{"title": "Work & Play"}, http.StatusCreated, &list)
+	dav := th.davClient()
+	listHref := fmt.Sprintf("/dav/calendars/%d/", list.ID)
+
+	rec := th.do("GET", "/.well-known/caldav", nil)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/dav/" {
+		t.Fatalf("GET /.well-known/caldav = %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = dav.want(http.StatusOK, "OPTIONS", "/dav/", "")
+	if dav := rec.Header().Get("DAV"); !strings.Contains(dav, "calendar-access") {
+		t.Fatalf("OPTIONS /dav/ returned DAV %q", dav)
+	}
+
+	// Apps sign in with an app password, not the real one or a token
+	for _, rec := range []*httptest.ResponseRecorder{
+		th.do("PROPFIND", "/dav/", nil),
+		(&davClient{th: th, password: "hash"}).do("PROPFIND", "/dav/", ""),
+	} {
+		if rec.Code != http.StatusUnauthorized || !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Basic ") {
+			t.Fatalf("PROPFIND without an app password = %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// The principal points at the calendar home
+	responses, _ := dav.multistatus("PROPFIND", "/dav/", `<?xml version="1.0"?>
+		<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+			<prop><current-user-principal/><C:calendar-home-set/><displayname/><getetag/></prop>
+		</propfind>`, "Depth", "0")
+	wantHrefs(t, "PROPFIND /dav/", responses, "/dav/")
+	principal := responses["/dav/"]
+	if !strings.Contains(principal, `<calendar-home-set xmlns="urn:ietf:params:xml:ns:caldav"><href xmlns="DAV:">/dav/calendars/</href>`) ||
+		!strings.Contains(principal, ">Ann</displayname>") ||
+		!strings.Contains(principal, "HTTP/1.1 404 Not Found <getetag") {
+		t.Fatalf("PROPFIND /dav/ = %s", principal)
+	}
+
+	// The home holds the inbox and a calendar per list
+	responses, _ = dav.multistatus("PROPFIND", "/dav/calendars/", `<propfind xmlns="DAV:"><prop><resourcetype/><displayname/></prop></propfind>`, "Depth", "1")
+	wantHrefs(t, "PROPFIND /dav/calendars/", responses, "/dav/calendars/", "/dav/calendars/inbox/", listHref)
+	if coll := responses[listHref]; !strings.Contains(coll, `<calendar xmlns="urn:ietf:params:xml:ns:caldav"/>`) ||
+		!strings.Contains(coll, ">Work &amp; Play</displayname>") {
+		t.Fatalf("PROPFIND of a list's calendar = %s", coll)
+	}
+
+	// A calendar lists its tasks and subtasks; allprop leaves calendar-data out
+	var task database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "report", "listId": list.ID}, http.StatusCreated, &task)
+	var subtask database.Subtask
+	th.call("POST", fmt.Sprintf("/api/tasks/%d/subtasks", task.ID), map[string]string{"text": "draft"}, http.StatusCreated, &subtask)
+	taskHref, subtaskHref := fmt.Sprintf("%stask-%d.ics", listHref, task.ID), fmt.Sprintf("%ssubtask-%d.ics", listHref, subtask.ID)
+	responses, _ = dav.multistatus("PROPFIND", listHref, "", "Depth", "1")
+	wantHrefs(t, "PROPFIND of a list's calendar", responses, listHref, taskHref, subtaskHref)
+	if obj := responses[taskHref]; !strings.Contains(obj, `<getetag xmlns="DAV:">&#34;`) || strings.Contains(obj, "calendar-data") {
+		t.Fatalf("PROPFIND allprop of a task = %s", obj)
+	}
+	responses, _ = dav.multistatus("PROPFIND", listHref, "", "Depth", "0")
+	wantHrefs(t, "PROPFIND of a calendar at depth 0", responses, listHref)
+
+	dav.want(http.StatusNotFound, "PROPFIND", "/dav/calendars/999/", "")
+	dav.want(http.StatusNotFound, "PROPFIND", "/dav/calendars/inbox/missing.ics", "")
+	dav.want(http.StatusBadRequest, "PROPFIND", "/dav/", "<propfind")
+}
+
+func TestCalDAVReports(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	dav := th.davClient()
+
+	var report, groceries, old database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "Q3 report", "dueAt": "2024-03-08"}, http.StatusCreated, &report)
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "groceries"}, http.StatusCreated, &groceries)
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "old"}, http.StatusCreated, &old)
+	href := func(task database.Task) string {
+		return fmt.Sprintf("/dav/calendars/inbox/task-%d.ics", task.ID)
+	}
+
+	// calendar-query filters by time range and text
+	query := func(filter string) map[string]string {
+		t.Helper()
+		responses, _ := dav.multistatus("REPORT", "/dav/calendars/inbox/", `<?xml version="1.0"?>
+			<C:calendar-query xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+				<prop><getetag/><C:calendar-data/></prop>
+				<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO">`+filter+`</C:comp-filter></C:comp-filter></C:filter>
+			</C:calendar-query>`, "Depth", "1")
+		return responses
+	}
+	responses := query(`<C:time-range start="20240307T000000Z" end="20240310T000000Z"/>`)
+	wantHrefs(t, "calendar-query by time range", responses, href(report))
+	if obj := responses[href(report)]; !strings.Contains(obj, "SUMMARY:Q3 report") || !strings.Contains(obj, "DUE;VALUE=DATE:20240308") {
+		t.Fatalf("calendar-query returned %s", obj)
+	}
+	wantHrefs(t, "calendar-query by text", query(`<C:prop-filter name="SUMMARY"><C:text-match>GROCER</C:text-match></C:prop-filter>`), href(groceries))
+	wantHrefs(t, "calendar-query for no due date", query(`<C:prop-filter name="DUE"><C:is-not-defined/></C:prop-filter>`), href(groceries), href(old))
+	wantHrefs(t, "calendar-query without a filter", query(""), href(report), href(groceries), href(old))
+	rec := dav.want(http.StatusForbidden, "REPORT", "/dav/calendars/inbox/", `<C:calendar-query xmlns:C="urn:ietf:params:xml:ns:caldav">
+		<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO"><C:prop-filter name="SUMMARY">
+			<C:text-match collation="i;unicode-casemap">q3</C:text-match>
+		</C:prop-filter></C:comp-filter></C:comp-filter></C:filter></C:calendar-query>`)
+	if !strings.Contains(rec.Body.String(), "<supported-collation") {
+		t.Fatalf("calendar-query with an unknown collation = %s", rec.Body)
+	}
+
+	// sync-collection without a token returns every member and a token to
+	// sync from
+	sync := func(token string) (map[string]string, string) {
+		t.Helper()
+		return dav.multistatus("REPORT", "/dav/calendars/inbox/", `<sync-collection xmlns="DAV:">
+			<sync-token>`+token+`</sync-token><sync-level>1</sync-level><prop><getetag/></prop>
+		</sync-collection>`)
+	}
+	responses, token := sync("")
+	wantHrefs(t, "initial sync-collection", responses, href(report), href(groceries), href(old))
+	if !strings.HasPrefix(token, syncTokenPrefix) {
+		t.Fatalf("sync-collection returned token %q", token)
+	}
+
+	// Then only what changed since, with what left the calendar as not found
+	th.call("PUT", fmt.Sprintf("/api/tasks/%d", groceries.ID), map[string]interface{}{"completed": true}, http.StatusOK, nil)
+	th.call("DELETE", fmt.Sprintf("/api/tasks/%d", old.ID), nil, http.StatusOK, nil)
+	responses, next := sync(token)
+	wantHrefs(t, "sync-collection", responses, href(groceries), href(old))
+	if !strings.HasPrefix(responses[href(groceries)], "HTTP/1.1 200 OK <getetag") || responses[href(old)] != "HTTP/1.1 404 Not Found" {
+		t.Fatalf("sync-collection returned %v", responses)
+	}
+	if next == token {
+		t.Fatalf("sync-collection returned the token it was given, %s", token)
+	}
+	responses, _ = sync(next)
+	wantHrefs(t, "sync-collection with no changes", responses)
+
+	for _, bad := range []string{"urn:todomaster:sync:0", "urn:other:sync:5", "junk"} {
+		rec := dav.want(http.StatusForbidden, "REPORT", "/dav/calendars/inbox/",
+			`<sync-collection xmlns="DAV:"><sync-token>`+bad+`</sync-token><sync-level>1</sync-level></sync-collection>`)
+		if !strings.Contains(rec.Body.String(), "<valid-sync-token") {
+			t.Fatalf("sync-collection with token %q = %s", bad, rec.Body)
+		}
+	}
+
+	// calendar-multiget returns the resources asked for
+	responses, _ = dav.multistatus("REPORT", "/dav/calendars/inbox/", `<C:calendar-multiget xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+		<prop><getetag/></prop><href>`+href(report)+`</href><href>/dav/calendars/inbox/missing.ics</href>
+	</C:calendar-multiget>`)
+	if !strings.HasPrefix(responses[href(report)], "HTTP/1.1 200 OK") || responses["/dav/calendars/inbox/missing.ics"] != "HTTP/1.1 404 Not Found" {
+		t.Fatalf("calendar-multiget returned %v", responses)
+	}
+
+	dav.want(http.StatusForbidden, "REPORT", "/dav/calendars/inbox/", `<expand-property xmlns="DAV:"/>`)
+	dav.want(http.StatusForbidden, "REPORT", "/dav/calendars/", `<sync-collection xmlns="DAV:"/>`)
+	dav.want(http.StatusBadRequest, "REPORT", "/dav/calendars/inbox/", "not xml")
+}
+
+func TestCalDAVPut(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	var list database.List
+	th.call("POST", "/api/lists", map[string]string{"title": "Work"}, http.StatusCreated, &list)
+	dav := th.davClient()
+	coll := fmt.Sprintf("/dav/calendars/%d/", list.ID)
+	href := coll + "abc.ics"
+
+	// A new resource creates a task in the calendar's list
+	created := vtodo("UID:abc@client", "SUMMARY:Q3 report", "PRIORITY:1", "CATEGORIES:work,q3", "DUE;VALUE=DATE:20240308")
+	dav.want(http.StatusCreated, "PUT", href, created, "If-None-Match", "*")
+	dav.want(http.StatusPreconditionFailed, "PUT", href, created, "If-None-Match", "*")
+	var tasks []*database.Task
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks) != 1 || tasks[0].Text != "Q3 report" || !tasks[0].Important || tasks[0].ListID == nil || *tasks[0].ListID != list.ID ||
+		!slices.Equal(tasks[0].Tags, []string{"work", "q3"}) || tasks[0].DueAt == nil || tasks[0].DueAt.String() != "2024-03-08" {
+		t.Fatalf("tasks after PUT of a new resource = %+v", tasks)
+	}
+	task := tasks[0]
+
+	// It keeps the client's name and UID
+	rec := dav.want(http.StatusOK, "GET", href, "")
+	etag := rec.Header().Get("ETag")
+	if !strings.Contains(rec.Body.String(), "UID:abc@client\r\n") || etag == "" {
+		t.Fatalf("GET %s = %s %s", href, etag, rec.Body)
+	}
+
+	// An update applies the fields that changed if the ETag still matches
+	updated := vtodo("UID:abc@client", "SUMMARY:Q3 report", "STATUS:COMPLETED", "CATEGORIES:q3,work", "DUE;VALUE=DATE:20240308")
+	dav.want(http.StatusPreconditionFailed, "PUT", href, updated, "If-Match", `"stale"`)
+	dav.want(http.StatusPreconditionFailed, "PUT", href, updated, "If-Match", "W/"+etag)
+	dav.want(http.StatusNoContent, "PUT", href, updated, "If-Match", etag)
+	var got database.Task
+	th.call("GET", fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusOK, &got)
+	if !got.Completed || got.Important || got.Text != "Q3 report" || !slices.Equal(got.Tags, []string{"work", "q3"}) {
+		t.Fatalf("task after PUT = %+v", got)
+	}
+	dav.want(http.StatusPreconditionFailed, "PUT", href, updated, "If-Match", etag)
+	etag = dav.want(http.StatusOK, "GET", href, "").Header().Get("ETag")
+	dav.want(http.StatusPreconditionFailed, "PUT", href, updated, "If-None-Match", "W/"+etag)
+
+	// A VTODO related to a task in the calendar creates a subtask of it
+	subtaskHref := coll + "def.ics"
+	dav.want(http.StatusCreated, "PUT", subtaskHref, vtodo("UID:def@client", "SUMMARY:Draft", "RELATED-TO:abc@client"), "If-None-Match", "*")
+	th.call("GET", fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusOK, &got)
+	if len(got.Subtasks) != 1 || got.Subtasks[0].Text != "Draft" {
+		t.Fatalf("task after PUT of a related VTODO = %+v", got)
+	}
+	if body := dav.want(http.StatusOK, "GET", subtaskHref, "").Body.String(); !strings.Contains(body, "RELATED-TO;RELTYPE=PARENT:abc@client") {
+		t.Fatalf("GET of a subtask = %s", body)
+	}
+	dav.want(http.StatusNoContent, "PUT", subtaskHref, vtodo("UID:def@client", "SUMMARY:Draft", "STATUS:COMPLETED", "RELATED-TO:abc@client"))
+	th.call("GET", fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusOK, &got)
+	if len(got.Subtasks) != 1 || !got.Subtasks[0].Completed {
+		t.Fatalf("task after completing its subtask over CalDAV = %+v", got)
+	}
+
+	// A parent that isn't a task in the calendar makes a task instead
+	dav.want(http.StatusCreated, "PUT", coll+"ghi.ics", vtodo("UID:ghi@client", "SUMMARY:Orphan", "RELATED-TO:missing@client"))
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks) != 2 || tasks[1].Text != "Orphan" {
+		t.Fatalf("tasks after PUT with an unknown parent = %+v", tasks)
+	}
+
+	for _, tt := range []struct {
+		name, path, body, condition string
+	}{
+		{"a VEVENT", coll + "event.ics", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:e\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", "supported-calendar-component"},
+		{"two VTODOs", coll + "two.ics", strings.Replace(vtodo("UID:x"), "END:VCALENDAR", "BEGIN:VTODO\r\nUID:y\r\nEND:VTODO\r\nEND:VCALENDAR", 1), "valid-calendar-object-resource"},
+		{"a UID in use", coll + "copy.ics", vtodo("UID:abc@client", "SUMMARY:Copy"), "no-uid-conflict"},
+		{"a different UID", href, vtodo("UID:other@client", "SUMMARY:Q3 report"), "no-uid-conflict"},
+		{"no UID", coll + "none.ics", vtodo("SUMMARY:No UID"), "valid-calendar-object-resource"},
+		{"not iCalendar", coll + "bad.ics", "hello", "valid-calendar-data"},
+	} {
+		rec := dav.do("PUT", tt.path, tt.body)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "<"+tt.condition) {
+			t.Errorf("PUT of %s = %d %s, want 403 %s", tt.name, rec.Code, rec.Body, tt.condition)
+		}
+	}
+	dav.want(http.StatusMethodNotAllowed, "PUT", coll, created)
+	dav.want(http.StatusNotFound, "PUT", "/dav/calendars/999/abc.ics", created)
+}
+
+func TestCalDAVDelete(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	dav := th.davClient()
+
+	var task database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "report"}, http.StatusCreated, &task)
+	var subtask database.Subtask
+	th.call("POST", fmt.Sprintf("/api/tasks/%d/subtasks", task.ID), map[string]string{"text": "draft"}, http.StatusCreated, &subtask)
+	taskHref := fmt.Sprintf("/dav/calendars/inbox/task-%d.ics", task.ID)
+	subtaskHref := fmt.Sprintf("/dav/calendars/inbox/subtask-%d.ics", subtask.ID)
+
+	etag := dav.want(http.StatusOK, "GET", subtaskHref, "").Header().Get("ETag")
+	dav.want(http.StatusPreconditionFailed, "DELETE", subtaskHref, "", "If-Match", `"stale"`)
+	dav.want(http.StatusNoContent, "DELETE", subtaskHref, "", "If-Match", etag)
+	dav.want(http.StatusNotFound, "GET", subtaskHref, "")
+	var got database.Task
+	th.call("GET", fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusOK, &got)
+	if len(got.Subtasks) != 0 {
+		t.Fatalf("task after deleting its subtask = %+v", got)
+	}
+
+	// A deleted task goes to the trash
+	dav.want(http.StatusNoContent, "DELETE", taskHref, "")
+	dav.want(http.StatusNotFound, "DELETE", taskHref, "")
+	th.call("GET", fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusNotFound, nil)
+	var trash database.Trash
+	th.call("GET", "/api/trash", nil, http.StatusOK, &trash)
+	if len(trash.Tasks) != 1 || trash.Tasks[0].ID != task.ID {
+		t.Fatalf("trash after DELETE = %+v", trash)
+	}
+	dav.want(http.StatusMethodNotAllowed, "DELETE", "/dav/calendars/inbox/", "")
+}
+
+func TestDAVActionSucceeded(t *testing.T) {
+	for _, tt := range []struct {
+		status int
+		body   string
+		want   int
+		error  string
+	}{
+		{http.StatusOK, `{}`, 0, ""},
+		{http.StatusConflict, `{"error":"version conflict"}`, http.StatusPreconditionFailed, "version conflict"},
+		{http.StatusBadRequest, `{"error":"text is required"}`, http.StatusBadRequest, "text is required"},
+		{http.StatusNotFound, ``, http.StatusNotFound, "Not Found"},
+	} {
+		resp := &capturedResponse{header: make(http.Header), status: tt.status}
+		resp.body.WriteString(tt.body)
+		rec := httptest.NewRecorder()
+		ok := davActionSucceeded(rec, resp, nil)
+		if ok != (tt.want == 0) || !ok && (rec.Code != tt.want || strings.TrimSpace(rec.Body.String()) != tt.error) {
+			t.Errorf("davActionSucceeded(%d %s) = %t, %d %q", tt.status, tt.body, ok, rec.Code, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	if davActionSucceeded(rec, nil, fmt.Errorf("boom")) || rec.Code != http.StatusInternalServerError {
+		t.Errorf("davActionSucceeded with an error = %d", rec.Code)
+	}
+}
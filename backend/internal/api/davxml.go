@@ -0,0 +1,431 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/ical"
+)
+
+// XML namespaces of WebDAV, CalDAV and the calendarserver.org extensions.
+const (
+	nsDAV            = "DAV:"
+	nsCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	nsCalendarServer = "http://calendarserver.org/ns/"
+)
+
+// davName is the name of a WebDAV property or element.
+func davName(space, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+// --- Request bodies ---
+
+// davPropNames is the content of a DAV:prop element in a request: the names of
+// the properties asked for.
+type davPropNames struct {
+	Names []davAny `xml:",any"`
+}
+
+// davAny is an element whose content is ignored.
+type davAny struct {
+	XMLName xml.Name
+}
+
+// names returns the property names in request order.
+func (p *davPropNames) names() []xml.Name {
+	names := make([]xml.Name, len(p.Names))
+	for i, n := range p.Names {
+		names[i] = n.XMLName
+	}
+	return names
+}
+
+// davPropfind is a PROPFIND request body.
+type davPropfind struct {
+	XMLName  xml.Name      `xml:"DAV: propfind"`
+	AllProp  *struct{}     `xml:"DAV: allprop"`
+	PropName *struct{}     `xml:"DAV: propname"`
+	Prop     *davPropNames `xml:"DAV: prop"`
+}
+
+// davPropertyUpdate is a PROPPATCH request body.
+type davPropertyUpdate struct {
+	XMLName xml.Name       `xml:"DAV: propertyupdate"`
+	Set     []davPropNames `xml:"DAV: set>prop"`
+	Remove  []davPropNames `xml:"DAV: remove>prop"`
+}
+
+// davMultiget is a CALDAV:calendar-multiget REPORT.
+type davMultiget struct {
+	XMLName  xml.Name      `xml:"urn:ietf:params:xml:ns:caldav calendar-multiget"`
+	AllProp  *struct{}     `xml:"DAV: allprop"`
+	PropName *struct{}     `xml:"DAV: propname"`
+	Prop     *davPropNames `xml:"DAV: prop"`
+	Hrefs    []string      `xml:"DAV: href"`
+}
+
+// davQuery is a CALDAV:calendar-query REPORT.
+type davQuery struct {
+	XMLName  xml.Name      `xml:"urn:ietf:params:xml:ns:caldav calendar-query"`
+	AllProp  *struct{}     `xml:"DAV: allprop"`
+	PropName *struct{}     `xml:"DAV: propname"`
+	Prop     *davPropNames `xml:"DAV: prop"`
+	Filter   *struct {
+		CompFilter davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// davSyncCollection is a DAV:sync-collection REPORT (RFC 6578).
+type davSyncCollection struct {
+	XMLName   xml.Name      `xml:"DAV: sync-collection"`
+	SyncToken string        `xml:"DAV: sync-token"`
+	SyncLevel string        `xml:"DAV: sync-level"`
+	Prop      *davPropNames `xml:"DAV: prop"`
+}
+
+// davCompFilter, davPropFilter and davParamFilter are the filters of a
+// calendar-query (RFC 4791 section 9.7).
+type davCompFilter struct {
+	Name         string          `xml:"name,attr"`
+	IsNotDefined *struct{}       `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *davTimeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	PropFilters  []davPropFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+	CompFilters  []davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type davPropFilter struct {
+	Name         string           `xml:"name,attr"`
+	IsNotDefined *struct{}        `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *davTimeRange    `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	TextMatch    *davTextMatch    `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+	ParamFilters []davParamFilter `xml:"urn:ietf:params:xml:ns:caldav param-filter"`
+}
+
+type davParamFilter struct {
+	Name         string        `xml:"name,attr"`
+	IsNotDefined *struct{}     `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TextMatch    *davTextMatch `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+}
+
+// davTextMatch matches text containing Value. Collation is "i;ascii-casemap"
+// (the default) or "i;octet".
+type davTextMatch struct {
+	Collation       string `xml:"collation,attr"`
+	NegateCondition string `xml:"negate-condition,attr"`
+	Value           string `xml:",chardata"`
+}
+
+// davTimeRange is a time range with UTC bounds; an empty bound is unbounded.
+type davTimeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+// errUnsupportedCollation reports a text-match with a collation we don't support.
+var errUnsupportedCollation = errors.New("unsupported collation")
+
+// davRootName returns the name of a request body's root element.
+func davRootName(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// --- Responses ---
+
+// davMultistatus is a 207 Multi-Status response body.
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"response"`
+	SyncToken string        `xml:"sync-token,omitempty"`
+}
+
+// davResponse is the status of one resource, either as a whole or per property.
+type davResponse struct {
+	Href      string        `xml:"href"`
+	Status    string        `xml:"status,omitempty"`
+	Propstats []davPropstat `xml:"propstat"`
+}
+
+type davPropstat struct {
+	Prop   davPropValues `xml:"prop"`
+	Status string        `xml:"status"`
+}
+
+type davPropValues struct {
+	Props []davProperty
+}
+
+// davProperty is a property with its value as XML.
+type davProperty struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+// davStatus formats an HTTP status line for a multistatus body.
+func davStatus(status int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", status, http.StatusText(status))
+}
+
+// writeMultistatus writes a 207 Multi-Status response.
+func writeMultistatus(w http.ResponseWriter, ms *davMultistatus) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(ms); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(buf.Bytes())
+}
+
+// writeDAVError writes an error response naming the precondition that failed,
+// such as CALDAV:supported-calendar-component.
+func writeDAVError(w http.ResponseWriter, status int, condition xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header+`<error xmlns="DAV:">`+davElement(condition, "")+`</error>`)
+}
+
+// davElement returns an element as XML with its namespace declared, so it can be
+// nested in any property value.
+func davElement(name xml.Name, inner string) string {
+	if inner == "" {
+		return `<` + name.Local + ` xmlns="` + name.Space + `"/>`
+	}
+	return `<` + name.Local + ` xmlns="` + name.Space + `">` + inner + `</` + name.Local + `>`
+}
+
+// davHref returns a DAV:href element.
+func davHref(href string) string {
+	return davElement(davName(nsDAV, "href"), davText(href))
+}
+
+// davText escapes character data.
+func davText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// --- calendar-query filters ---
+
+// matchCompFilter reports whether the components of parent that filter names
+// match it: any of them must when the filter has conditions, and none may exist
+// when it has is-not-defined.
+func matchCompFilter(parent *ical.Component, filter *davCompFilter, loc *time.Location) (bool, error) {
+	name := strings.ToUpper(filter.Name)
+	for _, child := range parent.Children {
+		if child.Name != name {
+			continue
+		}
+		if filter.IsNotDefined != nil {
+			return false, nil
+		}
+		ok, err := matchComponent(child, filter, loc)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return filter.IsNotDefined != nil, nil
+}
+
+// matchComponent reports whether a component matches a comp-filter's
+// time-range, prop-filters and nested comp-filters.
+func matchComponent(c *ical.Component, filter *davCompFilter, loc *time.Location) (bool, error) {
+	if filter.TimeRange != nil {
+		start, end, err := filter.TimeRange.bounds()
+		if err != nil {
+			return false, err
+		}
+		if !overlapsTodo(c, start, end, loc) {
+			return false, nil
+		}
+	}
+	for i := range filter.PropFilters {
+		ok, err := matchPropFilter(c, &filter.PropFilters[i], loc)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for i := range filter.CompFilters {
+		ok, err := matchCompFilter(c, &filter.CompFilters[i], loc)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchPropFilter reports whether any of a component's properties named by a
+// prop-filter matches it, or, with is-not-defined, that there are none.
+func matchPropFilter(c *ical.Component, filter *davPropFilter, loc *time.Location) (bool, error) {
+	props := c.All(strings.ToUpper(filter.Name))
+	if filter.IsNotDefined != nil {
+		return len(props) == 0, nil
+	}
+	for _, p := range props {
+		ok, err := matchProp(p, filter, loc)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func matchProp(p *ical.Prop, filter *davPropFilter, loc *time.Location) (bool, error) {
+	if filter.TimeRange != nil {
+		start, end, err := filter.TimeRange.bounds()
+		if err != nil {
+			return false, err
+		}
+		t, ok := propTime(p, loc)
+		if !ok || !start.IsZero() && t.Before(start) || !end.IsZero() && !t.Before(end) {
+			return false, nil
+		}
+	}
+	if filter.TextMatch != nil {
+		ok, err := filter.TextMatch.match(ical.ParseText(p.Value))
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, pf := range filter.ParamFilters {
+		values, defined := p.Params[strings.ToUpper(pf.Name)]
+		if pf.IsNotDefined != nil {
+			if defined {
+				return false, nil
+			}
+			continue
+		}
+		if !defined {
+			return false, nil
+		}
+		if pf.TextMatch != nil {
+			var matched bool
+			for _, v := range values {
+				ok, err := pf.TextMatch.match(v)
+				if err != nil {
+					return false, err
+				}
+				matched = matched || ok
+			}
+			if !matched {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// match reports whether s contains the text to match, or doesn't when the
+// condition is negated.
+func (m *davTextMatch) match(s string) (bool, error) {
+	var found bool
+	switch m.Collation {
+	case "", "i;ascii-casemap":
+		found = strings.Contains(asciiLower(s), asciiLower(m.Value))
+	case "i;octet":
+		found = strings.Contains(s, m.Value)
+	default:
+		return false, errUnsupportedCollation
+	}
+	return found != (m.NegateCondition == "yes"), nil
+}
+
+// asciiLower lower-cases ASCII letters only, as the i;ascii-casemap collation does.
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// bounds parses a time range; a missing bound is returned as the zero time.
+func (tr *davTimeRange) bounds() (start, end time.Time, err error) {
+	if tr.Start != "" {
+		if start, err = time.Parse("20060102T150405Z", tr.Start); err != nil {
+			return start, end, errors.New("invalid time-range start")
+		}
+	}
+	if tr.End != "" {
+		if end, err = time.Parse("20060102T150405Z", tr.End); err != nil {
+			return start, end, errors.New("invalid time-range end")
+		}
+	}
+	return start, end, nil
+}
+
+// overlapsTodo reports whether a VTODO overlaps a time range, by the rules of
+// RFC 4791 section 9.9. A zero start or end is unbounded.
+func overlapsTodo(c *ical.Component, start, end time.Time, loc *time.Location) bool {
+	if start.IsZero() {
+		start = time.Unix(0, 0).AddDate(-10000, 0, 0)
+	}
+	if end.IsZero() {
+		end = time.Unix(0, 0).AddDate(10000, 0, 0)
+	}
+	before := func(a, b time.Time) bool { return !a.After(b) } // a <= b
+
+	dtstart, hasStart := todoTime(c, "DTSTART", loc)
+	due, hasDue := todoTime(c, "DUE", loc)
+	completed, hasCompleted := todoTime(c, "COMPLETED", loc)
+	created, hasCreated := todoTime(c, "CREATED", loc)
+
+	switch {
+	case hasStart && hasDue:
+		return (start.Before(due) || before(start, dtstart)) &&
+			(end.After(dtstart) || before(due, end))
+	case hasStart:
+		return before(start, dtstart) && end.After(dtstart)
+	case hasDue:
+		return start.Before(due) && before(due, end)
+	case hasCompleted && hasCreated:
+		return (before(start, created) || before(start, completed)) &&
+			(before(created, end) || before(completed, end))
+	case hasCompleted:
+		return before(start, completed) && before(completed, end)
+	case hasCreated:
+		return end.After(created)
+	}
+	return true
+}
+
+// todoTime returns the time of a component's date property.
+func todoTime(c *ical.Component, name string, loc *time.Location) (time.Time, bool) {
+	p := c.Prop(name)
+	if p == nil {
+		return time.Time{}, false
+	}
+	return propTime(p, loc)
+}
+
+// propTime returns the time of a date property. DATE values and floating times
+// are taken to be in loc.
+func propTime(p *ical.Prop, loc *time.Location) (time.Time, bool) {
+	t, allDay, err := ical.ParseDate(p, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if allDay {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	return t, true
+}
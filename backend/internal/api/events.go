@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// eventStreamHeartbeat is how often an idle event stream gets a comment line, so
+// proxies don't time it out and clients notice dead connections. Tests shorten it.
+var eventStreamHeartbeat = 15 * time.Second
+
+// handleEvents streams the current user's hub events as Server-Sent Events, for
+// clients that can't open a WebSocket. Each event's data is the WebSocketEvent
+// JSON and its id is the event's seq, so a reconnecting client that sends
+// Last-Event-ID gets the events it missed replayed as on /ws?since=.
+func (h *Handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var since int64
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID != "" {
+		var err error
+		since, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || since < 0 {
+			h.errorResponse(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.Error("failed to clear write deadline", "error", err)
+	}
+
+	client := &Client{
+		hub:     h.hub,
+		userID:  userID,
+		send:    make(chan []byte, eventLogSize+1),
+		lastSeq: since,
+		resume:  lastEventID != "",
+	}
+	h.hub.register <- client
+	defer func() {
+		h.hub.unregister <- client
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(eventStreamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			if !ok {
+				// Hub dropped the client
+				return
+			}
+			if err := writeEvent(w, message); err != nil {
+				return
+			}
+			// Send whatever else is queued before flushing
+			for n := len(client.send); n > 0; n-- {
+				if err := writeEvent(w, <-client.send); err != nil {
+					return
+				}
+			}
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes a hub message as a Server-Sent Event, using its seq as the id.
+// A resync_required event takes the seq it tells the client to continue from, so
+// the browser resumes from there if the stream drops again.
+func writeEvent(w http.ResponseWriter, message []byte) error {
+	var event struct {
+		Seq     int64           `json:"seq"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	json.Unmarshal(message, &event)
+
+	id := event.Seq
+	if event.Type == "resync_required" {
+		var resync ResyncRequiredEvent
+		json.Unmarshal(event.Payload, &resync)
+		id = resync.Seq
+	}
+	if id > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", message)
+	return err
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseRecord is one record of an event stream: an event with its id and data, or
+// a comment.
+type sseRecord struct {
+	id, data, comment string
+}
+
+// eventStream is an open GET /api/events response.
+type eventStream struct {
+	t    *testing.T
+	resp *http.Response
+	body *bufio.Reader
+}
+
+// events opens the test user's event stream, resuming after lastEventID unless
+// it is empty, and returns it once the server has registered it with the hub.
+func (th *testHandler) events(server *httptest.Server, lastEventID string) *eventStream {
+	th.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	th.t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events", nil)
+	if err != nil {
+		th.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+th.token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		th.t.Fatalf("GET /api/events: %v", err)
+	}
+	th.t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		th.t.Fatalf("GET /api/events = %d", resp.StatusCode)
+	}
+	return &eventStream{t: th.t, resp: resp, body: bufio.NewReader(resp.Body)}
+}
+
+// next reads the next record from the stream.
+func (s *eventStream) next() sseRecord {
+	s.t.Helper()
+	var record sseRecord
+	for {
+		line, err := s.body.ReadString('\n')
+		if err != nil {
+			s.t.Fatalf("failed to read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return record
+		case strings.HasPrefix(line, ":"):
+			record.comment = strings.TrimSpace(line[1:])
+		case strings.HasPrefix(line, "id: "):
+			record.id = line[len("id: "):]
+		case strings.HasPrefix(line, "data: "):
+			record.data = line[len("data: "):]
+		default:
+			s.t.Fatalf("unexpected event stream line %q", line)
+		}
+	}
+}
+
+// nextEvent reads the next event from the stream, skipping heartbeats, and
+// checks that its id is its seq.
+func (s *eventStream) nextEvent() (sseRecord, WebSocketEvent) {
+	s.t.Helper()
+	for {
+		record := s.next()
+		if record.comment == "heartbeat" && record.data == "" {
+			continue
+		}
+		var event WebSocketEvent
+		if err := json.Unmarshal([]byte(record.data), &event); err != nil {
+			s.t.Fatalf("failed to decode event %+v: %v", record, err)
+		}
+		if event.Type != "resync_required" && record.id != strconv.FormatInt(event.Seq, 10) {
+			s.t.Fatalf("event %s has id %q", record.data, record.id)
+		}
+		return record, event
+	}
+}
+
+func TestEvents(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	server := httptest.NewServer(th.mux)
+	t.Cleanup(server.Close)
+
+	stream := th.events(server, "")
+	if ct, cc := stream.resp.Header.Get("Content-Type"), stream.resp.Header.Get("Cache-Control"); ct != "text/event-stream" || cc != "no-cache" {
+		t.Fatalf("GET /api/events returned Content-Type %q and Cache-Control %q", ct, cc)
+	}
+
+	// Each event is an id line with its seq and a data line with its JSON
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "report"}, http.StatusCreated, nil)
+	record := stream.next()
+	var event WebSocketEvent
+	if err := json.Unmarshal([]byte(record.data), &event); err != nil || record.id != "1" || event.Seq != 1 || event.Type != "task_created" {
+		t.Fatalf("first event = %+v", record)
+	}
+	for _, text := range []string{"notes", "slides"} {
+		th.call("POST", "/api/tasks", map[string]interface{}{"text": text}, http.StatusCreated, nil)
+	}
+	for want := int64(2); want <= 3; want++ {
+		if _, event := stream.nextEvent(); event.Seq != want || event.Type != "task_created" {
+			t.Fatalf("event = %+v, want seq %d", event, want)
+		}
+	}
+
+	// Reconnecting with Last-Event-ID replays the events after it, then
+	// continues live
+	resumed := th.events(server, "1")
+	for want := int64(2); want <= 3; want++ {
+		if _, event := resumed.nextEvent(); event.Seq != want {
+			t.Fatalf("replayed event seq = %d, want %d", event.Seq, want)
+		}
+	}
+	th.hub.BroadcastToUser(th.user.ID, WebSocketEvent{Type: "task_deleted"})
+	if _, event := resumed.nextEvent(); event.Seq != 4 || event.Type != "task_deleted" {
+		t.Fatalf("event after a replay = %+v", event)
+	}
+	if _, event := stream.nextEvent(); event.Seq != 4 {
+		t.Fatalf("live event seq = %d, want 4", event.Seq)
+	}
+
+	rec := th.doWithHeader("GET", "/api/events", nil, http.Header{"Last-Event-Id": {"abc"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("GET /api/events with a bad Last-Event-ID = %d", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/api/events", nil)
+	rec = httptest.NewRecorder()
+	th.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /api/events without a token = %d", rec.Code)
+	}
+}
+
+func TestEventsResync(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	server := httptest.NewServer(th.mux)
+	t.Cleanup(server.Close)
+
+	for i := 0; i < eventLogSize+10; i++ {
+		th.hub.BroadcastToUser(th.user.ID, WebSocketEvent{Type: "task_updated"})
+	}
+	latest := int64(eventLogSize + 10)
+	flush(t, th.hub)
+
+	// An id older than the log gets a resync_required event whose id is the
+	// latest seq, so that the stream resumes from there
+	stream := th.events(server, "5")
+	record, event := stream.nextEvent()
+	var resync ResyncRequiredEvent
+	data, _ := json.Marshal(event.Payload)
+	if err := json.Unmarshal(data, &resync); err != nil || event.Type != "resync_required" || resync.Seq != latest ||
+		record.id != strconv.FormatInt(latest, 10) {
+		t.Fatalf("first event = %+v", record)
+	}
+	th.hub.BroadcastToUser(th.user.ID, WebSocketEvent{Type: "task_created"})
+	if _, event := stream.nextEvent(); event.Seq != latest+1 || event.Type != "task_created" {
+		t.Fatalf("event after a resync = %+v, want seq %d", event, latest+1)
+	}
+
+	// Resuming from that id replays only what came after it
+	resumed := th.events(server, record.id)
+	if _, event := resumed.nextEvent(); event.Seq != latest+1 {
+		t.Fatalf("event after resuming from a resync = %+v, want seq %d", event, latest+1)
+	}
+}
+
+func TestEventsHeartbeat(t *testing.T) {
+	heartbeat := eventStreamHeartbeat
+	eventStreamHeartbeat = 10 * time.Millisecond
+	t.Cleanup(func() { eventStreamHeartbeat = heartbeat })
+
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	server := httptest.NewServer(th.mux)
+	t.Cleanup(server.Close)
+
+	// An idle stream gets comments, which carry no event
+	stream := th.events(server, "")
+	for i := 0; i < 2; i++ {
+		if record := stream.next(); record != (sseRecord{comment: "heartbeat"}) {
+			t.Fatalf("idle stream sent %+v, want a heartbeat", record)
+		}
+	}
+	th.hub.BroadcastToUser(th.user.ID, WebSocketEvent{Type: "task_created"})
+	if _, event := stream.nextEvent(); event.Seq != 1 || event.Type != "task_created" {
+		t.Fatalf("event after heartbeats = %+v", event)
+	}
+}
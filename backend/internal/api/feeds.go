@@ -0,0 +1,297 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/ical"
+)
+
+// feedProductID identifies the server in the PRODID of calendar feeds.
+const feedProductID = "-//TodoMaster 2010//Task Feed//EN"
+
+// feedRefreshInterval is how often calendar apps are asked to refetch a feed.
+const feedRefreshInterval = "PT1H"
+
+// CreateFeedRequest is the request body for creating a calendar feed token.
+type CreateFeedRequest struct {
+	Name   string `json:"name"`
+	ListID *int64 `json:"listId"`
+}
+
+// CreateFeedResponse is a new feed token. Token and URL are only returned here.
+type CreateFeedResponse struct {
+	*database.FeedToken
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// handleGetFeeds returns the current user's calendar feed tokens.
+func (h *Handler) handleGetFeeds(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	feeds, err := h.db.GetFeedTokens(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get feeds")
+		return
+	}
+
+	// Return empty array instead of null
+	if feeds == nil {
+		feeds = []*database.FeedToken{}
+	}
+
+	h.jsonResponse(w, http.StatusOK, feeds)
+}
+
+// handleCreateFeed creates a secret calendar feed URL for the current user's
+// tasks, or for one list's tasks when listId is set.
+func (h *Handler) handleCreateFeed(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var req CreateFeedRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.ListID != nil {
+		if _, err := h.db.GetList(r.Context(), userID, *req.ListID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				h.errorResponse(w, http.StatusBadRequest, "list not found")
+				return
+			}
+			h.errorResponse(w, http.StatusInternalServerError, "failed to get list")
+			return
+		}
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create feed")
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	feed, err := h.db.CreateFeedToken(r.Context(), userID, token, strings.TrimSpace(req.Name), req.ListID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create feed")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusCreated, CreateFeedResponse{
+		FeedToken: feed,
+		Token:     token,
+		URL:       "/api/ical/" + token + ".ics",
+	})
+}
+
+// handleDeleteFeed revokes a calendar feed token; its URL stops working at once.
+func (h *Handler) handleDeleteFeed(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	feedID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+
+	if err := h.db.DeleteFeedToken(r.Context(), userID, feedID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to delete feed")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "feed deleted successfully",
+	})
+}
+
+// handleICalFeed serves a feed token's tasks as an iCalendar file. The token in
+// the URL is the only credential, so calendar apps can subscribe to it.
+func (h *Handler) handleICalFeed(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok || token == "" {
+		h.errorResponse(w, http.StatusNotFound, "feed not found")
+		return
+	}
+
+	feed, err := h.db.GetFeedTokenByToken(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get feed")
+		return
+	}
+
+	name := "TodoMaster"
+	filter := database.TaskFilter{}
+	if feed.ListID != nil {
+		filter.ListID = feed.ListID
+		list, err := h.db.GetList(r.Context(), feed.UserID, *feed.ListID)
+		switch {
+		case err == nil:
+			name = list.Title
+		case !errors.Is(err, database.ErrNotFound):
+			h.errorResponse(w, http.StatusInternalServerError, "failed to get list")
+			return
+		}
+	}
+	if feed.Name != "" {
+		name = feed.Name
+	}
+
+	tasks, err := h.db.GetUserTasks(r.Context(), feed.UserID, filter)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+		return
+	}
+
+	// Rendered in full first so a failure can still be reported
+	var buf bytes.Buffer
+	if err := writeTaskCalendar(&buf, name, tasks); err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to render feed")
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// writeTaskCalendar writes tasks, with their subtasks, as a VCALENDAR of VTODOs.
+func writeTaskCalendar(out io.Writer, name string, tasks []*database.Task) error {
+	cal := ical.NewWriter(out)
+	cal.Begin("VCALENDAR")
+	cal.Property("VERSION", "2.0")
+	cal.Property("PRODID", feedProductID)
+	cal.Property("CALSCALE", "GREGORIAN")
+	cal.Property("NAME", ical.Text(name))
+	cal.Property("X-WR-CALNAME", ical.Text(name))
+	cal.Property("REFRESH-INTERVAL;VALUE=DURATION", feedRefreshInterval)
+	cal.Property("X-PUBLISHED-TTL", feedRefreshInterval)
+	for _, task := range tasks {
+		writeTaskTodo(cal, task, taskUID(task.ID))
+		for _, subtask := range task.Subtasks {
+			writeSubtaskTodo(cal, subtask, subtaskUID(subtask.ID), taskUID(task.ID))
+		}
+	}
+	cal.End("VCALENDAR")
+	return cal.Err()
+}
+
+// writeTaskTodo writes a task as a VTODO with the given UID.
+//
+// All-day dates are written as DATE values and timed ones in UTC. RFC 5545
+// requires DTSTART and DUE to have the same value type with DUE the later, so a
+// start date that doesn't fit is left out. A recurrence rule needs a DTSTART to
+// anchor it: an incomplete recurring task without a usable start date is
+// anchored at its due date, which then becomes its DTSTART instead of its DUE.
+func writeTaskTodo(cal *ical.Writer, task *database.Task, uid string) {
+	cal.Begin("VTODO")
+	cal.Property("UID", ical.Text(uid))
+	cal.Property("DTSTAMP", ical.DateTime(task.UpdatedAt))
+	cal.Property("CREATED", ical.DateTime(task.CreatedAt))
+	cal.Property("LAST-MODIFIED", ical.DateTime(task.UpdatedAt))
+	cal.Property("SEQUENCE", strconv.FormatInt(max(task.Version-1, 0), 10))
+	cal.Property("SUMMARY", ical.Text(task.Text))
+	if task.Completed {
+		cal.Property("STATUS", "COMPLETED")
+		cal.Property("PERCENT-COMPLETE", "100")
+		if task.CompletedAt != nil {
+			cal.Property("COMPLETED", ical.DateTime(*task.CompletedAt))
+		}
+	} else {
+		cal.Property("STATUS", "NEEDS-ACTION")
+	}
+	if task.Important {
+		cal.Property("PRIORITY", "1")
+	}
+	if len(task.Tags) > 0 {
+		cal.Property("CATEGORIES", ical.TextList(task.Tags))
+	}
+
+	start, due := task.StartAt, task.DueAt
+	if start != nil && due != nil && (start.AllDay != due.AllDay || !start.Time.Before(due.Time)) {
+		start = nil
+	}
+	rule := ""
+	if task.Recurrence != "" && !task.Completed {
+		rule = task.Recurrence
+		if start == nil {
+			start, due = due, nil
+		}
+		if start == nil {
+			rule = ""
+		}
+	}
+	if start != nil {
+		name, value := icalDate("DTSTART", start)
+		cal.Property(name, value)
+	}
+	if due != nil {
+		name, value := icalDate("DUE", due)
+		cal.Property(name, value)
+	}
+	if rule != "" {
+		if start.AllDay {
+			// UNTIL must have the same value type as DTSTART
+			rule = untilDateTime.ReplaceAllString(rule, "UNTIL=$1")
+		}
+		cal.Property("RRULE", rule)
+	}
+	cal.End("VTODO")
+}
+
+// writeSubtaskTodo writes a subtask as a VTODO with the given UID, related to
+// its task's VTODO by the task's UID.
+func writeSubtaskTodo(cal *ical.Writer, subtask *database.Subtask, uid, taskUID string) {
+	cal.Begin("VTODO")
+	cal.Property("UID", ical.Text(uid))
+	cal.Property("DTSTAMP", ical.DateTime(subtask.CreatedAt))
+	cal.Property("CREATED", ical.DateTime(subtask.CreatedAt))
+	cal.Property("SEQUENCE", strconv.FormatInt(max(subtask.Version-1, 0), 10))
+	cal.Property("SUMMARY", ical.Text(subtask.Text))
+	if subtask.Completed {
+		cal.Property("STATUS", "COMPLETED")
+		cal.Property("PERCENT-COMPLETE", "100")
+	} else {
+		cal.Property("STATUS", "NEEDS-ACTION")
+	}
+	cal.Property("RELATED-TO;RELTYPE=PARENT", ical.Text(taskUID))
+	cal.End("VTODO")
+}
+
+// untilDateTime matches a DATE-TIME UNTIL in a recurrence rule.
+var untilDateTime = regexp.MustCompile(`UNTIL=([0-9]{8})T[0-9]{6}Z`)
+
+// icalDate returns the property name, with a VALUE parameter for all-day
+// dates, and value for a task date.
+func icalDate(name string, date *database.TaskDate) (string, string) {
+	if date.AllDay {
+		return name + ";VALUE=DATE", ical.Date(date.Time)
+	}
+	return name, ical.DateTime(date.Time)
+}
+
+func taskUID(id int64) string {
+	return fmt.Sprintf("task-%d@todomaster", id)
+}
+
+func subtaskUID(id int64) string {
+	return fmt.Sprintf("subtask-%d@todomaster", id)
+}
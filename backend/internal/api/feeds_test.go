@@ -0,0 +1,147 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/ical"
+)
+
+// feed fetches a calendar feed by its URL, without signing in, and parses it.
+func (th *testHandler) feed(url string) *ical.Component {
+	th.t.Helper()
+	rec := th.do("GET", url, nil)
+	if rec.Code != http.StatusOK {
+		th.t.Fatalf("GET %s = %d %s", url, rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		th.t.Fatalf("GET %s returned Content-Type %q", url, ct)
+	}
+	body := rec.Body.String()
+	for i, line := range strings.SplitAfter(body, "\n") {
+		if line != "" && (!strings.HasSuffix(line, "\r\n") || len(line) > 75+2) {
+			th.t.Fatalf("GET %s: line %d isn't a folded CRLF line: %q", url, i+1, line)
+		}
+	}
+	cal, err := ical.Parse(strings.NewReader(body))
+	if err != nil {
+		th.t.Fatalf("GET %s: failed to parse feed: %v\n%s", url, err, body)
+	}
+	return cal
+}
+
+// todos returns a feed's VTODOs by UID, failing the test unless each has a
+// DTSTAMP.
+func todos(t *testing.T, cal *ical.Component) map[string]*ical.Component {
+	t.Helper()
+	byUID := make(map[string]*ical.Component)
+	for _, c := range cal.Children {
+		if c.Name != "VTODO" {
+			continue
+		}
+		uid := c.Prop("UID")
+		if uid == nil || uid.Value == "" {
+			t.Fatalf("VTODO without a UID: %+v", c.Props)
+		}
+		if c.Prop("DTSTAMP") == nil {
+			t.Fatalf("VTODO %s has no DTSTAMP", uid.Value)
+		}
+		if byUID[uid.Value] != nil {
+			t.Fatalf("two VTODOs with UID %s", uid.Value)
+		}
+		byUID[uid.Value] = c
+	}
+	return byUID
+}
+
+func TestFeed(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC))
+
+	var list *database.List
+	th.call("POST", "/api/lists", map[string]interface{}{"title": "Work"}, http.StatusCreated, &list)
+	var report, call *database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{
+		"text":      "Report, part 1; see C:\\docs\nthen send " + strings.Repeat("long ", 20),
+		"listId":    list.ID,
+		"important": true,
+		"tags":      []string{"q3", "a,b"},
+		"dueAt":     "2024-03-10",
+	}, http.StatusCreated, &report)
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "Call"}, http.StatusCreated, &call)
+	var draft *database.Subtask
+	th.call("POST", fmt.Sprintf("/api/tasks/%d/subtasks", report.ID), map[string]interface{}{"text": "draft"}, http.StatusCreated, &draft)
+
+	var all CreateFeedResponse
+	th.call("POST", "/api/feeds", map[string]interface{}{"name": "Ann's tasks"}, http.StatusCreated, &all)
+	cal := th.feed(all.URL)
+	if cal.Name != "VCALENDAR" || cal.Prop("VERSION").Value != "2.0" || cal.Prop("PRODID") == nil {
+		t.Fatalf("feed = %+v", cal.Props)
+	}
+	if got := ical.ParseText(cal.Prop("X-WR-CALNAME").Value); got != "Ann's tasks" {
+		t.Fatalf("feed name = %q", got)
+	}
+
+	vtodos := todos(t, cal)
+	if len(vtodos) != 3 {
+		t.Fatalf("feed has %d VTODOs, want 3", len(vtodos))
+	}
+	todo := vtodos[taskUID(report.ID)]
+	if todo == nil {
+		t.Fatalf("feed has no VTODO for task %d", report.ID)
+	}
+	if got := ical.ParseText(todo.Prop("SUMMARY").Value); got != report.Text {
+		t.Fatalf("summary = %q, want %q", got, report.Text)
+	}
+	if got, want := todo.Prop("CATEGORIES").Value, `q3,a\,b`; got != want {
+		t.Fatalf("categories = %q, want %q", got, want)
+	}
+	if due := todo.Prop("DUE"); due.Param("VALUE") != "DATE" || due.Value != "20240310" {
+		t.Fatalf("due = %+v", due)
+	}
+	if todo.Prop("PRIORITY").Value != "1" || todo.Prop("STATUS").Value != "NEEDS-ACTION" || todo.Prop("SEQUENCE").Value != "0" {
+		t.Fatalf("task VTODO = %+v", todo.Props)
+	}
+
+	sub := vtodos[subtaskUID(draft.ID)]
+	if sub == nil {
+		t.Fatalf("feed has no VTODO for subtask %d", draft.ID)
+	}
+	if related := sub.Prop("RELATED-TO"); related == nil || related.Param("RELTYPE") != "PARENT" || related.Value != taskUID(report.ID) {
+		t.Fatalf("subtask related to %+v, want task %d", related, report.ID)
+	}
+	if vtodos[taskUID(call.ID)].Prop("RELATED-TO") != nil {
+		t.Fatal("a task VTODO has a RELATED-TO")
+	}
+
+	// A list's feed has only that list's tasks, and is named after the list
+	var work CreateFeedResponse
+	th.call("POST", "/api/feeds", map[string]interface{}{"listId": list.ID}, http.StatusCreated, &work)
+	cal = th.feed(work.URL)
+	if got := ical.ParseText(cal.Prop("X-WR-CALNAME").Value); got != "Work" {
+		t.Fatalf("list feed name = %q", got)
+	}
+	vtodos = todos(t, cal)
+	if len(vtodos) != 2 || vtodos[taskUID(report.ID)] == nil || vtodos[subtaskUID(draft.ID)] == nil {
+		t.Fatalf("list feed has VTODOs %v", vtodos)
+	}
+	th.call("POST", "/api/feeds", map[string]interface{}{"listId": 9999}, http.StatusBadRequest, nil)
+
+	// A revoked token stops working at once, and other tokens keep working
+	var feeds []*database.FeedToken
+	th.call("GET", "/api/feeds", nil, http.StatusOK, &feeds)
+	if len(feeds) != 2 {
+		t.Fatalf("GET /api/feeds returned %d feeds, want 2", len(feeds))
+	}
+	th.call("DELETE", fmt.Sprintf("/api/feeds/%d", work.ID), nil, http.StatusOK, nil)
+	th.call("GET", work.URL, nil, http.StatusNotFound, nil)
+	th.call("DELETE", fmt.Sprintf("/api/feeds/%d", work.ID), nil, http.StatusNotFound, nil)
+	th.feed(all.URL)
+
+	for _, url := range []string{"/api/ical/unknown.ics", "/api/ical/" + strings.TrimSuffix(all.URL[len("/api/ical/"):], ".ics"), "/api/ical/.ics"} {
+		th.call("GET", url, nil, http.StatusNotFound, nil)
+	}
+}
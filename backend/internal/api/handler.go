@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/todomaster-2010/backend/internal/database"
@@ -13,14 +14,16 @@ import (
 
 // Handler provides HTTP handlers for the API.
 type Handler struct {
-	db        *database.DB
+	db        database.Store
 	jwtSecret string
 	mux       *http.ServeMux
 	hub       *Hub
+	now       func() time.Time
 }
 
 // New creates a new API handler with all routes configured.
-func New(db *database.DB, jwtSecret string) http.Handler {
+// The store is usually a *database.DB but can be any database.Store implementation.
+func New(db database.Store, jwtSecret string) http.Handler {
 	hub := NewHub()
 	go hub.Run()
 
@@ -29,6 +32,7 @@ func New(db *database.DB, jwtSecret string) http.Handler {
 		jwtSecret: jwtSecret,
 		mux:       http.NewServeMux(),
 		hub:       hub,
+		now:       time.Now,
 	}
 
 	// Register routes
@@ -61,10 +65,14 @@ func (h *Handler) registerRoutes() {
 	h.mux.HandleFunc("GET /api/tasks/{id}", h.requireAuth(h.handleGetTask))
 	h.mux.HandleFunc("PUT /api/tasks/{id}", h.requireAuth(h.handleUpdateTask))
 	h.mux.HandleFunc("DELETE /api/tasks/{id}", h.requireAuth(h.handleDeleteTask))
+	h.mux.HandleFunc("GET /api/tasks/{id}/history", h.requireAuth(h.handleGetTaskHistory))
 	h.mux.HandleFunc("POST /api/tasks/reorder", h.requireAuth(h.handleReorderTasks))
+	h.mux.HandleFunc("POST /api/tasks/batch", h.requireAuth(h.handleBatchTasks))
+	h.mux.HandleFunc("POST /api/tasks/parse", h.requireAuth(h.handleParseTask))
 
 	// Subtask endpoints (protected)
 	h.mux.HandleFunc("POST /api/tasks/{taskId}/subtasks", h.requireAuth(h.handleCreateSubtask))
+	h.mux.HandleFunc("POST /api/tasks/{taskId}/subtasks/reorder", h.requireAuth(h.handleReorderSubtasks))
 	h.mux.HandleFunc("PUT /api/subtasks/{id}", h.requireAuth(h.handleUpdateSubtask))
 	h.mux.HandleFunc("DELETE /api/subtasks/{id}", h.requireAuth(h.handleDeleteSubtask))
 
@@ -73,6 +81,71 @@ func (h *Handler) registerRoutes() {
 	h.mux.HandleFunc("POST /api/lists", h.requireAuth(h.handleCreateList))
 	h.mux.HandleFunc("PUT /api/lists/{id}", h.requireAuth(h.handleUpdateList))
 	h.mux.HandleFunc("DELETE /api/lists/{id}", h.requireAuth(h.handleDeleteList))
+	h.mux.HandleFunc("GET /api/lists/{id}/export/markdown", h.requireAuth(h.handleExportMarkdown))
+	h.mux.HandleFunc("POST /api/lists/{id}/import/markdown", h.requireAuth(h.handleImportMarkdown))
+
+	// Smart list endpoints (protected)
+	h.mux.HandleFunc("GET /api/smart-lists", h.requireAuth(h.handleGetSmartLists))
+	h.mux.HandleFunc("POST /api/smart-lists", h.requireAuth(h.handleCreateSmartList))
+	h.mux.HandleFunc("PUT /api/smart-lists/{id}", h.requireAuth(h.handleUpdateSmartList))
+	h.mux.HandleFunc("DELETE /api/smart-lists/{id}", h.requireAuth(h.handleDeleteSmartList))
+	h.mux.HandleFunc("GET /api/smart-lists/{id}/tasks", h.requireAuth(h.handleGetSmartListTasks))
+
+	// Tag endpoints (protected)
+	h.mux.HandleFunc("GET /api/tags", h.requireAuth(h.handleGetTags))
+	h.mux.HandleFunc("POST /api/tags", h.requireAuth(h.handleCreateTag))
+	h.mux.HandleFunc("PUT /api/tags/{id}", h.requireAuth(h.handleUpdateTag))
+	h.mux.HandleFunc("POST /api/tags/{id}/merge", h.requireAuth(h.handleMergeTag))
+	h.mux.HandleFunc("DELETE /api/tags/{id}", h.requireAuth(h.handleDeleteTag))
+
+	// Search endpoint (protected)
+	h.mux.HandleFunc("GET /api/search", h.requireAuth(h.handleSearch))
+
+	// Sync endpoint (protected)
+	h.mux.HandleFunc("GET /api/sync", h.requireAuth(h.handleSync))
+
+	// Server-Sent Events stream (protected), an alternative to the WebSocket
+	h.mux.HandleFunc("GET /api/events", h.requireAuth(h.handleEvents))
+
+	// Trash endpoints (protected)
+	h.mux.HandleFunc("GET /api/trash", h.requireAuth(h.handleGetTrash))
+	h.mux.HandleFunc("DELETE /api/trash", h.requireAuth(h.handleEmptyTrash))
+	h.mux.HandleFunc("POST /api/trash/tasks/{id}/restore", h.requireAuth(h.handleRestoreTask))
+	h.mux.HandleFunc("DELETE /api/trash/tasks/{id}", h.requireAuth(h.handlePurgeTask))
+	h.mux.HandleFunc("POST /api/trash/subtasks/{id}/restore", h.requireAuth(h.handleRestoreSubtask))
+	h.mux.HandleFunc("DELETE /api/trash/subtasks/{id}", h.requireAuth(h.handlePurgeSubtask))
+	h.mux.HandleFunc("POST /api/trash/lists/{id}/restore", h.requireAuth(h.handleRestoreList))
+	h.mux.HandleFunc("DELETE /api/trash/lists/{id}", h.requireAuth(h.handlePurgeList))
+
+	// Undo and redo (protected)
+	h.mux.HandleFunc("POST /api/undo", h.requireAuth(h.handleUndo))
+	h.mux.HandleFunc("POST /api/redo", h.requireAuth(h.handleRedo))
+
+	// Account export and import (protected)
+	h.mux.HandleFunc("GET /api/export", h.requireAuth(h.handleExport))
+	h.mux.HandleFunc("POST /api/import", h.requireAuth(h.handleImport))
+	h.mux.HandleFunc("GET /api/export/todotxt", h.requireAuth(h.handleExportTodoTxt))
+	h.mux.HandleFunc("POST /api/import/todotxt", h.requireAuth(h.handleImportTodoTxt))
+	h.mux.HandleFunc("POST /api/import/jobs", h.requireAuth(h.handleCreateImportJob))
+	h.mux.HandleFunc("GET /api/import/jobs", h.requireAuth(h.handleGetImportJobs))
+	h.mux.HandleFunc("GET /api/import/jobs/{id}", h.requireAuth(h.handleGetImportJob))
+
+	// Calendar feed tokens (protected)
+	h.mux.HandleFunc("GET /api/feeds", h.requireAuth(h.handleGetFeeds))
+	h.mux.HandleFunc("POST /api/feeds", h.requireAuth(h.handleCreateFeed))
+	h.mux.HandleFunc("DELETE /api/feeds/{id}", h.requireAuth(h.handleDeleteFeed))
+
+	// Calendar feed (authentication via the token in the URL)
+	h.mux.HandleFunc("GET /api/ical/{file}", h.handleICalFeed)
+
+	// App passwords (protected)
+	h.mux.HandleFunc("GET /api/app-passwords", h.requireAuth(h.handleGetAppPasswords))
+	h.mux.HandleFunc("POST /api/app-passwords", h.requireAuth(h.handleCreateAppPassword))
+	h.mux.HandleFunc("DELETE /api/app-passwords/{id}", h.requireAuth(h.handleDeleteAppPassword))
+
+	// CalDAV (Basic auth with an app password)
+	h.mux.HandleFunc("/.well-known/caldav", h.handleWellKnownCalDAV)
+	h.mux.HandleFunc(davRoot, h.requireBasicAuth(h.handleDAV))
 
 	// WebSocket endpoint (authentication via query param)
 	h.mux.HandleFunc("GET /ws", h.handleWebSocket)
@@ -89,12 +162,13 @@ func (h *Handler) corsMiddleware(next http.Handler) http.Handler {
 
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, Last-Event-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Next-Cursor")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 
-		// Handle preflight
-		if r.Method == "OPTIONS" {
+		// Handle preflight. CalDAV clients send OPTIONS to discover the server.
+		if r.Method == "OPTIONS" && !strings.HasPrefix(r.URL.Path, davRoot) {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/database/memory"
+)
+
+// testHandler serves the API from an in-memory store to a signed-in user, with a
+// clock the test sets.
+type testHandler struct {
+	*Handler
+	t     *testing.T
+	now   time.Time
+	user  *database.User
+	token string
+}
+
+// newTestHandler returns a handler for a new user whose clock reads now.
+func newTestHandler(t *testing.T, now time.Time) *testHandler {
+	t.Helper()
+	hub := NewHub()
+	go hub.Run()
+
+	th := &testHandler{t: t, now: now}
+	th.Handler = &Handler{
+		db:        memory.New(),
+		jwtSecret: "test-secret",
+		mux:       http.NewServeMux(),
+		hub:       hub,
+		now:       func() time.Time { return th.now },
+	}
+	th.registerRoutes()
+
+	ctx := context.Background()
+	user, err := th.db.CreateUser(ctx, "ann@example.com", "hash", "Ann")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	auth, err := th.generateAuthResponse(ctx, user)
+	if err != nil {
+		t.Fatalf("generateAuthResponse: %v", err)
+	}
+	th.user, th.token = user, auth.AccessToken
+	return th
+}
+
+// do sends a request as the user and returns the response. A non-nil body is
+// sent as JSON.
+func (th *testHandler) do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	th.t.Helper()
+	return th.doWithHeader(method, path, body, nil)
+}
+
+// doWithHeader sends a request like do, with the extra headers in header.
+func (th *testHandler) doWithHeader(method, path string, body interface{}, header http.Header) *httptest.ResponseRecorder {
+	th.t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			th.t.Fatalf("failed to encode request: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer "+th.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	th.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// call sends a request as the user, fails the test unless the response has status
+// want, and decodes the JSON response into out unless it is nil.
+func (th *testHandler) call(method, path string, body interface{}, want int, out interface{}) {
+	th.t.Helper()
+	rec := th.do(method, path, body)
+	if rec.Code != want {
+		th.t.Fatalf("%s %s = %d %s, want %d", method, path, rec.Code, rec.Body, want)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			th.t.Fatalf("%s %s: failed to decode response %s: %v", method, path, rec.Body, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// HistoryResponse is one page of a task's change history, newest first.
+// NextCursor is set when older entries remain; pass it back as ?cursor= to get them.
+type HistoryResponse struct {
+	Entries    []*database.HistoryEntry `json:"entries"`
+	NextCursor string                   `json:"nextCursor,omitempty"`
+}
+
+// handleGetTaskHistory returns a page of a task's change history.
+func (h *Handler) handleGetTaskHistory(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	taskID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			h.errorResponse(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if limit > maxHistoryLimit {
+			limit = maxHistoryLimit
+		}
+	}
+
+	var before int64
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		before, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || before < 1 {
+			h.errorResponse(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	// Fetch one extra entry to know whether another page follows
+	entries, err := h.db.GetTaskHistory(r.Context(), userID, taskID, before, limit+1)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "task not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get task history")
+		return
+	}
+
+	resp := HistoryResponse{Entries: entries}
+	if len(entries) > limit {
+		resp.Entries = entries[:limit]
+		resp.NextCursor = strconv.FormatInt(entries[limit-1].ID, 10)
+	}
+	if resp.Entries == nil {
+		resp.Entries = []*database.HistoryEntry{}
+	}
+
+	h.jsonResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/importer"
+)
+
+// importBatchSize is how many tasks an import job imports per transaction, and
+// so between progress events.
+const importBatchSize = 100
+
+// ImportProgressEvent is the payload of an import_progress event.
+type ImportProgressEvent struct {
+	ID        int64  `json:"id"`
+	Status    string `json:"status"`
+	Total     int    `json:"total"`
+	Processed int    `json:"processed"`
+}
+
+// handleCreateImportJob starts importing another app's export file, sent as the
+// body, into the current user's account. The source parameter names the app;
+// list names the list a Todoist CSV file's tasks go into. The file is read
+// before the response, so an unreadable one gets a 400, and its tasks are then
+// imported in the background by the job the response describes.
+func (h *Handler) handleCreateImportJob(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+	ctx := r.Context()
+
+	params := r.URL.Query()
+	source := params.Get("source")
+	switch source {
+	case importer.SourceTodoist, importer.SourceMicrosoftToDo, importer.SourceGoogleTasks:
+	default:
+		h.errorResponse(w, http.StatusBadRequest, "invalid source: want todoist, microsoft-todo or google-tasks")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxArchiveSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.errorResponse(w, http.StatusRequestEntityTooLarge, "file is too large")
+			return
+		}
+		h.errorResponse(w, http.StatusBadRequest, "failed to read file")
+		return
+	}
+
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	result, err := importer.Read(source, body, importer.Options{Now: h.now(), Location: loc, List: params.Get("list")})
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid file: "+err.Error())
+		return
+	}
+
+	job, err := h.db.CreateImportJob(ctx, userID, source, len(result.Tasks))
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create import job")
+		return
+	}
+	for _, problem := range result.Problems {
+		job.Errors = append(job.Errors, database.ImportItemError{Item: problem.Ref, Text: problem.Text, Message: problem.Message})
+	}
+	if err := h.db.UpdateImportJob(ctx, job); err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create import job")
+		return
+	}
+
+	// The job runs on its own copy, so the response isn't encoded while it changes
+	running := *job
+	go h.runImportJob(&running, result)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/import/jobs/%d", job.ID))
+	h.jsonResponse(w, http.StatusAccepted, job)
+}
+
+// runImportJob imports what was read from a file in batches, each a merge import
+// of an archive, saving the job's progress and broadcasting an import_progress
+// event after each. Lists merge by title and tags by name, as in an archive
+// import. A batch that cannot be imported is imported again one task at a time,
+// and the tasks that still fail are added to the job's errors. When it is done
+// the job is completed, or failed if none of its tasks could be imported; either
+// way an import_finished event carries the job.
+func (h *Handler) runImportJob(job *database.ImportJob, result *importer.Result) {
+	ctx := context.Background()
+
+	listIDs := make(map[string]int64, len(result.Lists))
+	for i, title := range result.Lists {
+		listIDs[title] = int64(i + 1)
+	}
+	tags := make(map[string]bool)
+	report := &database.ImportReport{}
+
+	// importTasks imports tasks start to end in one transaction
+	imported := 0
+	importTasks := func(start, end int) error {
+		archive := importBatch(result, start, end, listIDs)
+		var batch *database.ImportReport
+		err := validateArchive(archive)
+		if err == nil {
+			batch, err = h.db.ImportArchive(ctx, job.UserID, archive, database.ImportOptions{})
+		}
+		if err != nil {
+			return err
+		}
+		for _, task := range archive.Tasks {
+			for _, tag := range task.Tags {
+				tags[tag] = true
+			}
+		}
+		report.Lists.Created += batch.Lists.Created
+		report.Tags.Created += batch.Tags.Created
+		report.Tasks.Created += batch.Tasks.Created
+		report.Subtasks.Created += batch.Subtasks.Created
+		imported += end - start
+		return nil
+	}
+
+	var failed error
+	for start := 0; start == 0 || start < len(result.Tasks); start += importBatchSize {
+		end := min(start+importBatchSize, len(result.Tasks))
+		if err := importTasks(start, end); err != nil {
+			slog.Warn("import job batch failed, importing its tasks one at a time", "job", job.ID, "error", err)
+			failed = err
+			if start == 0 {
+				// Create the lists on their own, so that empty ones don't depend
+				// on the first task
+				if err := importTasks(0, 0); err != nil {
+					slog.Error("failed to import lists", "job", job.ID, "error", err)
+				}
+			}
+			for i := start; i < end; i++ {
+				if err := importTasks(i, i+1); err != nil {
+					task := result.Tasks[i]
+					slog.Error("failed to import task", "job", job.ID, "item", task.Ref, "error", err)
+					job.Errors = append(job.Errors, database.ImportItemError{Item: task.Ref, Text: task.Text, Message: "task could not be imported"})
+				}
+			}
+		}
+
+		job.Processed = end
+		if err := h.db.UpdateImportJob(ctx, job); err != nil {
+			slog.Error("failed to save import job progress", "job", job.ID, "error", err)
+		}
+		h.hub.BroadcastToUser(job.UserID, WebSocketEvent{
+			Type:    "import_progress",
+			Payload: ImportProgressEvent{ID: job.ID, Status: job.Status, Total: job.Total, Processed: job.Processed},
+		})
+	}
+
+	if failed != nil && imported == 0 {
+		slog.Error("import job failed", "job", job.ID, "error", failed)
+		job.Status, job.Error = database.ImportJobFailed, "failed to import tasks"
+		h.finishImportJob(ctx, job, report.Lists.Created > 0)
+		return
+	}
+
+	report.Lists.Merged = len(result.Lists) - report.Lists.Created
+	report.Tags.Merged = len(tags) - report.Tags.Created
+	job.Report = report
+	job.Status = database.ImportJobCompleted
+	h.finishImportJob(ctx, job, true)
+}
+
+// importBatch builds the archive of tasks start to end of a result. The first
+// batch carries every list, so that empty ones are created too, and later ones
+// only those their tasks are in.
+func importBatch(result *importer.Result, start, end int, listIDs map[string]int64) *database.Archive {
+	archive := &database.Archive{
+		Format:  database.ArchiveFormat,
+		Version: database.ArchiveVersion,
+		Lists:   []*database.ArchiveList{},
+		Tasks:   []*database.ArchiveTask{},
+	}
+	added := make(map[string]bool)
+	addList := func(title string) {
+		if !added[title] {
+			added[title] = true
+			archive.Lists = append(archive.Lists, &database.ArchiveList{ID: listIDs[title], Title: title})
+		}
+	}
+	if start == 0 {
+		for _, title := range result.Lists {
+			addList(title)
+		}
+	}
+
+	for _, task := range result.Tasks[start:end] {
+		t := &database.ArchiveTask{
+			Text:        task.Text,
+			Completed:   task.Completed,
+			Important:   task.Important,
+			Tags:        task.Tags,
+			CreatedAt:   task.CreatedAt,
+			CompletedAt: task.CompletedAt,
+		}
+		if task.List != "" {
+			addList(task.List)
+			id := listIDs[task.List]
+			t.ListID = &id
+		}
+		if task.Due != nil {
+			t.DueAt = &database.TaskDate{Time: *task.Due, AllDay: task.DueAllDay}
+		}
+		for _, subtask := range task.Subtasks {
+			t.Subtasks = append(t.Subtasks, &database.ArchiveSubtask{Text: subtask.Text, Completed: subtask.Completed, CreatedAt: subtask.CreatedAt})
+		}
+		archive.Tasks = append(archive.Tasks, t)
+	}
+	return archive
+}
+
+// finishImportJob saves a job that has stopped running and broadcasts an
+// import_finished event, and a data_imported event if anything was imported.
+func (h *Handler) finishImportJob(ctx context.Context, job *database.ImportJob, imported bool) {
+	if err := h.db.UpdateImportJob(ctx, job); err != nil {
+		slog.Error("failed to save import job", "job", job.ID, "error", err)
+	}
+	if saved, err := h.db.GetImportJob(ctx, job.UserID, job.ID); err == nil {
+		job = saved
+	}
+
+	h.hub.BroadcastToUser(job.UserID, WebSocketEvent{Type: "import_finished", Payload: job})
+	if imported {
+		// Too much may have changed to send piecemeal; clients resync
+		h.hub.BroadcastToUser(job.UserID, WebSocketEvent{
+			Type:    "data_imported",
+			Payload: map[string]string{"mode": importModeMerge},
+		})
+	}
+}
+
+// handleGetImportJobs returns the current user's import jobs, newest first.
+func (h *Handler) handleGetImportJobs(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	jobs, err := h.db.GetImportJobs(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get import jobs")
+		return
+	}
+
+	// Return empty array instead of null
+	if jobs == nil {
+		jobs = []*database.ImportJob{}
+	}
+
+	h.jsonResponse(w, http.StatusOK, jobs)
+}
+
+// handleGetImportJob returns an import job with its progress and error report.
+func (h *Handler) handleGetImportJob(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	jobID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid import job id")
+		return
+	}
+
+	job, err := h.db.GetImportJob(r.Context(), userID, jobID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "import job not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get import job")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, job)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// todoistCSV returns a Todoist CSV file of n tasks named "Task 1" to "Task n".
+func todoistCSV(n int) string {
+	var csv strings.Builder
+	csv.WriteString("TYPE,CONTENT,PRIORITY,INDENT\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&csv, "task,Task %d @imported,4,1\n", i)
+	}
+	return csv.String()
+}
+
+// importJob starts an import job for a Todoist CSV file into the Inbox list and
+// returns it once it has finished.
+func (th *testHandler) importJob(csv string) *database.ImportJob {
+	th.t.Helper()
+	req := httptest.NewRequest("POST", "/api/import/jobs?source=todoist&list=Inbox", strings.NewReader(csv))
+	req.Header.Set("Authorization", "Bearer "+th.token)
+	rec := httptest.NewRecorder()
+	th.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		th.t.Fatalf("POST /api/import/jobs = %d %s", rec.Code, rec.Body)
+	}
+	location := rec.Header().Get("Location")
+	if !strings.HasPrefix(location, "/api/import/jobs/") {
+		th.t.Fatalf("POST /api/import/jobs returned Location %q", location)
+	}
+
+	// The job runs in the background; wait for it to finish
+	var job *database.ImportJob
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		th.call("GET", location, nil, http.StatusOK, &job)
+		if job.Status != database.ImportJobRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			th.t.Fatalf("import job still running: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// failingImportStore is a store whose ImportArchive fails for archives holding a
+// task with one of the texts in fail.
+type failingImportStore struct {
+	database.Store
+	fail map[string]bool
+}
+
+func (s failingImportStore) ImportArchive(ctx context.Context, userID int64, archive *database.Archive, opts database.ImportOptions) (*database.ImportReport, error) {
+	for _, task := range archive.Tasks {
+		if s.fail[task.Text] {
+			return nil, errors.New("import failed")
+		}
+	}
+	return s.Store.ImportArchive(ctx, userID, archive, opts)
+}
+
+func TestImportJob(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC))
+
+	job := th.importJob(todoistCSV(importBatchSize + 20))
+	if job.Status != database.ImportJobCompleted || job.Total != importBatchSize+20 || job.Processed != job.Total ||
+		job.Report == nil || job.Report.Tasks.Created != job.Total || job.Report.Lists.Created != 1 || job.FinishedAt == nil {
+		t.Fatalf("finished import job = %+v", job)
+	}
+
+	var tasks []*database.Task
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks) != job.Total || tasks[0].Text != "Task 1" || len(tasks[0].Tags) != 1 || tasks[0].Tags[0] != "imported" {
+		t.Fatalf("imported %d tasks, the first %+v", len(tasks), tasks[0])
+	}
+
+	th.call("GET", "/api/import/jobs/9999", nil, http.StatusNotFound, nil)
+	th.call("POST", "/api/import/jobs?source=unknown", nil, http.StatusBadRequest, nil)
+}
+
+func TestImportJobTaskErrors(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC))
+	th.db = failingImportStore{th.db, map[string]bool{"Task 7": true, fmt.Sprintf("Task %d", importBatchSize+3): true}}
+
+	// The tasks that cannot be imported are reported and the rest are imported
+	job := th.importJob(todoistCSV(importBatchSize + 20))
+	if job.Status != database.ImportJobCompleted || job.Processed != job.Total || job.Report == nil ||
+		job.Report.Tasks.Created != job.Total-2 || job.Report.Lists.Created != 1 || job.Error != "" {
+		t.Fatalf("finished import job = %+v", job)
+	}
+	want := []database.ImportItemError{
+		{Item: "row 8", Text: "Task 7", Message: "task could not be imported"},
+		{Item: fmt.Sprintf("row %d", importBatchSize+4), Text: fmt.Sprintf("Task %d", importBatchSize+3), Message: "task could not be imported"},
+	}
+	if fmt.Sprint(job.Errors) != fmt.Sprint(want) {
+		t.Fatalf("import job errors = %+v, want %+v", job.Errors, want)
+	}
+
+	var tasks []*database.Task
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks) != job.Total-2 || tasks[6].Text != "Task 8" {
+		t.Fatalf("imported %d tasks, the seventh %+v", len(tasks), tasks[6])
+	}
+
+	// A job none of whose tasks can be imported fails
+	job = th.importJob("TYPE,CONTENT,PRIORITY,INDENT\ntask,Task 7,4,1\n")
+	if job.Status != database.ImportJobFailed || job.Error != "failed to import tasks" || job.Report != nil ||
+		len(job.Errors) != 1 || job.Errors[0].Item != "row 2" {
+		t.Fatalf("failed import job = %+v", job)
+	}
+}
@@ -18,16 +18,39 @@ type UpdateListRequest struct {
 	Title string `json:"title"`
 }
 
-// handleGetLists returns all lists for the current user.
+// handleGetLists returns the current user's lists, ordered by title and paged by
+// the limit and cursor query parameters. When a page is full the cursor for the
+// next one is returned in the X-Next-Cursor header.
 func (h *Handler) handleGetLists(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(int64)
 
-	lists, err := h.db.GetLists(r.Context(), userID)
+	limit, cursor, err := parsePage(r.URL.Query())
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var filter database.ListFilter
+	if cursor != nil {
+		filter.After = &database.List{ID: cursor.ID, Title: cursor.Text}
+	}
+	if limit > 0 {
+		// Fetch one extra list to know whether another page follows
+		filter.Limit = limit + 1
+	}
+
+	lists, err := h.db.GetLists(r.Context(), userID, filter)
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "failed to get lists")
 		return
 	}
 
+	if limit > 0 && len(lists) > limit {
+		lists = lists[:limit]
+		last := lists[limit-1]
+		w.Header().Set("X-Next-Cursor", pageCursor{ID: last.ID, Text: last.Title}.encode())
+	}
+
 	// Return empty array instead of null
 	if lists == nil {
 		lists = []*database.List{}
@@ -63,10 +86,11 @@ func (h *Handler) handleCreateList(w http.ResponseWriter, r *http.Request) {
 		Payload: list,
 	})
 
-	h.jsonResponse(w, http.StatusCreated, list)
+	h.versionedResponse(w, http.StatusCreated, list.Version, list)
 }
 
-// handleUpdateList updates a list's title.
+// handleUpdateList updates a list's title. If the request carries an If-Match
+// header the update only applies to that version of the list.
 func (h *Handler) handleUpdateList(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(int64)
 
@@ -76,6 +100,12 @@ func (h *Handler) handleUpdateList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		h.ifMatchError(w, err)
+		return
+	}
+
 	var req UpdateListRequest
 	if err := h.decodeJSON(r, &req); err != nil {
 		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
@@ -87,7 +117,13 @@ func (h *Handler) handleUpdateList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	list, err := h.db.UpdateList(r.Context(), userID, listID, req.Title)
+	list, err := h.db.UpdateList(r.Context(), userID, listID, req.Title, version)
+	if errors.Is(err, database.ErrVersionConflict) {
+		if list, err = h.db.GetList(r.Context(), userID, listID); err == nil {
+			h.conflictResponse(w, list.Version, list)
+			return
+		}
+	}
 	if err != nil {
 		if errors.Is(err, database.ErrNotFound) {
 			h.errorResponse(w, http.StatusNotFound, "list not found")
@@ -103,7 +139,7 @@ func (h *Handler) handleUpdateList(w http.ResponseWriter, r *http.Request) {
 		Payload: list,
 	})
 
-	h.jsonResponse(w, http.StatusOK, list)
+	h.versionedResponse(w, http.StatusOK, list.Version, list)
 }
 
 // handleDeleteList deletes a list.
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/markdown"
+)
+
+// Markdown import heading handling.
+const (
+	headingsTags   = "tags"
+	headingsIgnore = "ignore"
+)
+
+// handleExportMarkdown downloads a list's tasks as a Markdown checklist, in sort
+// order with their tags and subtasks.
+func (h *Handler) handleExportMarkdown(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+	ctx := r.Context()
+
+	listID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid list id")
+		return
+	}
+	if _, err := h.db.GetList(ctx, userID, listID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "list not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get list")
+		return
+	}
+	tasks, err := h.db.GetUserTasks(ctx, userID, database.TaskFilter{ListID: &listID, Sort: database.TaskSortOrder})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+		return
+	}
+
+	items := make([]markdown.Task, len(tasks))
+	for i, task := range tasks {
+		items[i] = markdown.Task{Text: task.Text, Completed: task.Completed, Tags: task.Tags}
+		for _, subtask := range task.Subtasks {
+			items[i].Subtasks = append(items[i].Subtasks, markdown.Subtask{Text: subtask.Text, Completed: subtask.Completed})
+		}
+	}
+
+	filename := fmt.Sprintf("todomaster-list-%d.md", listID)
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, markdown.Format(items))
+}
+
+// handleImportMarkdown adds the checklist items in a Markdown document to a list
+// as tasks with subtasks, as a merge import into that list. Tags are matched to
+// the user's tags by name, with underscores for spaces, and the rest are
+// created. headings=ignore stops headings from becoming tags, and dryRun=true
+// reports what the import would do without changing anything.
+func (h *Handler) handleImportMarkdown(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+	ctx := r.Context()
+
+	listID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid list id")
+		return
+	}
+
+	params := r.URL.Query()
+	var opts markdown.Options
+	switch params.Get("headings") {
+	case "", headingsTags:
+	case headingsIgnore:
+		opts.IgnoreHeadings = true
+	default:
+		h.errorResponse(w, http.StatusBadRequest, "invalid headings: want tags or ignore")
+		return
+	}
+	var dryRun bool
+	if v := params.Get("dryRun"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "invalid dryRun")
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxArchiveSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.errorResponse(w, http.StatusRequestEntityTooLarge, "document is too large")
+			return
+		}
+		h.errorResponse(w, http.StatusBadRequest, "failed to read document")
+		return
+	}
+
+	if _, err := h.db.GetList(ctx, userID, listID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "list not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get list")
+		return
+	}
+	tags, err := h.db.GetTags(ctx, userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tags")
+		return
+	}
+	tagNames := make([]string, len(tags))
+	for i, tag := range tags {
+		tagNames[i] = tag.Name
+	}
+
+	archive := &database.Archive{
+		Format:  database.ArchiveFormat,
+		Version: database.ArchiveVersion,
+		Lists:   []*database.ArchiveList{},
+		Tasks:   []*database.ArchiveTask{},
+	}
+	for _, item := range markdown.Parse(string(trimBOM(body)), opts) {
+		task := &database.ArchiveTask{Text: item.Text, Completed: item.Completed}
+		for _, tag := range item.Tags {
+			task.Tags = append(task.Tags, matchWrittenName(tag, tagNames, markdown.Name))
+		}
+		for _, subtask := range item.Subtasks {
+			task.Subtasks = append(task.Subtasks, &database.ArchiveSubtask{Text: subtask.Text, Completed: subtask.Completed})
+		}
+		archive.Tasks = append(archive.Tasks, task)
+	}
+	if err := validateArchive(archive); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid document: "+err.Error())
+		return
+	}
+
+	report, err := h.db.ImportArchive(ctx, userID, archive, database.ImportOptions{DryRun: dryRun, ListID: &listID})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to import document")
+		return
+	}
+
+	if !dryRun {
+		h.hub.BroadcastToUser(userID, WebSocketEvent{
+			Type:    "data_imported",
+			Payload: map[string]string{"mode": importModeMerge},
+		})
+	}
+
+	h.jsonResponse(w, http.StatusOK, ImportResponse{Mode: importModeMerge, DryRun: dryRun, Report: report})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+// pageCursor is the decoded form of the opaque cursor returned in the
+// X-Next-Cursor header: the order a page was read in, and the ID and sort value
+// of its last row, which the next page starts after.
+type pageCursor struct {
+	Sort string     `json:"s,omitempty"`
+	Desc bool       `json:"d,omitempty"`
+	ID   int64      `json:"id"`
+	Int  int        `json:"i,omitempty"`
+	Text string     `json:"t,omitempty"`
+	Time *time.Time `json:"at,omitempty"`
+}
+
+// encode returns the cursor in its opaque form.
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageCursor reads a cursor in the form encode writes.
+func decodePageCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.ID <= 0 {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// parsePage reads the limit and cursor query parameters. Without either the
+// whole listing is returned and limit is 0; a cursor alone gets the default
+// page size.
+func parsePage(params url.Values) (limit int, cursor *pageCursor, err error) {
+	if v := params.Get("cursor"); v != "" {
+		c, err := decodePageCursor(v)
+		if err != nil {
+			return 0, nil, err
+		}
+		cursor = &c
+		limit = defaultPageLimit
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return 0, nil, errors.New("invalid limit")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+	return limit, cursor, nil
+}
+
+// taskCursor returns the cursor that continues a task listing after task.
+func taskCursor(filter database.TaskFilter, task *database.Task) pageCursor {
+	c := pageCursor{Sort: filter.Sort, Desc: filter.Desc, ID: task.ID}
+	switch filter.Sort {
+	case database.TaskSortCreated:
+		c.Time = &task.CreatedAt
+	case database.TaskSortUpdated:
+		c.Time = &task.UpdatedAt
+	case database.TaskSortDue:
+		if task.DueAt != nil {
+			c.Time = &task.DueAt.Time
+		}
+	case database.TaskSortText:
+		c.Text = task.Text
+	default:
+		c.Int = task.SortOrder
+	}
+	return c
+}
+
+// afterTask returns the position a task cursor continues from. The cursor must
+// have been issued for the same order.
+func (c pageCursor) afterTask(filter database.TaskFilter) (*database.Task, error) {
+	if c.Sort != filter.Sort || c.Desc != filter.Desc {
+		return nil, errors.New("invalid cursor")
+	}
+	task := &database.Task{ID: c.ID, SortOrder: c.Int, Text: c.Text}
+	switch filter.Sort {
+	case database.TaskSortCreated, database.TaskSortUpdated:
+		if c.Time == nil {
+			return nil, errors.New("invalid cursor")
+		}
+		task.CreatedAt, task.UpdatedAt = *c.Time, *c.Time
+	case database.TaskSortDue:
+		if c.Time != nil {
+			task.DueAt = &database.TaskDate{Time: *c.Time}
+		}
+	}
+	return task, nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/quickadd"
+)
+
+// applyQuickAdd parses the text of a quick-add request, moving the tags,
+// importance, list and due date it names into the request. Fields the request
+// sets itself take precedence, except that tags are added to its own.
+func (h *Handler) applyQuickAdd(ctx context.Context, userID int64, req *CreateTaskRequest) error {
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		return err
+	}
+	lists, err := h.db.GetLists(ctx, userID, database.ListFilter{})
+	if err != nil {
+		return err
+	}
+	titles := make([]string, len(lists))
+	for i, list := range lists {
+		titles[i] = list.Title
+	}
+
+	result := quickadd.Parse(req.Text, quickadd.Options{Now: h.now(), Location: loc, Lists: titles})
+
+	req.Text = result.Text
+	req.Important = req.Important || result.Important
+	for _, tag := range result.Tags {
+		if !slices.Contains(req.Tags, tag) {
+			req.Tags = append(req.Tags, tag)
+		}
+	}
+	if req.ListID == nil && result.List != "" {
+		for _, list := range lists {
+			if list.Title == result.List {
+				req.ListID = &list.ID
+				break
+			}
+		}
+	}
+	if req.DueAt == nil && result.Due != nil {
+		if result.DueAllDay {
+			y, m, d := result.Due.Date()
+			req.DueAt = &database.TaskDate{Time: time.Date(y, m, d, 0, 0, 0, 0, time.UTC), AllDay: true}
+		} else {
+			req.DueAt = &database.TaskDate{Time: result.Due.UTC()}
+		}
+	}
+	req.QuickAdd = false
+
+	return nil
+}
+
+// handleParseTask previews quick add: it parses a create request's text as
+// POST /api/tasks would with quickAdd set and returns the resulting request
+// without creating the task.
+func (h *Handler) handleParseTask(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var req CreateTaskRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Text == "" {
+		h.errorResponse(w, http.StatusBadRequest, "text is required")
+		return
+	}
+
+	if err := h.applyQuickAdd(r.Context(), userID, &req); err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to parse task")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, req)
+}
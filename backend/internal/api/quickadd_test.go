@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func TestQuickAdd(t *testing.T) {
+	// Tuesday, 10am in New York
+	th := newTestHandler(t, time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC))
+	th.call("PUT", "/api/user/me", map[string]string{"timezone": "America/New_York"}, http.StatusOK, nil)
+	var work, side database.List
+	th.call("POST", "/api/lists", map[string]string{"title": "Work"}, http.StatusCreated, &work)
+	th.call("POST", "/api/lists", map[string]string{"title": "Side Projects"}, http.StatusCreated, &side)
+
+	for _, tt := range []struct {
+		text      string
+		wantText  string
+		wantTags  []string
+		important bool
+		listID    int64
+		due       string
+	}{
+		{"Buy milk", "Buy milk", nil, false, 0, ""},
+		{"Call dentist today", "Call dentist", nil, false, 0, "2024-03-05"},
+		{"Call dentist tomorrow", "Call dentist", nil, false, 0, "2024-03-06"},
+		{"Review next friday", "Review", nil, false, 0, "2024-03-08"},
+		{"Call 3pm", "Call", nil, false, 0, "2024-03-05T20:00:00Z"},
+		{"Call 9am", "Call", nil, false, 0, "2024-03-06T14:00:00Z"},
+		{"Write docs @side projects #writing !", "Write docs", []string{"writing"}, true, side.ID, ""},
+		{"Email @bob", "Email @bob", nil, false, 0, ""},
+	} {
+		var req CreateTaskRequest
+		th.call("POST", "/api/tasks/parse", map[string]string{"text": tt.text}, http.StatusOK, &req)
+		var due string
+		if req.DueAt != nil {
+			due = req.DueAt.String()
+		}
+		if req.Text != tt.wantText || !slices.Equal(req.Tags, tt.wantTags) || req.Important != tt.important ||
+			(req.ListID == nil) != (tt.listID == 0) || req.ListID != nil && *req.ListID != tt.listID || due != tt.due || req.QuickAdd {
+			t.Errorf("parse %q = %+v due %q", tt.text, req, due)
+		}
+	}
+	th.call("POST", "/api/tasks/parse", map[string]string{"text": ""}, http.StatusBadRequest, nil)
+
+	// What the request sets itself wins, except that tags are added to
+	var task database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{
+		"text":     "Call dentist tomorrow 3pm #health ! @Side Projects",
+		"quickAdd": true,
+		"tags":     []string{"calls", "health"},
+		"listId":   work.ID,
+		"dueAt":    "2024-03-07",
+	}, http.StatusCreated, &task)
+	if task.Text != "Call dentist" || !slices.Equal(task.Tags, []string{"calls", "health"}) || !task.Important ||
+		task.ListID == nil || *task.ListID != work.ID || task.DueAt == nil || task.DueAt.String() != "2024-03-07" {
+		t.Fatalf("quick-added task = %+v", task)
+	}
+
+	// Without quickAdd the text is kept as typed
+	var plain database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "Call 3pm #health"}, http.StatusCreated, &plain)
+	if plain.Text != "Call 3pm #health" || len(plain.Tags) != 0 || plain.DueAt != nil {
+		t.Fatalf("task created without quick add = %+v", plain)
+	}
+
+	// Text that is all tokens leaves nothing to create
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "tomorrow #health", "quickAdd": true}, http.StatusBadRequest, nil)
+}
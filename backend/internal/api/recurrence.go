@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/recurrence"
+)
+
+// normalizeRecurrence validates a recurrence rule and repeat mode and returns the
+// rule in canonical form. An empty rule means the task does not repeat.
+func normalizeRecurrence(rule, repeatFrom string) (string, error) {
+	switch repeatFrom {
+	case "", database.RepeatFromDue, database.RepeatFromCompletion:
+	default:
+		return "", errors.New("invalid repeatFrom: want due or completion")
+	}
+	if rule == "" {
+		return "", nil
+	}
+	parsed, err := recurrence.Parse(rule)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// normalizeRecurrenceUpdates validates the recurrence and repeatFrom keys of an
+// UpdateTask updates map, rewriting the rule in canonical form.
+func normalizeRecurrenceUpdates(updates map[string]interface{}) error {
+	var repeatFrom string
+	if v, ok := updates["repeatFrom"]; ok && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			return errors.New("invalid repeatFrom: want due or completion")
+		}
+		repeatFrom = s
+	}
+	var rule string
+	if v, ok := updates["recurrence"]; ok && v != nil {
+		s, ok := v.(string)
+		if !ok {
+			return errors.New("invalid recurrence: want an RRULE string")
+		}
+		rule = s
+	}
+	normalized, err := normalizeRecurrence(rule, repeatFrom)
+	if err != nil {
+		return err
+	}
+	if _, ok := updates["recurrence"]; ok {
+		updates["recurrence"] = normalized
+	}
+	return nil
+}
+
+// occurrenceScheduler returns the function the store calls to schedule the next
+// occurrence of one of the user's recurring tasks as it is completed now. A rule
+// with a count carries on with one occurrence fewer.
+func (h *Handler) occurrenceScheduler(ctx context.Context, userID int64) (database.NextOccurrence, error) {
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := h.now()
+
+	return func(task *database.Task) (*database.Occurrence, error) {
+		rule, err := recurrence.Parse(task.Recurrence)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recurrence: %w", err)
+		}
+		if rule.Count == 1 {
+			return nil, nil
+		}
+		dueAt, startAt, ok := nextOccurrence(task, rule, now, loc)
+		if !ok {
+			return nil, nil
+		}
+		if rule.Count > 1 {
+			rule.Count--
+		}
+		return &database.Occurrence{DueAt: dueAt, StartAt: startAt, Recurrence: rule.String()}, nil
+	}, nil
+}
+
+// nextOccurrence computes the due and start dates of the occurrence after task.
+//
+// By default the series continues from the task's due date. When the task repeats
+// from completion, or has no due date, the next occurrence is the first one after
+// the day it was completed at now, keeping the due date's time of day. Timed dates
+// are computed in loc so they keep their local time across DST changes; all-day
+// dates are computed on the calendar. The start date, if any, keeps its offset
+// from the due date.
+func nextOccurrence(task *database.Task, rule *recurrence.Rule, now time.Time, loc *time.Location) (dueAt, startAt *database.TaskDate, ok bool) {
+	allDay := task.DueAt == nil || task.DueAt.AllDay
+
+	var anchor time.Time
+	switch {
+	case task.DueAt != nil && task.RepeatFrom != database.RepeatFromCompletion:
+		anchor = task.DueAt.Time
+		if !allDay {
+			anchor = anchor.In(loc)
+		}
+	case allDay:
+		y, m, d := now.In(loc).Date()
+		anchor = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	default:
+		due := task.DueAt.Time.In(loc)
+		y, m, d := now.In(loc).Date()
+		anchor = time.Date(y, m, d, due.Hour(), due.Minute(), due.Second(), due.Nanosecond(), loc)
+	}
+
+	next, ok := rule.Next(anchor, anchor)
+	if !ok {
+		return nil, nil, false
+	}
+	dueAt = &database.TaskDate{Time: next.UTC(), AllDay: allDay}
+
+	if task.StartAt != nil && task.DueAt != nil {
+		startAt = &database.TaskDate{
+			Time:   task.StartAt.Time.Add(dueAt.Time.Sub(task.DueAt.Time)),
+			AllDay: task.StartAt.AllDay,
+		}
+	}
+	return dueAt, startAt, true
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// complete completes a task and returns the occurrence it spawned, if any.
+func (th *testHandler) complete(task *database.Task) (completed, next *database.Task) {
+	th.t.Helper()
+	th.call("PUT", fmt.Sprintf("/api/tasks/%d", task.ID), map[string]interface{}{"completed": true}, http.StatusOK, &completed)
+
+	var tasks []*database.Task
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	for _, t := range tasks {
+		if !t.Completed && t.ID > task.ID {
+			if next != nil {
+				th.t.Fatalf("completing task %d spawned tasks %d and %d", task.ID, next.ID, t.ID)
+			}
+			next = t
+		}
+	}
+	return completed, next
+}
+
+// wantDue fails the test unless a task is due at due, in its API form.
+func wantDue(t *testing.T, task *database.Task, due string) {
+	t.Helper()
+	if task == nil {
+		t.Fatalf("no next occurrence, want one due %s", due)
+	}
+	if task.DueAt == nil || task.DueAt.String() != due {
+		t.Fatalf("next occurrence due %v, want %s", task.DueAt, due)
+	}
+}
+
+func TestRecurrenceWeeklyByDay(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC))
+
+	var task *database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{
+		"text":       "gym",
+		"dueAt":      "2024-03-04",
+		"recurrence": "FREQ=WEEKLY;BYDAY=MO,TH",
+	}, http.StatusCreated, &task)
+
+	// Monday, Thursday, then Monday of the next week
+	for _, due := range []string{"2024-03-07", "2024-03-11", "2024-03-14"} {
+		_, next := th.complete(task)
+		wantDue(t, next, due)
+		task = next
+	}
+}
+
+func TestRecurrenceRepeatFrom(t *testing.T) {
+	// Completed well after the task was due
+	now := time.Date(2024, 3, 20, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		dueAt      string
+		startAt    string
+		repeatFrom string
+		wantDue    string
+		wantStart  string
+	}{
+		{"due date", "2024-03-01", "", database.RepeatFromDue, "2024-03-08", ""},
+		{"completion date", "2024-03-01", "", database.RepeatFromCompletion, "2024-03-27", ""},
+		{"timed completion date", "2024-03-01T09:30:00Z", "", database.RepeatFromCompletion, "2024-03-27T09:30:00Z", ""},
+		{"no due date", "", "", database.RepeatFromDue, "2024-03-27", ""},
+		{"start date", "2024-03-01", "2024-02-28", database.RepeatFromDue, "2024-03-08", "2024-03-06"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t, now)
+			req := map[string]interface{}{"text": "water plants", "recurrence": "FREQ=WEEKLY", "repeatFrom": tt.repeatFrom}
+			if tt.dueAt != "" {
+				req["dueAt"] = tt.dueAt
+			}
+			if tt.startAt != "" {
+				req["startAt"] = tt.startAt
+			}
+			var task *database.Task
+			th.call("POST", "/api/tasks", req, http.StatusCreated, &task)
+
+			_, next := th.complete(task)
+			wantDue(t, next, tt.wantDue)
+			var start string
+			if next.StartAt != nil {
+				start = next.StartAt.String()
+			}
+			if start != tt.wantStart {
+				t.Fatalf("next occurrence starts %q, want %q", start, tt.wantStart)
+			}
+			if next.RepeatFrom != tt.repeatFrom {
+				t.Fatalf("next occurrence repeats from %q, want %q", next.RepeatFrom, tt.repeatFrom)
+			}
+		})
+	}
+}
+
+func TestRecurrenceCopiesTask(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC))
+
+	var list *database.List
+	th.call("POST", "/api/lists", map[string]interface{}{"title": "Home"}, http.StatusCreated, &list)
+	var task *database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{
+		"text":       "clean",
+		"listId":     list.ID,
+		"important":  true,
+		"tags":       []string{"chores", "home"},
+		"dueAt":      "2024-03-08",
+		"recurrence": "FREQ=WEEKLY;COUNT=2",
+	}, http.StatusCreated, &task)
+	var dropped *database.Subtask
+	for _, text := range []string{"kitchen", "garage", "bathroom"} {
+		th.call("POST", fmt.Sprintf("/api/tasks/%d/subtasks", task.ID), map[string]interface{}{"text": text}, http.StatusCreated, &dropped)
+		if text == "garage" {
+			th.call("DELETE", fmt.Sprintf("/api/subtasks/%d", dropped.ID), nil, http.StatusOK, nil)
+		}
+	}
+
+	completed, next := th.complete(task)
+	if !completed.Completed || completed.Recurrence != "" {
+		t.Fatalf("completed occurrence = %+v, want completed without a rule", completed)
+	}
+	wantDue(t, next, "2024-03-15")
+	if next.Text != "clean" || !next.Important || next.ListID == nil || *next.ListID != list.ID ||
+		next.Recurrence != "FREQ=WEEKLY;COUNT=1" {
+		t.Fatalf("next occurrence = %+v", next)
+	}
+	if fmt.Sprint(next.Tags) != "[chores home]" {
+		t.Fatalf("next occurrence has tags %v, want [chores home]", next.Tags)
+	}
+	var texts []string
+	for _, subtask := range next.Subtasks {
+		if subtask.Completed {
+			t.Fatalf("next occurrence has completed subtask %+v", subtask)
+		}
+		texts = append(texts, subtask.Text)
+	}
+	if fmt.Sprint(texts) != "[kitchen bathroom]" {
+		t.Fatalf("next occurrence has subtasks %v, want [kitchen bathroom]", texts)
+	}
+
+	// Completing the old occurrence again or the last one spawns nothing
+	th.call("PUT", fmt.Sprintf("/api/tasks/%d", task.ID), map[string]interface{}{"completed": false}, http.StatusOK, nil)
+	th.complete(task)
+	if _, last := th.complete(next); last != nil {
+		t.Fatalf("the last occurrence spawned %+v", last)
+	}
+	var tasks []*database.Task
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks) != 2 {
+		t.Fatalf("%d tasks at the end of the series, want 2", len(tasks))
+	}
+}
+
+func TestRecurrenceBatch(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC))
+
+	var task *database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{
+		"text":       "timesheet",
+		"dueAt":      "2024-03-08",
+		"recurrence": "FREQ=WEEKLY",
+	}, http.StatusCreated, &task)
+
+	// A move into a missing list fails the batch before anything spawns
+	var resp BatchTasksResponse
+	th.call("POST", "/api/tasks/batch", map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "complete", "taskId": task.ID},
+		{"op": "move", "taskId": task.ID, "listId": 9999},
+	}}, http.StatusNotFound, &resp)
+	if resp.Results[0].Status != batchSkipped || resp.Results[1].Status != batchFailed || resp.Results[1].Error != "list not found" {
+		t.Fatalf("batch with a missing list = %+v", resp)
+	}
+	var tasks []*database.Task
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks) != 1 || tasks[0].Completed {
+		t.Fatalf("tasks after a failed batch = %+v", tasks)
+	}
+
+	th.call("POST", "/api/tasks/batch", map[string]interface{}{"operations": []map[string]interface{}{
+		{"op": "complete", "taskId": task.ID, "version": task.Version},
+	}}, http.StatusOK, &resp)
+	if completed := resp.Results[0].Task; resp.Results[0].Status != batchOK || !completed.Completed || completed.Recurrence != "" {
+		t.Fatalf("batch result = %+v", resp.Results[0])
+	}
+	th.call("GET", "/api/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks) != 2 {
+		t.Fatalf("%d tasks after completing in a batch, want 2", len(tasks))
+	}
+	wantDue(t, tasks[1], "2024-03-15")
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+// handleSearch runs a full-text search over the current user's tasks.
+// Results can be scoped with the listId, completed and tag query parameters.
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+	params := r.URL.Query()
+
+	q := database.SearchQuery{
+		Text:  params.Get("q"),
+		Tag:   params.Get("tag"),
+		Limit: defaultSearchLimit,
+	}
+	if q.Text == "" {
+		h.errorResponse(w, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	if v := params.Get("listId"); v != "" {
+		listID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "invalid listId")
+			return
+		}
+		q.ListID = &listID
+	}
+	if v := params.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "invalid completed")
+			return
+		}
+		q.Completed = &completed
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			h.errorResponse(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if limit > maxSearchLimit {
+			limit = maxSearchLimit
+		}
+		q.Limit = limit
+	}
+
+	results, err := h.db.SearchTasks(r.Context(), userID, q)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to search tasks")
+		return
+	}
+
+	// Return empty array instead of null
+	if results == nil {
+		results = []*database.SearchResult{}
+	}
+
+	h.jsonResponse(w, http.StatusOK, results)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/query"
+)
+
+// maxQueryLength caps the length of a task query, in bytes.
+const maxQueryLength = 1000
+
+// CreateSmartListRequest is the request body for creating a smart list.
+type CreateSmartListRequest struct {
+	Title string `json:"title"`
+	Query string `json:"query"`
+}
+
+// UpdateSmartListRequest is the request body for updating a smart list. Fields
+// left out keep their current value.
+type UpdateSmartListRequest struct {
+	Title *string `json:"title"`
+	Query *string `json:"query"`
+}
+
+// parseQuery parses a task query, returning an error message fit for the client.
+func parseQuery(s string) (query.Expr, error) {
+	if len(s) > maxQueryLength {
+		return nil, errors.New("query is too long")
+	}
+	expr, err := query.Parse(s)
+	if err != nil {
+		return nil, errors.New("invalid query: " + err.Error())
+	}
+	return expr, nil
+}
+
+// parseQueryParam reads the task query in the q parameter, returning nil if
+// there is none.
+func parseQueryParam(params url.Values) (query.Expr, error) {
+	v := params.Get("q")
+	if v == "" {
+		return nil, nil
+	}
+	return parseQuery(v)
+}
+
+// handleGetSmartLists returns the current user's smart lists, ordered by title.
+func (h *Handler) handleGetSmartLists(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	lists, err := h.db.GetSmartLists(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get smart lists")
+		return
+	}
+
+	// Return empty array instead of null
+	if lists == nil {
+		lists = []*database.SmartList{}
+	}
+
+	h.jsonResponse(w, http.StatusOK, lists)
+}
+
+// handleCreateSmartList saves a task query as a smart list.
+func (h *Handler) handleCreateSmartList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var req CreateSmartListRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Title == "" {
+		h.errorResponse(w, http.StatusBadRequest, "title is required")
+		return
+	}
+	if req.Query == "" {
+		h.errorResponse(w, http.StatusBadRequest, "query is required")
+		return
+	}
+	if _, err := parseQuery(req.Query); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, err := h.db.CreateSmartList(r.Context(), userID, req.Title, req.Query)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create smart list")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "smart_list_created",
+		Payload: list,
+	})
+
+	h.versionedResponse(w, http.StatusCreated, list.Version, list)
+}
+
+// handleUpdateSmartList changes a smart list's title or query. If the request
+// carries an If-Match header the update only applies to that version of the
+// smart list.
+func (h *Handler) handleUpdateSmartList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	listID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid smart list id")
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		h.ifMatchError(w, err)
+		return
+	}
+
+	var req UpdateSmartListRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Title != nil && *req.Title == "" {
+		h.errorResponse(w, http.StatusBadRequest, "title is required")
+		return
+	}
+	if req.Query != nil {
+		if *req.Query == "" {
+			h.errorResponse(w, http.StatusBadRequest, "query is required")
+			return
+		}
+		if _, err := parseQuery(*req.Query); err != nil {
+			h.errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	current, err := h.db.GetSmartList(r.Context(), userID, listID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "smart list not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to update smart list")
+		return
+	}
+	title, text := current.Title, current.Query
+	if req.Title != nil {
+		title = *req.Title
+	}
+	if req.Query != nil {
+		text = *req.Query
+	}
+
+	list, err := h.db.UpdateSmartList(r.Context(), userID, listID, title, text, version)
+	if errors.Is(err, database.ErrVersionConflict) {
+		if list, err = h.db.GetSmartList(r.Context(), userID, listID); err == nil {
+			h.conflictResponse(w, list.Version, list)
+			return
+		}
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "smart list not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to update smart list")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "smart_list_updated",
+		Payload: list,
+	})
+
+	h.versionedResponse(w, http.StatusOK, list.Version, list)
+}
+
+// handleDeleteSmartList deletes a smart list. The tasks it matches are not
+// affected.
+func (h *Handler) handleDeleteSmartList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	listID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid smart list id")
+		return
+	}
+
+	if err := h.db.DeleteSmartList(r.Context(), userID, listID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "smart list not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to delete smart list")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "smart_list_deleted",
+		Payload: map[string]int64{"id": listID},
+	})
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "smart list deleted successfully",
+	})
+}
+
+// handleGetSmartListTasks evaluates a smart list. It takes the same parameters
+// and returns the same shape as GET /api/tasks; a q parameter narrows the smart
+// list's query further.
+func (h *Handler) handleGetSmartListTasks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	listID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid smart list id")
+		return
+	}
+
+	extra, err := parseQueryParam(r.URL.Query())
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, err := h.db.GetSmartList(r.Context(), userID, listID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "smart list not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+		return
+	}
+
+	expr, err := query.Parse(list.Query)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+		return
+	}
+	if extra != nil {
+		expr = query.And{expr, extra}
+	}
+
+	h.writeTasks(w, r, userID, expr)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// SyncResponse is everything that changed since the cursor a client sent.
+// Lists, tasks, subtasks, tags and smart lists are current copies to upsert;
+// Deleted holds the IDs to drop. Pass Cursor back as ?cursor= on the next sync.
+// When Reset is set the response is a full snapshot and the client should discard
+// its local data.
+type SyncResponse struct {
+	Cursor     string                `json:"cursor"`
+	Reset      bool                  `json:"reset"`
+	Lists      []*database.List      `json:"lists"`
+	Tasks      []*database.Task      `json:"tasks"`
+	Subtasks   []*database.Subtask   `json:"subtasks"`
+	Tags       []*database.Tag       `json:"tags"`
+	SmartLists []*database.SmartList `json:"smartLists"`
+	Deleted    database.SyncDeleted  `json:"deleted"`
+}
+
+// handleSync returns the current user's changes since the cursor query parameter,
+// or a full snapshot when there is none.
+func (h *Handler) handleSync(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var cursor int64
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		var err error
+		cursor, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 0 {
+			h.errorResponse(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	changes, err := h.db.GetChanges(r.Context(), userID, cursor)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get changes")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, SyncResponse{
+		Cursor:     strconv.FormatInt(changes.Cursor, 10),
+		Reset:      changes.Reset,
+		Lists:      changes.Lists,
+		Tasks:      changes.Tasks,
+		Subtasks:   changes.Subtasks,
+		Tags:       changes.Tags,
+		SmartLists: changes.SmartLists,
+		Deleted:    changes.Deleted,
+	})
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// tagColorPattern matches the colors a tag may have: #rgb or #rrggbb.
+var tagColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// CreateTagRequest is the request body for creating a tag.
+type CreateTagRequest struct {
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// UpdateTagRequest is the request body for updating a tag.
+// Fields left out of the body are not changed; an empty color or description clears it.
+type UpdateTagRequest struct {
+	Name        *string `json:"name"`
+	Color       *string `json:"color"`
+	Description *string `json:"description"`
+}
+
+// MergeTagRequest is the request body for merging a tag into another.
+type MergeTagRequest struct {
+	Into int64 `json:"into"`
+}
+
+// TagsChangedEvent is the payload of a tags_changed event: the user's tags after
+// the change and the IDs of the tasks whose tags changed with it.
+type TagsChangedEvent struct {
+	Tags    []*database.Tag `json:"tags"`
+	TaskIDs []int64         `json:"taskIds"`
+}
+
+// handleGetTags returns the current user's tags with their usage counts.
+func (h *Handler) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	tags, err := h.db.GetTags(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tags")
+		return
+	}
+
+	// Return empty array instead of null
+	if tags == nil {
+		tags = []*database.Tag{}
+	}
+
+	h.jsonResponse(w, http.StatusOK, tags)
+}
+
+// handleCreateTag creates a new tag.
+func (h *Handler) handleCreateTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	var req CreateTagRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		h.errorResponse(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if req.Color != "" && !tagColorPattern.MatchString(req.Color) {
+		h.errorResponse(w, http.StatusBadRequest, "invalid color")
+		return
+	}
+
+	tag, err := h.db.CreateTag(r.Context(), userID, &database.Tag{
+		Name:        req.Name,
+		Color:       req.Color,
+		Description: req.Description,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrTagExists) {
+			h.errorResponse(w, http.StatusConflict, "tag already exists")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to create tag")
+		return
+	}
+
+	h.broadcastTagsChanged(r.Context(), userID, nil)
+
+	h.jsonResponse(w, http.StatusCreated, tag)
+}
+
+// handleUpdateTag renames a tag or changes its color or description.
+func (h *Handler) handleUpdateTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	tagID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+
+	var req UpdateTagRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			h.errorResponse(w, http.StatusBadRequest, "name is required")
+			return
+		}
+		updates["name"] = name
+	}
+	if req.Color != nil {
+		if *req.Color != "" && !tagColorPattern.MatchString(*req.Color) {
+			h.errorResponse(w, http.StatusBadRequest, "invalid color")
+			return
+		}
+		updates["color"] = *req.Color
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	tag, taskIDs, err := h.db.UpdateTag(r.Context(), userID, tagID, updates)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "tag not found")
+			return
+		}
+		if errors.Is(err, database.ErrTagExists) {
+			h.errorResponse(w, http.StatusConflict, "tag already exists; merge the tags instead")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to update tag")
+		return
+	}
+
+	h.broadcastTagsChanged(r.Context(), userID, taskIDs)
+
+	h.jsonResponse(w, http.StatusOK, tag)
+}
+
+// handleMergeTag moves all tasks from one tag onto another and deletes the first.
+func (h *Handler) handleMergeTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	tagID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+
+	var req MergeTagRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Into == 0 || req.Into == tagID {
+		h.errorResponse(w, http.StatusBadRequest, "into must be another tag id")
+		return
+	}
+
+	tag, taskIDs, err := h.db.MergeTags(r.Context(), userID, tagID, req.Into)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "tag not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to merge tags")
+		return
+	}
+
+	h.broadcastTagsChanged(r.Context(), userID, taskIDs)
+
+	h.jsonResponse(w, http.StatusOK, tag)
+}
+
+// handleDeleteTag removes a tag from all tasks and deletes it.
+func (h *Handler) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	tagID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+
+	taskIDs, err := h.db.DeleteTag(r.Context(), userID, tagID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "tag not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to delete tag")
+		return
+	}
+
+	h.broadcastTagsChanged(r.Context(), userID, taskIDs)
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "tag deleted successfully",
+	})
+}
+
+// broadcastTagsChanged sends the user's current tags to their sessions along with
+// the IDs of the tasks that need refreshing.
+func (h *Handler) broadcastTagsChanged(ctx context.Context, userID int64, taskIDs []int64) {
+	tags, err := h.db.GetTags(ctx, userID)
+	if err != nil {
+		return
+	}
+	if tags == nil {
+		tags = []*database.Tag{}
+	}
+	if taskIDs == nil {
+		taskIDs = []int64{}
+	}
+
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "tags_changed",
+		Payload: TagsChangedEvent{Tags: tags, TaskIDs: taskIDs},
+	})
+}
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/query"
 )
 
 // CreateTaskRequest is the request body for creating a task.
@@ -15,6 +19,19 @@ type CreateTaskRequest struct {
 	Important bool     `json:"important,omitempty"`
 	Completed bool     `json:"completed,omitempty"`
 	ListID    *int64   `json:"listId,omitempty"`
+
+	// DueAt and StartAt take a YYYY-MM-DD date for all-day dates or an RFC 3339 timestamp.
+	DueAt   *database.TaskDate `json:"dueAt,omitempty"`
+	StartAt *database.TaskDate `json:"startAt,omitempty"`
+
+	// Recurrence is an RFC 5545 RRULE such as "FREQ=WEEKLY;BYDAY=MO,WE".
+	// RepeatFrom is "due" (the default) or "completion".
+	Recurrence string `json:"recurrence,omitempty"`
+	RepeatFrom string `json:"repeatFrom,omitempty"`
+
+	// QuickAdd parses tags, importance, a list and a due date out of Text; see
+	// package quickadd.
+	QuickAdd bool `json:"quickAdd,omitempty"`
 }
 
 // ReorderTasksRequest is the request body for reordering tasks.
@@ -22,21 +39,89 @@ type ReorderTasksRequest struct {
 	TaskIDs []int64 `json:"taskIds"`
 }
 
+// ReorderSubtasksRequest is the request body for reordering a task's subtasks.
+type ReorderSubtasksRequest struct {
+	SubtaskIDs []int64 `json:"subtaskIds"`
+}
+
 // CreateSubtaskRequest is the request body for creating a subtask.
 type CreateSubtaskRequest struct {
 	Text string `json:"text"`
 }
 
-// handleGetTasks returns all tasks for the current user.
+// handleGetTasks returns the current user's tasks, narrowed, sorted and paged by
+// the query parameters parseTaskFilter and parsePage read, and by a task query in
+// the q parameter. When a page is full the cursor for the next one is returned in
+// the X-Next-Cursor header.
 func (h *Handler) handleGetTasks(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(int64)
 
-	tasks, err := h.db.GetUserTasks(r.Context(), userID)
+	expr, err := parseQueryParam(r.URL.Query())
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.writeTasks(w, r, userID, expr)
+}
+
+// writeTasks responds with the user's tasks matching the request's filter and
+// page parameters and, unless it is nil, the query expr.
+func (h *Handler) writeTasks(w http.ResponseWriter, r *http.Request, userID int64, expr query.Expr) {
+	params := r.URL.Query()
+
+	loc := time.UTC
+	if hasDateFilter(params) || expr != nil {
+		var err error
+		loc, err = h.userLocation(r.Context(), userID)
+		if err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+			return
+		}
+	}
+	filter, err := parseTaskFilter(params, loc, h.now())
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if expr != nil {
+		// list: terms name lists by title
+		lists, err := h.db.GetLists(r.Context(), userID, database.ListFilter{})
+		if err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+			return
+		}
+		filter.Query = &database.TaskQuery{Expr: expr, Lists: lists, Now: filter.Now, Location: loc}
+	}
+
+	limit, cursor, err := parsePage(params)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if cursor != nil {
+		if filter.After, err = cursor.afterTask(filter); err != nil {
+			h.errorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if limit > 0 {
+		// Fetch one extra task to know whether another page follows
+		filter.Limit = limit + 1
+	}
+
+	tasks, err := h.db.GetUserTasks(r.Context(), userID, filter)
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
 		return
 	}
 
+	if limit > 0 && len(tasks) > limit {
+		tasks = tasks[:limit]
+		w.Header().Set("X-Next-Cursor", taskCursor(filter, tasks[limit-1]).encode())
+	}
+
 	// Return empty array instead of null
 	if tasks == nil {
 		tasks = []*database.Task{}
@@ -55,12 +140,35 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.QuickAdd {
+		if err := h.applyQuickAdd(r.Context(), userID, &req); err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "failed to create task")
+			return
+		}
+	}
+
 	if req.Text == "" {
 		h.errorResponse(w, http.StatusBadRequest, "text is required")
 		return
 	}
 
-	task, err := h.db.CreateTask(r.Context(), userID, req.ListID, req.Text, req.Tags, req.Important, req.Completed)
+	rule, err := normalizeRecurrence(req.Recurrence, req.RepeatFrom)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	task, err := h.db.CreateTask(r.Context(), userID, &database.Task{
+		ListID:     req.ListID,
+		Text:       req.Text,
+		Tags:       req.Tags,
+		Important:  req.Important,
+		Completed:  req.Completed,
+		DueAt:      req.DueAt,
+		StartAt:    req.StartAt,
+		Recurrence: rule,
+		RepeatFrom: req.RepeatFrom,
+	})
 	if err != nil {
 		h.errorResponse(w, http.StatusInternalServerError, "failed to create task")
 		return
@@ -72,7 +180,7 @@ func (h *Handler) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 		Payload: task,
 	})
 
-	h.jsonResponse(w, http.StatusCreated, task)
+	h.versionedResponse(w, http.StatusCreated, task.Version, task)
 }
 
 // handleGetTask returns a single task.
@@ -95,10 +203,11 @@ func (h *Handler) handleGetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.jsonResponse(w, http.StatusOK, task)
+	h.versionedResponse(w, http.StatusOK, task.Version, task)
 }
 
-// handleUpdateTask updates a task.
+// handleUpdateTask updates a task. If the request carries an If-Match header the
+// update only applies to that version of the task.
 func (h *Handler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(int64)
 
@@ -108,13 +217,55 @@ func (h *Handler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		h.ifMatchError(w, err)
+		return
+	}
+
 	var updates map[string]interface{}
 	if err := h.decodeJSON(r, &updates); err != nil {
 		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	for _, key := range []string{"dueAt", "startAt"} {
+		if v, ok := updates[key]; ok {
+			if _, err := database.TaskDateFromUpdate(v); err != nil {
+				h.errorResponse(w, http.StatusBadRequest, "invalid "+key)
+				return
+			}
+		}
+	}
+	if v, ok := updates["tags"]; ok {
+		if _, err := database.TaskTagsFromUpdate(v); err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "invalid tags")
+			return
+		}
+	}
+
+	if err := normalizeRecurrenceUpdates(updates); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	task, err := h.db.UpdateTask(r.Context(), userID, taskID, updates)
+	// Completing a recurring task schedules its next occurrence
+	var task, next *database.Task
+	if completed, _ := updates["completed"].(bool); completed {
+		var schedule database.NextOccurrence
+		if schedule, err = h.occurrenceScheduler(r.Context(), userID); err != nil {
+			h.errorResponse(w, http.StatusInternalServerError, "failed to update task")
+			return
+		}
+		task, next, err = h.db.CompleteRecurringTask(r.Context(), userID, taskID, updates, version, schedule)
+	} else {
+		task, err = h.db.UpdateTask(r.Context(), userID, taskID, updates, version)
+	}
+	if errors.Is(err, database.ErrVersionConflict) {
+		if task, err = h.db.GetTask(r.Context(), userID, taskID); err == nil {
+			h.conflictResponse(w, task.Version, task)
+			return
+		}
+	}
 	if err != nil {
 		if errors.Is(err, database.ErrNotFound) {
 			h.errorResponse(w, http.StatusNotFound, "task not found")
@@ -129,8 +280,14 @@ func (h *Handler) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		Type:    "task_updated",
 		Payload: task,
 	})
+	if next != nil {
+		h.hub.BroadcastToUser(userID, WebSocketEvent{
+			Type:    "task_created",
+			Payload: next,
+		})
+	}
 
-	h.jsonResponse(w, http.StatusOK, task)
+	h.versionedResponse(w, http.StatusOK, task.Version, task)
 }
 
 // handleDeleteTask deletes a task.
@@ -226,10 +383,11 @@ func (h *Handler) handleCreateSubtask(w http.ResponseWriter, r *http.Request) {
 		Payload: subtask,
 	})
 
-	h.jsonResponse(w, http.StatusCreated, subtask)
+	h.versionedResponse(w, http.StatusCreated, subtask.Version, subtask)
 }
 
-// handleUpdateSubtask updates a subtask.
+// handleUpdateSubtask updates a subtask. If the request carries an If-Match header
+// the update only applies to that version of the subtask.
 func (h *Handler) handleUpdateSubtask(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(int64)
 
@@ -239,13 +397,25 @@ func (h *Handler) handleUpdateSubtask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		h.ifMatchError(w, err)
+		return
+	}
+
 	var updates map[string]interface{}
 	if err := h.decodeJSON(r, &updates); err != nil {
 		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	subtask, err := h.db.UpdateSubtask(r.Context(), userID, subtaskID, updates)
+	subtask, err := h.db.UpdateSubtask(r.Context(), userID, subtaskID, updates, version)
+	if errors.Is(err, database.ErrVersionConflict) {
+		if subtask, err = h.db.GetSubtask(r.Context(), userID, subtaskID); err == nil {
+			h.conflictResponse(w, subtask.Version, subtask)
+			return
+		}
+	}
 	if err != nil {
 		if errors.Is(err, database.ErrNotFound) {
 			h.errorResponse(w, http.StatusNotFound, "subtask not found")
@@ -261,7 +431,7 @@ func (h *Handler) handleUpdateSubtask(w http.ResponseWriter, r *http.Request) {
 		Payload: subtask,
 	})
 
-	h.jsonResponse(w, http.StatusOK, subtask)
+	h.versionedResponse(w, http.StatusOK, subtask.Version, subtask)
 }
 
 // handleDeleteSubtask deletes a subtask.
@@ -293,3 +463,153 @@ func (h *Handler) handleDeleteSubtask(w http.ResponseWriter, r *http.Request) {
 		"message": "subtask deleted successfully",
 	})
 }
+
+// handleReorderSubtasks reorders a task's subtasks.
+func (h *Handler) handleReorderSubtasks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	taskID, err := strconv.ParseInt(r.PathValue("taskId"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	var req ReorderSubtasksRequest
+	if err := h.decodeJSON(r, &req); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.db.ReorderSubtasks(r.Context(), userID, taskID, req.SubtaskIDs); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "task not found")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to reorder subtasks")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "subtasks_reordered",
+		Payload: map[string]interface{}{"taskId": taskID, "subtaskIds": req.SubtaskIDs},
+	})
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "subtasks reordered successfully",
+	})
+}
+
+// hasDateFilter reports whether any date filter query parameter is set, which
+// needs the user's time zone to read.
+func hasDateFilter(params url.Values) bool {
+	for _, name := range []string{"dueAfter", "dueBefore", "overdue", "createdAfter", "createdBefore", "updatedAfter", "updatedBefore"} {
+		if params.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTaskFilter reads the task filter and sort query parameters. The date range
+// parameters accept a YYYY-MM-DD date, meaning midnight at the start of that day
+// in loc, or an RFC 3339 timestamp. listId is a list ID or "none" for tasks in no
+// list, and tag may be repeated, matching tasks with any of the tags unless
+// tagMatch is "all".
+func parseTaskFilter(params url.Values, loc *time.Location, now time.Time) (database.TaskFilter, error) {
+	filter := database.TaskFilter{Now: now, Location: loc, Sort: database.TaskSortOrder}
+
+	if v := params.Get("overdue"); v != "" {
+		overdue, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("invalid overdue")
+		}
+		filter.Overdue = overdue
+	}
+
+	if v := params.Get("listId"); v == "none" {
+		filter.NoList = true
+	} else if v != "" {
+		listID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid listId")
+		}
+		filter.ListID = &listID
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **bool
+	}{{"completed", &filter.Completed}, {"important", &filter.Important}} {
+		v := params.Get(p.name)
+		if v == "" {
+			continue
+		}
+		value, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("invalid " + p.name)
+		}
+		*p.dest = &value
+	}
+
+	filter.Tags = params["tag"]
+	switch params.Get("tagMatch") {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		return filter, errors.New("invalid tagMatch")
+	}
+
+	if v := params.Get("sort"); v != "" {
+		if !database.ValidTaskSort(v) {
+			return filter, errors.New("invalid sort")
+		}
+		filter.Sort = v
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, errors.New("invalid order")
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"dueAfter", &filter.DueAfter},
+		{"dueBefore", &filter.DueBefore},
+		{"createdAfter", &filter.CreatedAfter},
+		{"createdBefore", &filter.CreatedBefore},
+		{"updatedAfter", &filter.UpdatedAfter},
+		{"updatedBefore", &filter.UpdatedBefore},
+	} {
+		v := params.Get(p.name)
+		if v == "" {
+			continue
+		}
+		date, err := database.ParseTaskDate(v)
+		if err != nil {
+			return filter, errors.New("invalid " + p.name)
+		}
+		start, _ := date.Span(loc)
+		*p.dest = &start
+	}
+
+	return filter, nil
+}
+
+// userLocation returns the user's configured time zone, falling back to UTC.
+func (h *Handler) userLocation(ctx context.Context, userID int64) (*time.Location, error) {
+	user, err := h.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/todotxt"
+)
+
+// handleExportTodoTxt downloads the current user's tasks as a todo.txt file, one
+// line per task in sort order. Subtasks and recurrence rules are left out.
+func (h *Handler) handleExportTodoTxt(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+	ctx := r.Context()
+
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	lists, err := h.db.GetLists(ctx, userID, database.ListFilter{})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get lists")
+		return
+	}
+	tasks, err := h.db.GetUserTasks(ctx, userID, database.TaskFilter{Sort: database.TaskSortOrder})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tasks")
+		return
+	}
+
+	titles := make(map[int64]string, len(lists))
+	for _, list := range lists {
+		titles[list.ID] = list.Title
+	}
+	var buf bytes.Buffer
+	for _, task := range tasks {
+		line := todotxt.Task{
+			Text:      task.Text,
+			Completed: task.Completed,
+			Important: task.Important,
+			CreatedAt: inLocation(&task.CreatedAt, loc),
+			Tags:      task.Tags,
+			Due:       todoTxtDate(task.DueAt, loc),
+			Start:     todoTxtDate(task.StartAt, loc),
+		}
+		if task.Completed {
+			line.CompletedAt = inLocation(task.CompletedAt, loc)
+		}
+		if task.ListID != nil {
+			line.Project = titles[*task.ListID]
+		}
+		buf.WriteString(todotxt.Format(line))
+		buf.WriteByte('\n')
+	}
+
+	filename := fmt.Sprintf("todomaster-export-%s.txt", h.now().In(loc).Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// handleImportTodoTxt adds the tasks in a todo.txt file to the current user's
+// account, as a merge import of an archive built from its lines. Projects and
+// tags are matched to the user's lists and tags by name, with underscores for
+// spaces, and the rest are created. dryRun=true reports what the import would do
+// without changing anything.
+func (h *Handler) handleImportTodoTxt(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+	ctx := r.Context()
+
+	var dryRun bool
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			h.errorResponse(w, http.StatusBadRequest, "invalid dryRun")
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxArchiveSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.errorResponse(w, http.StatusRequestEntityTooLarge, "file is too large")
+			return
+		}
+		h.errorResponse(w, http.StatusBadRequest, "failed to read file")
+		return
+	}
+
+	loc, err := h.userLocation(ctx, userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	lists, err := h.db.GetLists(ctx, userID, database.ListFilter{})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get lists")
+		return
+	}
+	tags, err := h.db.GetTags(ctx, userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get tags")
+		return
+	}
+	listTitles := make([]string, len(lists))
+	for i, list := range lists {
+		listTitles[i] = list.Title
+	}
+	tagNames := make([]string, len(tags))
+	for i, tag := range tags {
+		tagNames[i] = tag.Name
+	}
+
+	archive := &database.Archive{
+		Format:  database.ArchiveFormat,
+		Version: database.ArchiveVersion,
+		Lists:   []*database.ArchiveList{},
+		Tasks:   []*database.ArchiveTask{},
+	}
+	listIDs := make(map[string]int64)
+	scanner := bufio.NewScanner(bytes.NewReader(trimBOM(body)))
+	scanner.Buffer(nil, maxArchiveSize)
+	for scanner.Scan() {
+		line, ok := todotxt.Parse(scanner.Text())
+		if !ok {
+			continue
+		}
+		task := &database.ArchiveTask{
+			Text:        line.Text,
+			Completed:   line.Completed,
+			Important:   line.Important,
+			CompletedAt: localDate(line.CompletedAt, loc),
+			DueAt:       allDayDate(line.Due),
+			StartAt:     allDayDate(line.Start),
+		}
+		if created := localDate(line.CreatedAt, loc); created != nil {
+			task.CreatedAt = *created
+		}
+		if line.Project != "" {
+			title := matchWrittenName(line.Project, listTitles, todotxt.Name)
+			id, ok := listIDs[title]
+			if !ok {
+				id = int64(len(archive.Lists) + 1)
+				listIDs[title] = id
+				archive.Lists = append(archive.Lists, &database.ArchiveList{ID: id, Title: title})
+			}
+			task.ListID = &id
+		}
+		for _, tag := range line.Tags {
+			task.Tags = append(task.Tags, matchWrittenName(tag, tagNames, todotxt.Name))
+		}
+		archive.Tasks = append(archive.Tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	if err := validateArchive(archive); err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid file: "+err.Error())
+		return
+	}
+
+	report, err := h.db.ImportArchive(ctx, userID, archive, database.ImportOptions{DryRun: dryRun})
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to import file")
+		return
+	}
+
+	if !dryRun {
+		h.hub.BroadcastToUser(userID, WebSocketEvent{
+			Type:    "data_imported",
+			Payload: map[string]string{"mode": importModeMerge},
+		})
+	}
+
+	h.jsonResponse(w, http.StatusOK, ImportResponse{Mode: importModeMerge, DryRun: dryRun, Report: report})
+}
+
+// matchWrittenName returns the name among names that a name read from a file
+// refers to: the same name, or else one that written would give it. A name
+// matching none is returned as given.
+func matchWrittenName(name string, names []string, written func(string) string) string {
+	for _, n := range names {
+		if n == name {
+			return n
+		}
+	}
+	for _, n := range names {
+		if written(n) == name {
+			return n
+		}
+	}
+	return name
+}
+
+// todoTxtDate returns the day of a task date: an all-day date's own, or a timed
+// date's in loc.
+func todoTxtDate(date *database.TaskDate, loc *time.Location) *time.Time {
+	if date == nil {
+		return nil
+	}
+	if date.AllDay {
+		return &date.Time
+	}
+	return inLocation(&date.Time, loc)
+}
+
+// inLocation returns t in loc, or nil if t is nil.
+func inLocation(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(loc)
+	return &local
+}
+
+// localDate returns midnight in loc of a todo.txt date, or nil if it is nil.
+func localDate(date *time.Time, loc *time.Location) *time.Time {
+	if date == nil {
+		return nil
+	}
+	y, m, d := date.Date()
+	local := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	return &local
+}
+
+// allDayDate returns a todo.txt date as an all-day task date, or nil if it is nil.
+func allDayDate(date *time.Time) *database.TaskDate {
+	if date == nil {
+		return nil
+	}
+	return &database.TaskDate{Time: *date, AllDay: true}
+}
+
+// trimBOM strips a UTF-8 byte order mark from the start of a file.
+func trimBOM(body []byte) []byte {
+	return bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// handleGetTrash returns the current user's deleted lists, tasks and subtasks.
+func (h *Handler) handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	trash, err := h.db.GetTrash(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to get trash")
+		return
+	}
+
+	h.jsonResponse(w, http.StatusOK, trash)
+}
+
+// handleEmptyTrash permanently deletes everything in the current user's trash.
+func (h *Handler) handleEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	count, err := h.db.EmptyTrash(r.Context(), userID)
+	if err != nil {
+		h.errorResponse(w, http.StatusInternalServerError, "failed to empty trash")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "trash_emptied",
+		Payload: map[string]int64{"count": count},
+	})
+
+	h.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"message": "trash emptied successfully",
+		"count":   count,
+	})
+}
+
+// handleRestoreTask restores a task from the trash.
+func (h *Handler) handleRestoreTask(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	taskID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	task, err := h.db.RestoreTask(r.Context(), userID, taskID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "task not found in trash")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to restore task")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "task_restored",
+		Payload: task,
+	})
+
+	h.jsonResponse(w, http.StatusOK, task)
+}
+
+// handlePurgeTask permanently deletes a task from the trash.
+func (h *Handler) handlePurgeTask(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	taskID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	if err := h.db.PurgeTask(r.Context(), userID, taskID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "task not found in trash")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to delete task")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "task_purged",
+		Payload: map[string]int64{"id": taskID},
+	})
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "task permanently deleted",
+	})
+}
+
+// handleRestoreSubtask restores a subtask from the trash.
+func (h *Handler) handleRestoreSubtask(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	subtaskID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid subtask id")
+		return
+	}
+
+	subtask, err := h.db.RestoreSubtask(r.Context(), userID, subtaskID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "subtask not found in trash")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to restore subtask")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "subtask_restored",
+		Payload: subtask,
+	})
+
+	h.jsonResponse(w, http.StatusOK, subtask)
+}
+
+// handlePurgeSubtask permanently deletes a subtask from the trash.
+func (h *Handler) handlePurgeSubtask(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	subtaskID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid subtask id")
+		return
+	}
+
+	if err := h.db.PurgeSubtask(r.Context(), userID, subtaskID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "subtask not found in trash")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to delete subtask")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "subtask_purged",
+		Payload: map[string]int64{"id": subtaskID},
+	})
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "subtask permanently deleted",
+	})
+}
+
+// handleRestoreList restores a list and the tasks deleted with it from the trash.
+func (h *Handler) handleRestoreList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	listID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid list id")
+		return
+	}
+
+	list, tasks, err := h.db.RestoreList(r.Context(), userID, listID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "list not found in trash")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to restore list")
+		return
+	}
+
+	if tasks == nil {
+		tasks = []*database.Task{}
+	}
+	payload := map[string]interface{}{
+		"list":  list,
+		"tasks": tasks,
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "list_restored",
+		Payload: payload,
+	})
+
+	h.jsonResponse(w, http.StatusOK, payload)
+}
+
+// handlePurgeList permanently deletes a list from the trash.
+func (h *Handler) handlePurgeList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	listID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errorResponse(w, http.StatusBadRequest, "invalid list id")
+		return
+	}
+
+	if err := h.db.PurgeList(r.Context(), userID, listID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			h.errorResponse(w, http.StatusNotFound, "list not found in trash")
+			return
+		}
+		h.errorResponse(w, http.StatusInternalServerError, "failed to delete list")
+		return
+	}
+
+	// Broadcast to other sessions
+	h.hub.BroadcastToUser(userID, WebSocketEvent{
+		Type:    "list_purged",
+		Payload: map[string]int64{"id": listID},
+	})
+
+	h.jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "list permanently deleted",
+	})
+}
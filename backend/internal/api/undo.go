@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// handleUndo undoes the current user's newest operation that has not been
+// undone. The inverse of its changes is applied in one transaction and
+// broadcast as the events the changes would have sent.
+func (h *Handler) handleUndo(w http.ResponseWriter, r *http.Request) {
+	h.stepOperation(w, r, true)
+}
+
+// handleRedo applies the operation the current user most recently undid again.
+func (h *Handler) handleRedo(w http.ResponseWriter, r *http.Request) {
+	h.stepOperation(w, r, false)
+}
+
+// stepOperation undoes or redoes an operation and responds with it.
+func (h *Handler) stepOperation(w http.ResponseWriter, r *http.Request, undo bool) {
+	userID := r.Context().Value(userIDKey).(int64)
+
+	step := h.db.RedoOperation
+	verb, done := "redo", "redone"
+	if undo {
+		step = h.db.UndoOperation
+		verb, done = "undo", "undone"
+	}
+
+	op, err := step(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+			h.errorResponse(w, http.StatusConflict, "nothing to "+verb)
+		case errors.Is(err, database.ErrStaleOperation):
+			h.errorResponse(w, http.StatusConflict, "the change can no longer be "+done)
+		default:
+			h.errorResponse(w, http.StatusInternalServerError, "failed to "+verb)
+		}
+		return
+	}
+
+	h.broadcastOperation(r.Context(), userID, op, undo)
+
+	h.jsonResponse(w, http.StatusOK, op)
+}
+
+// broadcastOperation sends the events for what undoing or redoing an operation
+// did to each of its items, in the order the changes were applied.
+func (h *Handler) broadcastOperation(ctx context.Context, userID int64, op *database.Operation, undo bool) {
+	for i := range op.Changes {
+		change := op.Changes[i]
+		if undo {
+			change = op.Changes[len(op.Changes)-1-i]
+		}
+
+		event, err := h.operationEvent(ctx, userID, change, undo)
+		if err != nil {
+			// The item changed again since; that change sent its own event
+			slog.Error("failed to load undone item", "entity", change.Entity, "id", change.ID, "error", err)
+			continue
+		}
+		h.hub.BroadcastToUser(userID, event)
+	}
+}
+
+// operationEvent returns the event for one change an undo or redo applied: the
+// event an update, delete or trash restore of the item sends.
+func (h *Handler) operationEvent(ctx context.Context, userID int64, change database.OperationChange, undo bool) (WebSocketEvent, error) {
+	effect, _ := change.Effect(undo)
+	if effect == database.HistoryDelete {
+		return WebSocketEvent{
+			Type:    change.Entity + "_deleted",
+			Payload: map[string]int64{"id": change.ID},
+		}, nil
+	}
+
+	eventType := change.Entity + "_updated"
+	if effect == database.HistoryRestore {
+		eventType = change.Entity + "_restored"
+	}
+
+	switch change.Entity {
+	case database.SyncTask:
+		task, err := h.db.GetTask(ctx, userID, change.ID)
+		if err != nil {
+			return WebSocketEvent{}, err
+		}
+		return WebSocketEvent{Type: eventType, Payload: task}, nil
+	case database.SyncSubtask:
+		subtask, err := h.db.GetSubtask(ctx, userID, change.ID)
+		if err != nil {
+			return WebSocketEvent{}, err
+		}
+		return WebSocketEvent{Type: eventType, Payload: subtask}, nil
+	default:
+		list, err := h.db.GetList(ctx, userID, change.ID)
+		if err != nil {
+			return WebSocketEvent{}, err
+		}
+		if effect == database.HistoryUpdate {
+			return WebSocketEvent{Type: eventType, Payload: list}, nil
+		}
+		tasks, err := h.db.GetUserTasks(ctx, userID, database.TaskFilter{ListID: &list.ID, Sort: database.TaskSortOrder})
+		if err != nil {
+			return WebSocketEvent{}, err
+		}
+		if tasks == nil {
+			tasks = []*database.Task{}
+		}
+		return WebSocketEvent{
+			Type:    eventType,
+			Payload: map[string]interface{}{"list": list, "tasks": tasks},
+		}, nil
+	}
+}
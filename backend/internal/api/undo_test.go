@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func TestUndo(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+
+	var task database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "report"}, http.StatusCreated, &task)
+	path := fmt.Sprintf("/api/tasks/%d", task.ID)
+
+	// Completing a task and then expanding it, as the task list does, leaves the
+	// complete undoable
+	th.call("PUT", path, map[string]interface{}{"completed": true}, http.StatusOK, nil)
+	th.call("PUT", path, map[string]interface{}{"isExpanded": true}, http.StatusOK, nil)
+	var op database.Operation
+	th.call("POST", "/api/undo", nil, http.StatusOK, &op)
+	if op.Action != database.OperationTaskUpdate || !op.Undone {
+		t.Fatalf("undone operation = %+v", op)
+	}
+	th.call("GET", path, nil, http.StatusOK, &task)
+	if task.Completed || !task.IsExpanded {
+		t.Fatalf("task after undoing a complete = %+v", task)
+	}
+	th.call("POST", "/api/redo", nil, http.StatusOK, &op)
+	th.call("GET", path, nil, http.StatusOK, &task)
+	if !task.Completed || !task.IsExpanded {
+		t.Fatalf("task after redoing a complete = %+v", task)
+	}
+	th.call("POST", "/api/redo", nil, http.StatusConflict, nil)
+
+	// Undoing the complete and then the create moves the task to the trash
+	th.call("POST", "/api/undo", nil, http.StatusOK, nil)
+	th.call("POST", "/api/undo", nil, http.StatusOK, &op)
+	if op.Action != database.OperationTaskCreate {
+		t.Fatalf("undone operation = %+v", op)
+	}
+	th.call("GET", path, nil, http.StatusNotFound, nil)
+	th.call("POST", "/api/undo", nil, http.StatusConflict, nil)
+	th.call("POST", "/api/redo", nil, http.StatusOK, nil)
+	th.call("GET", path, nil, http.StatusOK, &task)
+	if task.Text != "report" || task.Completed {
+		t.Fatalf("task after redoing its create = %+v", task)
+	}
+}
@@ -3,14 +3,17 @@ package api
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/todomaster-2010/backend/internal/database"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // UpdateUserRequest is the request body for updating user profile.
+// Fields left out of the body are not changed.
 type UpdateUserRequest struct {
-	DisplayName string `json:"displayName"`
+	DisplayName *string `json:"displayName"`
+	Timezone    *string `json:"timezone"`
 }
 
 // ChangePasswordRequest is the request body for changing password.
@@ -46,7 +49,28 @@ func (h *Handler) handleUpdateMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.db.UpdateUser(r.Context(), userID, req.DisplayName)
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			h.errorResponse(w, http.StatusBadRequest, "invalid timezone")
+			return
+		}
+		if err := h.db.UpdateUserTimezone(r.Context(), userID, *req.Timezone); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				h.errorResponse(w, http.StatusNotFound, "user not found")
+				return
+			}
+			h.errorResponse(w, http.StatusInternalServerError, "failed to update user")
+			return
+		}
+	}
+
+	var user *database.User
+	var err error
+	if req.DisplayName != nil {
+		user, err = h.db.UpdateUser(r.Context(), userID, *req.DisplayName)
+	} else {
+		user, err = h.db.GetUserByID(r.Context(), userID)
+	}
 	if err != nil {
 		if errors.Is(err, database.ErrNotFound) {
 			h.errorResponse(w, http.StatusNotFound, "user not found")
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ConflictResponse is the body of a 409 response to a write made against a stale
+// version: the error and the server's current copy of the resource.
+type ConflictResponse struct {
+	Error   string      `json:"error"`
+	Current interface{} `json:"current"`
+}
+
+// etag formats a row version as a strong entity tag.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// errWeakIfMatch is returned for an If-Match header with a weak entity tag, which
+// never matches: If-Match uses strong comparison (RFC 7232, section 3.1).
+var errWeakIfMatch = errors.New("If-Match requires a strong entity tag")
+
+// ifMatchVersion returns the version named by the request's If-Match header, or 0
+// when the header is absent or "*" and the write should not be checked.
+func ifMatchVersion(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	if strings.HasPrefix(value, "W/") {
+		return 0, errWeakIfMatch
+	}
+	value = strings.Trim(value, `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errors.New("invalid If-Match header")
+	}
+	return version, nil
+}
+
+// ifMatchError writes the response to an If-Match header ifMatchVersion refused:
+// 412 for a weak entity tag, which can't match, and 400 for a malformed one.
+func (h *Handler) ifMatchError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errWeakIfMatch) {
+		h.errorResponse(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	h.errorResponse(w, http.StatusBadRequest, err.Error())
+}
+
+// versionedResponse writes a JSON response carrying the resource's ETag.
+func (h *Handler) versionedResponse(w http.ResponseWriter, status int, version int64, data interface{}) {
+	w.Header().Set("ETag", etag(version))
+	h.jsonResponse(w, status, data)
+}
+
+// conflictResponse writes a 409 response with the server's current copy of a
+// resource whose version did not match the request's If-Match header.
+func (h *Handler) conflictResponse(w http.ResponseWriter, version int64, current interface{}) {
+	h.versionedResponse(w, http.StatusConflict, version, ConflictResponse{
+		Error:   "version conflict",
+		Current: current,
+	})
+}
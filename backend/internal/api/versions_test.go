@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func TestIfMatch(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC))
+
+	var task *database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "report"}, http.StatusCreated, &task)
+	path := fmt.Sprintf("/api/tasks/%d", task.ID)
+
+	put := func(ifMatch, text string, want int) *database.Task {
+		t.Helper()
+		rec := th.doWithHeader("PUT", path, map[string]interface{}{"text": text}, http.Header{"If-Match": {ifMatch}})
+		if rec.Code != want {
+			t.Fatalf("PUT with If-Match %s = %d %s, want %d", ifMatch, rec.Code, rec.Body, want)
+		}
+		if want != http.StatusOK {
+			return nil
+		}
+		var updated *database.Task
+		if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+			t.Fatalf("failed to decode response %s: %v", rec.Body, err)
+		}
+		if got, want := rec.Header().Get("ETag"), etag(updated.Version); got != want {
+			t.Fatalf("ETag = %s, want %s", got, want)
+		}
+		return updated
+	}
+
+	// A strong tag for the current version applies; a stale one conflicts
+	task = put(`"1"`, "Q3 report", http.StatusOK)
+	put(`"1"`, "stale", http.StatusConflict)
+
+	// Weak tags never match under strong comparison, even for the current version
+	put(fmt.Sprintf(`W/"%d"`, task.Version), "weak", http.StatusPreconditionFailed)
+	put(`W/"1"`, "weak", http.StatusPreconditionFailed)
+
+	put(`"x"`, "malformed", http.StatusBadRequest)
+	put(`"0"`, "zero", http.StatusBadRequest)
+	put("*", "any", http.StatusOK)
+
+	var got *database.Task
+	th.call("GET", path, nil, http.StatusOK, &got)
+	if got.Text != "any" {
+		t.Fatalf("task after If-Match writes = %q", got.Text)
+	}
+}
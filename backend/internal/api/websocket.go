@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -19,18 +20,50 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 }
 
+// eventLogSize is how many of a user's most recent events the hub keeps for replay.
+const eventLogSize = 256
+
+// maxMessageSize is the largest message a client may send, such as a task update.
+const maxMessageSize = 64 * 1024
+
 // WebSocketEvent represents an event broadcast to clients.
 type WebSocketEvent struct {
-	Type    string      `json:"type"`    // "task_created", "task_updated", "task_deleted", "tasks_reordered", etc.
-	Payload interface{} `json:"payload"` // The relevant data
+	Seq     int64       `json:"seq,omitempty"` // Per-user sequence number, set by BroadcastToUser
+	Type    string      `json:"type"`          // "task_created", "task_updated", "task_deleted", "tasks_reordered", etc.
+	Payload interface{} `json:"payload"`       // The relevant data
+}
+
+// ResyncRequiredEvent is the payload of a resync_required event, sent to a client
+// reconnecting with a sequence number whose missed events are no longer kept.
+// The client should refetch its data (see GET /api/sync) and continue from Seq.
+type ResyncRequiredEvent struct {
+	Seq int64 `json:"seq"`
 }
 
-// Client represents a connected WebSocket client.
+// Client represents a connected WebSocket client, or an event stream client from
+// GET /api/events, which has no conn and only receives.
 type Client struct {
-	hub    *Hub
-	conn   *websocket.Conn
-	userID int64
-	send   chan []byte
+	hub     *Hub
+	handler *Handler
+	conn    *websocket.Conn
+	userID  int64
+	token   string
+	send    chan []byte
+
+	// lastSeq is the sequence number of the last event queued for the client;
+	// older events still on their way through the hub are skipped. Only the
+	// hub's Run loop touches it after registration.
+	lastSeq int64
+
+	// resume is set when the client reconnected with ?since= and wants the
+	// events after lastSeq replayed.
+	resume bool
+}
+
+// eventLog holds a user's most recent events, oldest first.
+type eventLog struct {
+	seq    int64 // sequence number of the last event
+	events []*broadcastMessage
 }
 
 // Hub maintains the set of active clients and broadcasts messages to clients.
@@ -48,11 +81,25 @@ type Hub struct {
 	unregister chan *Client
 
 	mu sync.RWMutex
+
+	// Recent events per userID, for replay to reconnecting clients. Only the Run
+	// loop touches them, so events are numbered in the order clients get them.
+	logs map[int64]*eventLog
 }
 
 type broadcastMessage struct {
 	userID  int64
+	seq     int64
 	message []byte
+
+	// event is set on an event from BroadcastToUser until Run numbers it and
+	// encodes it into message.
+	event *WebSocketEvent
+
+	// client is set on a reply meant for that client alone. Replies go through
+	// the same queue as events so a client sees the events its request caused
+	// before the request's ack.
+	client *Client
 }
 
 // NewHub creates a new hub.
@@ -62,6 +109,7 @@ func NewHub() *Hub {
 		broadcast:  make(chan *broadcastMessage, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		logs:       make(map[int64]*eventLog),
 	}
 }
 
@@ -76,6 +124,9 @@ func (h *Hub) Run() {
 			}
 			h.clients[client.userID][client] = true
 			h.mu.Unlock()
+			if client.resume {
+				h.replay(client)
+			}
 			slog.Info("ws client connected", "userID", client.userID)
 
 		case client := <-h.unregister:
@@ -93,13 +144,23 @@ func (h *Hub) Run() {
 			slog.Info("ws client disconnected", "userID", client.userID)
 
 		case msg := <-h.broadcast:
+			if msg.event != nil && !h.record(msg) {
+				continue
+			}
+
 			h.mu.RLock()
 			clients := h.clients[msg.userID]
 			h.mu.RUnlock()
 
 			for client := range clients {
+				if !client.wants(msg) {
+					continue
+				}
 				select {
 				case client.send <- msg.message:
+					if msg.seq > client.lastSeq {
+						client.lastSeq = msg.seq
+					}
 				default:
 					// Client's send buffer is full, close connection
 					h.mu.Lock()
@@ -113,27 +174,108 @@ func (h *Hub) Run() {
 }
 
 // BroadcastToUser sends a message to all connections for a specific user.
+// The hub gives the event the user's next sequence number and keeps it for
+// replay. The payload is encoded right away, so the caller may change it after.
 func (h *Hub) BroadcastToUser(userID int64, event WebSocketEvent) {
-	data, err := json.Marshal(event)
+	payload, err := json.Marshal(event.Payload)
 	if err != nil {
 		slog.Error("failed to marshal ws event", "error", err)
 		return
 	}
+	event.Payload = json.RawMessage(payload)
 
 	h.broadcast <- &broadcastMessage{
-		userID:  userID,
-		message: data,
+		userID: userID,
+		event:  &event,
 	}
 }
 
-// readPump pumps messages from the WebSocket connection to the hub.
+// record numbers an event from BroadcastToUser with the user's next sequence
+// number, encodes it and adds it to the user's log. It reports false if the
+// event couldn't be encoded. Only called from Run.
+func (h *Hub) record(msg *broadcastMessage) bool {
+	log := h.logs[msg.userID]
+	if log == nil {
+		log = &eventLog{}
+		h.logs[msg.userID] = log
+	}
+
+	msg.event.Seq = log.seq + 1
+	data, err := json.Marshal(msg.event)
+	if err != nil {
+		slog.Error("failed to marshal ws event", "error", err)
+		return false
+	}
+	msg.seq, msg.message, msg.event = msg.event.Seq, data, nil
+
+	log.seq = msg.seq
+	if len(log.events) == eventLogSize {
+		copy(log.events, log.events[1:])
+		log.events = log.events[:eventLogSize-1]
+	}
+	log.events = append(log.events, msg)
+	return true
+}
+
+// reply sends a message to one client, if it is still connected.
+func (h *Hub) reply(client *Client, message []byte) {
+	h.broadcast <- &broadcastMessage{
+		userID:  client.userID,
+		message: message,
+		client:  client,
+	}
+}
+
+// wants reports whether msg should be sent to the client: it is either a reply to
+// the client or an event the client hasn't been sent yet.
+func (c *Client) wants(msg *broadcastMessage) bool {
+	if msg.client != nil {
+		return msg.client == c
+	}
+	return msg.seq > c.lastSeq
+}
+
+// replay queues the events a reconnecting client missed after its lastSeq, in
+// order. If some of them are no longer kept, or the client claims to have seen
+// events this hub never sent (say, before a server restart), it gets a
+// resync_required event instead. Only called from Run.
+func (h *Hub) replay(client *Client) {
+	var latest int64
+	var events []*broadcastMessage
+	if log := h.logs[client.userID]; log != nil {
+		latest = log.seq
+		events = log.events
+	}
+
+	since := client.lastSeq
+	if since > latest || (len(events) > 0 && since < events[0].seq-1) {
+		data, _ := json.Marshal(WebSocketEvent{
+			Type:    "resync_required",
+			Payload: ResyncRequiredEvent{Seq: latest},
+		})
+		client.send <- data
+		client.lastSeq = latest
+		return
+	}
+
+	// The send buffer is at least eventLogSize long and the client is new,
+	// so the whole log fits.
+	for _, msg := range events {
+		if msg.seq > since {
+			client.send <- msg.message
+			client.lastSeq = msg.seq
+		}
+	}
+}
+
+// readPump reads requests from the WebSocket connection and handles them in order.
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(512)
+	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -141,14 +283,14 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				slog.Error("ws read error", "error", err)
 			}
 			break
 		}
-		// We don't process incoming messages for now, just keep connection alive
+		c.handler.handleWebSocketRequest(c, message)
 	}
 }
 
@@ -212,6 +354,17 @@ func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A reconnecting client passes the last sequence number it saw
+	var since int64
+	resume := r.URL.Query().Has("since")
+	if resume {
+		since, err = strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+		if err != nil || since < 0 {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		slog.Error("ws upgrade failed", "error", err)
@@ -219,10 +372,14 @@ func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		hub:    h.hub,
-		conn:   conn,
-		userID: userID,
-		send:   make(chan []byte, 256),
+		hub:     h.hub,
+		handler: h,
+		conn:    conn,
+		userID:  userID,
+		token:   tokenString,
+		send:    make(chan []byte, eventLogSize+1),
+		lastSeq: since,
+		resume:  resume,
 	}
 
 	h.hub.register <- client
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hubClient registers an event stream client with the hub, as GET /api/events
+// does, resuming after since unless it is negative.
+func hubClient(t *testing.T, hub *Hub, userID, since int64) *Client {
+	t.Helper()
+	client := &Client{
+		hub:    hub,
+		userID: userID,
+		send:   make(chan []byte, eventLogSize+1),
+	}
+	if since >= 0 {
+		client.lastSeq, client.resume = since, true
+	}
+	hub.register <- client
+	return client
+}
+
+// nextEvent waits for the next message sent to a client and decodes it.
+func nextEvent(t *testing.T, client *Client) WebSocketEvent {
+	t.Helper()
+	select {
+	case message, ok := <-client.send:
+		if !ok {
+			t.Fatal("hub dropped the client")
+		}
+		var event WebSocketEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			t.Fatalf("failed to decode event %s: %v", message, err)
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return WebSocketEvent{}
+}
+
+// wantNoEvent fails the test if the client has been sent anything.
+func wantNoEvent(t *testing.T, client *Client) {
+	t.Helper()
+	// Anything queued before this marker has reached the client
+	client.hub.reply(client, []byte(`{"type":"marker"}`))
+	if event := nextEvent(t, client); event.Type != "marker" {
+		t.Fatalf("got unexpected event %+v", event)
+	}
+}
+
+// flush waits until the hub has handled everything queued so far, by sending a
+// reply through it to a client of its own.
+func flush(t *testing.T, hub *Hub) {
+	t.Helper()
+	wantNoEvent(t, hubClient(t, hub, -1, -1))
+}
+
+func TestHubReplay(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	live := hubClient(t, hub, 1, -1)
+	other := hubClient(t, hub, 2, -1)
+	for i := 0; i < 5; i++ {
+		hub.BroadcastToUser(1, WebSocketEvent{Type: "task_updated", Payload: map[string]int{"id": i}})
+	}
+	for want := int64(1); want <= 5; want++ {
+		if event := nextEvent(t, live); event.Seq != want || event.Type != "task_updated" {
+			t.Fatalf("live event = %+v, want seq %d", event, want)
+		}
+	}
+	wantNoEvent(t, other)
+
+	// A reconnecting client gets the events after the one it last saw, in order,
+	// and then live ones
+	resumed := hubClient(t, hub, 1, 2)
+	for want := int64(3); want <= 5; want++ {
+		if event := nextEvent(t, resumed); event.Seq != want {
+			t.Fatalf("replayed event seq = %d, want %d", event.Seq, want)
+		}
+	}
+	hub.BroadcastToUser(1, WebSocketEvent{Type: "task_deleted"})
+	if event := nextEvent(t, resumed); event.Seq != 6 || event.Type != "task_deleted" {
+		t.Fatalf("event after a replay = %+v", event)
+	}
+
+	// Up to date, nothing is replayed
+	current := hubClient(t, hub, 1, 6)
+	wantNoEvent(t, current)
+
+	// Another user's numbering is separate
+	hub.BroadcastToUser(2, WebSocketEvent{Type: "task_created"})
+	if event := nextEvent(t, other); event.Seq != 1 {
+		t.Fatalf("another user's first event seq = %d, want 1", event.Seq)
+	}
+}
+
+func TestHubResyncRequired(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+
+	for i := 0; i < eventLogSize+10; i++ {
+		hub.BroadcastToUser(1, WebSocketEvent{Type: "task_updated"})
+	}
+	latest := int64(eventLogSize + 10)
+	flush(t, hub)
+
+	for _, tt := range []struct {
+		name   string
+		since  int64
+		resync bool
+	}{
+		{"just before the log", 10, false},
+		{"older than the log", 5, true},
+		{"ahead of the hub", latest + 3, true},
+	} {
+		client := hubClient(t, hub, 1, tt.since)
+		event := nextEvent(t, client)
+		if !tt.resync {
+			if event.Type != "task_updated" || event.Seq != tt.since+1 {
+				t.Fatalf("%s: first event = %+v, want seq %d", tt.name, event, tt.since+1)
+			}
+			continue
+		}
+		if event.Type != "resync_required" || event.Seq != 0 {
+			t.Fatalf("%s: first event = %+v, want resync_required", tt.name, event)
+		}
+		var resync ResyncRequiredEvent
+		data, _ := json.Marshal(event.Payload)
+		if err := json.Unmarshal(data, &resync); err != nil || resync.Seq != latest {
+			t.Fatalf("%s: resync payload = %s, want seq %d", tt.name, data, latest)
+		}
+
+		// After a resync the client continues with live events
+		wantNoEvent(t, client)
+		hub.BroadcastToUser(1, WebSocketEvent{Type: "task_created"})
+		latest++
+		if event := nextEvent(t, client); event.Seq != latest {
+			t.Fatalf("%s: event after a resync = %+v, want seq %d", tt.name, event, latest)
+		}
+	}
+}
+
+func TestHubConcurrentBroadcasts(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	client := hubClient(t, hub, 1, -1)
+
+	// Fewer events than a client's send buffer holds, so a slow reader isn't
+	// dropped
+	const senders, perSender = 8, eventLogSize / 8
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				hub.BroadcastToUser(1, WebSocketEvent{Type: "task_updated"})
+			}
+		}()
+	}
+
+	// Every event arrives, numbered in the order it arrives
+	for want := int64(1); want <= senders*perSender; want++ {
+		if event := nextEvent(t, client); event.Seq != want {
+			t.Fatalf("event seq = %d, want %d", event.Seq, want)
+		}
+	}
+	wg.Wait()
+	wantNoEvent(t, client)
+
+	// The log replays the newest events in the same order
+	resumed := hubClient(t, hub, 1, senders*perSender-3)
+	for want := int64(senders*perSender - 2); want <= senders*perSender; want++ {
+		if event := nextEvent(t, resumed); event.Seq != want {
+			t.Fatalf("replayed event seq = %d, want %d", event.Seq, want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// WebSocketRequest is a mutation a client sends over its WebSocket connection.
+// Data is the body the equivalent REST call takes, ID fills its path parameter
+// and Version, if set, is sent as its If-Match header.
+type WebSocketRequest struct {
+	RequestID string          `json:"requestId"`
+	Action    string          `json:"action"`            // "task.create", "task.update", "list.delete", etc.
+	ID        int64           `json:"id,omitempty"`      // Target task, subtask or list; the task for subtask.create and subtask.reorder
+	Version   int64           `json:"version,omitempty"` // Expected version for updates
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// WebSocketResponse answers a WebSocketRequest with the status code and body the
+// equivalent REST call would have returned.
+type WebSocketResponse struct {
+	Type      string          `json:"type"` // "ack" or "error"
+	RequestID string          `json:"requestId"`
+	Status    int             `json:"status"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsAction maps a WebSocket action onto the REST handler that performs it.
+type wsAction struct {
+	method string
+	path   string // REST path; {param} is replaced by the request's ID
+	param  string // path parameter filled from the request's ID, if any
+	handle func(*Handler, http.ResponseWriter, *http.Request)
+}
+
+// wsActions are the mutations clients may send over the WebSocket connection.
+var wsActions = map[string]wsAction{
+	"task.create":  {"POST", "/api/tasks", "", (*Handler).handleCreateTask},
+	"task.update":  {"PUT", "/api/tasks/{id}", "id", (*Handler).handleUpdateTask},
+	"task.delete":  {"DELETE", "/api/tasks/{id}", "id", (*Handler).handleDeleteTask},
+	"task.reorder": {"POST", "/api/tasks/reorder", "", (*Handler).handleReorderTasks},
+	"task.batch":   {"POST", "/api/tasks/batch", "", (*Handler).handleBatchTasks},
+
+	"subtask.create":  {"POST", "/api/tasks/{taskId}/subtasks", "taskId", (*Handler).handleCreateSubtask},
+	"subtask.update":  {"PUT", "/api/subtasks/{id}", "id", (*Handler).handleUpdateSubtask},
+	"subtask.delete":  {"DELETE", "/api/subtasks/{id}", "id", (*Handler).handleDeleteSubtask},
+	"subtask.reorder": {"POST", "/api/tasks/{taskId}/subtasks/reorder", "taskId", (*Handler).handleReorderSubtasks},
+
+	"list.create": {"POST", "/api/lists", "", (*Handler).handleCreateList},
+	"list.update": {"PUT", "/api/lists/{id}", "id", (*Handler).handleUpdateList},
+	"list.delete": {"DELETE", "/api/lists/{id}", "id", (*Handler).handleDeleteList},
+}
+
+// capturedResponse captures the response of a REST handler run by runAction.
+type capturedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *capturedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *capturedResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *capturedResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// handleWebSocketRequest runs a client's request through the REST handler for its
+// action, so it gets the same validation, authorization and broadcasts, and
+// replies to the client alone with the result.
+func (h *Handler) handleWebSocketRequest(client *Client, message []byte) {
+	var req WebSocketRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		h.hub.reply(client, wsError(req.RequestID, http.StatusBadRequest, "invalid request"))
+		return
+	}
+
+	// The socket was authenticated when it connected; requests stop being
+	// accepted once that token expires, as they would over HTTP.
+	if _, err := h.validateToken(client.token); err != nil {
+		h.hub.reply(client, wsError(req.RequestID, http.StatusUnauthorized, "invalid or expired token"))
+		return
+	}
+
+	action, ok := wsActions[req.Action]
+	if !ok {
+		h.hub.reply(client, wsError(req.RequestID, http.StatusBadRequest, "unknown action"))
+		return
+	}
+	if action.param != "" && req.ID <= 0 {
+		h.hub.reply(client, wsError(req.RequestID, http.StatusBadRequest, "id is required"))
+		return
+	}
+
+	slog.Info("ws request", "action", req.Action, "id", req.ID, "userID", client.userID)
+
+	// Handlers run on the connection's read goroutine, where a panic would take
+	// down the server rather than just this request as net/http does
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Error("ws request panicked", "action", req.Action, "id", req.ID, "userID", client.userID,
+				"panic", p, "stack", string(debug.Stack()))
+			h.hub.reply(client, wsError(req.RequestID, http.StatusInternalServerError, "failed to handle request"))
+		}
+	}()
+
+	w, err := h.runAction(context.Background(), client.userID, action, req.ID, req.Version, req.Data)
+	if err != nil {
+		h.hub.reply(client, wsError(req.RequestID, http.StatusInternalServerError, "failed to handle request"))
+		return
+	}
+
+	resp := WebSocketResponse{
+		Type:      "ack",
+		RequestID: req.RequestID,
+		Status:    w.status,
+		Payload:   bytes.TrimSpace(w.body.Bytes()),
+	}
+	if w.status >= 400 {
+		resp.Type = "error"
+	}
+	data, _ := json.Marshal(resp)
+	h.hub.reply(client, data)
+}
+
+// runAction runs an action's REST handler as the given user, filling its path
+// parameter with id and, if version is set, sending it as the If-Match header.
+// The response is captured rather than sent.
+func (h *Handler) runAction(ctx context.Context, userID int64, action wsAction, id, version int64, body []byte) (*capturedResponse, error) {
+	path := action.path
+	if action.param != "" {
+		path = strings.Replace(path, "{"+action.param+"}", strconv.FormatInt(id, 10), 1)
+	}
+
+	ctx = context.WithValue(ctx, userIDKey, userID)
+	r, err := http.NewRequestWithContext(ctx, action.method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if action.param != "" {
+		r.SetPathValue(action.param, strconv.FormatInt(id, 10))
+	}
+	if version != 0 {
+		r.Header.Set("If-Match", etag(version))
+	}
+
+	w := &capturedResponse{header: make(http.Header)}
+	action.handle(h, w, r)
+	return w, nil
+}
+
+// wsError encodes an error reply shaped like errorResponse's body.
+func wsError(requestID string, status int, message string) []byte {
+	payload, _ := json.Marshal(map[string]string{"error": message})
+	data, _ := json.Marshal(WebSocketResponse{
+		Type:      "error",
+		RequestID: requestID,
+		Status:    status,
+		Payload:   payload,
+	})
+	return data
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// wsClient registers a WebSocket client for the test user, signed in with token.
+func (th *testHandler) wsClient(token string) *Client {
+	th.t.Helper()
+	client := &Client{
+		hub:     th.hub,
+		handler: th.Handler,
+		userID:  th.user.ID,
+		token:   token,
+		send:    make(chan []byte, eventLogSize+1),
+	}
+	th.hub.register <- client
+	return client
+}
+
+// wsRequest sends a request over a client's connection and returns the reply to
+// it, skipping the events broadcast before it.
+func (th *testHandler) wsRequest(client *Client, message string) WebSocketResponse {
+	th.t.Helper()
+	th.handleWebSocketRequest(client, []byte(message))
+	for {
+		select {
+		case data, ok := <-client.send:
+			if !ok {
+				th.t.Fatal("hub dropped the client")
+			}
+			var resp WebSocketResponse
+			if err := json.Unmarshal(data, &resp); err != nil {
+				th.t.Fatalf("failed to decode message %s: %v", data, err)
+			}
+			if resp.Type == "ack" || resp.Type == "error" {
+				return resp
+			}
+		case <-time.After(5 * time.Second):
+			th.t.Fatalf("timed out waiting for a reply to %s", message)
+		}
+	}
+}
+
+// wantWSError fails the test unless resp is an error reply with status and message.
+func wantWSError(t *testing.T, what string, resp WebSocketResponse, status int, message string) {
+	t.Helper()
+	var body map[string]string
+	if err := json.Unmarshal(resp.Payload, &body); err != nil || resp.Type != "error" || resp.Status != status || body["error"] != message {
+		t.Fatalf("%s = %s %d %s, want error %d %q", what, resp.Type, resp.Status, resp.Payload, status, message)
+	}
+}
+
+func TestWebSocketRequests(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	client := th.wsClient(th.token)
+
+	resp := th.wsRequest(client, `{"requestId":"1","action":"task.create","data":{"text":"report","tags":["q3"]}}`)
+	var task database.Task
+	if err := json.Unmarshal(resp.Payload, &task); err != nil || resp.Type != "ack" || resp.RequestID != "1" || resp.Status != http.StatusCreated {
+		t.Fatalf("task.create = %s %s %d %s", resp.Type, resp.RequestID, resp.Status, resp.Payload)
+	}
+	if task.Text != "report" || len(task.Tags) != 1 || task.Tags[0] != "q3" {
+		t.Fatalf("created task = %+v", task)
+	}
+
+	resp = th.wsRequest(client, fmt.Sprintf(`{"requestId":"2","action":"task.update","id":%d,"version":%d,"data":{"text":"Q3 report"}}`, task.ID, task.Version))
+	if err := json.Unmarshal(resp.Payload, &task); err != nil || resp.Type != "ack" || resp.Status != http.StatusOK || task.Text != "Q3 report" {
+		t.Fatalf("task.update = %s %d %s", resp.Type, resp.Status, resp.Payload)
+	}
+
+	// The REST handler's errors come back as the status it would have returned
+	resp = th.wsRequest(client, fmt.Sprintf(`{"requestId":"3","action":"task.update","id":%d,"version":1,"data":{"text":"stale"}}`, task.ID))
+	if resp.Type != "error" || resp.Status != http.StatusConflict {
+		t.Fatalf("task.update with a stale version = %s %d %s", resp.Type, resp.Status, resp.Payload)
+	}
+	resp = th.wsRequest(client, `{"requestId":"4","action":"task.delete","id":999}`)
+	wantWSError(t, "task.delete of a missing task", resp, http.StatusNotFound, "task not found")
+
+	var got database.Task
+	th.call("GET", fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusOK, &got)
+	if got.Text != "Q3 report" || got.Version != task.Version {
+		t.Fatalf("task after failed requests = %+v", got)
+	}
+}
+
+func TestWebSocketRequestErrors(t *testing.T) {
+	th := newTestHandler(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC))
+	client := th.wsClient(th.token)
+
+	var task database.Task
+	th.call("POST", "/api/tasks", map[string]interface{}{"text": "report", "tags": []string{"q3"}}, http.StatusCreated, &task)
+
+	for _, tt := range []struct {
+		name    string
+		message string
+		status  int
+		error   string
+	}{
+		{"not JSON", `{"action":`, http.StatusBadRequest, "invalid request"},
+		{"unknown action", `{"action":"task.archive","id":1}`, http.StatusBadRequest, "unknown action"},
+		{"no id", `{"action":"task.delete"}`, http.StatusBadRequest, "id is required"},
+		{"malformed data", fmt.Sprintf(`{"action":"task.update","id":%d,"data":[1]}`, task.ID), http.StatusBadRequest, "invalid request body"},
+		{"tags not strings", fmt.Sprintf(`{"action":"task.update","id":%d,"data":{"tags":[1]}}`, task.ID), http.StatusBadRequest, "invalid tags"},
+		{"tags not a list", fmt.Sprintf(`{"action":"task.update","id":%d,"data":{"tags":"q4"}}`, task.ID), http.StatusBadRequest, "invalid tags"},
+	} {
+		wantWSError(t, tt.name, th.wsRequest(client, tt.message), tt.status, tt.error)
+	}
+
+	var got database.Task
+	th.call("GET", fmt.Sprintf("/api/tasks/%d", task.ID), nil, http.StatusOK, &got)
+	if got.Version != task.Version || len(got.Tags) != 1 || got.Tags[0] != "q3" {
+		t.Fatalf("task after rejected requests = %+v", got)
+	}
+
+	// Requests stop being accepted once the connection's token expires
+	expired := th.wsClient("expired")
+	wantWSError(t, "request with an expired token", th.wsRequest(expired, `{"action":"task.delete","id":1}`),
+		http.StatusUnauthorized, "invalid or expired token")
+
+	// A handler that panics fails its request, and the connection carries on
+	wsActions["test.panic"] = wsAction{"POST", "/api/panic", "", func(*Handler, http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}}
+	t.Cleanup(func() { delete(wsActions, "test.panic") })
+	wantWSError(t, "request whose handler panics", th.wsRequest(client, `{"requestId":"5","action":"test.panic"}`),
+		http.StatusInternalServerError, "failed to handle request")
+	resp := th.wsRequest(client, fmt.Sprintf(`{"action":"task.update","id":%d,"data":{"tags":["q4"]}}`, task.ID))
+	if err := json.Unmarshal(resp.Payload, &got); err != nil || resp.Status != http.StatusOK || len(got.Tags) != 1 || got.Tags[0] != "q4" {
+		t.Fatalf("task.update after a panic = %s %d %s", resp.Type, resp.Status, resp.Payload)
+	}
+}
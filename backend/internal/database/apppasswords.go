@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AppPassword is a generated password that signs a third-party app, such as a
+// CalDAV client, into a user's account without their real password. Only its
+// hash is stored.
+type AppPassword struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"userId"`
+	PasswordHash string    `json:"-"` // Never expose in JSON
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// appPasswordColumns is the column list read by scanAppPassword, qualified with the "ap" alias.
+const appPasswordColumns = `ap.id, ap.user_id, ap.password_hash, ap.name, ap.created_at`
+
+// scanAppPassword scans a row selected with appPasswordColumns.
+func scanAppPassword(row rowScanner) (*AppPassword, error) {
+	password := &AppPassword{}
+	if err := row.Scan(&password.ID, &password.UserID, &password.PasswordHash, &password.Name, &password.CreatedAt); err != nil {
+		return nil, err
+	}
+	return password, nil
+}
+
+// CreateAppPassword stores the hash of a new app password. The password should
+// be a secure random string.
+func (db *DB) CreateAppPassword(ctx context.Context, userID int64, password, name string) (*AppPassword, error) {
+	result, err := db.ExecContext(ctx,
+		`INSERT INTO app_passwords (user_id, password_hash, name) VALUES (?, ?, ?)`,
+		userID, hashToken(password), name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create app password: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app password id: %w", err)
+	}
+
+	created, err := scanAppPassword(db.QueryRowContext(ctx,
+		`SELECT `+appPasswordColumns+` FROM app_passwords ap WHERE ap.id = ?`, id,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app password: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetAppPasswords retrieves a user's app passwords, oldest first.
+func (db *DB) GetAppPasswords(ctx context.Context, userID int64) ([]*AppPassword, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+appPasswordColumns+` FROM app_passwords ap WHERE ap.user_id = ? ORDER BY ap.id ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query app passwords: %w", err)
+	}
+	defer rows.Close()
+
+	var passwords []*AppPassword
+	for rows.Next() {
+		password, err := scanAppPassword(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan app password: %w", err)
+		}
+		passwords = append(passwords, password)
+	}
+
+	return passwords, rows.Err()
+}
+
+// CheckAppPassword finds the user's app password matching password.
+// Returns ErrNotFound if there is none.
+func (db *DB) CheckAppPassword(ctx context.Context, userID int64, password string) (*AppPassword, error) {
+	found, err := scanAppPassword(db.QueryRowContext(ctx,
+		`SELECT `+appPasswordColumns+` FROM app_passwords ap WHERE ap.user_id = ? AND ap.password_hash = ?`,
+		userID, hashToken(password),
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app password: %w", err)
+	}
+
+	return found, nil
+}
+
+// DeleteAppPassword revokes an app password.
+func (db *DB) DeleteAppPassword(ctx context.Context, userID, passwordID int64) error {
+	result, err := db.ExecContext(ctx,
+		`DELETE FROM app_passwords WHERE id = ? AND user_id = ?`,
+		passwordID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete app password: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,474 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Archive format identifiers. ArchiveVersion changes whenever the archive layout
+// changes in a way older readers would misread; ImportArchive only accepts
+// archives of versions it knows.
+const (
+	ArchiveFormat  = "todomaster-archive"
+	ArchiveVersion = 1
+)
+
+// Archive is a portable copy of a user's data. Lists and tasks carry the IDs they
+// had when exported, which tasks use to refer to their list; importing assigns
+// new IDs. Tasks refer to tags by name and are listed in their sort order.
+type Archive struct {
+	Format     string              `json:"format"`
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exportedAt"`
+	Settings   ArchiveSettings     `json:"settings"`
+	Lists      []*ArchiveList      `json:"lists"`
+	Tags       []*ArchiveTag       `json:"tags"`
+	Tasks      []*ArchiveTask      `json:"tasks"`
+	SmartLists []*ArchiveSmartList `json:"smartLists"`
+}
+
+// ArchiveSettings holds the user's profile settings.
+type ArchiveSettings struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+}
+
+// ArchiveList is a list in an archive.
+type ArchiveList struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ArchiveTag is a tag in an archive.
+type ArchiveTag struct {
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ArchiveTask is a task in an archive, with its subtasks in order.
+type ArchiveTask struct {
+	ID          int64             `json:"id"`
+	ListID      *int64            `json:"listId"`
+	Text        string            `json:"text"`
+	Completed   bool              `json:"completed"`
+	Important   bool              `json:"important"`
+	DueAt       *TaskDate         `json:"dueAt,omitempty"`
+	StartAt     *TaskDate         `json:"startAt,omitempty"`
+	Recurrence  string            `json:"recurrence,omitempty"`
+	RepeatFrom  string            `json:"repeatFrom,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Subtasks    []*ArchiveSubtask `json:"subtasks,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+}
+
+// ArchiveSubtask is a subtask in an archive.
+type ArchiveSubtask struct {
+	Text      string    `json:"text"`
+	Completed bool      `json:"completed"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ArchiveSmartList is a smart list in an archive.
+type ArchiveSmartList struct {
+	Title string `json:"title"`
+	Query string `json:"query"`
+}
+
+// NewArchive builds an archive from a user's live data. tasks must have their
+// tags and subtasks attached and be in sort order; a task whose list is not among
+// lists is exported without one.
+func NewArchive(user *User, lists []*List, tags []*Tag, tasks []*Task, smartLists []*SmartList) *Archive {
+	archive := &Archive{
+		Format:     ArchiveFormat,
+		Version:    ArchiveVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Settings:   ArchiveSettings{DisplayName: user.DisplayName, Timezone: user.Timezone},
+		Lists:      []*ArchiveList{},
+		Tags:       []*ArchiveTag{},
+		Tasks:      []*ArchiveTask{},
+		SmartLists: []*ArchiveSmartList{},
+	}
+	listIDs := make(map[int64]bool, len(lists))
+	for _, list := range lists {
+		listIDs[list.ID] = true
+		archive.Lists = append(archive.Lists, &ArchiveList{ID: list.ID, Title: list.Title, CreatedAt: list.CreatedAt})
+	}
+	for _, tag := range tags {
+		archive.Tags = append(archive.Tags, &ArchiveTag{Name: tag.Name, Color: tag.Color, Description: tag.Description})
+	}
+	for _, task := range tasks {
+		t := &ArchiveTask{
+			ID:          task.ID,
+			ListID:      task.ListID,
+			Text:        task.Text,
+			Completed:   task.Completed,
+			Important:   task.Important,
+			DueAt:       task.DueAt,
+			StartAt:     task.StartAt,
+			Recurrence:  task.Recurrence,
+			RepeatFrom:  task.RepeatFrom,
+			Tags:        task.Tags,
+			CreatedAt:   task.CreatedAt,
+			CompletedAt: task.CompletedAt,
+		}
+		if t.ListID != nil && !listIDs[*t.ListID] {
+			t.ListID = nil
+		}
+		for _, subtask := range task.Subtasks {
+			t.Subtasks = append(t.Subtasks, &ArchiveSubtask{Text: subtask.Text, Completed: subtask.Completed, CreatedAt: subtask.CreatedAt})
+		}
+		archive.Tasks = append(archive.Tasks, t)
+	}
+	for _, list := range smartLists {
+		archive.SmartLists = append(archive.SmartLists, &ArchiveSmartList{Title: list.Title, Query: list.Query})
+	}
+	return archive
+}
+
+// Validate checks that an archive is one ImportArchive can read: a known format
+// and version, required fields set, unique list and task IDs and tasks that only
+// refer to lists in the archive. The problem found is described with its
+// location, such as "tasks[3]: text is required".
+func (a *Archive) Validate() error {
+	if a.Format != ArchiveFormat {
+		return fmt.Errorf("format must be %q", ArchiveFormat)
+	}
+	if a.Version < 1 || a.Version > ArchiveVersion {
+		return fmt.Errorf("unsupported version %d", a.Version)
+	}
+
+	lists := make(map[int64]bool, len(a.Lists))
+	for i, list := range a.Lists {
+		if list == nil || list.Title == "" {
+			return fmt.Errorf("lists[%d]: title is required", i)
+		}
+		if lists[list.ID] {
+			return fmt.Errorf("lists[%d]: duplicate id %d", i, list.ID)
+		}
+		lists[list.ID] = true
+	}
+	for i, tag := range a.Tags {
+		if tag == nil || tag.Name == "" {
+			return fmt.Errorf("tags[%d]: name is required", i)
+		}
+	}
+	tasks := make(map[int64]bool, len(a.Tasks))
+	for i, task := range a.Tasks {
+		if task == nil || task.Text == "" {
+			return fmt.Errorf("tasks[%d]: text is required", i)
+		}
+		if task.ID != 0 {
+			if tasks[task.ID] {
+				return fmt.Errorf("tasks[%d]: duplicate id %d", i, task.ID)
+			}
+			tasks[task.ID] = true
+		}
+		if task.ListID != nil && !lists[*task.ListID] {
+			return fmt.Errorf("tasks[%d]: list %d is not in the archive", i, *task.ListID)
+		}
+		for _, tag := range task.Tags {
+			if tag == "" {
+				return fmt.Errorf("tasks[%d]: empty tag", i)
+			}
+		}
+		for j, subtask := range task.Subtasks {
+			if subtask == nil || subtask.Text == "" {
+				return fmt.Errorf("tasks[%d].subtasks[%d]: text is required", i, j)
+			}
+		}
+	}
+	for i, list := range a.SmartLists {
+		if list == nil || list.Title == "" || list.Query == "" {
+			return fmt.Errorf("smartLists[%d]: title and query are required", i)
+		}
+	}
+	return nil
+}
+
+// AllTags returns the archive's tags followed by any tag its tasks carry that is
+// not listed, each name once.
+func (a *Archive) AllTags() []*ArchiveTag {
+	seen := make(map[string]bool)
+	var tags []*ArchiveTag
+	for _, tag := range a.Tags {
+		if !seen[tag.Name] {
+			seen[tag.Name] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, task := range a.Tasks {
+		for _, name := range task.Tags {
+			if !seen[name] {
+				seen[name] = true
+				tags = append(tags, &ArchiveTag{Name: name})
+			}
+		}
+	}
+	return tags
+}
+
+// ImportOptions controls ImportArchive. Replace deletes all of the user's lists,
+// tasks, tags and smart lists, including those in the trash, and applies the
+// archive's settings; otherwise the archive is merged into the existing data.
+// DryRun reports what the import would do without changing anything. ListID,
+// if set, is an existing list that tasks without a list of their own go into.
+type ImportOptions struct {
+	Replace bool
+	DryRun  bool
+	ListID  *int64
+}
+
+// ImportReport describes what an import did, or would do on a dry run.
+type ImportReport struct {
+	Lists      ImportCounts `json:"lists"`
+	Tags       ImportCounts `json:"tags"`
+	Tasks      ImportCounts `json:"tasks"`
+	Subtasks   ImportCounts `json:"subtasks"`
+	SmartLists ImportCounts `json:"smartLists"`
+	// Settings reports whether the archive's settings were applied.
+	Settings bool `json:"settings"`
+	// ListIDs and TaskIDs map the IDs in the archive to the IDs the lists and
+	// tasks were given. They are not set on a dry run.
+	ListIDs map[int64]int64 `json:"listIds,omitempty"`
+	TaskIDs map[int64]int64 `json:"taskIds,omitempty"`
+}
+
+// ImportCounts counts rows by what an import did with them. Merged rows matched
+// existing ones, which were kept: lists by title, tags by name and smart lists by
+// title and query. Deleted rows were removed by a replace.
+type ImportCounts struct {
+	Created int `json:"created"`
+	Merged  int `json:"merged"`
+	Deleted int `json:"deleted"`
+}
+
+// ImportArchive restores a validated archive into a user's account in a single
+// transaction. Imported tasks are appended after the user's existing ones.
+func (db *DB) ImportArchive(ctx context.Context, userID int64, archive *Archive, opts ImportOptions) (*ImportReport, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	report := &ImportReport{ListIDs: make(map[int64]int64), TaskIDs: make(map[int64]int64)}
+
+	if opts.Replace {
+		if err := replaceUserDataTx(ctx, tx, userID, archive.Settings, report); err != nil {
+			return nil, err
+		}
+	}
+
+	// Lists merge with the live list of the same title that existed before the import
+	existingLists := make(map[string]int64)
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, title FROM lists WHERE user_id = ? AND deleted_at IS NULL ORDER BY id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lists: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan list: %w", err)
+		}
+		existingLists[title] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lists: %w", err)
+	}
+
+	for _, list := range archive.Lists {
+		if id, ok := existingLists[list.Title]; ok {
+			report.ListIDs[list.ID] = id
+			report.Lists.Merged++
+			continue
+		}
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO lists (user_id, title, created_at) VALUES (?, ?, ?)`,
+			userID, list.Title, sqlTime(orNow(list.CreatedAt)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create list: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get list id: %w", err)
+		}
+		report.ListIDs[list.ID] = id
+		report.Lists.Created++
+	}
+
+	for _, tag := range archive.AllTags() {
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO tags (user_id, name, color, description) VALUES (?, ?, ?, ?)
+			 ON CONFLICT(user_id, name) DO NOTHING`,
+			userID, tag.Name, nullString(tag.Color), nullString(tag.Description),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tag: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			report.Tags.Created++
+		} else {
+			report.Tags.Merged++
+		}
+	}
+
+	var maxOrder sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT MAX(sort_order) FROM tasks WHERE user_id = ?`, userID).Scan(&maxOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get max sort order: %w", err)
+	}
+
+	for i, task := range archive.Tasks {
+		listID := opts.ListID
+		if task.ListID != nil {
+			id := report.ListIDs[*task.ListID]
+			listID = &id
+		}
+		var completedAt interface{}
+		if task.Completed {
+			completedAt = sqlTime(time.Now())
+			if task.CompletedAt != nil {
+				completedAt = sqlTime(*task.CompletedAt)
+			}
+		}
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO tasks (user_id, list_id, text, sort_order, important, completed, completed_at,
+			   due_at, due_all_day, start_at, start_all_day, recurrence, repeat_from, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			userID, listID, task.Text, int(maxOrder.Int64)+i+1, task.Important, task.Completed, completedAt,
+			task.DueAt.sqlValue(), task.DueAt != nil && task.DueAt.AllDay,
+			task.StartAt.sqlValue(), task.StartAt != nil && task.StartAt.AllDay,
+			nullString(task.Recurrence), nullString(task.RepeatFrom), sqlTime(orNow(task.CreatedAt)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create task: %w", err)
+		}
+		taskID, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get task id: %w", err)
+		}
+		if task.ID != 0 {
+			report.TaskIDs[task.ID] = taskID
+		}
+		report.Tasks.Created++
+
+		if err := addTagsToTaskTx(ctx, tx, userID, taskID, task.Tags); err != nil {
+			return nil, err
+		}
+
+		for j, subtask := range task.Subtasks {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO subtasks (task_id, text, completed, sort_order, created_at) VALUES (?, ?, ?, ?, ?)`,
+				taskID, subtask.Text, subtask.Completed, j+1, sqlTime(orNow(subtask.CreatedAt)),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create subtask: %w", err)
+			}
+			report.Subtasks.Created++
+		}
+
+		if err := recordHistory(ctx, tx, userID, taskID, nil, HistoryCreate, HistoryValue(task.Text)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, list := range archive.SmartLists {
+		var exists int
+		err := tx.QueryRowContext(ctx,
+			`SELECT 1 FROM smart_lists WHERE user_id = ? AND title = ? AND query = ?`,
+			userID, list.Title, list.Query,
+		).Scan(&exists)
+		if err == nil {
+			report.SmartLists.Merged++
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get smart list: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO smart_lists (user_id, title, query) VALUES (?, ?, ?)`,
+			userID, list.Title, list.Query,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create smart list: %w", err)
+		}
+		report.SmartLists.Created++
+	}
+
+	if opts.DryRun {
+		report.ListIDs, report.TaskIDs = nil, nil
+		return report, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return report, nil
+}
+
+// replaceUserDataTx deletes a user's lists, tasks, tags and smart lists, counting
+// them in report, and applies the archive's settings.
+func replaceUserDataTx(ctx context.Context, tx *sql.Tx, userID int64, settings ArchiveSettings, report *ImportReport) error {
+	err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM subtasks WHERE task_id IN (SELECT id FROM tasks WHERE user_id = ?)`,
+		userID,
+	).Scan(&report.Subtasks.Deleted)
+	if err != nil {
+		return fmt.Errorf("failed to count subtasks: %w", err)
+	}
+
+	// Tasks first, so deleting the lists can't cascade to them
+	for _, d := range []struct {
+		stmt  string
+		count *int
+	}{
+		{`DELETE FROM tasks WHERE user_id = ?`, &report.Tasks.Deleted},
+		{`DELETE FROM lists WHERE user_id = ?`, &report.Lists.Deleted},
+		{`DELETE FROM tags WHERE user_id = ?`, &report.Tags.Deleted},
+		{`DELETE FROM smart_lists WHERE user_id = ?`, &report.SmartLists.Deleted},
+	} {
+		result, err := tx.ExecContext(ctx, d.stmt, userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete existing data: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		*d.count = int(n)
+	}
+
+	if settings.DisplayName != "" || settings.Timezone != "" {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE users SET display_name = COALESCE(NULLIF(?, ''), display_name),
+			   timezone = COALESCE(NULLIF(?, ''), timezone), updated_at = CURRENT_TIMESTAMP
+			 WHERE id = ?`,
+			settings.DisplayName, settings.Timezone, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update settings: %w", err)
+		}
+		report.Settings = true
+	}
+
+	return nil
+}
+
+// orNow returns t, or the current time if t is zero.
+func orNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TaskOperation is one change to a task in a batch. Updates takes the same keys
+// as UpdateTask, AddTags and RemoveTags edit the tags the task has when the
+// operation runs, and Delete moves the task to the trash instead. A non-zero
+// Version makes the operation conditional, as it does for UpdateTask.
+type TaskOperation struct {
+	TaskID     int64
+	Version    int64
+	Updates    map[string]interface{}
+	AddTags    []string
+	RemoveTags []string
+	Delete     bool
+}
+
+// TaskUpdates returns the updates the operation makes to a task that currently
+// has tags, with its tag edits folded in as a "tags" update.
+func (op TaskOperation) TaskUpdates(tags []string) map[string]interface{} {
+	updates := make(map[string]interface{}, len(op.Updates)+1)
+	for key, value := range op.Updates {
+		updates[key] = value
+	}
+	if len(op.AddTags) == 0 && len(op.RemoveTags) == 0 {
+		return updates
+	}
+
+	removed := make(map[string]bool, len(op.RemoveTags))
+	for _, tag := range op.RemoveTags {
+		removed[tag] = true
+	}
+	seen := make(map[string]bool)
+	edited := []interface{}{}
+	for _, list := range [][]string{tags, op.AddTags} {
+		for _, tag := range list {
+			if removed[tag] || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			edited = append(edited, tag)
+		}
+	}
+	updates["tags"] = edited
+	return updates
+}
+
+// MovesTo returns the list the operation moves its task into, or false if it
+// doesn't move the task into a list.
+func (op TaskOperation) MovesTo() (int64, bool) {
+	switch v := op.Updates["listId"].(type) {
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// ErrListNotFound is returned by BatchUpdateTasks when an operation moves a task
+// into a list the user doesn't have.
+var ErrListNotFound = errors.New("list not found")
+
+// BatchUpdate is the outcome of BatchUpdateTasks.
+type BatchUpdate struct {
+	Tasks   []*Task           // Each operation's task after the batch; nil if it ended up in the trash
+	Created []*Task           // Next occurrences of the recurring tasks the batch completed
+	Changes []OperationChange // What the batch changed, as logged in the undo log
+}
+
+// BatchError reports the operation a batch failed on. None of the batch's
+// operations are applied.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BatchUpdateTasks applies operations to a user's tasks in order, in a single
+// transaction. An operation that completes a recurring task also creates its
+// next occurrence, scheduled by next, as CompleteRecurringTask does. The batch
+// is recorded in the user's undo log as one operation. If any operation fails
+// nothing is applied and the error is a *BatchError.
+func (db *DB) BatchUpdateTasks(ctx context.Context, userID int64, ops []TaskOperation, next NextOccurrence) (*BatchUpdate, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The tasks as they were, in the order the batch first touches them
+	var taskIDs []int64
+	old := make(map[int64]*Task)
+	var createdIDs []int64
+	for i, op := range ops {
+		if _, seen := old[op.TaskID]; !seen {
+			task, err := getTaskTx(ctx, tx, userID, op.TaskID)
+			if err != nil {
+				return nil, &BatchError{Index: i, Err: err}
+			}
+			taskIDs = append(taskIDs, op.TaskID)
+			old[op.TaskID] = task
+		}
+
+		nextID, err := applyTaskOperationTx(ctx, tx, userID, op, next)
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		if nextID != 0 {
+			createdIDs = append(createdIDs, nextID)
+		}
+	}
+
+	// The changes, logged with the batch
+	var changes []OperationChange
+	for _, id := range taskIDs {
+		task, err := getTaskTx(ctx, tx, userID, id)
+		if errors.Is(err, ErrNotFound) {
+			changes = append(changes, OperationChange{Entity: SyncTask, ID: id, Action: HistoryDelete})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if change, ok := TaskChange(old[id], task); ok {
+			changes = append(changes, change)
+		}
+	}
+	for _, id := range createdIDs {
+		changes = append(changes, OperationChange{Entity: SyncTask, ID: id, Action: HistoryCreate})
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationTaskBatch, changes); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result := &BatchUpdate{Tasks: make([]*Task, len(ops)), Changes: changes}
+	final := make(map[int64]*Task)
+	for _, id := range taskIDs {
+		task, err := db.GetTask(ctx, userID, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		final[id] = task
+	}
+	for i, op := range ops {
+		result.Tasks[i] = final[op.TaskID]
+	}
+	for _, id := range createdIDs {
+		task, err := db.GetTask(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, task)
+	}
+	return result, nil
+}
+
+// applyTaskOperationTx applies one operation of a batch within its transaction.
+// It returns the ID of the next occurrence the operation spawned, if any.
+func applyTaskOperationTx(ctx context.Context, tx *sql.Tx, userID int64, op TaskOperation, next NextOccurrence) (int64, error) {
+	task, err := getTaskTx(ctx, tx, userID, op.TaskID)
+	if err != nil {
+		return 0, err
+	}
+	if op.Version != 0 && task.Version != op.Version {
+		return 0, ErrVersionConflict
+	}
+
+	if op.Delete {
+		return 0, deleteTaskTx(ctx, tx, userID, op.TaskID)
+	}
+	if listID, ok := op.MovesTo(); ok {
+		var exists bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM lists WHERE id = ? AND user_id = ? AND deleted_at IS NULL)`,
+			listID, userID,
+		).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get list: %w", err)
+		}
+		if !exists {
+			return 0, ErrListNotFound
+		}
+	}
+
+	updates := op.TaskUpdates(task.Tags)
+	if err := updateTaskTx(ctx, tx, userID, op.TaskID, updates, 0); err != nil {
+		return 0, err
+	}
+	return spawnNextOccurrenceTx(ctx, tx, userID, op.TaskID, updates, next)
+}
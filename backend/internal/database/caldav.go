@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CalDAVObject records the resource name and UID a CalDAV client gave a task or
+// subtask it created, so the client finds it under the same ones. Exactly one of
+// TaskID and SubtaskID is set. Tasks and subtasks without one are served under
+// names and UIDs derived from their IDs.
+type CalDAVObject struct {
+	UserID    int64
+	TaskID    *int64
+	SubtaskID *int64
+	UID       string
+	Name      string
+}
+
+// CreateCalDAVTask creates a task for a resource a client put, and records the
+// client's name and UID for it and the creation in the user's undo log, in one
+// transaction. obj's TaskID and SubtaskID
+// are ignored.
+func (db *DB) CreateCalDAVTask(ctx context.Context, obj *CalDAVObject, task *Task) (*Task, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	taskID, err := createTaskTx(ctx, tx, obj.UserID, task)
+	if err != nil {
+		return nil, err
+	}
+	if err := createCalDAVObjectTx(ctx, tx, obj, &taskID, nil); err != nil {
+		return nil, err
+	}
+	if err := recordOperationTx(ctx, tx, obj.UserID, OperationTaskCreate, []OperationChange{
+		{Entity: SyncTask, ID: taskID, Action: HistoryCreate},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return db.GetTask(ctx, obj.UserID, taskID)
+}
+
+// CreateCalDAVSubtask adds a subtask, completed if completed is set, to one of
+// the user's live tasks for a resource a client put, and records the client's
+// name and UID for it and the creation in the user's undo log, in one
+// transaction. obj's TaskID and SubtaskID are ignored.
+func (db *DB) CreateCalDAVSubtask(ctx context.Context, obj *CalDAVObject, taskID int64, text string, completed bool) (*Subtask, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	subtask, err := createSubtaskTx(ctx, tx, obj.UserID, taskID, text)
+	if err != nil {
+		return nil, err
+	}
+	if completed {
+		subtask, err = updateSubtaskTx(ctx, tx, obj.UserID, subtask.ID, map[string]interface{}{"completed": true}, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := createCalDAVObjectTx(ctx, tx, obj, nil, &subtask.ID); err != nil {
+		return nil, err
+	}
+	if err := recordOperationTx(ctx, tx, obj.UserID, OperationSubtaskCreate, []OperationChange{
+		{Entity: SyncSubtask, ID: subtask.ID, Action: HistoryCreate},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return subtask, nil
+}
+
+// createCalDAVObjectTx records a client's name and UID for a task or subtask
+// within a transaction.
+func createCalDAVObjectTx(ctx context.Context, tx *sql.Tx, obj *CalDAVObject, taskID, subtaskID *int64) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO caldav_objects (user_id, task_id, subtask_id, uid, name) VALUES (?, ?, ?, ?, ?)`,
+		obj.UserID, taskID, subtaskID, obj.UID, obj.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create caldav object: %w", err)
+	}
+	return nil
+}
+
+// GetCalDAVObjects retrieves the names and UIDs recorded for a user's tasks and
+// subtasks. Those of purged tasks and subtasks are deleted with them.
+func (db *DB) GetCalDAVObjects(ctx context.Context, userID int64) ([]*CalDAVObject, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT user_id, task_id, subtask_id, uid, name FROM caldav_objects WHERE user_id = ? ORDER BY id ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query caldav objects: %w", err)
+	}
+	defer rows.Close()
+
+	var objects []*CalDAVObject
+	for rows.Next() {
+		obj := &CalDAVObject{}
+		var taskID, subtaskID sql.NullInt64
+		if err := rows.Scan(&obj.UserID, &taskID, &subtaskID, &obj.UID, &obj.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan caldav object: %w", err)
+		}
+		if taskID.Valid {
+			obj.TaskID = &taskID.Int64
+		}
+		if subtaskID.Valid {
+			obj.SubtaskID = &subtaskID.Int64
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -14,6 +15,11 @@ type DB struct {
 	*sql.DB
 }
 
+// timeFormat extends the layout SQLite's CURRENT_TIMESTAMP uses with milliseconds.
+// Times written by the application use it so they still compare correctly in SQL
+// against CURRENT_TIMESTAMP values while telling apart writes in the same second.
+const timeFormat = "2006-01-02 15:04:05.000"
+
 // New creates a new database connection and ensures the data directory exists.
 func New(dbPath string) (*DB, error) {
 	// Ensure directory exists
@@ -37,89 +43,7 @@ func New(dbPath string) (*DB, error) {
 	return &DB{DB: db}, nil
 }
 
-// Migrate runs database migrations to set up the schema.
-func (db *DB) Migrate() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			display_name TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
-
-		`CREATE TABLE IF NOT EXISTS sessions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			token_hash TEXT UNIQUE NOT NULL,
-			expires_at DATETIME NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_token_hash ON sessions(token_hash)`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
-
-		`CREATE TABLE IF NOT EXISTS lists (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_lists_user_id ON lists(user_id)`,
-
-		`CREATE TABLE IF NOT EXISTS tasks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			list_id INTEGER,
-			text TEXT NOT NULL,
-			completed BOOLEAN DEFAULT FALSE,
-			important BOOLEAN DEFAULT FALSE,
-			is_expanded BOOLEAN DEFAULT FALSE,
-			sort_order INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-			FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_tasks_list_id ON tasks(list_id)`,
-
-		`CREATE TABLE IF NOT EXISTS subtasks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			task_id INTEGER NOT NULL,
-			text TEXT NOT NULL,
-			completed BOOLEAN DEFAULT FALSE,
-			sort_order INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_subtasks_task_id ON subtasks(task_id)`,
-
-		`CREATE TABLE IF NOT EXISTS tags (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE NOT NULL
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS task_tags (
-			task_id INTEGER NOT NULL,
-			tag_id INTEGER NOT NULL,
-			PRIMARY KEY (task_id, tag_id),
-			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
-			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_task_tags_task_id ON task_tags(task_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_task_tags_tag_id ON task_tags(tag_id)`,
-	}
-
-	for _, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			return fmt.Errorf("migration failed: %w\nSQL: %s", err, migration)
-		}
-	}
-
-	return nil
+// sqlTime formats a time for storage in a DATETIME column.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
 }
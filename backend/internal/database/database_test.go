@@ -0,0 +1,29 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/database/storetest"
+)
+
+// newDB opens a migrated database in a temporary directory.
+func newDB(t testing.TB) *database.DB {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "todomaster.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return newDB(t)
+	})
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// dateFormat is the layout of all-day dates in the API.
+const dateFormat = "2006-01-02"
+
+// TaskDate is a task's due or start date. A timed date is an absolute instant.
+// An all-day date is a calendar day with no time zone of its own; it covers that
+// whole day in the user's time zone. In JSON, all-day dates are written as
+// "2006-01-02" and timed dates as RFC 3339 timestamps.
+type TaskDate struct {
+	// Time is the instant of a timed date, or midnight UTC of an all-day date.
+	Time   time.Time
+	AllDay bool
+}
+
+// ParseTaskDate parses a date in either API form: "2006-01-02" for an all-day
+// date or an RFC 3339 timestamp for a timed one.
+func ParseTaskDate(s string) (*TaskDate, error) {
+	if t, err := time.Parse(dateFormat, s); err == nil {
+		return &TaskDate{Time: t, AllDay: true}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: want YYYY-MM-DD or an RFC 3339 timestamp", s)
+	}
+	return &TaskDate{Time: t.UTC().Truncate(time.Millisecond)}, nil
+}
+
+// String returns the API form of the date.
+func (d TaskDate) String() string {
+	if d.AllDay {
+		return d.Time.Format(dateFormat)
+	}
+	return d.Time.UTC().Format(time.RFC3339Nano)
+}
+
+// MarshalJSON writes the date in its API form.
+func (d TaskDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a date in either API form.
+func (d *TaskDate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTaskDate(s)
+	if err != nil {
+		return err
+	}
+	*d = *parsed
+	return nil
+}
+
+// Span returns the interval the date covers in loc: the whole day for an all-day
+// date, or the single instant of a timed date.
+func (d TaskDate) Span(loc *time.Location) (start, end time.Time) {
+	if !d.AllDay {
+		return d.Time, d.Time
+	}
+	y, m, day := d.Time.Date()
+	return time.Date(y, m, day, 0, 0, 0, 0, loc), time.Date(y, m, day+1, 0, 0, 0, 0, loc)
+}
+
+// TaskDateFromUpdate reads a dueAt or startAt value from an UpdateTask updates map:
+// nil clears the date and a string is parsed with ParseTaskDate.
+func TaskDateFromUpdate(v interface{}) (*TaskDate, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return ParseTaskDate(v)
+	case *TaskDate:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("invalid date %v", v)
+	}
+}
+
+// Equal reports whether two optional dates are the same.
+func (d *TaskDate) Equal(other *TaskDate) bool {
+	if d == nil || other == nil {
+		return d == other
+	}
+	return d.AllDay == other.AllDay && d.Time.Equal(other.Time)
+}
+
+// sqlValue returns the value stored in a date column: the instant for timed dates
+// and midnight of the day for all-day dates, both in the sqlTime layout so they
+// compare as strings.
+func (d *TaskDate) sqlValue() interface{} {
+	if d == nil {
+		return nil
+	}
+	return sqlTime(d.Time)
+}
+
+// scanTaskDate builds a TaskDate from nullable date columns.
+func scanTaskDate(t sql.NullTime, allDay bool) *TaskDate {
+	if !t.Valid {
+		return nil
+	}
+	return &TaskDate{Time: t.Time.UTC(), AllDay: allDay}
+}
+
+// localDay returns midnight UTC of the calendar day t falls on in loc, the form
+// all-day dates are stored in.
+func localDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// matchesDue reports whether a task passes the filter's due date conditions.
+func (f TaskFilter) matchesDue(task *Task) bool {
+	if f.DueAfter == nil && f.DueBefore == nil && !f.Overdue {
+		return true
+	}
+	if task.DueAt == nil {
+		return false
+	}
+
+	start, end := task.DueAt.Span(f.location())
+	if task.DueAt.AllDay {
+		if f.DueAfter != nil && !end.After(*f.DueAfter) {
+			return false
+		}
+		if f.DueBefore != nil && !start.Before(*f.DueBefore) {
+			return false
+		}
+		if f.Overdue && (task.Completed || end.After(f.Now)) {
+			return false
+		}
+		return true
+	}
+
+	if f.DueAfter != nil && start.Before(*f.DueAfter) {
+		return false
+	}
+	if f.DueBefore != nil && !start.Before(*f.DueBefore) {
+		return false
+	}
+	if f.Overdue && (task.Completed || !start.Before(f.Now)) {
+		return false
+	}
+	return true
+}
+
+// dueConditions returns WHERE conditions, on the "t" task alias, equivalent to
+// matchesDue. All-day bounds are converted to stored day values: a day overlaps [after, before)
+// when it is on or after after's local day and before before's local day, or on
+// that day too if before is not a local midnight.
+func (f TaskFilter) dueConditions() (string, []interface{}) {
+	if f.DueAfter == nil && f.DueBefore == nil && !f.Overdue {
+		return "", nil
+	}
+	loc := f.location()
+
+	timed := "NOT t.due_all_day"
+	allDay := "t.due_all_day"
+	var timedArgs, allDayArgs []interface{}
+
+	if f.DueAfter != nil {
+		timed += " AND t.due_at >= ?"
+		timedArgs = append(timedArgs, sqlTime(*f.DueAfter))
+		allDay += " AND t.due_at >= ?"
+		allDayArgs = append(allDayArgs, sqlTime(localDay(*f.DueAfter, loc)))
+	}
+	if f.DueBefore != nil {
+		timed += " AND t.due_at < ?"
+		timedArgs = append(timedArgs, sqlTime(*f.DueBefore))
+		day := localDay(*f.DueBefore, loc)
+		if y, m, d := day.Date(); !time.Date(y, m, d, 0, 0, 0, 0, loc).Equal(*f.DueBefore) {
+			day = day.AddDate(0, 0, 1)
+		}
+		allDay += " AND t.due_at < ?"
+		allDayArgs = append(allDayArgs, sqlTime(day))
+	}
+	if f.Overdue {
+		timed += " AND t.due_at < ?"
+		timedArgs = append(timedArgs, sqlTime(f.Now))
+		allDay += " AND t.due_at < ?"
+		allDayArgs = append(allDayArgs, sqlTime(localDay(f.Now, loc)))
+	}
+
+	where := " AND t.due_at IS NOT NULL AND ((" + timed + ") OR (" + allDay + "))"
+	if f.Overdue {
+		where += " AND NOT t.completed"
+	}
+	return where, append(timedArgs, allDayArgs...)
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FeedToken grants read-only access to a user's tasks as a calendar feed. The
+// token itself is only shown when it is created; like session tokens, only its
+// hash is stored. A token with a ListID only shows that list's tasks.
+type FeedToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	TokenHash string    `json:"-"` // Never expose in JSON
+	Name      string    `json:"name"`
+	ListID    *int64    `json:"listId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// feedTokenColumns is the column list read by scanFeedToken, qualified with the "ft" alias.
+const feedTokenColumns = `ft.id, ft.user_id, ft.token_hash, ft.name, ft.list_id, ft.created_at`
+
+// scanFeedToken scans a row selected with feedTokenColumns.
+func scanFeedToken(row rowScanner) (*FeedToken, error) {
+	feed := &FeedToken{}
+	var listID sql.NullInt64
+	if err := row.Scan(&feed.ID, &feed.UserID, &feed.TokenHash, &feed.Name, &listID, &feed.CreatedAt); err != nil {
+		return nil, err
+	}
+	if listID.Valid {
+		feed.ListID = &listID.Int64
+	}
+	return feed, nil
+}
+
+// CreateFeedToken stores the hash of a new feed token. The token should be a
+// secure random string.
+func (db *DB) CreateFeedToken(ctx context.Context, userID int64, token, name string, listID *int64) (*FeedToken, error) {
+	result, err := db.ExecContext(ctx,
+		`INSERT INTO feed_tokens (user_id, token_hash, name, list_id) VALUES (?, ?, ?, ?)`,
+		userID, hashToken(token), name, listID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feed token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed token id: %w", err)
+	}
+
+	feed, err := scanFeedToken(db.QueryRowContext(ctx,
+		`SELECT `+feedTokenColumns+` FROM feed_tokens ft WHERE ft.id = ?`, id,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed token: %w", err)
+	}
+
+	return feed, nil
+}
+
+// GetFeedTokens retrieves a user's feed tokens, oldest first.
+func (db *DB) GetFeedTokens(ctx context.Context, userID int64) ([]*FeedToken, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+feedTokenColumns+` FROM feed_tokens ft WHERE ft.user_id = ? ORDER BY ft.id ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feed tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var feeds []*FeedToken
+	for rows.Next() {
+		feed, err := scanFeedToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan feed token: %w", err)
+		}
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, rows.Err()
+}
+
+// GetFeedTokenByToken finds a feed token by the token itself (not its hash).
+// Returns ErrNotFound if it doesn't exist or has been revoked.
+func (db *DB) GetFeedTokenByToken(ctx context.Context, token string) (*FeedToken, error) {
+	feed, err := scanFeedToken(db.QueryRowContext(ctx,
+		`SELECT `+feedTokenColumns+` FROM feed_tokens ft WHERE ft.token_hash = ?`,
+		hashToken(token),
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed token: %w", err)
+	}
+
+	return feed, nil
+}
+
+// DeleteFeedToken revokes a feed token.
+func (db *DB) DeleteFeedToken(ctx context.Context, userID, feedID int64) error {
+	result, err := db.ExecContext(ctx,
+		`DELETE FROM feed_tokens WHERE id = ? AND user_id = ?`,
+		feedID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete feed token: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,347 @@
+package database
+
+import (
+	"cmp"
+	"strings"
+	"time"
+)
+
+// Keys tasks can be sorted by.
+const (
+	TaskSortOrder   = "sortOrder"
+	TaskSortCreated = "createdAt"
+	TaskSortUpdated = "updatedAt"
+	TaskSortDue     = "dueAt"
+	TaskSortText    = "text"
+)
+
+// ValidTaskSort reports whether key is one of the keys tasks can be sorted by.
+func ValidTaskSort(key string) bool {
+	switch key {
+	case TaskSortOrder, TaskSortCreated, TaskSortUpdated, TaskSortDue, TaskSortText:
+		return true
+	}
+	return false
+}
+
+// TaskFilter narrows, orders and pages a task query.
+//
+// DueAfter (inclusive) and DueBefore (exclusive) bound a range of instants. A timed
+// due date matches if it falls in the range; an all-day due date matches if any
+// part of its day, in Location, overlaps the range. Overdue selects incomplete
+// tasks whose due date has passed at Now: a timed date once its instant has passed,
+// an all-day date once its whole day has.
+type TaskFilter struct {
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	Overdue   bool
+
+	// ListID limits the tasks to one list, and NoList to tasks in no list.
+	ListID    *int64
+	NoList    bool
+	Completed *bool
+	Important *bool
+
+	// Tags limits the tasks to those with any of the named tags, or with all of
+	// them when AllTags is set.
+	Tags    []string
+	AllTags bool
+
+	// The After bounds are inclusive and the Before bounds exclusive.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+
+	// Sort is the key tasks are ordered by, TaskSortOrder if empty, and Desc
+	// reverses it. Ties are broken by ID in the same direction. Tasks without a due
+	// date come last when sorting by due date in either direction.
+	Sort string
+	Desc bool
+
+	// Query, if set, limits the tasks to those matching a task query.
+	Query *TaskQuery
+
+	// After, if set, skips the tasks up to and including this one in the order;
+	// only its ID and sort key are read. Limit caps the number of tasks returned,
+	// with zero meaning no limit.
+	After *Task
+	Limit int
+
+	// Now is the current time used for Overdue.
+	Now time.Time
+	// Location is the user's time zone, used to place all-day dates. Nil means UTC.
+	Location *time.Location
+}
+
+func (f TaskFilter) location() *time.Location {
+	if f.Location == nil {
+		return time.UTC
+	}
+	return f.Location
+}
+
+func (f TaskFilter) sortKey() string {
+	if f.Sort == "" {
+		return TaskSortOrder
+	}
+	return f.Sort
+}
+
+// Matches reports whether a task passes the filter and comes after the filter's
+// After task. It ignores Limit.
+func (f TaskFilter) Matches(task *Task) bool {
+	if !f.matchesDue(task) {
+		return false
+	}
+	if f.ListID != nil && (task.ListID == nil || *task.ListID != *f.ListID) {
+		return false
+	}
+	if f.NoList && task.ListID != nil {
+		return false
+	}
+	if f.Completed != nil && task.Completed != *f.Completed {
+		return false
+	}
+	if f.Important != nil && task.Important != *f.Important {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		matched := 0
+		for _, tag := range uniqueStrings(f.Tags) {
+			for _, name := range task.Tags {
+				if name == tag {
+					matched++
+					break
+				}
+			}
+		}
+		if matched == 0 || (f.AllTags && matched < len(uniqueStrings(f.Tags))) {
+			return false
+		}
+	}
+	if !inRange(task.CreatedAt, f.CreatedAfter, f.CreatedBefore) ||
+		!inRange(task.UpdatedAt, f.UpdatedAfter, f.UpdatedBefore) {
+		return false
+	}
+	if f.Query != nil && !f.Query.Matches(task) {
+		return false
+	}
+	return f.After == nil || f.Compare(task, f.After) > 0
+}
+
+// Compare orders two tasks the way the filter sorts them, returning a negative
+// number when a comes first, a positive one when b does and zero only when they
+// have the same ID.
+func (f TaskFilter) Compare(a, b *Task) int {
+	c := 0
+	switch f.sortKey() {
+	case TaskSortCreated:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case TaskSortUpdated:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case TaskSortText:
+		c = strings.Compare(a.Text, b.Text)
+	case TaskSortDue:
+		switch {
+		case a.DueAt == nil && b.DueAt == nil:
+		case a.DueAt == nil:
+			return 1
+		case b.DueAt == nil:
+			return -1
+		default:
+			c = a.DueAt.Time.Compare(b.DueAt.Time)
+		}
+	default:
+		c = cmp.Compare(a.SortOrder, b.SortOrder)
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	if f.Desc {
+		return -c
+	}
+	return c
+}
+
+// sqlConditions returns WHERE conditions, on the "t" task alias, equivalent to
+// Matches.
+func (f TaskFilter) sqlConditions() (string, []interface{}) {
+	where, args := f.dueConditions()
+
+	if f.ListID != nil {
+		where += " AND t.list_id = ?"
+		args = append(args, *f.ListID)
+	}
+	if f.NoList {
+		where += " AND t.list_id IS NULL"
+	}
+	if f.Completed != nil {
+		where += " AND t.completed = ?"
+		args = append(args, *f.Completed)
+	}
+	if f.Important != nil {
+		where += " AND t.important = ?"
+		args = append(args, *f.Important)
+	}
+	if len(f.Tags) > 0 {
+		tags := uniqueStrings(f.Tags)
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+		matched := `SELECT COUNT(DISTINCT tg.name) FROM task_tags tt
+			 JOIN tags tg ON tg.id = tt.tag_id
+			 WHERE tt.task_id = t.id AND tg.name IN (` + placeholders + `)`
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+		if f.AllTags {
+			where += " AND (" + matched + ") = ?"
+			args = append(args, len(tags))
+		} else {
+			where += " AND (" + matched + ") > 0"
+		}
+	}
+	for _, r := range []struct {
+		column        string
+		after, before *time.Time
+	}{
+		{"t.created_at", f.CreatedAfter, f.CreatedBefore},
+		{"t.updated_at", f.UpdatedAfter, f.UpdatedBefore},
+	} {
+		rangeWhere, rangeArgs := timeRangeConditions(r.column, r.after, r.before)
+		where += rangeWhere
+		args = append(args, rangeArgs...)
+	}
+	if f.Query != nil {
+		queryWhere, queryArgs := f.Query.sqlCondition()
+		where += " AND " + queryWhere
+		args = append(args, queryArgs...)
+	}
+
+	if f.After != nil {
+		column, value := f.sortColumn()
+		op := ">"
+		if f.Desc {
+			op = "<"
+		}
+		after := "(" + column + " " + op + " " + value + " OR (" + column + " = " + value + " AND t.id " + op + " ?))"
+		switch {
+		case f.sortKey() != TaskSortDue:
+			where += " AND " + after
+			args = append(args, f.sortArg(f.After), f.sortArg(f.After), f.After.ID)
+		case f.After.DueAt != nil:
+			where += " AND (t.due_at IS NULL OR " + after + ")"
+			args = append(args, f.sortArg(f.After), f.sortArg(f.After), f.After.ID)
+		default:
+			where += " AND t.due_at IS NULL AND t.id " + op + " ?"
+			args = append(args, f.After.ID)
+		}
+	}
+
+	return where, args
+}
+
+// sqlOrder returns the ORDER BY and LIMIT clauses for the filter's sort and limit.
+func (f TaskFilter) sqlOrder() (string, []interface{}) {
+	dir := " ASC"
+	if f.Desc {
+		dir = " DESC"
+	}
+	column, _ := f.sortColumn()
+	order := " ORDER BY " + column + dir + ", t.id" + dir
+	if f.sortKey() == TaskSortDue {
+		order = " ORDER BY t.due_at IS NULL, " + column + dir + ", t.id" + dir
+	}
+	if f.Limit > 0 {
+		return order + " LIMIT ?", []interface{}{f.Limit}
+	}
+	return order, nil
+}
+
+// sortColumn returns the SQL expression tasks are sorted by and the expression to
+// compare it with a task's value, bound by sortArg.
+func (f TaskFilter) sortColumn() (column, value string) {
+	switch f.sortKey() {
+	case TaskSortCreated:
+		return "julianday(t.created_at)", "julianday(?)"
+	case TaskSortUpdated:
+		return "julianday(t.updated_at)", "julianday(?)"
+	case TaskSortDue:
+		return "t.due_at", "?"
+	case TaskSortText:
+		return "t.text", "?"
+	default:
+		return "t.sort_order", "?"
+	}
+}
+
+// sortArg returns a task's value of the filter's sort key as a query argument.
+func (f TaskFilter) sortArg(task *Task) interface{} {
+	switch f.sortKey() {
+	case TaskSortCreated:
+		return sqlTime(task.CreatedAt)
+	case TaskSortUpdated:
+		return sqlTime(task.UpdatedAt)
+	case TaskSortDue:
+		return task.DueAt.sqlValue()
+	case TaskSortText:
+		return task.Text
+	default:
+		return task.SortOrder
+	}
+}
+
+// ListFilter pages a list query. Lists are ordered by title, then ID; After, if
+// set, skips the lists up to and including this one, reading only its ID and
+// title, and Limit caps the number returned, with zero meaning no limit.
+type ListFilter struct {
+	After *List
+	Limit int
+}
+
+// Matches reports whether a list comes after the filter's After list.
+func (f ListFilter) Matches(list *List) bool {
+	return f.After == nil || CompareLists(list, f.After) > 0
+}
+
+// CompareLists orders lists by title, then ID.
+func CompareLists(a, b *List) int {
+	if c := strings.Compare(a.Title, b.Title); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// timeRangeConditions returns WHERE conditions equivalent to inRange for a
+// timestamp column.
+func timeRangeConditions(column string, after, before *time.Time) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	// julianday reads both CURRENT_TIMESTAMP values and sqlTime ones
+	if after != nil {
+		where += " AND julianday(" + column + ") >= julianday(?)"
+		args = append(args, sqlTime(*after))
+	}
+	if before != nil {
+		where += " AND julianday(" + column + ") < julianday(?)"
+		args = append(args, sqlTime(*before))
+	}
+	return where, args
+}
+
+// inRange reports whether t falls in [after, before), either bound being optional.
+func inRange(t time.Time, after, before *time.Time) bool {
+	return (after == nil || !t.Before(*after)) && (before == nil || t.Before(*before))
+}
+
+// uniqueStrings returns values with duplicates removed, keeping the first of each.
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
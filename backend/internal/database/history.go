@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// History actions.
+const (
+	HistoryCreate  = "create"
+	HistoryUpdate  = "update"
+	HistoryDelete  = "delete"
+	HistoryRestore = "restore"
+)
+
+// HistoryEntry records one change to a task or one of its subtasks.
+// Entries are keyed by task, not list, so they follow the task when it moves.
+// Update entries name the changed field and carry its old and new values as JSON;
+// create entries carry the initial text as the new value.
+type HistoryEntry struct {
+	ID        int64           `json:"id"`
+	TaskID    int64           `json:"taskId"`
+	SubtaskID *int64          `json:"subtaskId,omitempty"`
+	ActorID   int64           `json:"actorId"`
+	Action    string          `json:"action"`
+	Field     string          `json:"field,omitempty"`
+	OldValue  json.RawMessage `json:"oldValue,omitempty"`
+	NewValue  json.RawMessage `json:"newValue,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// FieldChange is a single field that differs between two versions of a task or subtask.
+type FieldChange struct {
+	Field    string
+	OldValue json.RawMessage
+	NewValue json.RawMessage
+}
+
+// DiffTask returns the tracked fields that differ between two versions of a task:
+// text, completed, important, listId, dueAt, startAt, recurrence, repeatFrom and
+// tags. UI state such as isExpanded and sortOrder is not tracked.
+func DiffTask(old, new *Task) []FieldChange {
+	var changes []FieldChange
+	if old.Text != new.Text {
+		changes = append(changes, fieldChange("text", old.Text, new.Text))
+	}
+	if old.Completed != new.Completed {
+		changes = append(changes, fieldChange("completed", old.Completed, new.Completed))
+	}
+	if old.Important != new.Important {
+		changes = append(changes, fieldChange("important", old.Important, new.Important))
+	}
+	if !sameID(old.ListID, new.ListID) {
+		changes = append(changes, fieldChange("listId", old.ListID, new.ListID))
+	}
+	if !old.DueAt.Equal(new.DueAt) {
+		changes = append(changes, fieldChange("dueAt", old.DueAt, new.DueAt))
+	}
+	if !old.StartAt.Equal(new.StartAt) {
+		changes = append(changes, fieldChange("startAt", old.StartAt, new.StartAt))
+	}
+	if old.Recurrence != new.Recurrence {
+		changes = append(changes, fieldChange("recurrence", old.Recurrence, new.Recurrence))
+	}
+	if old.RepeatFrom != new.RepeatFrom {
+		changes = append(changes, fieldChange("repeatFrom", old.RepeatFrom, new.RepeatFrom))
+	}
+	if !sameTags(old.Tags, new.Tags) {
+		changes = append(changes, fieldChange("tags", nonNilTags(old.Tags), nonNilTags(new.Tags)))
+	}
+	return changes
+}
+
+// DiffSubtask returns the tracked fields that differ between two versions of a subtask.
+func DiffSubtask(old, new *Subtask) []FieldChange {
+	var changes []FieldChange
+	if old.Text != new.Text {
+		changes = append(changes, fieldChange("text", old.Text, new.Text))
+	}
+	if old.Completed != new.Completed {
+		changes = append(changes, fieldChange("completed", old.Completed, new.Completed))
+	}
+	return changes
+}
+
+// HistoryValue encodes a value for HistoryEntry.OldValue or NewValue.
+func HistoryValue(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func fieldChange(field string, old, new interface{}) FieldChange {
+	return FieldChange{Field: field, OldValue: HistoryValue(old), NewValue: HistoryValue(new)}
+}
+
+func sameID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameTags compares two tag lists as sets.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// nonNilTags makes an empty tag list encode as [] rather than null.
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// GetTaskHistory returns up to limit history entries for a task, newest first.
+// If before is non-zero only entries with a smaller ID are returned, which lets
+// callers page through the history using the last ID they saw. History stays
+// readable while the task is in the trash.
+func (db *DB) GetTaskHistory(ctx context.Context, userID, taskID, before int64, limit int) ([]*HistoryEntry, error) {
+	var exists int
+	err := db.QueryRowContext(ctx,
+		`SELECT 1 FROM tasks WHERE id = ? AND user_id = ?`,
+		taskID, userID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	query := `SELECT id, task_id, subtask_id, actor_id, action, field, old_value, new_value, created_at
+		 FROM task_history WHERE task_id = ?`
+	args := []interface{}{taskID}
+	if before > 0 {
+		query += ` AND id < ?`
+		args = append(args, before)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*HistoryEntry
+	for rows.Next() {
+		entry := &HistoryEntry{}
+		var field, oldValue, newValue sql.NullString
+		err := rows.Scan(&entry.ID, &entry.TaskID, &entry.SubtaskID, &entry.ActorID, &entry.Action,
+			&field, &oldValue, &newValue, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan history entry: %w", err)
+		}
+		entry.Field = field.String
+		if oldValue.Valid {
+			entry.OldValue = json.RawMessage(oldValue.String)
+		}
+		if newValue.Valid {
+			entry.NewValue = json.RawMessage(newValue.String)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// recordHistory inserts a history entry without a field, such as a create or delete.
+func recordHistory(ctx context.Context, tx *sql.Tx, actorID, taskID int64, subtaskID *int64, action string, newValue json.RawMessage) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO task_history (task_id, subtask_id, actor_id, action, new_value, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		taskID, subtaskID, actorID, action, nullRaw(newValue), sqlTime(time.Now()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return nil
+}
+
+// recordChanges inserts one update entry per changed field.
+func recordChanges(ctx context.Context, tx *sql.Tx, actorID, taskID int64, subtaskID *int64, changes []FieldChange) error {
+	ts := sqlTime(time.Now())
+	for _, change := range changes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO task_history (task_id, subtask_id, actor_id, action, field, old_value, new_value, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			taskID, subtaskID, actorID, HistoryUpdate, change.Field, string(change.OldValue), string(change.NewValue), ts,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record history: %w", err)
+		}
+	}
+	return nil
+}
+
+// nullRaw stores an empty JSON value as NULL.
+func nullRaw(v json.RawMessage) interface{} {
+	if len(v) == 0 {
+		return nil
+	}
+	return string(v)
+}
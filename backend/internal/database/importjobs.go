@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Import job statuses. A job is running until it either completes or fails.
+const (
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// ImportJobInterrupted is the error of a job that was running when the server
+// stopped.
+const ImportJobInterrupted = "interrupted by a server restart"
+
+// ImportJob is an import of another app's export file that runs in the
+// background. Processed counts the tasks imported so far out of Total.
+type ImportJob struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"userId"`
+	Source    string `json:"source"`
+	Status    string `json:"status"`
+	Total     int    `json:"total"`
+	Processed int    `json:"processed"`
+	// Report counts what the import did. It is set when the job completes.
+	Report *ImportReport `json:"report,omitempty"`
+	// Errors lists the items that were left out or only partly imported.
+	Errors []ImportItemError `json:"errors"`
+	// Error says why a failed job failed.
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// ImportItemError is a problem with one item of an imported file. Item
+// identifies it in the file and Text is its title, if it has one.
+type ImportItemError struct {
+	Item    string `json:"item"`
+	Text    string `json:"text,omitempty"`
+	Message string `json:"message"`
+}
+
+// importJobColumns is the column list read by scanImportJob, qualified with the "ij" alias.
+const importJobColumns = `ij.id, ij.user_id, ij.source, ij.status, ij.total, ij.processed,
+	ij.report, ij.errors, ij.error, ij.created_at, ij.finished_at`
+
+// scanImportJob scans a row selected with importJobColumns.
+func scanImportJob(row rowScanner) (*ImportJob, error) {
+	job := &ImportJob{}
+	var report sql.NullString
+	var errs string
+	var finishedAt sql.NullTime
+	if err := row.Scan(&job.ID, &job.UserID, &job.Source, &job.Status, &job.Total, &job.Processed,
+		&report, &errs, &job.Error, &job.CreatedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	if report.Valid {
+		if err := json.Unmarshal([]byte(report.String), &job.Report); err != nil {
+			return nil, fmt.Errorf("failed to decode import report: %w", err)
+		}
+	}
+	if err := json.Unmarshal([]byte(errs), &job.Errors); err != nil {
+		return nil, fmt.Errorf("failed to decode import errors: %w", err)
+	}
+	if job.Errors == nil {
+		job.Errors = []ImportItemError{}
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
+
+// CreateImportJob records a running import of total tasks.
+func (db *DB) CreateImportJob(ctx context.Context, userID int64, source string, total int) (*ImportJob, error) {
+	result, err := db.ExecContext(ctx,
+		`INSERT INTO import_jobs (user_id, source, status, total) VALUES (?, ?, ?, ?)`,
+		userID, source, ImportJobRunning, total,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job id: %w", err)
+	}
+
+	return db.GetImportJob(ctx, userID, id)
+}
+
+// UpdateImportJob saves a job's status, progress, report and errors. A job that
+// is no longer running is given its finish time.
+func (db *DB) UpdateImportJob(ctx context.Context, job *ImportJob) error {
+	var report interface{}
+	if job.Report != nil {
+		data, err := json.Marshal(job.Report)
+		if err != nil {
+			return fmt.Errorf("failed to encode import report: %w", err)
+		}
+		report = string(data)
+	}
+	errs := job.Errors
+	if errs == nil {
+		errs = []ImportItemError{}
+	}
+	data, err := json.Marshal(errs)
+	if err != nil {
+		return fmt.Errorf("failed to encode import errors: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx,
+		`UPDATE import_jobs SET status = ?, processed = ?, report = ?, errors = ?, error = ?,
+		   finished_at = CASE WHEN ? = ? THEN NULL ELSE COALESCE(finished_at, CURRENT_TIMESTAMP) END
+		 WHERE id = ? AND user_id = ?`,
+		job.Status, job.Processed, report, string(data), job.Error,
+		job.Status, ImportJobRunning, job.ID, job.UserID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update import job: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetImportJob retrieves one of a user's import jobs.
+func (db *DB) GetImportJob(ctx context.Context, userID, jobID int64) (*ImportJob, error) {
+	job, err := scanImportJob(db.QueryRowContext(ctx,
+		`SELECT `+importJobColumns+` FROM import_jobs ij WHERE ij.id = ? AND ij.user_id = ?`,
+		jobID, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+
+	return job, nil
+}
+
+// GetImportJobs retrieves a user's import jobs, newest first.
+func (db *DB) GetImportJobs(ctx context.Context, userID int64) ([]*ImportJob, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+importJobColumns+` FROM import_jobs ij WHERE ij.user_id = ? ORDER BY ij.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*ImportJob
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// FailInterruptedImportJobs marks every running import job failed. The server
+// calls it at startup, since no job can still be running then. It returns the
+// number of jobs it marked.
+func (db *DB) FailInterruptedImportJobs(ctx context.Context) (int64, error) {
+	result, err := db.ExecContext(ctx,
+		`UPDATE import_jobs SET status = ?, error = ?, finished_at = CURRENT_TIMESTAMP WHERE status = ?`,
+		ImportJobFailed, ImportJobInterrupted, ImportJobRunning,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update import jobs: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
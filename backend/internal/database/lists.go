@@ -2,17 +2,36 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"time"
 )
 
 // List represents a user-created list of tasks.
 type List struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"userId"`
-	Title     string    `json:"title"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"userId"`
+	Title     string     `json:"title"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// listColumns is the column list read by scanList, qualified with the "l" alias.
+const listColumns = `l.id, l.user_id, l.title, l.version, l.created_at, l.updated_at, l.deleted_at`
+
+// scanList scans a row selected with listColumns.
+func scanList(row rowScanner) (*List, error) {
+	list := &List{}
+	var deletedAt sql.NullTime
+	if err := row.Scan(&list.ID, &list.UserID, &list.Title, &list.Version, &list.CreatedAt, &list.UpdatedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		list.DeletedAt = &deletedAt.Time
+	}
+	return list, nil
 }
 
 // CreateList creates a new list.
@@ -34,17 +53,27 @@ func (db *DB) CreateList(ctx context.Context, userID int64, title string) (*List
 		ID:        id,
 		UserID:    userID,
 		Title:     title,
+		Version:   1,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}, nil
 }
 
-// GetLists retrieves all lists for a user.
-func (db *DB) GetLists(ctx context.Context, userID int64) ([]*List, error) {
-	rows, err := db.QueryContext(ctx,
-		`SELECT id, user_id, title, created_at, updated_at FROM lists WHERE user_id = ? ORDER BY title ASC`,
-		userID,
-	)
+// GetLists retrieves a user's lists, ordered by title, that pass the filter.
+func (db *DB) GetLists(ctx context.Context, userID int64, filter ListFilter) ([]*List, error) {
+	query := `SELECT ` + listColumns + ` FROM lists l WHERE l.user_id = ? AND l.deleted_at IS NULL`
+	args := []interface{}{userID}
+	if filter.After != nil {
+		query += ` AND (l.title > ? OR (l.title = ? AND l.id > ?))`
+		args = append(args, filter.After.Title, filter.After.Title, filter.After.ID)
+	}
+	query += ` ORDER BY l.title ASC, l.id ASC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query lists: %w", err)
 	}
@@ -52,8 +81,8 @@ func (db *DB) GetLists(ctx context.Context, userID int64) ([]*List, error) {
 
 	var lists []*List
 	for rows.Next() {
-		list := &List{}
-		if err := rows.Scan(&list.ID, &list.UserID, &list.Title, &list.CreatedAt, &list.UpdatedAt); err != nil {
+		list, err := scanList(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan list: %w", err)
 		}
 		lists = append(lists, list)
@@ -62,34 +91,124 @@ func (db *DB) GetLists(ctx context.Context, userID int64) ([]*List, error) {
 	return lists, rows.Err()
 }
 
-// UpdateList updates a list's title.
-func (db *DB) UpdateList(ctx context.Context, userID, listID int64, title string) (*List, error) {
-	result, err := db.ExecContext(ctx,
-		`UPDATE lists SET title = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`,
-		title, listID, userID,
+// GetList retrieves a single list by ID for a specific user.
+func (db *DB) GetList(ctx context.Context, userID, listID int64) (*List, error) {
+	list, err := scanList(db.QueryRowContext(ctx,
+		`SELECT `+listColumns+` FROM lists l WHERE l.id = ? AND l.user_id = ? AND l.deleted_at IS NULL`,
+		listID, userID,
+	))
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list: %w", err)
+	}
+
+	return list, nil
+}
+
+// UpdateList updates a list's title and records the change in the user's undo
+// log. A non-zero version makes the update conditional: it fails with
+// ErrVersionConflict unless the list is at that version.
+func (db *DB) UpdateList(ctx context.Context, userID, listID int64, title string, version int64) (*List, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := getListTx(ctx, tx, userID, listID)
+	if err != nil {
+		return nil, err
+	}
+	if err := updateListTx(ctx, tx, userID, listID, title, version); err != nil {
+		return nil, err
+	}
+	list, err := getListTx(ctx, tx, userID, listID)
+	if err != nil {
+		return nil, err
+	}
+	if change, ok := ListChange(old, list); ok {
+		if err := recordOperationTx(ctx, tx, userID, OperationListUpdate, []OperationChange{change}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return list, nil
+}
+
+// updateListTx sets a live list's title within a transaction.
+func updateListTx(ctx context.Context, tx *sql.Tx, userID, listID int64, title string, version int64) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE lists SET title = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`,
+		title, listID, userID, version, version,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update list: %w", err)
+		return fmt.Errorf("failed to update list: %w", err)
 	}
 
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return nil, ErrNotFound
+		if _, err := getListTx(ctx, tx, userID, listID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
 	}
+	return nil
+}
 
-	return &List{
-		ID:        listID,
-		UserID:    userID,
-		Title:     title,
-		UpdatedAt: time.Now(), // Approx
-	}, nil
+// getListTx loads a live list within a transaction.
+func getListTx(ctx context.Context, tx *sql.Tx, userID, listID int64) (*List, error) {
+	list, err := scanList(tx.QueryRowContext(ctx,
+		`SELECT `+listColumns+` FROM lists l WHERE l.id = ? AND l.user_id = ? AND l.deleted_at IS NULL`,
+		listID, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list: %w", err)
+	}
+	return list, nil
 }
 
-// DeleteList deletes a list and (optionally) its tasks or unassigns them.
-// For now, we will CASCADE delete tasks via FK constraint if simpler, or just delete list.
-// The schema should probably handle CASCADE.
+// DeleteList moves a list and the tasks in it to the trash. The tasks are flagged
+// with deleted_with_list so RestoreList can bring them back together. The delete
+// is recorded in the user's undo log.
 func (db *DB) DeleteList(ctx context.Context, userID, listID int64) error {
-	result, err := db.ExecContext(ctx, `DELETE FROM lists WHERE id = ? AND user_id = ?`, listID, userID)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteListTx(ctx, tx, userID, listID); err != nil {
+		return err
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationListDelete, []OperationChange{
+		{Entity: SyncList, ID: listID, Action: HistoryDelete},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteListTx moves a list and the tasks in it to the trash within a transaction.
+func deleteListTx(ctx context.Context, tx *sql.Tx, userID, listID int64) error {
+	deletedAt := sqlTime(time.Now())
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE lists SET deleted_at = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE id = ? AND user_id = ? AND deleted_at IS NULL`,
+		deletedAt, listID, userID,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to delete list: %w", err)
 	}
@@ -97,5 +216,24 @@ func (db *DB) DeleteList(ctx context.Context, userID, listID int64) error {
 	if rows == 0 {
 		return ErrNotFound
 	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO task_history (task_id, actor_id, action, created_at)
+		 SELECT id, ?, ?, ? FROM tasks WHERE list_id = ? AND deleted_at IS NULL`,
+		userID, HistoryDelete, deletedAt, listID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE tasks SET deleted_at = ?, deleted_with_list = TRUE, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE list_id = ? AND deleted_at IS NULL`,
+		deletedAt, listID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete list tasks: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateAppPassword stores the hash of a new app password.
+func (s *Store) CreateAppPassword(ctx context.Context, userID int64, password, name string) (*database.AppPassword, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := &database.AppPassword{
+		ID:           s.nextID("app_passwords"),
+		UserID:       userID,
+		PasswordHash: hashToken(password),
+		Name:         name,
+		CreatedAt:    now(),
+	}
+	s.appPasswords[created.ID] = created
+
+	copied := *created
+	return &copied, nil
+}
+
+// GetAppPasswords retrieves a user's app passwords, oldest first.
+func (s *Store) GetAppPasswords(ctx context.Context, userID int64) ([]*database.AppPassword, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var passwords []*database.AppPassword
+	for _, password := range s.appPasswords {
+		if password.UserID == userID {
+			copied := *password
+			passwords = append(passwords, &copied)
+		}
+	}
+
+	sort.Slice(passwords, func(i, j int) bool { return passwords[i].ID < passwords[j].ID })
+	return passwords, nil
+}
+
+// CheckAppPassword finds the user's app password matching password.
+func (s *Store) CheckAppPassword(ctx context.Context, userID int64, password string) (*database.AppPassword, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	passwordHash := hashToken(password)
+	for _, found := range s.appPasswords {
+		if found.UserID == userID && found.PasswordHash == passwordHash {
+			copied := *found
+			return &copied, nil
+		}
+	}
+
+	return nil, database.ErrNotFound
+}
+
+// DeleteAppPassword revokes an app password.
+func (s *Store) DeleteAppPassword(ctx context.Context, userID, passwordID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	password, ok := s.appPasswords[passwordID]
+	if !ok || password.UserID != userID {
+		return database.ErrNotFound
+	}
+
+	delete(s.appPasswords, passwordID)
+	return nil
+}
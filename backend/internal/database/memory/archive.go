@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// ImportArchive restores a validated archive into a user's account. On a dry run
+// the report is worked out without changing anything.
+func (s *Store) ImportArchive(ctx context.Context, userID int64, archive *database.Archive, opts database.ImportOptions) (*database.ImportReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apply := !opts.DryRun
+	report := &database.ImportReport{ListIDs: make(map[int64]int64), TaskIDs: make(map[int64]int64)}
+
+	if opts.Replace {
+		s.replaceUserData(userID, archive.Settings, report, apply)
+	}
+
+	// Lists merge with the live list of the same title that existed before the import
+	existingLists := make(map[string]int64)
+	if !opts.Replace {
+		for _, list := range s.lists {
+			if list.UserID != userID || list.DeletedAt != nil {
+				continue
+			}
+			if id, ok := existingLists[list.Title]; !ok || list.ID < id {
+				existingLists[list.Title] = list.ID
+			}
+		}
+	}
+
+	for _, list := range archive.Lists {
+		if id, ok := existingLists[list.Title]; ok {
+			report.ListIDs[list.ID] = id
+			report.Lists.Merged++
+			continue
+		}
+		report.Lists.Created++
+		if !apply {
+			continue
+		}
+		createdAt := importTime(list.CreatedAt)
+		created := &database.List{
+			ID:        s.nextID("lists"),
+			UserID:    userID,
+			Title:     list.Title,
+			Version:   1,
+			CreatedAt: createdAt,
+			UpdatedAt: now(),
+		}
+		s.lists[created.ID] = created
+		s.changed(userID, database.SyncList, created.ID)
+		report.ListIDs[list.ID] = created.ID
+	}
+
+	for _, tag := range archive.AllTags() {
+		if !opts.Replace && s.findTag(userID, tag.Name) != nil {
+			report.Tags.Merged++
+			continue
+		}
+		report.Tags.Created++
+		if !apply {
+			continue
+		}
+		created := &database.Tag{
+			ID:          s.nextID("tags"),
+			UserID:      userID,
+			Name:        tag.Name,
+			Color:       tag.Color,
+			Description: tag.Description,
+			CreatedAt:   now(),
+		}
+		s.tags[created.ID] = created
+		s.changed(userID, database.SyncTag, created.ID)
+	}
+
+	maxOrder := 0
+	for _, task := range s.tasks {
+		if task.UserID == userID && task.SortOrder > maxOrder {
+			maxOrder = task.SortOrder
+		}
+	}
+
+	for i, task := range archive.Tasks {
+		report.Tasks.Created++
+		report.Subtasks.Created += len(task.Subtasks)
+		if !apply {
+			continue
+		}
+
+		created := &database.Task{
+			ID:         s.nextID("tasks"),
+			UserID:     userID,
+			Text:       task.Text,
+			Completed:  task.Completed,
+			Important:  task.Important,
+			SortOrder:  maxOrder + i + 1,
+			Version:    1,
+			DueAt:      copyDate(task.DueAt),
+			StartAt:    copyDate(task.StartAt),
+			Recurrence: task.Recurrence,
+			RepeatFrom: task.RepeatFrom,
+			CreatedAt:  importTime(task.CreatedAt),
+			UpdatedAt:  now(),
+		}
+		if task.ListID != nil {
+			id := report.ListIDs[*task.ListID]
+			created.ListID = &id
+		} else if opts.ListID != nil {
+			id := *opts.ListID
+			created.ListID = &id
+		}
+		if task.Completed {
+			completedAt := stamp()
+			if task.CompletedAt != nil {
+				completedAt = task.CompletedAt.UTC().Truncate(time.Millisecond)
+			}
+			created.CompletedAt = &completedAt
+		}
+		s.tasks[created.ID] = created
+		s.changed(userID, database.SyncTask, created.ID)
+		if task.ID != 0 {
+			report.TaskIDs[task.ID] = created.ID
+		}
+		s.addTagsToTask(userID, created.ID, task.Tags)
+
+		for j, subtask := range task.Subtasks {
+			createdSubtask := &database.Subtask{
+				ID:        s.nextID("subtasks"),
+				TaskID:    created.ID,
+				Text:      subtask.Text,
+				Completed: subtask.Completed,
+				SortOrder: j + 1,
+				Version:   1,
+				CreatedAt: importTime(subtask.CreatedAt),
+			}
+			s.subtasks[createdSubtask.ID] = createdSubtask
+			s.changed(userID, database.SyncSubtask, createdSubtask.ID)
+		}
+
+		s.record(userID, created.ID, nil, database.HistoryCreate, database.HistoryValue(task.Text))
+	}
+
+	type smartListKey struct{ title, query string }
+	existingSmartLists := make(map[smartListKey]bool)
+	if !opts.Replace {
+		for _, list := range s.smartLists {
+			if list.UserID == userID {
+				existingSmartLists[smartListKey{list.Title, list.Query}] = true
+			}
+		}
+	}
+	for _, list := range archive.SmartLists {
+		key := smartListKey{list.Title, list.Query}
+		if existingSmartLists[key] {
+			report.SmartLists.Merged++
+			continue
+		}
+		existingSmartLists[key] = true
+		report.SmartLists.Created++
+		if !apply {
+			continue
+		}
+		ts := now()
+		created := &database.SmartList{
+			ID:        s.nextID("smart_lists"),
+			UserID:    userID,
+			Title:     list.Title,
+			Query:     list.Query,
+			Version:   1,
+			CreatedAt: ts,
+			UpdatedAt: ts,
+		}
+		s.smartLists[created.ID] = created
+		s.changed(userID, database.SyncSmartList, created.ID)
+	}
+
+	if !apply {
+		report.ListIDs, report.TaskIDs = nil, nil
+	}
+	return report, nil
+}
+
+// replaceUserData deletes, if apply is set, a user's lists, tasks, tags and
+// smart lists and applies the archive's settings, counting them in report.
+func (s *Store) replaceUserData(userID int64, settings database.ArchiveSettings, report *database.ImportReport, apply bool) {
+	for id, task := range s.tasks {
+		if task.UserID != userID {
+			continue
+		}
+		report.Tasks.Deleted++
+		for _, subtask := range s.subtasks {
+			if subtask.TaskID == id {
+				report.Subtasks.Deleted++
+			}
+		}
+		if apply {
+			s.deleteTask(id)
+		}
+	}
+	for id, list := range s.lists {
+		if list.UserID != userID {
+			continue
+		}
+		report.Lists.Deleted++
+		if apply {
+			s.changed(userID, database.SyncList, id)
+			s.deleteListFeeds(id)
+			delete(s.lists, id)
+		}
+	}
+	for id, tag := range s.tags {
+		if tag.UserID != userID {
+			continue
+		}
+		report.Tags.Deleted++
+		if apply {
+			s.deleteTag(id)
+		}
+	}
+	for id, list := range s.smartLists {
+		if list.UserID != userID {
+			continue
+		}
+		report.SmartLists.Deleted++
+		if apply {
+			s.changed(userID, database.SyncSmartList, id)
+			delete(s.smartLists, id)
+		}
+	}
+
+	if settings.DisplayName != "" || settings.Timezone != "" {
+		report.Settings = true
+		if user, ok := s.users[userID]; ok && apply {
+			if settings.DisplayName != "" {
+				user.DisplayName = settings.DisplayName
+			}
+			if settings.Timezone != "" {
+				user.Timezone = settings.Timezone
+			}
+			user.UpdatedAt = now()
+		}
+	}
+}
+
+// importTime returns an imported timestamp at the precision the SQLite store
+// keeps, or the current time if it is zero.
+func importTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return now()
+	}
+	return t.UTC().Truncate(time.Millisecond)
+}
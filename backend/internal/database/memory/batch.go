@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// BatchUpdateTasks applies operations to a user's tasks in order, all or nothing.
+// An operation that completes a recurring task also creates its next occurrence,
+// scheduled by next, as CompleteRecurringTask does. The batch is recorded in the
+// user's undo log as one operation. If any operation would fail nothing is
+// applied and the error is a *database.BatchError.
+func (s *Store) BatchUpdateTasks(ctx context.Context, userID int64, ops []database.TaskOperation, next database.NextOccurrence) (*database.BatchUpdate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check the whole batch against the versions and deletions earlier operations
+	// would leave behind, and schedule the occurrences it spawns, before changing
+	// anything, since there is no rollback. Batch operations leave the dates a
+	// schedule reads alone, so it only has to track which rules are used up.
+	versions := make(map[int64]int64)
+	recurs := make(map[int64]bool)
+	occurrences := make(map[int]*database.Occurrence)
+	for i, op := range ops {
+		version, ok := versions[op.TaskID]
+		if !ok {
+			if task, found := s.tasks[op.TaskID]; found && task.UserID == userID && task.DeletedAt == nil {
+				version = task.Version
+				recurs[op.TaskID] = task.Recurrence != ""
+			}
+		}
+		if version == 0 {
+			return nil, &database.BatchError{Index: i, Err: database.ErrNotFound}
+		}
+		if op.Version != 0 && op.Version != version {
+			return nil, &database.BatchError{Index: i, Err: database.ErrVersionConflict}
+		}
+		if op.Delete {
+			versions[op.TaskID] = 0
+			continue
+		}
+		if listID, ok := op.MovesTo(); ok {
+			if list, found := s.lists[listID]; !found || list.UserID != userID || list.DeletedAt != nil {
+				return nil, &database.BatchError{Index: i, Err: database.ErrListNotFound}
+			}
+		}
+		if err := s.checkTaskUpdates(op.Updates); err != nil {
+			return nil, &database.BatchError{Index: i, Err: err}
+		}
+		versions[op.TaskID] = version + 1
+
+		if completed, _ := op.Updates["completed"].(bool); completed && recurs[op.TaskID] {
+			preview, err := s.updatedTask(s.tasks[op.TaskID], op.Updates)
+			if err != nil {
+				return nil, &database.BatchError{Index: i, Err: err}
+			}
+			occurrence, err := next(s.taskView(&preview))
+			if err != nil {
+				return nil, &database.BatchError{Index: i, Err: fmt.Errorf("failed to schedule next occurrence: %w", err)}
+			}
+			occurrences[i] = occurrence
+			recurs[op.TaskID] = false
+			versions[op.TaskID]++
+		}
+	}
+
+	// The tasks as they were, in the order the batch first touches them
+	var taskIDs []int64
+	old := make(map[int64]*database.Task)
+	for _, op := range ops {
+		if _, seen := old[op.TaskID]; !seen {
+			taskIDs = append(taskIDs, op.TaskID)
+			old[op.TaskID] = s.taskView(s.tasks[op.TaskID])
+		}
+	}
+
+	result := &database.BatchUpdate{Tasks: make([]*database.Task, len(ops))}
+	for i, op := range ops {
+		task := s.tasks[op.TaskID]
+		if op.Delete {
+			s.trashTask(userID, task)
+			continue
+		}
+		view, err := s.updateTask(userID, task, op.TaskUpdates(s.taskView(task).Tags))
+		if err != nil {
+			return nil, err
+		}
+		occurrence, ok := occurrences[i]
+		if !ok {
+			continue
+		}
+		if occurrence != nil {
+			created := s.createTask(userID, &database.Task{
+				ListID:     view.ListID,
+				Text:       view.Text,
+				Tags:       view.Tags,
+				Important:  view.Important,
+				DueAt:      occurrence.DueAt,
+				StartAt:    occurrence.StartAt,
+				Recurrence: occurrence.Recurrence,
+				RepeatFrom: view.RepeatFrom,
+			})
+			for _, subtask := range view.Subtasks {
+				s.createSubtask(userID, created.ID, subtask.Text)
+			}
+			result.Created = append(result.Created, s.taskView(created))
+		}
+		if _, err := s.updateTask(userID, task, map[string]interface{}{"recurrence": nil}); err != nil {
+			return nil, err
+		}
+	}
+
+	final := make(map[int64]*database.Task)
+	for _, id := range taskIDs {
+		if task := s.tasks[id]; task.DeletedAt == nil {
+			final[id] = s.taskView(task)
+			if change, ok := database.TaskChange(old[id], final[id]); ok {
+				result.Changes = append(result.Changes, change)
+			}
+		} else {
+			result.Changes = append(result.Changes, database.OperationChange{Entity: database.SyncTask, ID: id, Action: database.HistoryDelete})
+		}
+	}
+	for i, op := range ops {
+		result.Tasks[i] = final[op.TaskID]
+	}
+	for _, created := range result.Created {
+		result.Changes = append(result.Changes, database.OperationChange{Entity: database.SyncTask, ID: created.ID, Action: database.HistoryCreate})
+	}
+	s.recordOperation(userID, database.OperationTaskBatch, result.Changes)
+	return result, nil
+}
+
+// checkTaskUpdates reports the error updateTask would return for updates.
+func (s *Store) checkTaskUpdates(updates map[string]interface{}) error {
+	if listID, ok := updates["listId"]; ok {
+		var id int64
+		if v, ok := listID.(float64); ok {
+			id = int64(v)
+		} else if v, ok := listID.(int64); ok {
+			id = v
+		}
+		if _, ok := s.lists[id]; id != 0 && !ok {
+			return fmt.Errorf("failed to update task: list %d does not exist", id)
+		}
+	}
+	for _, key := range []string{"dueAt", "startAt"} {
+		if v, ok := updates[key]; ok {
+			if _, err := database.TaskDateFromUpdate(v); err != nil {
+				return fmt.Errorf("failed to update task: %w", err)
+			}
+		}
+	}
+	if v, ok := updates["tags"]; ok {
+		if _, err := database.TaskTagsFromUpdate(v); err != nil {
+			return fmt.Errorf("failed to update task: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateCalDAVTask creates a task for a resource a client put, and records the
+// client's name and UID for it and the creation in the user's undo log.
+func (s *Store) CreateCalDAVTask(ctx context.Context, obj *database.CalDAVObject, task *database.Task) (*database.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task.ListID != nil {
+		if _, ok := s.lists[*task.ListID]; !ok {
+			return nil, fmt.Errorf("failed to create task: list %d does not exist", *task.ListID)
+		}
+	}
+
+	created := s.createTask(obj.UserID, task)
+	s.createCalDAVObject(obj, &created.ID, nil)
+	s.recordOperation(obj.UserID, database.OperationTaskCreate, []database.OperationChange{
+		{Entity: database.SyncTask, ID: created.ID, Action: database.HistoryCreate},
+	})
+	return s.taskView(created), nil
+}
+
+// CreateCalDAVSubtask adds a subtask, completed if completed is set, to one of
+// the user's live tasks for a resource a client put, and records the client's
+// name and UID for it and the creation in the user's undo log.
+func (s *Store) CreateCalDAVSubtask(ctx context.Context, obj *database.CalDAVObject, taskID int64, text string, completed bool) (*database.Subtask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != obj.UserID || task.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+
+	created := s.createSubtask(obj.UserID, taskID, text)
+	if completed {
+		var err error
+		if created, err = s.updateSubtask(obj.UserID, s.subtasks[created.ID], map[string]interface{}{"completed": true}); err != nil {
+			return nil, err
+		}
+	}
+	s.createCalDAVObject(obj, nil, &created.ID)
+	s.recordOperation(obj.UserID, database.OperationSubtaskCreate, []database.OperationChange{
+		{Entity: database.SyncSubtask, ID: created.ID, Action: database.HistoryCreate},
+	})
+	return created, nil
+}
+
+// createCalDAVObject records a client's name and UID for a task or subtask.
+// Must be called with mu held.
+func (s *Store) createCalDAVObject(obj *database.CalDAVObject, taskID, subtaskID *int64) {
+	s.pruneCalDAVObjects()
+	s.caldavObjects = append(s.caldavObjects, &database.CalDAVObject{
+		UserID:    obj.UserID,
+		TaskID:    copyID(taskID),
+		SubtaskID: copyID(subtaskID),
+		UID:       obj.UID,
+		Name:      obj.Name,
+	})
+}
+
+// GetCalDAVObjects retrieves the names and UIDs recorded for a user's tasks and
+// subtasks.
+func (s *Store) GetCalDAVObjects(ctx context.Context, userID int64) ([]*database.CalDAVObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneCalDAVObjects()
+	var objects []*database.CalDAVObject
+	for _, obj := range s.caldavObjects {
+		if obj.UserID == userID {
+			copied := *obj
+			copied.TaskID = copyID(obj.TaskID)
+			copied.SubtaskID = copyID(obj.SubtaskID)
+			objects = append(objects, &copied)
+		}
+	}
+	return objects, nil
+}
+
+// pruneCalDAVObjects drops the objects of purged tasks and subtasks, as the
+// foreign key cascade does in SQLite. Must be called with mu held.
+func (s *Store) pruneCalDAVObjects() {
+	s.caldavObjects = slices.DeleteFunc(s.caldavObjects, func(obj *database.CalDAVObject) bool {
+		if obj.TaskID != nil {
+			_, ok := s.tasks[*obj.TaskID]
+			return !ok
+		}
+		_, ok := s.subtasks[*obj.SubtaskID]
+		return !ok
+	})
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateFeedToken stores the hash of a new feed token.
+func (s *Store) CreateFeedToken(ctx context.Context, userID int64, token, name string, listID *int64) (*database.FeedToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed := &database.FeedToken{
+		ID:        s.nextID("feed_tokens"),
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		Name:      name,
+		ListID:    copyID(listID),
+		CreatedAt: now(),
+	}
+	s.feeds[feed.ID] = feed
+
+	return copyFeed(feed), nil
+}
+
+// GetFeedTokens retrieves a user's feed tokens, oldest first.
+func (s *Store) GetFeedTokens(ctx context.Context, userID int64) ([]*database.FeedToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var feeds []*database.FeedToken
+	for _, feed := range s.feeds {
+		if feed.UserID == userID {
+			feeds = append(feeds, copyFeed(feed))
+		}
+	}
+
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].ID < feeds[j].ID })
+	return feeds, nil
+}
+
+// GetFeedTokenByToken finds a feed token by the token itself (not its hash).
+func (s *Store) GetFeedTokenByToken(ctx context.Context, token string) (*database.FeedToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokenHash := hashToken(token)
+	for _, feed := range s.feeds {
+		if feed.TokenHash == tokenHash {
+			return copyFeed(feed), nil
+		}
+	}
+
+	return nil, database.ErrNotFound
+}
+
+// DeleteFeedToken revokes a feed token.
+func (s *Store) DeleteFeedToken(ctx context.Context, userID, feedID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed, ok := s.feeds[feedID]
+	if !ok || feed.UserID != userID {
+		return database.ErrNotFound
+	}
+
+	delete(s.feeds, feedID)
+	return nil
+}
+
+// deleteListFeeds revokes the feed tokens limited to a list that is being
+// deleted, as the foreign key cascade does in SQLite. Must be called with mu held.
+func (s *Store) deleteListFeeds(listID int64) {
+	for id, feed := range s.feeds {
+		if feed.ListID != nil && *feed.ListID == listID {
+			delete(s.feeds, id)
+		}
+	}
+}
+
+func copyFeed(feed *database.FeedToken) *database.FeedToken {
+	copied := *feed
+	copied.ListID = copyID(feed.ListID)
+	return &copied
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// GetTaskHistory returns up to limit history entries for a task, newest first,
+// starting below the before ID when it is non-zero.
+func (s *Store) GetTaskHistory(ctx context.Context, userID, taskID, before int64, limit int) ([]*database.HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+
+	var entries []*database.HistoryEntry
+	history := s.history[taskID]
+	for i := len(history) - 1; i >= 0 && len(entries) < limit; i-- {
+		if before > 0 && history[i].ID >= before {
+			continue
+		}
+		copied := *history[i]
+		copied.SubtaskID = copyID(history[i].SubtaskID)
+		entries = append(entries, &copied)
+	}
+
+	return entries, nil
+}
+
+// --- Helpers (must be called with mu held) ---
+
+// record appends a history entry without a field, such as a create or delete.
+func (s *Store) record(actorID, taskID int64, subtaskID *int64, action string, newValue json.RawMessage) {
+	s.history[taskID] = append(s.history[taskID], &database.HistoryEntry{
+		ID:        s.nextID("task_history"),
+		TaskID:    taskID,
+		SubtaskID: copyID(subtaskID),
+		ActorID:   actorID,
+		Action:    action,
+		NewValue:  newValue,
+		CreatedAt: stamp(),
+	})
+}
+
+// recordChanges appends one update entry per changed field.
+func (s *Store) recordChanges(actorID, taskID int64, subtaskID *int64, changes []database.FieldChange) {
+	ts := stamp()
+	for _, change := range changes {
+		s.history[taskID] = append(s.history[taskID], &database.HistoryEntry{
+			ID:        s.nextID("task_history"),
+			TaskID:    taskID,
+			SubtaskID: copyID(subtaskID),
+			ActorID:   actorID,
+			Action:    database.HistoryUpdate,
+			Field:     change.Field,
+			OldValue:  change.OldValue,
+			NewValue:  change.NewValue,
+			CreatedAt: ts,
+		})
+	}
+}
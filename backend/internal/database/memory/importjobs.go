@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateImportJob records a running import of total tasks.
+func (s *Store) CreateImportJob(ctx context.Context, userID int64, source string, total int) (*database.ImportJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := &database.ImportJob{
+		ID:        s.nextID("import_jobs"),
+		UserID:    userID,
+		Source:    source,
+		Status:    database.ImportJobRunning,
+		Total:     total,
+		Errors:    []database.ImportItemError{},
+		CreatedAt: now(),
+	}
+	s.importJobs[created.ID] = created
+
+	return copyImportJob(created), nil
+}
+
+// UpdateImportJob saves a job's status, progress, report and errors.
+func (s *Store) UpdateImportJob(ctx context.Context, job *database.ImportJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.importJobs[job.ID]
+	if !ok || existing.UserID != job.UserID {
+		return database.ErrNotFound
+	}
+
+	updated := copyImportJob(job)
+	updated.Source, updated.Total, updated.CreatedAt = existing.Source, existing.Total, existing.CreatedAt
+	updated.FinishedAt = nil
+	if updated.Status != database.ImportJobRunning {
+		updated.FinishedAt = existing.FinishedAt
+		if updated.FinishedAt == nil {
+			finishedAt := now()
+			updated.FinishedAt = &finishedAt
+		}
+	}
+	s.importJobs[job.ID] = updated
+	return nil
+}
+
+// GetImportJob retrieves one of a user's import jobs.
+func (s *Store) GetImportJob(ctx context.Context, userID, jobID int64) (*database.ImportJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.importJobs[jobID]
+	if !ok || job.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+	return copyImportJob(job), nil
+}
+
+// GetImportJobs retrieves a user's import jobs, newest first.
+func (s *Store) GetImportJobs(ctx context.Context, userID int64) ([]*database.ImportJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var jobs []*database.ImportJob
+	for _, job := range s.importJobs {
+		if job.UserID == userID {
+			jobs = append(jobs, copyImportJob(job))
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID > jobs[j].ID })
+	return jobs, nil
+}
+
+// FailInterruptedImportJobs marks every running import job failed.
+func (s *Store) FailInterruptedImportJobs(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, job := range s.importJobs {
+		if job.Status == database.ImportJobRunning {
+			finishedAt := now()
+			job.Status, job.Error, job.FinishedAt = database.ImportJobFailed, database.ImportJobInterrupted, &finishedAt
+			n++
+		}
+	}
+	return n, nil
+}
+
+// copyImportJob returns a copy of a job that shares nothing with it.
+func copyImportJob(job *database.ImportJob) *database.ImportJob {
+	copied := *job
+	if job.Report != nil {
+		report := *job.Report
+		report.ListIDs, report.TaskIDs = nil, nil
+		copied.Report = &report
+	}
+	copied.Errors = slices.Clone(job.Errors)
+	if copied.Errors == nil {
+		copied.Errors = []database.ImportItemError{}
+	}
+	if job.FinishedAt != nil {
+		finishedAt := *job.FinishedAt
+		copied.FinishedAt = &finishedAt
+	}
+	return &copied
+}
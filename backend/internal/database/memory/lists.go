@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateList creates a new list.
+func (s *Store) CreateList(ctx context.Context, userID int64, title string) (*database.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts := now()
+	list := &database.List{
+		ID:        s.nextID("lists"),
+		UserID:    userID,
+		Title:     title,
+		Version:   1,
+		CreatedAt: ts,
+		UpdatedAt: ts,
+	}
+	s.lists[list.ID] = list
+	s.changed(userID, database.SyncList, list.ID)
+
+	copied := *list
+	return &copied, nil
+}
+
+// GetLists retrieves a user's lists, ordered by title, that pass the filter.
+func (s *Store) GetLists(ctx context.Context, userID int64, filter database.ListFilter) ([]*database.List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lists []*database.List
+	for _, list := range s.lists {
+		if list.UserID == userID && list.DeletedAt == nil && filter.Matches(list) {
+			lists = append(lists, copyList(list))
+		}
+	}
+
+	sortLists(lists)
+	if filter.Limit > 0 && len(lists) > filter.Limit {
+		lists = lists[:filter.Limit]
+	}
+
+	return lists, nil
+}
+
+// sortLists orders lists by title, then ID.
+func sortLists(lists []*database.List) {
+	sort.Slice(lists, func(i, j int) bool {
+		return database.CompareLists(lists[i], lists[j]) < 0
+	})
+}
+
+// GetList retrieves a single list by ID for a specific user.
+func (s *Store) GetList(ctx context.Context, userID, listID int64) (*database.List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, ok := s.lists[listID]
+	if !ok || list.UserID != userID || list.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+
+	return copyList(list), nil
+}
+
+// UpdateList updates a list's title and records the change in the user's undo
+// log. A non-zero version makes the update conditional.
+func (s *Store) UpdateList(ctx context.Context, userID, listID int64, title string, version int64) (*database.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.lists[listID]
+	if !ok || list.UserID != userID || list.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+	if version != 0 && list.Version != version {
+		return nil, database.ErrVersionConflict
+	}
+
+	old := copyList(list)
+	s.updateList(list, title)
+	if change, ok := database.ListChange(old, list); ok {
+		s.recordOperation(userID, database.OperationListUpdate, []database.OperationChange{change})
+	}
+	return copyList(list), nil
+}
+
+// updateList sets a live list's title.
+func (s *Store) updateList(list *database.List, title string) {
+	list.Title = title
+	list.UpdatedAt = now()
+	list.Version++
+	s.changed(list.UserID, database.SyncList, list.ID)
+}
+
+// DeleteList moves a list and the tasks in it to the trash, and records it in the
+// user's undo log.
+func (s *Store) DeleteList(ctx context.Context, userID, listID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.lists[listID]
+	if !ok || list.UserID != userID || list.DeletedAt != nil {
+		return database.ErrNotFound
+	}
+
+	s.trashList(userID, list)
+	s.recordOperation(userID, database.OperationListDelete, []database.OperationChange{
+		{Entity: database.SyncList, ID: listID, Action: database.HistoryDelete},
+	})
+	return nil
+}
+
+// trashList moves a live list and the tasks in it to the trash.
+func (s *Store) trashList(userID int64, list *database.List) {
+	ts := stamp()
+	list.DeletedAt = &ts
+	list.UpdatedAt = ts
+	list.Version++
+	s.changed(list.UserID, database.SyncList, list.ID)
+	for _, task := range s.tasks {
+		if task.ListID != nil && *task.ListID == list.ID && task.DeletedAt == nil {
+			task.DeletedAt = &ts
+			task.UpdatedAt = ts
+			task.Version++
+			s.changed(task.UserID, database.SyncTask, task.ID)
+			s.deletedWithList[task.ID] = true
+			s.record(userID, task.ID, nil, database.HistoryDelete, nil)
+		}
+	}
+}
+
+// copyList returns a copy of a list that doesn't alias stored values.
+func copyList(list *database.List) *database.List {
+	copied := *list
+	copied.DeletedAt = copyTime(list.DeletedAt)
+	return &copied
+}
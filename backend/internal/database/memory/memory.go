@@ -0,0 +1,119 @@
+// Package memory provides an in-memory implementation of database.Store.
+// It mirrors the behaviour of the SQLite repository and is meant for tests
+// and local experiments; nothing is persisted.
+package memory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// Store is an in-memory database.Store. It is safe for concurrent use.
+type Store struct {
+	mu sync.RWMutex
+
+	lastID map[string]int64
+
+	users    map[int64]*database.User
+	sessions map[int64]*database.Session
+	lists    map[int64]*database.List
+	tasks    map[int64]*database.Task
+	subtasks map[int64]*database.Subtask
+
+	smartLists map[int64]*database.SmartList
+	feeds      map[int64]*database.FeedToken
+
+	appPasswords  map[int64]*database.AppPassword
+	caldavObjects []*database.CalDAVObject
+
+	importJobs map[int64]*database.ImportJob
+
+	// operations maps a user ID to their undo log, oldest first.
+	operations map[int64][]*database.Operation
+
+	// taskTags maps a task ID to its set of tag IDs.
+	tags     map[int64]*database.Tag
+	taskTags map[int64]map[int64]bool
+
+	// history maps a task ID to its change history, oldest first.
+	history map[int64][]*database.HistoryEntry
+
+	// deletedWithList holds the IDs of trashed tasks that were deleted by DeleteList.
+	deletedWithList map[int64]bool
+
+	// changes maps each changed row to its latest change, like the SQLite
+	// sync_changes table. Sequence numbers come from nextID("sync_changes").
+	changes map[changeKey]change
+}
+
+// changeKey identifies a row by kind (database.SyncList, SyncTask, ...) and ID.
+type changeKey struct {
+	entity string
+	id     int64
+}
+
+// change is the latest change to a row.
+type change struct {
+	seq    int64
+	userID int64
+}
+
+var _ database.Store = (*Store)(nil)
+
+// New creates an empty in-memory store.
+func New() *Store {
+	return &Store{
+		lastID:   make(map[string]int64),
+		users:    make(map[int64]*database.User),
+		sessions: make(map[int64]*database.Session),
+		lists:    make(map[int64]*database.List),
+		tasks:    make(map[int64]*database.Task),
+		subtasks: make(map[int64]*database.Subtask),
+		tags:     make(map[int64]*database.Tag),
+		taskTags: make(map[int64]map[int64]bool),
+
+		smartLists: make(map[int64]*database.SmartList),
+		feeds:      make(map[int64]*database.FeedToken),
+
+		appPasswords: make(map[int64]*database.AppPassword),
+
+		importJobs: make(map[int64]*database.ImportJob),
+		operations: make(map[int64][]*database.Operation),
+
+		history:         make(map[int64][]*database.HistoryEntry),
+		deletedWithList: make(map[int64]bool),
+		changes:         make(map[changeKey]change),
+	}
+}
+
+// nextID returns the next auto-increment ID for a table. Must be called with mu held.
+func (s *Store) nextID(table string) int64 {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// changed records a change to one of the user's rows for sync. Must be called with mu held.
+func (s *Store) changed(userID int64, entity string, id int64) {
+	s.changes[changeKey{entity, id}] = change{seq: s.nextID("sync_changes"), userID: userID}
+}
+
+// now returns the current time at the same precision SQLite's CURRENT_TIMESTAMP uses.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// stamp returns the current time at the millisecond precision the SQLite store
+// uses for times it writes itself, such as deletion times.
+func stamp() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// hashToken creates a SHA-256 hash of a token, matching the SQLite store.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// recordOperation adds an operation to the end of a user's undo log, discarding
+// the operations that were undone and the oldest ones beyond MaxOperations. An
+// operation without changes is not recorded. Must be called with mu held, by the
+// change that made the operation.
+func (s *Store) recordOperation(userID int64, action string, changes []database.OperationChange) {
+	if len(changes) == 0 {
+		return
+	}
+	ops := slices.DeleteFunc(s.operations[userID], func(op *database.Operation) bool { return op.Undone })
+	op := &database.Operation{
+		ID:        s.nextID("operations"),
+		UserID:    userID,
+		Action:    action,
+		Changes:   copyChanges(changes),
+		CreatedAt: now(),
+	}
+	ops = append(ops, op)
+	if len(ops) > database.MaxOperations {
+		ops = ops[len(ops)-database.MaxOperations:]
+	}
+	s.operations[userID] = ops
+}
+
+// UndoOperation undoes the user's newest operation that has not been undone.
+func (s *Store) UndoOperation(ctx context.Context, userID int64) (*database.Operation, error) {
+	return s.stepOperation(userID, true)
+}
+
+// RedoOperation applies the user's most recently undone operation again.
+func (s *Store) RedoOperation(ctx context.Context, userID int64) (*database.Operation, error) {
+	return s.stepOperation(userID, false)
+}
+
+// stepOperation undoes or redoes the operation at the top of the undo or redo
+// stack.
+func (s *Store) stepOperation(userID int64, undo bool) (*database.Operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ops := s.operations[userID]
+	i := slices.IndexFunc(ops, func(op *database.Operation) bool { return op.Undone })
+	if undo {
+		if i < 0 {
+			i = len(ops)
+		}
+		i--
+	}
+	if i < 0 {
+		return nil, database.ErrNotFound
+	}
+	op := ops[i]
+
+	// Check every change before making any, since there is no rollback
+	for _, change := range op.Changes {
+		if err := s.checkChange(userID, change, undo); err != nil {
+			if !errors.Is(err, database.ErrNotFound) && !errors.Is(err, database.ErrStaleOperation) {
+				return nil, err
+			}
+			s.operations[userID] = slices.Delete(ops, i, i+1)
+			return nil, database.ErrStaleOperation
+		}
+	}
+
+	for j := range op.Changes {
+		change := op.Changes[j]
+		if undo {
+			change = op.Changes[len(op.Changes)-1-j]
+		}
+		if err := s.applyChange(userID, change, undo); err != nil {
+			return nil, err
+		}
+	}
+	op.Undone = undo
+
+	return copyOperation(op), nil
+}
+
+// checkChange reports the error applyChange would return for a change, with
+// ErrNotFound if its item is gone or not in the state the change expects and
+// ErrStaleOperation if an update's item no longer holds the values it expects.
+func (s *Store) checkChange(userID int64, change database.OperationChange, undo bool) error {
+	effect, updates := change.Effect(undo)
+	switch change.Entity {
+	case database.SyncTask:
+		task, ok := s.tasks[change.ID]
+		if !ok || task.UserID != userID || (task.DeletedAt != nil) != (effect == database.HistoryRestore) {
+			return database.ErrNotFound
+		}
+		if effect == database.HistoryUpdate {
+			if !change.Holds(database.TaskValues(s.taskView(task)), undo) {
+				return database.ErrStaleOperation
+			}
+			return s.checkTaskUpdates(updates)
+		}
+	case database.SyncSubtask:
+		subtask, ok := s.subtasks[change.ID]
+		if !ok || (subtask.DeletedAt != nil) != (effect == database.HistoryRestore) {
+			return database.ErrNotFound
+		}
+		if task := s.tasks[subtask.TaskID]; task.UserID != userID || task.DeletedAt != nil {
+			return database.ErrNotFound
+		}
+		if effect == database.HistoryUpdate {
+			if !change.Holds(database.SubtaskValues(subtask), undo) {
+				return database.ErrStaleOperation
+			}
+			_, hasText := updates["text"].(string)
+			_, hasCompleted := updates["completed"].(bool)
+			_, hasSortOrder := updates["sortOrder"].(float64)
+			if !hasText && !hasCompleted && !hasSortOrder {
+				return fmt.Errorf("no valid updates provided")
+			}
+		}
+	case database.SyncList:
+		list, ok := s.lists[change.ID]
+		if !ok || list.UserID != userID || (list.DeletedAt != nil) != (effect == database.HistoryRestore) {
+			return database.ErrNotFound
+		}
+		if effect == database.HistoryUpdate && !change.Holds(database.ListValues(list), undo) {
+			return database.ErrStaleOperation
+		}
+	default:
+		return fmt.Errorf("unknown change %s to %s", change.Action, change.Entity)
+	}
+	return nil
+}
+
+// applyChange undoes or redoes one change of an operation that checkChange has
+// passed.
+func (s *Store) applyChange(userID int64, change database.OperationChange, undo bool) error {
+	effect, updates := change.Effect(undo)
+	switch change.Entity {
+	case database.SyncTask:
+		task := s.tasks[change.ID]
+		switch effect {
+		case database.HistoryUpdate:
+			if _, err := s.updateTask(userID, task, updates); err != nil {
+				return err
+			}
+		case database.HistoryDelete:
+			s.trashTask(userID, task)
+		case database.HistoryRestore:
+			s.restoreTask(userID, task)
+		}
+	case database.SyncSubtask:
+		subtask := s.subtasks[change.ID]
+		switch effect {
+		case database.HistoryUpdate:
+			// A reorder's changes hold only the sort order, which isn't an update
+			// UpdateSubtask takes
+			if sortOrder, ok := updates["sortOrder"].(float64); ok {
+				s.moveSubtask(userID, subtask, int(sortOrder))
+			} else if _, err := s.updateSubtask(userID, subtask, updates); err != nil {
+				return err
+			}
+		case database.HistoryDelete:
+			s.trashSubtask(userID, subtask)
+		case database.HistoryRestore:
+			s.restoreSubtask(userID, subtask)
+		}
+	case database.SyncList:
+		list := s.lists[change.ID]
+		switch effect {
+		case database.HistoryUpdate:
+			title, _ := updates["title"].(string)
+			s.updateList(list, title)
+		case database.HistoryDelete:
+			s.trashList(userID, list)
+		case database.HistoryRestore:
+			s.restoreList(userID, list)
+		}
+	}
+	return nil
+}
+
+// copyOperation returns a copy of an operation that shares nothing with it.
+func copyOperation(op *database.Operation) *database.Operation {
+	copied := *op
+	copied.Changes = copyChanges(op.Changes)
+	return &copied
+}
+
+// copyChanges returns a deep copy of an operation's changes, with their values
+// decoded the way the SQLite store reads them back.
+func copyChanges(changes []database.OperationChange) []database.OperationChange {
+	data, _ := json.Marshal(changes)
+	var copied []database.OperationChange
+	json.Unmarshal(data, &copied)
+	return copied
+}
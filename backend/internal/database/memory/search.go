@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// SearchTasks returns the user's live tasks whose text, subtasks or tags contain
+// every search term as a word prefix. Ranking approximates the SQLite store's
+// weighting of text over subtasks over tags; snippets highlight whole words.
+func (s *Store) SearchTasks(ctx context.Context, userID int64, q database.SearchQuery) ([]*database.SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := database.SearchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var results []*database.SearchResult
+	for _, task := range s.tasks {
+		if task.UserID != userID || task.DeletedAt != nil {
+			continue
+		}
+		if q.ListID != nil && (task.ListID == nil || *task.ListID != *q.ListID) {
+			continue
+		}
+		if q.Completed != nil && task.Completed != *q.Completed {
+			continue
+		}
+		view := s.taskView(task)
+		if q.Tag != "" && !containsString(view.Tags, q.Tag) {
+			continue
+		}
+
+		var subtaskTexts []string
+		for _, subtask := range view.Subtasks {
+			subtaskTexts = append(subtaskTexts, subtask.Text)
+		}
+		fields := []string{view.Text, strings.Join(subtaskTexts, " "), strings.Join(view.Tags, " ")}
+		weights := []float64{10, 5, 2}
+
+		var score float64
+		matched := true
+		best := -1
+		for _, term := range terms {
+			found := false
+			for i, field := range fields {
+				if hits := countPrefixHits(field, term); hits > 0 {
+					found = true
+					score -= weights[i] * float64(hits)
+					if best == -1 || i < best {
+						best = i
+					}
+				}
+			}
+			if !found {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		results = append(results, &database.SearchResult{
+			Task:    view,
+			Rank:    score,
+			Snippet: highlight(fields[best], terms),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank < results[j].Rank
+		}
+		return results[i].Task.ID < results[j].Task.ID
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+
+	return results, nil
+}
+
+// countPrefixHits counts the words in text that start with term.
+func countPrefixHits(text, term string) int {
+	hits := 0
+	for _, word := range database.SearchTerms(text) {
+		if strings.HasPrefix(word, term) {
+			hits++
+		}
+	}
+	return hits
+}
+
+// highlight wraps the words of text that start with any of the terms in snippet markers.
+func highlight(text string, terms []string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		for _, w := range database.SearchTerms(word) {
+			if matchesAnyPrefix(w, terms) {
+				words[i] = database.SnippetOpen + word + database.SnippetClose
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func matchesAnyPrefix(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
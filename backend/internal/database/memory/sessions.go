@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateSession creates a new session for a user.
+func (s *Store) CreateSession(ctx context.Context, userID int64, token string, expiresAt time.Time) (*database.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := &database.Session{
+		ID:        s.nextID("sessions"),
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+		CreatedAt: now(),
+	}
+	s.sessions[session.ID] = session
+
+	copied := *session
+	return &copied, nil
+}
+
+// GetSessionByToken finds a session by its token (not hash).
+// Returns ErrNotFound if the session doesn't exist or has expired.
+func (s *Store) GetSessionByToken(ctx context.Context, token string) (*database.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokenHash := hashToken(token)
+	for _, session := range s.sessions {
+		if session.TokenHash == tokenHash && session.ExpiresAt.After(time.Now()) {
+			copied := *session
+			return &copied, nil
+		}
+	}
+
+	return nil, database.ErrNotFound
+}
+
+// DeleteSession deletes a session by token.
+func (s *Store) DeleteSession(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenHash := hashToken(token)
+	for id, session := range s.sessions {
+		if session.TokenHash == tokenHash {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// DeleteUserSessions deletes all sessions for a user (logout everywhere).
+func (s *Store) DeleteUserSessions(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// CleanupExpiredSessions removes all expired sessions.
+func (s *Store) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	current := time.Now()
+	for id, session := range s.sessions {
+		if !session.ExpiresAt.After(current) {
+			delete(s.sessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateSmartList saves a query as a smart list.
+func (s *Store) CreateSmartList(ctx context.Context, userID int64, title, query string) (*database.SmartList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts := now()
+	list := &database.SmartList{
+		ID:        s.nextID("smart_lists"),
+		UserID:    userID,
+		Title:     title,
+		Query:     query,
+		Version:   1,
+		CreatedAt: ts,
+		UpdatedAt: ts,
+	}
+	s.smartLists[list.ID] = list
+	s.changed(userID, database.SyncSmartList, list.ID)
+
+	copied := *list
+	return &copied, nil
+}
+
+// GetSmartLists retrieves a user's smart lists, ordered by title.
+func (s *Store) GetSmartLists(ctx context.Context, userID int64) ([]*database.SmartList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lists []*database.SmartList
+	for _, list := range s.smartLists {
+		if list.UserID == userID {
+			copied := *list
+			lists = append(lists, &copied)
+		}
+	}
+
+	sortSmartLists(lists)
+	return lists, nil
+}
+
+// sortSmartLists orders smart lists by title, then ID.
+func sortSmartLists(lists []*database.SmartList) {
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Title != lists[j].Title {
+			return lists[i].Title < lists[j].Title
+		}
+		return lists[i].ID < lists[j].ID
+	})
+}
+
+// GetSmartList retrieves a single smart list by ID for a specific user.
+func (s *Store) GetSmartList(ctx context.Context, userID, listID int64) (*database.SmartList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, ok := s.smartLists[listID]
+	if !ok || list.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+
+	copied := *list
+	return &copied, nil
+}
+
+// UpdateSmartList replaces a smart list's title and query. A non-zero version
+// makes the update conditional.
+func (s *Store) UpdateSmartList(ctx context.Context, userID, listID int64, title, query string, version int64) (*database.SmartList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.smartLists[listID]
+	if !ok || list.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+	if version != 0 && list.Version != version {
+		return nil, database.ErrVersionConflict
+	}
+
+	list.Title = title
+	list.Query = query
+	list.UpdatedAt = now()
+	list.Version++
+	s.changed(list.UserID, database.SyncSmartList, list.ID)
+
+	copied := *list
+	return &copied, nil
+}
+
+// DeleteSmartList deletes a smart list.
+func (s *Store) DeleteSmartList(ctx context.Context, userID, listID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.smartLists[listID]
+	if !ok || list.UserID != userID {
+		return database.ErrNotFound
+	}
+
+	delete(s.smartLists, listID)
+	s.changed(userID, database.SyncSmartList, listID)
+
+	return nil
+}
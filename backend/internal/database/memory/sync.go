@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// GetSyncCursor returns the latest sync cursor, the one GetChanges would return
+// now for any user.
+func (s *Store) GetSyncCursor(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastID["sync_changes"], nil
+}
+
+// GetChanges returns the user's rows that changed after cursor. A zero cursor, or
+// one the store never issued, returns a full snapshot with Reset set.
+func (s *Store) GetChanges(ctx context.Context, userID, cursor int64) (*database.SyncChanges, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := s.lastID["sync_changes"]
+	changes := database.NewSyncChanges(latest)
+	if cursor <= 0 || cursor > latest {
+		s.syncSnapshot(userID, changes)
+		return changes, nil
+	}
+
+	var deleted []changeKey
+	for key, c := range s.changes {
+		if c.userID != userID || c.seq <= cursor {
+			continue
+		}
+		switch key.entity {
+		case database.SyncList:
+			if list, ok := s.lists[key.id]; ok && list.DeletedAt == nil {
+				changes.Lists = append(changes.Lists, copyList(list))
+				continue
+			}
+		case database.SyncTask:
+			if task, ok := s.tasks[key.id]; ok && task.DeletedAt == nil {
+				changes.Tasks = append(changes.Tasks, s.taskView(task))
+				continue
+			}
+		case database.SyncSubtask:
+			if subtask, ok := s.liveSubtask(userID, key.id); ok {
+				changes.Subtasks = append(changes.Subtasks, copySubtask(subtask))
+				continue
+			}
+		case database.SyncTag:
+			if tag, ok := s.tags[key.id]; ok {
+				changes.Tags = append(changes.Tags, s.tagView(tag))
+				continue
+			}
+		case database.SyncSmartList:
+			if list, ok := s.smartLists[key.id]; ok {
+				copied := *list
+				changes.SmartLists = append(changes.SmartLists, &copied)
+				continue
+			}
+		}
+		deleted = append(deleted, key)
+	}
+
+	sortLists(changes.Lists)
+	sortTasks(changes.Tasks)
+	sort.Slice(changes.Subtasks, func(i, j int) bool {
+		a, b := changes.Subtasks[i], changes.Subtasks[j]
+		if a.TaskID != b.TaskID {
+			return a.TaskID < b.TaskID
+		}
+		if a.SortOrder != b.SortOrder {
+			return a.SortOrder < b.SortOrder
+		}
+		return a.ID < b.ID
+	})
+	sortTags(changes.Tags)
+	sortSmartLists(changes.SmartLists)
+
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].id < deleted[j].id })
+	for _, key := range deleted {
+		changes.Deleted.Add(key.entity, key.id)
+	}
+
+	return changes, nil
+}
+
+// syncSnapshot fills changes with all of the user's live lists, tasks, tags and
+// smart lists.
+func (s *Store) syncSnapshot(userID int64, changes *database.SyncChanges) {
+	changes.Reset = true
+
+	for _, list := range s.lists {
+		if list.UserID == userID && list.DeletedAt == nil {
+			changes.Lists = append(changes.Lists, copyList(list))
+		}
+	}
+	for _, task := range s.tasks {
+		if task.UserID == userID && task.DeletedAt == nil {
+			changes.Tasks = append(changes.Tasks, s.taskView(task))
+		}
+	}
+	for _, tag := range s.tags {
+		if tag.UserID == userID {
+			changes.Tags = append(changes.Tags, s.tagView(tag))
+		}
+	}
+	for _, list := range s.smartLists {
+		if list.UserID == userID {
+			copied := *list
+			changes.SmartLists = append(changes.SmartLists, &copied)
+		}
+	}
+
+	sortLists(changes.Lists)
+	sortTasks(changes.Tasks)
+	sortTags(changes.Tags)
+	sortSmartLists(changes.SmartLists)
+}
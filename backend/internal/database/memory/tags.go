@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// GetTags returns the user's tags ordered by name.
+func (s *Store) GetTags(ctx context.Context, userID int64) ([]*database.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tags []*database.Tag
+	for _, tag := range s.tags {
+		if tag.UserID == userID {
+			tags = append(tags, s.tagView(tag))
+		}
+	}
+
+	sortTags(tags)
+
+	return tags, nil
+}
+
+// sortTags orders tags by name, then ID.
+func sortTags(tags []*database.Tag) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Name != tags[j].Name {
+			return tags[i].Name < tags[j].Name
+		}
+		return tags[i].ID < tags[j].ID
+	})
+}
+
+// GetTag retrieves a single tag by ID for a specific user.
+func (s *Store) GetTag(ctx context.Context, userID, tagID int64) (*database.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tag, ok := s.tags[tagID]
+	if !ok || tag.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+	return s.tagView(tag), nil
+}
+
+// CreateTag creates a tag from the name, color and description set on tag.
+func (s *Store) CreateTag(ctx context.Context, userID int64, tag *database.Tag) (*database.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findTag(userID, tag.Name) != nil {
+		return nil, database.ErrTagExists
+	}
+
+	created := &database.Tag{
+		ID:          s.nextID("tags"),
+		UserID:      userID,
+		Name:        tag.Name,
+		Color:       tag.Color,
+		Description: tag.Description,
+		CreatedAt:   now(),
+	}
+	s.tags[created.ID] = created
+	s.changed(userID, database.SyncTag, created.ID)
+
+	return s.tagView(created), nil
+}
+
+// UpdateTag changes a tag's name, color or description, returning the IDs of the
+// tasks whose tags changed.
+func (s *Store) UpdateTag(ctx context.Context, userID, tagID int64, updates map[string]interface{}) (*database.Tag, []int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag, ok := s.tags[tagID]
+	if !ok || tag.UserID != userID {
+		return nil, nil, database.ErrNotFound
+	}
+
+	name, rename := updates["name"].(string)
+	rename = rename && name != tag.Name
+	if rename {
+		if s.findTag(userID, name) != nil {
+			return nil, nil, database.ErrTagExists
+		}
+	}
+
+	if v, ok := updates["color"]; ok {
+		tag.Color, _ = v.(string)
+		s.changed(userID, database.SyncTag, tagID)
+	}
+	if v, ok := updates["description"]; ok {
+		tag.Description, _ = v.(string)
+		s.changed(userID, database.SyncTag, tagID)
+	}
+
+	var taskIDs []int64
+	if rename {
+		taskIDs = s.tagTaskIDs(tagID)
+		s.changeTaskTags(userID, taskIDs, func() {
+			tag.Name = name
+			s.changed(userID, database.SyncTag, tagID)
+		})
+	}
+
+	return s.tagView(tag), taskIDs, nil
+}
+
+// MergeTags moves every task tagged with source onto target and deletes source.
+func (s *Store) MergeTags(ctx context.Context, userID, sourceID, targetID int64) (*database.Tag, []int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, ok := s.tags[sourceID]
+	if !ok || source.UserID != userID {
+		return nil, nil, database.ErrNotFound
+	}
+	target, ok := s.tags[targetID]
+	if !ok || target.UserID != userID {
+		return nil, nil, database.ErrNotFound
+	}
+
+	taskIDs := s.tagTaskIDs(sourceID)
+	s.changeTaskTags(userID, taskIDs, func() {
+		for _, id := range taskIDs {
+			s.taskTags[id][targetID] = true
+		}
+		s.deleteTag(sourceID)
+	})
+
+	return s.tagView(target), taskIDs, nil
+}
+
+// DeleteTag removes a tag from all of the user's tasks and deletes it.
+func (s *Store) DeleteTag(ctx context.Context, userID, tagID int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag, ok := s.tags[tagID]
+	if !ok || tag.UserID != userID {
+		return nil, database.ErrNotFound
+	}
+
+	taskIDs := s.tagTaskIDs(tagID)
+	s.changeTaskTags(userID, taskIDs, func() {
+		s.deleteTag(tagID)
+	})
+
+	return taskIDs, nil
+}
+
+// findTag returns the user's tag with the given name, or nil.
+func (s *Store) findTag(userID int64, name string) *database.Tag {
+	for _, tag := range s.tags {
+		if tag.UserID == userID && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// tagView returns a copy of a tag with its live task count.
+func (s *Store) tagView(tag *database.Tag) *database.Tag {
+	view := *tag
+	view.TaskCount = 0
+	for _, id := range s.tagTaskIDs(tag.ID) {
+		if s.tasks[id].DeletedAt == nil {
+			view.TaskCount++
+		}
+	}
+	return &view
+}
+
+// tagTaskIDs returns the IDs of the tasks, live or trashed, that carry a tag.
+func (s *Store) tagTaskIDs(tagID int64) []int64 {
+	var taskIDs []int64
+	for taskID, tagIDs := range s.taskTags {
+		if tagIDs[tagID] {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	sort.Slice(taskIDs, func(i, j int) bool { return taskIDs[i] < taskIDs[j] })
+	return taskIDs
+}
+
+// changeTaskTags runs change, which alters the tags of the given tasks, then
+// records each task's old and new tags in its history and bumps its version.
+func (s *Store) changeTaskTags(userID int64, taskIDs []int64, change func()) {
+	before := make(map[int64][]string, len(taskIDs))
+	for _, id := range taskIDs {
+		before[id] = s.tagsOf(id)
+	}
+
+	change()
+
+	ts := now()
+	for _, id := range taskIDs {
+		old := &database.Task{Tags: before[id]}
+		s.recordChanges(userID, id, nil, database.DiffTask(old, &database.Task{Tags: s.tagsOf(id)}))
+		s.tasks[id].UpdatedAt = ts
+		s.tasks[id].Version++
+		s.changed(userID, database.SyncTask, id)
+	}
+}
+
+// deleteTag unlinks a tag from its tasks and deletes it.
+func (s *Store) deleteTag(tagID int64) {
+	for _, tagIDs := range s.taskTags {
+		delete(tagIDs, tagID)
+	}
+	s.changed(s.tags[tagID].UserID, database.SyncTag, tagID)
+	delete(s.tags, tagID)
+}
@@ -0,0 +1,689 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateTask creates a new task for a user from the list, text, flags, dates and
+// tags set on task, and records it in the user's undo log. Other fields are
+// ignored.
+func (s *Store) CreateTask(ctx context.Context, userID int64, task *database.Task) (*database.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task.ListID != nil {
+		if _, ok := s.lists[*task.ListID]; !ok {
+			return nil, fmt.Errorf("failed to create task: list %d does not exist", *task.ListID)
+		}
+	}
+
+	created := s.createTask(userID, task)
+	s.recordOperation(userID, database.OperationTaskCreate, []database.OperationChange{
+		{Entity: database.SyncTask, ID: created.ID, Action: database.HistoryCreate},
+	})
+	return s.taskView(created), nil
+}
+
+// createTask adds a task at the end of the user's tasks.
+func (s *Store) createTask(userID int64, task *database.Task) *database.Task {
+	maxOrder := 0
+	for _, task := range s.tasks {
+		if task.UserID == userID && task.SortOrder > maxOrder {
+			maxOrder = task.SortOrder
+		}
+	}
+
+	ts := now()
+	created := &database.Task{
+		ID:         s.nextID("tasks"),
+		UserID:     userID,
+		ListID:     copyID(task.ListID),
+		Text:       task.Text,
+		Completed:  task.Completed,
+		Important:  task.Important,
+		SortOrder:  maxOrder + 1,
+		Version:    1,
+		DueAt:      copyDate(task.DueAt),
+		StartAt:    copyDate(task.StartAt),
+		Recurrence: task.Recurrence,
+		RepeatFrom: task.RepeatFrom,
+		CreatedAt:  ts,
+		UpdatedAt:  ts,
+	}
+	if task.Completed {
+		completedAt := stamp()
+		created.CompletedAt = &completedAt
+	}
+	s.tasks[created.ID] = created
+	s.changed(userID, database.SyncTask, created.ID)
+	s.addTagsToTask(userID, created.ID, task.Tags)
+	s.record(userID, created.ID, nil, database.HistoryCreate, database.HistoryValue(task.Text))
+
+	return created
+}
+
+// GetTask retrieves a single task by ID for a specific user.
+func (s *Store) GetTask(ctx context.Context, userID, taskID int64) (*database.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+
+	return s.taskView(task), nil
+}
+
+// GetUserTasks retrieves a user's tasks that pass the filter, in the filter's order.
+func (s *Store) GetUserTasks(ctx context.Context, userID int64, filter database.TaskFilter) ([]*database.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tasks []*database.Task
+	for _, task := range s.tasks {
+		if task.UserID != userID || task.DeletedAt != nil {
+			continue
+		}
+		if view := s.taskView(task); filter.Matches(view) {
+			tasks = append(tasks, view)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return filter.Compare(tasks[i], tasks[j]) < 0
+	})
+	if filter.Limit > 0 && len(tasks) > filter.Limit {
+		tasks = tasks[:filter.Limit]
+	}
+
+	return tasks, nil
+}
+
+// sortTasks orders tasks by sort order, then ID.
+func sortTasks(tasks []*database.Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].SortOrder != tasks[j].SortOrder {
+			return tasks[i].SortOrder < tasks[j].SortOrder
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// UpdateTask updates a task's properties and records the changed fields in the task's history
+// and the update in the user's undo log.
+// If version is non-zero the update only applies to that version of the task.
+func (s *Store) UpdateTask(ctx context.Context, userID, taskID int64, updates map[string]interface{}, version int64) (*database.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+	if version != 0 && task.Version != version {
+		return nil, database.ErrVersionConflict
+	}
+
+	old := s.taskView(task)
+	view, err := s.updateTask(userID, task, updates)
+	if err != nil {
+		return nil, err
+	}
+	s.recordTaskUpdate(userID, old, view, nil)
+	return view, nil
+}
+
+// CompleteRecurringTask applies updates to a task like UpdateTask. If they
+// complete a recurring task, it also creates the occurrence scheduled by next,
+// copying the task's list, text, importance, tags, repeat mode and live subtasks,
+// and clears the completed task's rule, and records it all in the user's undo
+// log. Nothing changes if any step would fail.
+func (s *Store) CompleteRecurringTask(ctx context.Context, userID, taskID int64, updates map[string]interface{}, version int64, next database.NextOccurrence) (*database.Task, *database.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt != nil {
+		return nil, nil, database.ErrNotFound
+	}
+	if version != 0 && task.Version != version {
+		return nil, nil, database.ErrVersionConflict
+	}
+
+	updated, err := s.updatedTask(task, updates)
+	if err != nil {
+		return nil, nil, err
+	}
+	old := s.taskView(task)
+	completed, _ := updates["completed"].(bool)
+	if !completed || updated.Recurrence == "" {
+		view := s.saveTask(userID, task, updated, updates)
+		s.recordTaskUpdate(userID, old, view, nil)
+		return view, nil, nil
+	}
+
+	// Schedule from a copy of the task as the updates leave it, before anything
+	// changes
+	preview := s.taskView(&updated)
+	occurrence, err := next(preview)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to schedule next occurrence: %w", err)
+	}
+
+	view := s.saveTask(userID, task, updated, updates)
+	var spawned *database.Task
+	if occurrence != nil {
+		created := s.createTask(userID, &database.Task{
+			ListID:     view.ListID,
+			Text:       view.Text,
+			Tags:       view.Tags,
+			Important:  view.Important,
+			DueAt:      occurrence.DueAt,
+			StartAt:    occurrence.StartAt,
+			Recurrence: occurrence.Recurrence,
+			RepeatFrom: view.RepeatFrom,
+		})
+		for _, subtask := range view.Subtasks {
+			s.createSubtask(userID, created.ID, subtask.Text)
+		}
+		spawned = s.taskView(created)
+	}
+
+	view, err = s.updateTask(userID, task, map[string]interface{}{"recurrence": nil})
+	if err != nil {
+		return nil, nil, err
+	}
+	s.recordTaskUpdate(userID, old, view, spawned)
+	return view, spawned, nil
+}
+
+// recordTaskUpdate logs an update of a task from old to task in the user's undo
+// log, with the creation of the next occurrence it spawned unless that is nil.
+func (s *Store) recordTaskUpdate(userID int64, old, task, spawned *database.Task) {
+	var changes []database.OperationChange
+	if change, ok := database.TaskChange(old, task); ok {
+		changes = append(changes, change)
+	}
+	if spawned != nil {
+		changes = append(changes, database.OperationChange{Entity: database.SyncTask, ID: spawned.ID, Action: database.HistoryCreate})
+	}
+	s.recordOperation(userID, database.OperationTaskUpdate, changes)
+}
+
+// updateTask applies UpdateTask's changes to a live task. It only fails before
+// anything has changed.
+func (s *Store) updateTask(userID int64, task *database.Task, updates map[string]interface{}) (*database.Task, error) {
+	updated, err := s.updatedTask(task, updates)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveTask(userID, task, updated, updates), nil
+}
+
+// updatedTask returns a copy of a task with UpdateTask's changes applied, other
+// than to its tags, without storing it.
+func (s *Store) updatedTask(task *database.Task, updates map[string]interface{}) (database.Task, error) {
+	updated := *task
+	if text, ok := updates["text"].(string); ok {
+		updated.Text = text
+	}
+	if completed, ok := updates["completed"].(bool); ok {
+		if !completed {
+			updated.CompletedAt = nil
+		} else if !task.Completed || task.CompletedAt == nil {
+			completedAt := stamp()
+			updated.CompletedAt = &completedAt
+		}
+		updated.Completed = completed
+	}
+	if recurrence, ok := updates["recurrence"]; ok {
+		updated.Recurrence, _ = recurrence.(string)
+	}
+	if repeatFrom, ok := updates["repeatFrom"]; ok {
+		updated.RepeatFrom, _ = repeatFrom.(string)
+	}
+	if important, ok := updates["important"].(bool); ok {
+		updated.Important = important
+	}
+	if isExpanded, ok := updates["isExpanded"].(bool); ok {
+		updated.IsExpanded = isExpanded
+	}
+	if sortOrder, ok := updates["sortOrder"].(float64); ok {
+		updated.SortOrder = int(sortOrder)
+	}
+	if listID, ok := updates["listId"]; ok {
+		var newListID *int64
+		if v, ok := listID.(float64); ok {
+			id := int64(v)
+			newListID = &id
+		} else if v, ok := listID.(int64); ok {
+			newListID = &v
+		}
+		if newListID != nil {
+			if _, ok := s.lists[*newListID]; !ok {
+				return database.Task{}, fmt.Errorf("failed to update task: list %d does not exist", *newListID)
+			}
+		}
+		updated.ListID = newListID
+	}
+	if v, ok := updates["dueAt"]; ok {
+		date, err := database.TaskDateFromUpdate(v)
+		if err != nil {
+			return database.Task{}, fmt.Errorf("failed to update task: %w", err)
+		}
+		updated.DueAt = copyDate(date)
+	}
+	if v, ok := updates["startAt"]; ok {
+		date, err := database.TaskDateFromUpdate(v)
+		if err != nil {
+			return database.Task{}, fmt.Errorf("failed to update task: %w", err)
+		}
+		updated.StartAt = copyDate(date)
+	}
+	if v, ok := updates["tags"]; ok {
+		if _, err := database.TaskTagsFromUpdate(v); err != nil {
+			return database.Task{}, fmt.Errorf("failed to update task: %w", err)
+		}
+	}
+	updated.UpdatedAt = now()
+	updated.Version++
+	return updated, nil
+}
+
+// saveTask stores the updated copy of a task made by updatedTask, sets its tags
+// from updates and records the changes.
+func (s *Store) saveTask(userID int64, task *database.Task, updated database.Task, updates map[string]interface{}) *database.Task {
+	old := s.taskView(task)
+	s.changed(updated.UserID, database.SyncTask, updated.ID)
+	*task = updated
+
+	if value, ok := updates["tags"]; ok {
+		// updatedTask has checked them
+		tags, _ := database.TaskTagsFromUpdate(value)
+		s.taskTags[task.ID] = nil
+		s.addTagsToTask(userID, task.ID, tags)
+	}
+
+	view := s.taskView(task)
+	s.recordChanges(userID, task.ID, nil, database.DiffTask(old, view))
+
+	return view
+}
+
+// DeleteTask moves a task to the trash and records it in the user's undo log.
+func (s *Store) DeleteTask(ctx context.Context, userID, taskID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt != nil {
+		return database.ErrNotFound
+	}
+
+	s.trashTask(userID, task)
+	s.recordOperation(userID, database.OperationTaskDelete, []database.OperationChange{
+		{Entity: database.SyncTask, ID: taskID, Action: database.HistoryDelete},
+	})
+	return nil
+}
+
+// trashTask moves a live task to the trash.
+func (s *Store) trashTask(userID int64, task *database.Task) {
+	ts := stamp()
+	task.DeletedAt = &ts
+	task.UpdatedAt = ts
+	task.Version++
+	s.changed(task.UserID, database.SyncTask, task.ID)
+	s.record(userID, task.ID, nil, database.HistoryDelete, nil)
+}
+
+// ReorderTasks updates the sort order of tasks, and records the reorder in the
+// user's undo log.
+func (s *Store) ReorderTasks(ctx context.Context, userID int64, taskIDs []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The order as it was, for the undo log
+	live := func(taskID int64) (*database.Task, bool) {
+		task, ok := s.tasks[taskID]
+		return task, ok && task.UserID == userID && task.DeletedAt == nil
+	}
+	before := make(map[int64]int, len(taskIDs))
+	for _, taskID := range taskIDs {
+		if task, ok := live(taskID); ok {
+			before[taskID] = task.SortOrder
+		}
+	}
+
+	ts := now()
+	after := make(map[int64]int, len(taskIDs))
+	for i, taskID := range taskIDs {
+		if task, ok := live(taskID); ok {
+			task.SortOrder = i
+			task.UpdatedAt = ts
+			task.Version++
+			s.changed(task.UserID, database.SyncTask, task.ID)
+			after[taskID] = i
+		}
+	}
+
+	changes := database.ReorderChanges(database.SyncTask, taskIDs, before, after)
+	s.recordOperation(userID, database.OperationTaskReorder, changes)
+	return nil
+}
+
+// --- Subtask operations ---
+
+// CreateSubtask creates a new subtask for a task and records it in the user's
+// undo log.
+func (s *Store) CreateSubtask(ctx context.Context, userID, taskID int64, text string) (*database.Subtask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+
+	subtask := s.createSubtask(userID, taskID, text)
+	s.recordOperation(userID, database.OperationSubtaskCreate, []database.OperationChange{
+		{Entity: database.SyncSubtask, ID: subtask.ID, Action: database.HistoryCreate},
+	})
+	return subtask, nil
+}
+
+// createSubtask adds a subtask to the end of a live task and returns a copy of it.
+func (s *Store) createSubtask(userID, taskID int64, text string) *database.Subtask {
+	maxOrder := 0
+	for _, subtask := range s.subtasks {
+		if subtask.TaskID == taskID && subtask.SortOrder > maxOrder {
+			maxOrder = subtask.SortOrder
+		}
+	}
+
+	subtask := &database.Subtask{
+		ID:        s.nextID("subtasks"),
+		TaskID:    taskID,
+		Text:      text,
+		SortOrder: maxOrder + 1,
+		Version:   1,
+		CreatedAt: now(),
+	}
+	s.subtasks[subtask.ID] = subtask
+	s.changed(userID, database.SyncSubtask, subtask.ID)
+	s.record(userID, taskID, &subtask.ID, database.HistoryCreate, database.HistoryValue(text))
+
+	copied := *subtask
+	return &copied
+}
+
+// GetSubtask retrieves a live subtask of one of the user's live tasks.
+func (s *Store) GetSubtask(ctx context.Context, userID, subtaskID int64) (*database.Subtask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subtask, ok := s.liveSubtask(userID, subtaskID)
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return copySubtask(subtask), nil
+}
+
+// GetSubtasks retrieves all subtasks for a task.
+func (s *Store) GetSubtasks(ctx context.Context, taskID int64) ([]*database.Subtask, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.subtasksOf(taskID), nil
+}
+
+// UpdateSubtask updates a subtask's properties and records the changed fields in
+// the parent task's history and the update in the user's undo log. A non-zero
+// version makes the update conditional.
+func (s *Store) UpdateSubtask(ctx context.Context, userID, subtaskID int64, updates map[string]interface{}, version int64) (*database.Subtask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subtask, ok := s.liveSubtask(userID, subtaskID)
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	if version != 0 && subtask.Version != version {
+		return nil, database.ErrVersionConflict
+	}
+
+	old := copySubtask(subtask)
+	updated, err := s.updateSubtask(userID, subtask, updates)
+	if err != nil {
+		return nil, err
+	}
+	if change, ok := database.SubtaskChange(old, updated); ok {
+		s.recordOperation(userID, database.OperationSubtaskUpdate, []database.OperationChange{change})
+	}
+	return updated, nil
+}
+
+// updateSubtask applies UpdateSubtask's changes to a live subtask.
+func (s *Store) updateSubtask(userID int64, subtask *database.Subtask, updates map[string]interface{}) (*database.Subtask, error) {
+	text, hasText := updates["text"].(string)
+	completed, hasCompleted := updates["completed"].(bool)
+	if !hasText && !hasCompleted {
+		return nil, fmt.Errorf("no valid updates provided")
+	}
+
+	old := *subtask
+	if hasText {
+		subtask.Text = text
+	}
+	if hasCompleted {
+		subtask.Completed = completed
+	}
+	subtask.Version++
+	s.changed(userID, database.SyncSubtask, subtask.ID)
+	s.recordChanges(userID, subtask.TaskID, &subtask.ID, database.DiffSubtask(&old, subtask))
+
+	return copySubtask(subtask), nil
+}
+
+// DeleteSubtask moves a subtask to the trash and records it in the user's undo
+// log.
+func (s *Store) DeleteSubtask(ctx context.Context, userID, subtaskID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subtask, ok := s.liveSubtask(userID, subtaskID)
+	if !ok {
+		return database.ErrNotFound
+	}
+
+	s.trashSubtask(userID, subtask)
+	s.recordOperation(userID, database.OperationSubtaskDelete, []database.OperationChange{
+		{Entity: database.SyncSubtask, ID: subtaskID, Action: database.HistoryDelete},
+	})
+	return nil
+}
+
+// trashSubtask moves a live subtask to the trash.
+func (s *Store) trashSubtask(userID int64, subtask *database.Subtask) {
+	ts := stamp()
+	subtask.DeletedAt = &ts
+	subtask.Version++
+	s.changed(userID, database.SyncSubtask, subtask.ID)
+	s.record(userID, subtask.TaskID, &subtask.ID, database.HistoryDelete, nil)
+}
+
+// ReorderSubtasks updates the sort order of a task's subtasks, and records the
+// reorder in the user's undo log.
+func (s *Store) ReorderSubtasks(ctx context.Context, userID, taskID int64, subtaskIDs []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt != nil {
+		return database.ErrNotFound
+	}
+
+	live := func(subtaskID int64) (*database.Subtask, bool) {
+		subtask, ok := s.subtasks[subtaskID]
+		return subtask, ok && subtask.TaskID == taskID && subtask.DeletedAt == nil
+	}
+	before := make(map[int64]int, len(subtaskIDs))
+	for _, subtaskID := range subtaskIDs {
+		if subtask, ok := live(subtaskID); ok {
+			before[subtaskID] = subtask.SortOrder
+		}
+	}
+
+	after := make(map[int64]int, len(subtaskIDs))
+	for i, subtaskID := range subtaskIDs {
+		if subtask, ok := live(subtaskID); ok {
+			s.moveSubtask(userID, subtask, i)
+			after[subtaskID] = i
+		}
+	}
+
+	s.recordOperation(userID, database.OperationSubtaskReorder, database.ReorderChanges(database.SyncSubtask, subtaskIDs, before, after))
+	return nil
+}
+
+// moveSubtask sets the sort order of a live subtask.
+func (s *Store) moveSubtask(userID int64, subtask *database.Subtask, sortOrder int) {
+	subtask.SortOrder = sortOrder
+	subtask.Version++
+	s.changed(userID, database.SyncSubtask, subtask.ID)
+}
+
+// --- Helpers (must be called with mu held) ---
+
+// liveSubtask returns a subtask owned by the user if neither it nor its task is deleted.
+func (s *Store) liveSubtask(userID, subtaskID int64) (*database.Subtask, bool) {
+	subtask, ok := s.subtasks[subtaskID]
+	if !ok || subtask.DeletedAt != nil {
+		return nil, false
+	}
+	task := s.tasks[subtask.TaskID]
+	if task.UserID != userID || task.DeletedAt != nil {
+		return nil, false
+	}
+	return subtask, true
+}
+
+// taskView returns a copy of a task with its tags and subtasks attached.
+func (s *Store) taskView(task *database.Task) *database.Task {
+	view := *task
+	view.ListID = copyID(task.ListID)
+	view.DeletedAt = copyTime(task.DeletedAt)
+	view.CompletedAt = copyTime(task.CompletedAt)
+	view.DueAt = copyDate(task.DueAt)
+	view.StartAt = copyDate(task.StartAt)
+	view.Tags = s.tagsOf(task.ID)
+	view.Subtasks = s.subtasksOf(task.ID)
+	return &view
+}
+
+// tagsOf returns a task's tag names ordered by tag ID, like the SQLite join.
+func (s *Store) tagsOf(taskID int64) []string {
+	var ids []int64
+	for id := range s.taskTags[taskID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var tags []string
+	for _, id := range ids {
+		tags = append(tags, s.tags[id].Name)
+	}
+	return tags
+}
+
+// subtasksOf returns copies of a task's live subtasks ordered by sort order.
+func (s *Store) subtasksOf(taskID int64) []*database.Subtask {
+	var subtasks []*database.Subtask
+	for _, subtask := range s.subtasks {
+		if subtask.TaskID == taskID && subtask.DeletedAt == nil {
+			subtasks = append(subtasks, copySubtask(subtask))
+		}
+	}
+
+	sort.Slice(subtasks, func(i, j int) bool {
+		if subtasks[i].SortOrder != subtasks[j].SortOrder {
+			return subtasks[i].SortOrder < subtasks[j].SortOrder
+		}
+		return subtasks[i].ID < subtasks[j].ID
+	})
+
+	return subtasks
+}
+
+// addTagsToTask links tags to a task, creating any of the user's tags that don't
+// exist yet.
+func (s *Store) addTagsToTask(userID, taskID int64, tags []string) {
+	for _, name := range tags {
+		tag := s.findTag(userID, name)
+		if tag == nil {
+			tag = &database.Tag{ID: s.nextID("tags"), UserID: userID, Name: name, CreatedAt: now()}
+			s.tags[tag.ID] = tag
+			s.changed(userID, database.SyncTag, tag.ID)
+		}
+		tagID := tag.ID
+		if s.taskTags[taskID] == nil {
+			s.taskTags[taskID] = make(map[int64]bool)
+		}
+		s.taskTags[taskID][tagID] = true
+	}
+}
+
+// deleteTask removes a task along with its subtasks and tag links. Like the
+// SQLite store it records a change to the task but not to its subtasks.
+func (s *Store) deleteTask(taskID int64) {
+	s.changed(s.tasks[taskID].UserID, database.SyncTask, taskID)
+	delete(s.tasks, taskID)
+	delete(s.taskTags, taskID)
+	delete(s.deletedWithList, taskID)
+	delete(s.history, taskID)
+	for id, subtask := range s.subtasks {
+		if subtask.TaskID == taskID {
+			delete(s.subtasks, id)
+		}
+	}
+}
+
+// copySubtask returns a copy of a subtask that doesn't alias stored values.
+func copySubtask(subtask *database.Subtask) *database.Subtask {
+	copied := *subtask
+	copied.DeletedAt = copyTime(subtask.DeletedAt)
+	return &copied
+}
+
+// copyTime returns a copy of an optional time.
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := *t
+	return &v
+}
+
+// copyDate returns a copy of an optional task date.
+func copyDate(d *database.TaskDate) *database.TaskDate {
+	if d == nil {
+		return nil
+	}
+	v := *d
+	return &v
+}
+
+// copyID returns a copy of an optional ID so callers can't alias stored values.
+func copyID(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+	v := *id
+	return &v
+}
@@ -0,0 +1,279 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// GetTrash returns the user's deleted items, most recently deleted first.
+func (s *Store) GetTrash(ctx context.Context, userID int64) (*database.Trash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trash := &database.Trash{
+		Lists:    []*database.List{},
+		Tasks:    []*database.Task{},
+		Subtasks: []*database.Subtask{},
+	}
+
+	for _, list := range s.lists {
+		if list.UserID == userID && list.DeletedAt != nil {
+			trash.Lists = append(trash.Lists, copyList(list))
+		}
+	}
+	sort.Slice(trash.Lists, func(i, j int) bool {
+		return deletedBefore(trash.Lists[j].DeletedAt, trash.Lists[j].ID, trash.Lists[i].DeletedAt, trash.Lists[i].ID)
+	})
+
+	for _, task := range s.tasks {
+		if task.UserID == userID && task.DeletedAt != nil && !s.deletedWithList[task.ID] {
+			trash.Tasks = append(trash.Tasks, s.taskView(task))
+		}
+	}
+	sort.Slice(trash.Tasks, func(i, j int) bool {
+		return deletedBefore(trash.Tasks[j].DeletedAt, trash.Tasks[j].ID, trash.Tasks[i].DeletedAt, trash.Tasks[i].ID)
+	})
+
+	for _, subtask := range s.subtasks {
+		task := s.tasks[subtask.TaskID]
+		if task.UserID == userID && task.DeletedAt == nil && subtask.DeletedAt != nil {
+			trash.Subtasks = append(trash.Subtasks, copySubtask(subtask))
+		}
+	}
+	sort.Slice(trash.Subtasks, func(i, j int) bool {
+		return deletedBefore(trash.Subtasks[j].DeletedAt, trash.Subtasks[j].ID, trash.Subtasks[i].DeletedAt, trash.Subtasks[i].ID)
+	})
+
+	return trash, nil
+}
+
+// RestoreTask brings a task back from the trash, dropping its list if the list is still deleted.
+func (s *Store) RestoreTask(ctx context.Context, userID, taskID int64) (*database.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt == nil {
+		return nil, database.ErrNotFound
+	}
+
+	s.restoreTask(userID, task)
+	return s.taskView(task), nil
+}
+
+// restoreTask brings a trashed task back, dropping its list if the list is still deleted.
+func (s *Store) restoreTask(userID int64, task *database.Task) {
+	task.DeletedAt = nil
+	task.UpdatedAt = now()
+	task.Version++
+	s.changed(task.UserID, database.SyncTask, task.ID)
+	delete(s.deletedWithList, task.ID)
+	s.record(userID, task.ID, nil, database.HistoryRestore, nil)
+
+	if task.ListID != nil {
+		if list, ok := s.lists[*task.ListID]; ok && list.DeletedAt != nil {
+			s.recordChanges(userID, task.ID, nil, []database.FieldChange{{
+				Field:    "listId",
+				OldValue: database.HistoryValue(task.ListID),
+				NewValue: database.HistoryValue(nil),
+			}})
+			task.ListID = nil
+		}
+	}
+}
+
+// RestoreSubtask brings a subtask back from the trash. The parent task must not be deleted.
+func (s *Store) RestoreSubtask(ctx context.Context, userID, subtaskID int64) (*database.Subtask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subtask, ok := s.subtasks[subtaskID]
+	if !ok || subtask.DeletedAt == nil {
+		return nil, database.ErrNotFound
+	}
+	task := s.tasks[subtask.TaskID]
+	if task.UserID != userID || task.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+
+	s.restoreSubtask(userID, subtask)
+	return copySubtask(subtask), nil
+}
+
+// restoreSubtask brings a trashed subtask of a live task back.
+func (s *Store) restoreSubtask(userID int64, subtask *database.Subtask) {
+	subtask.DeletedAt = nil
+	subtask.Version++
+	s.changed(userID, database.SyncSubtask, subtask.ID)
+	s.record(userID, subtask.TaskID, &subtask.ID, database.HistoryRestore, nil)
+}
+
+// RestoreList brings a list back from the trash together with the tasks deleted with it.
+func (s *Store) RestoreList(ctx context.Context, userID, listID int64) (*database.List, []*database.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.lists[listID]
+	if !ok || list.UserID != userID || list.DeletedAt == nil {
+		return nil, nil, database.ErrNotFound
+	}
+
+	s.restoreList(userID, list)
+
+	var tasks []*database.Task
+	for _, task := range s.tasks {
+		if task.ListID != nil && *task.ListID == listID && task.DeletedAt == nil {
+			tasks = append(tasks, s.taskView(task))
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].SortOrder != tasks[j].SortOrder {
+			return tasks[i].SortOrder < tasks[j].SortOrder
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+
+	return copyList(list), tasks, nil
+}
+
+// restoreList brings a trashed list back together with the tasks deleted with it.
+func (s *Store) restoreList(userID int64, list *database.List) {
+	ts := now()
+	for _, task := range s.tasks {
+		if s.deletedWithList[task.ID] && *task.ListID == list.ID {
+			task.DeletedAt = nil
+			task.UpdatedAt = ts
+			task.Version++
+			s.changed(task.UserID, database.SyncTask, task.ID)
+			delete(s.deletedWithList, task.ID)
+			s.record(userID, task.ID, nil, database.HistoryRestore, nil)
+		}
+	}
+	list.DeletedAt = nil
+	list.UpdatedAt = ts
+	list.Version++
+	s.changed(list.UserID, database.SyncList, list.ID)
+}
+
+// PurgeTask permanently deletes a task that is in the trash.
+func (s *Store) PurgeTask(ctx context.Context, userID, taskID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[taskID]
+	if !ok || task.UserID != userID || task.DeletedAt == nil {
+		return database.ErrNotFound
+	}
+
+	s.deleteTask(taskID)
+	return nil
+}
+
+// PurgeSubtask permanently deletes a subtask that is in the trash.
+func (s *Store) PurgeSubtask(ctx context.Context, userID, subtaskID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subtask, ok := s.subtasks[subtaskID]
+	if !ok || subtask.DeletedAt == nil || s.tasks[subtask.TaskID].UserID != userID {
+		return database.ErrNotFound
+	}
+
+	delete(s.subtasks, subtaskID)
+	s.changed(userID, database.SyncSubtask, subtaskID)
+	return nil
+}
+
+// PurgeList permanently deletes a list that is in the trash along with the tasks deleted with it.
+func (s *Store) PurgeList(ctx context.Context, userID, listID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, ok := s.lists[listID]
+	if !ok || list.UserID != userID || list.DeletedAt == nil {
+		return database.ErrNotFound
+	}
+
+	s.purgeList(listID)
+	return nil
+}
+
+// EmptyTrash permanently deletes everything in a user's trash.
+func (s *Store) EmptyTrash(ctx context.Context, userID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purge(func(userOf int64, deletedAt time.Time) bool {
+		return userOf == userID
+	}), nil
+}
+
+// PurgeTrash permanently deletes all items that were moved to the trash before the cutoff.
+func (s *Store) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purge(func(userOf int64, deletedAt time.Time) bool {
+		return deletedAt.Before(before)
+	}), nil
+}
+
+// purge permanently deletes trashed subtasks, tasks and lists that match, in the
+// same order as the SQLite store, and returns how many were removed.
+func (s *Store) purge(match func(userID int64, deletedAt time.Time) bool) int64 {
+	var total int64
+
+	for id, subtask := range s.subtasks {
+		if subtask.DeletedAt != nil && match(s.tasks[subtask.TaskID].UserID, *subtask.DeletedAt) {
+			delete(s.subtasks, id)
+			s.changed(s.tasks[subtask.TaskID].UserID, database.SyncSubtask, id)
+			total++
+		}
+	}
+	for id, task := range s.tasks {
+		if task.DeletedAt != nil && match(task.UserID, *task.DeletedAt) {
+			s.deleteTask(id)
+			total++
+		}
+	}
+	for id, list := range s.lists {
+		if list.DeletedAt != nil && match(list.UserID, *list.DeletedAt) {
+			s.purgeList(id)
+			total++
+		}
+	}
+
+	return total
+}
+
+// purgeList deletes a list and the tasks deleted with it; other tasks pointing
+// at the list are detached.
+func (s *Store) purgeList(listID int64) {
+	for id, task := range s.tasks {
+		if task.ListID == nil || *task.ListID != listID {
+			continue
+		}
+		if s.deletedWithList[id] {
+			s.deleteTask(id)
+		} else {
+			task.ListID = nil
+			task.UpdatedAt = now()
+			task.Version++
+			s.changed(task.UserID, database.SyncTask, task.ID)
+		}
+	}
+	s.changed(s.lists[listID].UserID, database.SyncList, listID)
+	s.deleteListFeeds(listID)
+	delete(s.lists, listID)
+}
+
+// deletedBefore orders trash items by deletion time, then ID.
+func deletedBefore(a *time.Time, aID int64, b *time.Time, bID int64) bool {
+	if !a.Equal(*b) {
+		return a.Before(*b)
+	}
+	return aID < bID
+}
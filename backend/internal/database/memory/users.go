@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// CreateUser creates a new user with the given email and password hash.
+func (s *Store) CreateUser(ctx context.Context, email, passwordHash, displayName string) (*database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return nil, database.ErrDuplicateEmail
+		}
+	}
+
+	ts := now()
+	user := &database.User{
+		ID:           s.nextID("users"),
+		Email:        email,
+		PasswordHash: passwordHash,
+		DisplayName:  displayName,
+		Timezone:     "UTC",
+		CreatedAt:    ts,
+		UpdatedAt:    ts,
+	}
+	s.users[user.ID] = user
+
+	copied := *user
+	return &copied, nil
+}
+
+// GetUserByID retrieves a user by their ID.
+func (s *Store) GetUserByID(ctx context.Context, id int64) (*database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+
+	copied := *user
+	return &copied, nil
+}
+
+// GetUserByEmail retrieves a user by their email address.
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*database.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+
+	return nil, database.ErrNotFound
+}
+
+// UpdateUser updates a user's profile information.
+func (s *Store) UpdateUser(ctx context.Context, id int64, displayName string) (*database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+
+	user.DisplayName = displayName
+	user.UpdatedAt = now()
+
+	copied := *user
+	return &copied, nil
+}
+
+// UpdateUserTimezone sets the IANA time zone used to interpret a user's all-day dates.
+func (s *Store) UpdateUserTimezone(ctx context.Context, id int64, timezone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+
+	user.Timezone = timezone
+	user.UpdatedAt = now()
+	return nil
+}
+
+// UpdateUserPassword updates a user's password.
+func (s *Store) UpdateUserPassword(ctx context.Context, id int64, newPasswordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[id]; ok {
+		user.PasswordHash = newPasswordHash
+		user.UpdatedAt = now()
+	}
+	return nil
+}
+
+// DeleteUser deletes a user and all their associated data.
+func (s *Store) DeleteUser(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return database.ErrNotFound
+	}
+
+	delete(s.users, id)
+
+	for sid, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sid)
+		}
+	}
+	for lid, list := range s.lists {
+		if list.UserID == id {
+			delete(s.lists, lid)
+		}
+	}
+	for tid, task := range s.tasks {
+		if task.UserID == id {
+			s.deleteTask(tid)
+		}
+	}
+	for tid, tag := range s.tags {
+		if tag.UserID == id {
+			delete(s.tags, tid)
+		}
+	}
+	for lid, list := range s.smartLists {
+		if list.UserID == id {
+			delete(s.smartLists, lid)
+		}
+	}
+	for fid, feed := range s.feeds {
+		if feed.UserID == id {
+			delete(s.feeds, fid)
+		}
+	}
+	for pid, password := range s.appPasswords {
+		if password.UserID == id {
+			delete(s.appPasswords, pid)
+		}
+	}
+	s.caldavObjects = slices.DeleteFunc(s.caldavObjects, func(obj *database.CalDAVObject) bool {
+		return obj.UserID == id
+	})
+	for jid, job := range s.importJobs {
+		if job.UserID == id {
+			delete(s.importJobs, jid)
+		}
+	}
+	delete(s.operations, id)
+	for key, change := range s.changes {
+		if change.userID == id {
+			delete(s.changes, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,780 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMigrationChecksum is returned when an applied migration no longer matches its definition.
+var ErrMigrationChecksum = errors.New("applied migration has been modified")
+
+// ErrUnknownMigration is returned when the database has a migration applied that this binary doesn't know about.
+var ErrUnknownMigration = errors.New("database has unknown migration applied")
+
+// Migration is a single versioned schema change.
+// Up and Down may contain several statements separated by semicolons.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum returns a hash of the migration's SQL, used to detect edits after it was applied.
+func (m Migration) Checksum() string {
+	hash := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+	return hex.EncodeToString(hash[:])
+}
+
+// AppliedMigration records a migration that has been run against the database.
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Checksum  string    `json:"checksum"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// migrations is the ordered list of schema changes. Never edit an entry once it has
+// shipped; add a new migration instead.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: `
+			CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				email TEXT UNIQUE NOT NULL,
+				password_hash TEXT NOT NULL,
+				display_name TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+			CREATE TABLE IF NOT EXISTS sessions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				token_hash TEXT UNIQUE NOT NULL,
+				expires_at DATETIME NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_sessions_token_hash ON sessions(token_hash);
+			CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+			CREATE TABLE IF NOT EXISTS lists (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_lists_user_id ON lists(user_id);
+
+			CREATE TABLE IF NOT EXISTS tasks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				list_id INTEGER,
+				text TEXT NOT NULL,
+				completed BOOLEAN DEFAULT FALSE,
+				important BOOLEAN DEFAULT FALSE,
+				is_expanded BOOLEAN DEFAULT FALSE,
+				sort_order INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id);
+			CREATE INDEX IF NOT EXISTS idx_tasks_list_id ON tasks(list_id);
+
+			CREATE TABLE IF NOT EXISTS subtasks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id INTEGER NOT NULL,
+				text TEXT NOT NULL,
+				completed BOOLEAN DEFAULT FALSE,
+				sort_order INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_subtasks_task_id ON subtasks(task_id);
+
+			CREATE TABLE IF NOT EXISTS tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT UNIQUE NOT NULL
+			);
+
+			CREATE TABLE IF NOT EXISTS task_tags (
+				task_id INTEGER NOT NULL,
+				tag_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, tag_id),
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+				FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_task_tags_task_id ON task_tags(task_id);
+			CREATE INDEX IF NOT EXISTS idx_task_tags_tag_id ON task_tags(tag_id);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS task_tags;
+			DROP TABLE IF EXISTS tags;
+			DROP TABLE IF EXISTS subtasks;
+			DROP TABLE IF EXISTS tasks;
+			DROP TABLE IF EXISTS lists;
+			DROP TABLE IF EXISTS sessions;
+			DROP TABLE IF EXISTS users;
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "soft_delete",
+		Up: `
+			ALTER TABLE lists ADD COLUMN deleted_at DATETIME;
+			ALTER TABLE tasks ADD COLUMN deleted_at DATETIME;
+			ALTER TABLE tasks ADD COLUMN deleted_with_list BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE subtasks ADD COLUMN deleted_at DATETIME;
+			CREATE INDEX idx_lists_deleted_at ON lists(deleted_at);
+			CREATE INDEX idx_tasks_deleted_at ON tasks(deleted_at);
+			CREATE INDEX idx_subtasks_deleted_at ON subtasks(deleted_at);
+		`,
+		Down: `
+			DELETE FROM subtasks WHERE deleted_at IS NOT NULL;
+			DELETE FROM tasks WHERE deleted_at IS NOT NULL;
+			DELETE FROM lists WHERE deleted_at IS NOT NULL;
+			DROP INDEX idx_subtasks_deleted_at;
+			DROP INDEX idx_tasks_deleted_at;
+			DROP INDEX idx_lists_deleted_at;
+			ALTER TABLE subtasks DROP COLUMN deleted_at;
+			ALTER TABLE tasks DROP COLUMN deleted_with_list;
+			ALTER TABLE tasks DROP COLUMN deleted_at;
+			ALTER TABLE lists DROP COLUMN deleted_at;
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "task_history",
+		Up: `
+			CREATE TABLE task_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				task_id INTEGER NOT NULL,
+				subtask_id INTEGER,
+				actor_id INTEGER NOT NULL,
+				action TEXT NOT NULL,
+				field TEXT,
+				old_value TEXT,
+				new_value TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+				FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_task_history_task_id ON task_history(task_id, id);
+		`,
+		Down: `
+			DROP TABLE task_history;
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "task_search",
+		Up: `
+			CREATE VIRTUAL TABLE tasks_fts USING fts5(
+				text, subtasks, tags,
+				tokenize = 'unicode61 remove_diacritics 2'
+			);
+
+			CREATE TRIGGER tasks_fts_insert AFTER INSERT ON tasks BEGIN
+				INSERT INTO tasks_fts (rowid, text, subtasks, tags) VALUES (new.id, new.text, '', '');
+			END;
+			CREATE TRIGGER tasks_fts_update AFTER UPDATE OF text ON tasks BEGIN
+				UPDATE tasks_fts SET text = new.text WHERE rowid = new.id;
+			END;
+			CREATE TRIGGER tasks_fts_delete AFTER DELETE ON tasks BEGIN
+				DELETE FROM tasks_fts WHERE rowid = old.id;
+			END;
+
+			CREATE TRIGGER subtasks_fts_insert AFTER INSERT ON subtasks BEGIN
+				UPDATE tasks_fts SET subtasks = (
+					SELECT COALESCE(group_concat(text, ' '), '') FROM subtasks
+					WHERE task_id = new.task_id AND deleted_at IS NULL
+				) WHERE rowid = new.task_id;
+			END;
+			CREATE TRIGGER subtasks_fts_update AFTER UPDATE OF text, deleted_at ON subtasks BEGIN
+				UPDATE tasks_fts SET subtasks = (
+					SELECT COALESCE(group_concat(text, ' '), '') FROM subtasks
+					WHERE task_id = new.task_id AND deleted_at IS NULL
+				) WHERE rowid = new.task_id;
+			END;
+			CREATE TRIGGER subtasks_fts_delete AFTER DELETE ON subtasks BEGIN
+				UPDATE tasks_fts SET subtasks = (
+					SELECT COALESCE(group_concat(text, ' '), '') FROM subtasks
+					WHERE task_id = old.task_id AND deleted_at IS NULL
+				) WHERE rowid = old.task_id;
+			END;
+
+			CREATE TRIGGER task_tags_fts_insert AFTER INSERT ON task_tags BEGIN
+				UPDATE tasks_fts SET tags = (
+					SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+					JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = new.task_id
+				) WHERE rowid = new.task_id;
+			END;
+			CREATE TRIGGER task_tags_fts_delete AFTER DELETE ON task_tags BEGIN
+				UPDATE tasks_fts SET tags = (
+					SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+					JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = old.task_id
+				) WHERE rowid = old.task_id;
+			END;
+			CREATE TRIGGER tags_fts_update AFTER UPDATE OF name ON tags BEGIN
+				UPDATE tasks_fts SET tags = (
+					SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+					JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = tasks_fts.rowid
+				) WHERE rowid IN (SELECT task_id FROM task_tags WHERE tag_id = new.id);
+			END;
+
+			INSERT INTO tasks_fts (rowid, text, subtasks, tags)
+			SELECT t.id, t.text,
+				(SELECT COALESCE(group_concat(s.text, ' '), '') FROM subtasks s
+				 WHERE s.task_id = t.id AND s.deleted_at IS NULL),
+				(SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+				 JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = t.id)
+			FROM tasks t;
+		`,
+		Down: `
+			DROP TRIGGER tags_fts_update;
+			DROP TRIGGER task_tags_fts_delete;
+			DROP TRIGGER task_tags_fts_insert;
+			DROP TRIGGER subtasks_fts_delete;
+			DROP TRIGGER subtasks_fts_update;
+			DROP TRIGGER subtasks_fts_insert;
+			DROP TRIGGER tasks_fts_delete;
+			DROP TRIGGER tasks_fts_update;
+			DROP TRIGGER tasks_fts_insert;
+			DROP TABLE tasks_fts;
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "task_dates",
+		Up: `
+			ALTER TABLE tasks ADD COLUMN due_at DATETIME;
+			ALTER TABLE tasks ADD COLUMN due_all_day BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE tasks ADD COLUMN start_at DATETIME;
+			ALTER TABLE tasks ADD COLUMN start_all_day BOOLEAN NOT NULL DEFAULT FALSE;
+			CREATE INDEX idx_tasks_due_at ON tasks(user_id, due_at);
+			ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+		`,
+		Down: `
+			ALTER TABLE users DROP COLUMN timezone;
+			DROP INDEX idx_tasks_due_at;
+			ALTER TABLE tasks DROP COLUMN start_all_day;
+			ALTER TABLE tasks DROP COLUMN start_at;
+			ALTER TABLE tasks DROP COLUMN due_all_day;
+			ALTER TABLE tasks DROP COLUMN due_at;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "task_recurrence",
+		Up: `
+			ALTER TABLE tasks ADD COLUMN recurrence TEXT;
+			ALTER TABLE tasks ADD COLUMN repeat_from TEXT;
+			ALTER TABLE tasks ADD COLUMN completed_at DATETIME;
+			UPDATE tasks SET completed_at = updated_at WHERE completed;
+		`,
+		Down: `
+			ALTER TABLE tasks DROP COLUMN completed_at;
+			ALTER TABLE tasks DROP COLUMN repeat_from;
+			ALTER TABLE tasks DROP COLUMN recurrence;
+		`,
+	},
+	{
+		// Tags were shared by every account through a global unique name. Each
+		// user gets their own copy of the tags their tasks use; unused tags are
+		// dropped. Rolling back merges same-named tags again and loses colors.
+		Version: 7,
+		Name:    "user_tags",
+		Up: `
+			CREATE TABLE user_tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				color TEXT,
+				description TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (user_id, name),
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			INSERT INTO user_tags (user_id, name)
+				SELECT DISTINCT t.user_id, tg.name FROM task_tags tt
+				JOIN tasks t ON t.id = tt.task_id
+				JOIN tags tg ON tg.id = tt.tag_id
+				ORDER BY t.user_id, tg.id;
+
+			CREATE TABLE user_task_tags (
+				task_id INTEGER NOT NULL,
+				tag_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, tag_id),
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+				FOREIGN KEY (tag_id) REFERENCES user_tags(id) ON DELETE CASCADE
+			);
+			INSERT INTO user_task_tags (task_id, tag_id)
+				SELECT tt.task_id, ut.id FROM task_tags tt
+				JOIN tasks t ON t.id = tt.task_id
+				JOIN tags tg ON tg.id = tt.tag_id
+				JOIN user_tags ut ON ut.user_id = t.user_id AND ut.name = tg.name;
+
+			DROP TABLE task_tags;
+			DROP TABLE tags;
+			ALTER TABLE user_tags RENAME TO tags;
+			ALTER TABLE user_task_tags RENAME TO task_tags;
+			CREATE INDEX idx_task_tags_task_id ON task_tags(task_id);
+			CREATE INDEX idx_task_tags_tag_id ON task_tags(tag_id);
+		` + tagSearchTriggers,
+		Down: `
+			CREATE TABLE shared_tags (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT UNIQUE NOT NULL
+			);
+			INSERT INTO shared_tags (name) SELECT name FROM tags GROUP BY name ORDER BY MIN(id);
+
+			CREATE TABLE shared_task_tags (
+				task_id INTEGER NOT NULL,
+				tag_id INTEGER NOT NULL,
+				PRIMARY KEY (task_id, tag_id),
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+				FOREIGN KEY (tag_id) REFERENCES shared_tags(id) ON DELETE CASCADE
+			);
+			INSERT INTO shared_task_tags (task_id, tag_id)
+				SELECT tt.task_id, st.id FROM task_tags tt
+				JOIN tags tg ON tg.id = tt.tag_id
+				JOIN shared_tags st ON st.name = tg.name;
+
+			DROP TABLE task_tags;
+			DROP TABLE tags;
+			ALTER TABLE shared_tags RENAME TO tags;
+			ALTER TABLE shared_task_tags RENAME TO task_tags;
+			CREATE INDEX idx_task_tags_task_id ON task_tags(task_id);
+			CREATE INDEX idx_task_tags_tag_id ON task_tags(tag_id);
+		` + tagSearchTriggers,
+	},
+	{
+		Version: 8,
+		Name:    "row_versions",
+		Up: `
+			ALTER TABLE lists ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+			ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+			ALTER TABLE subtasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+		`,
+		Down: `
+			ALTER TABLE subtasks DROP COLUMN version;
+			ALTER TABLE tasks DROP COLUMN version;
+			ALTER TABLE lists DROP COLUMN version;
+		`,
+	},
+	{
+		// sync_changes keeps one row per list, task, subtask and tag: the latest
+		// change to it. AUTOINCREMENT makes seq strictly increasing across restarts
+		// and deletions, so it can serve as a sync cursor. Subtasks deleted along
+		// with their task leave no row of their own; the task's covers them.
+		Version: 9,
+		Name:    "sync_changes",
+		Up: `
+			CREATE TABLE sync_changes (
+				seq INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				entity TEXT NOT NULL,
+				entity_id INTEGER NOT NULL,
+				UNIQUE (entity, entity_id)
+			);
+			CREATE INDEX idx_sync_changes_user_seq ON sync_changes(user_id, seq);
+
+			CREATE TRIGGER lists_sync_insert AFTER INSERT ON lists BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'list', new.id);
+			END;
+			CREATE TRIGGER lists_sync_update AFTER UPDATE ON lists BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'list', new.id);
+			END;
+			CREATE TRIGGER lists_sync_delete AFTER DELETE ON lists BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (old.user_id, 'list', old.id);
+			END;
+
+			CREATE TRIGGER tasks_sync_insert AFTER INSERT ON tasks BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'task', new.id);
+			END;
+			CREATE TRIGGER tasks_sync_update AFTER UPDATE ON tasks BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'task', new.id);
+			END;
+			CREATE TRIGGER tasks_sync_delete AFTER DELETE ON tasks BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (old.user_id, 'task', old.id);
+			END;
+
+			CREATE TRIGGER subtasks_sync_insert AFTER INSERT ON subtasks BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id)
+				SELECT user_id, 'subtask', new.id FROM tasks WHERE id = new.task_id;
+			END;
+			CREATE TRIGGER subtasks_sync_update AFTER UPDATE ON subtasks BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id)
+				SELECT user_id, 'subtask', new.id FROM tasks WHERE id = new.task_id;
+			END;
+			CREATE TRIGGER subtasks_sync_delete AFTER DELETE ON subtasks BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id)
+				SELECT user_id, 'subtask', old.id FROM tasks WHERE id = old.task_id;
+			END;
+
+			CREATE TRIGGER tags_sync_insert AFTER INSERT ON tags BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'tag', new.id);
+			END;
+			CREATE TRIGGER tags_sync_update AFTER UPDATE ON tags BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'tag', new.id);
+			END;
+			CREATE TRIGGER tags_sync_delete AFTER DELETE ON tags BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (old.user_id, 'tag', old.id);
+			END;
+
+			CREATE TRIGGER users_sync_delete AFTER DELETE ON users BEGIN
+				DELETE FROM sync_changes WHERE user_id = old.id;
+			END;
+		`,
+		Down: `
+			DROP TRIGGER users_sync_delete;
+			DROP TRIGGER tags_sync_delete;
+			DROP TRIGGER tags_sync_update;
+			DROP TRIGGER tags_sync_insert;
+			DROP TRIGGER subtasks_sync_delete;
+			DROP TRIGGER subtasks_sync_update;
+			DROP TRIGGER subtasks_sync_insert;
+			DROP TRIGGER tasks_sync_delete;
+			DROP TRIGGER tasks_sync_update;
+			DROP TRIGGER tasks_sync_insert;
+			DROP TRIGGER lists_sync_delete;
+			DROP TRIGGER lists_sync_update;
+			DROP TRIGGER lists_sync_insert;
+			DROP TABLE sync_changes;
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "smart_lists",
+		Up: `
+			CREATE TABLE smart_lists (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				query TEXT NOT NULL,
+				version INTEGER NOT NULL DEFAULT 1,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_smart_lists_user_id ON smart_lists(user_id);
+
+			CREATE TRIGGER smart_lists_sync_insert AFTER INSERT ON smart_lists BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'smart_list', new.id);
+			END;
+			CREATE TRIGGER smart_lists_sync_update AFTER UPDATE ON smart_lists BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (new.user_id, 'smart_list', new.id);
+			END;
+			CREATE TRIGGER smart_lists_sync_delete AFTER DELETE ON smart_lists BEGIN
+				INSERT OR REPLACE INTO sync_changes (user_id, entity, entity_id) VALUES (old.user_id, 'smart_list', old.id);
+			END;
+		`,
+		Down: `
+			DROP TRIGGER smart_lists_sync_delete;
+			DROP TRIGGER smart_lists_sync_update;
+			DROP TRIGGER smart_lists_sync_insert;
+			DELETE FROM sync_changes WHERE entity = 'smart_list';
+			DROP TABLE smart_lists;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "feed_tokens",
+		Up: `
+			CREATE TABLE feed_tokens (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				token_hash TEXT UNIQUE NOT NULL,
+				name TEXT NOT NULL DEFAULT '',
+				list_id INTEGER,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_feed_tokens_user_id ON feed_tokens(user_id);
+		`,
+		Down: `
+			DROP TABLE feed_tokens;
+		`,
+	},
+	{
+		Version: 12,
+		Name:    "caldav",
+		Up: `
+			CREATE TABLE app_passwords (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				password_hash TEXT UNIQUE NOT NULL,
+				name TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_app_passwords_user_id ON app_passwords(user_id);
+
+			CREATE TABLE caldav_objects (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				task_id INTEGER,
+				subtask_id INTEGER,
+				uid TEXT NOT NULL,
+				name TEXT NOT NULL,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+				FOREIGN KEY (subtask_id) REFERENCES subtasks(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_caldav_objects_user_id ON caldav_objects(user_id);
+		`,
+		Down: `
+			DROP TABLE caldav_objects;
+			DROP TABLE app_passwords;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "import_jobs",
+		Up: `
+			CREATE TABLE import_jobs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				source TEXT NOT NULL,
+				status TEXT NOT NULL,
+				total INTEGER NOT NULL DEFAULT 0,
+				processed INTEGER NOT NULL DEFAULT 0,
+				report TEXT,
+				errors TEXT NOT NULL DEFAULT '[]',
+				error TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				finished_at DATETIME,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_import_jobs_user_id ON import_jobs(user_id);
+		`,
+		Down: `
+			DROP TABLE import_jobs;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "operations",
+		Up: `
+			CREATE TABLE operations (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				action TEXT NOT NULL,
+				changes TEXT NOT NULL,
+				undone BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);
+			CREATE INDEX idx_operations_user_id ON operations(user_id, id);
+		`,
+		Down: `
+			DROP TABLE operations;
+		`,
+	},
+}
+
+// tagSearchTriggers recreates the triggers that keep tasks_fts.tags in sync after
+// the tags and task_tags tables are rebuilt, and resyncs the column.
+const tagSearchTriggers = `
+			CREATE TRIGGER task_tags_fts_insert AFTER INSERT ON task_tags BEGIN
+				UPDATE tasks_fts SET tags = (
+					SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+					JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = new.task_id
+				) WHERE rowid = new.task_id;
+			END;
+			CREATE TRIGGER task_tags_fts_delete AFTER DELETE ON task_tags BEGIN
+				UPDATE tasks_fts SET tags = (
+					SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+					JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = old.task_id
+				) WHERE rowid = old.task_id;
+			END;
+			CREATE TRIGGER tags_fts_update AFTER UPDATE OF name ON tags BEGIN
+				UPDATE tasks_fts SET tags = (
+					SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+					JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = tasks_fts.rowid
+				) WHERE rowid IN (SELECT task_id FROM task_tags WHERE tag_id = new.id);
+			END;
+			UPDATE tasks_fts SET tags = (
+				SELECT COALESCE(group_concat(tg.name, ' '), '') FROM task_tags tt
+				JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = tasks_fts.rowid
+			);
+`
+
+// Migrate applies all pending migrations in order, each in its own transaction.
+// It refuses to run if an already applied migration has been edited since.
+func (db *DB) Migrate() error {
+	ctx := context.Background()
+
+	pending, err := db.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		if err := db.applyMigration(ctx, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PendingMigrations returns the migrations that have not been applied yet, in order.
+// It also verifies the checksums of the applied ones.
+func (db *DB) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	applied, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		m, ok := known[a.Version]
+		if !ok {
+			return nil, fmt.Errorf("%w: version %d (%s)", ErrUnknownMigration, a.Version, a.Name)
+		}
+		if m.Checksum() != a.Checksum {
+			return nil, fmt.Errorf("%w: version %d (%s)", ErrMigrationChecksum, a.Version, a.Name)
+		}
+		done[a.Version] = true
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// AppliedMigrations returns the migrations recorded in schema_migrations, oldest first.
+func (db *DB) AppliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	if err := db.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
+
+// MigrateDown rolls back applied migrations, newest first, until the schema is at
+// the given version. Use 0 to roll back everything.
+func (db *DB) MigrateDown(ctx context.Context, target int) error {
+	if _, err := db.PendingMigrations(ctx); err != nil {
+		return err
+	}
+
+	applied, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	for i := len(applied) - 1; i >= 0; i-- {
+		if applied[i].Version <= target {
+			break
+		}
+		if err := db.revertMigration(ctx, known[applied[i].Version]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureMigrationsTable creates the schema_migrations bookkeeping table.
+func (db *DB) ensureMigrationsTable(ctx context.Context) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// applyMigration runs a migration's up SQL and records it in a single transaction.
+func (db *DB) applyMigration(ctx context.Context, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`,
+		m.Version, m.Name, m.Checksum(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	return tx.Commit()
+}
+
+// revertMigration runs a migration's down SQL and removes its record in a single transaction.
+func (db *DB) revertMigration(ctx context.Context, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+		return fmt.Errorf("rollback of migration %d (%s) failed: %w", m.Version, m.Name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	if err != nil {
+		return fmt.Errorf("failed to remove migration record %d: %w", m.Version, err)
+	}
+
+	return tx.Commit()
+}
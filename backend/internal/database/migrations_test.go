@@ -0,0 +1,210 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// openDB opens a database in a temporary directory without migrating it.
+func openDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "todomaster.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// schema returns the database's tables, indexes and triggers with their SQL,
+// one per line, in name order.
+func schema(t *testing.T, db *database.DB) string {
+	t.Helper()
+	rows, err := db.Query(`SELECT type, name, COALESCE(sql, '') FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		t.Fatalf("failed to query schema: %v", err)
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var kind, name, sql string
+		if err := rows.Scan(&kind, &name, &sql); err != nil {
+			t.Fatalf("failed to scan schema: %v", err)
+		}
+		lines = append(lines, kind+" "+name+": "+strings.Join(strings.Fields(sql), " "))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to query schema: %v", err)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// migrationVersions returns the versions of the applied and the pending
+// migrations.
+func migrationVersions(t *testing.T, db *database.DB) (applied, pending []int) {
+	t.Helper()
+	ctx := context.Background()
+	done, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("AppliedMigrations: %v", err)
+	}
+	for _, a := range done {
+		applied = append(applied, a.Version)
+	}
+	todo, err := db.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	for _, m := range todo {
+		pending = append(pending, m.Version)
+	}
+	return applied, pending
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+
+	all, err := db.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	if len(all) == 0 {
+		t.Fatal("a new database has no pending migrations")
+	}
+	for i, m := range all {
+		if m.Version != i+1 || m.Name == "" || m.Checksum() == "" {
+			t.Fatalf("migration %d = %d %q", i, m.Version, m.Name)
+		}
+	}
+	if applied, err := db.AppliedMigrations(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("AppliedMigrations of a new database = %+v, %v", applied, err)
+	}
+	empty := schema(t, db)
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	latest := len(all)
+	applied, err := db.AppliedMigrations(ctx)
+	if err != nil || len(applied) != latest {
+		t.Fatalf("AppliedMigrations = %+v, %v", applied, err)
+	}
+	for i, a := range applied {
+		if a.Version != all[i].Version || a.Name != all[i].Name || a.Checksum != all[i].Checksum() || a.AppliedAt.IsZero() {
+			t.Fatalf("applied migration %d = %+v", i, a)
+		}
+	}
+	if _, pending := migrationVersions(t, db); len(pending) != 0 {
+		t.Fatalf("pending migrations after Migrate = %v", pending)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate of a migrated database: %v", err)
+	}
+	full := schema(t, db)
+
+	// Down to each version, then back up to the same schema
+	for target := latest - 1; target >= 0; target-- {
+		if err := db.MigrateDown(ctx, target); err != nil {
+			t.Fatalf("MigrateDown(%d): %v", target, err)
+		}
+		applied, pending := migrationVersions(t, db)
+		if len(applied) != target || (target > 0 && applied[target-1] != target) {
+			t.Fatalf("applied migrations after MigrateDown(%d) = %v", target, applied)
+		}
+		if len(pending) != latest-target || pending[0] != target+1 {
+			t.Fatalf("pending migrations after MigrateDown(%d) = %v", target, pending)
+		}
+		if target == 0 {
+			if got := schema(t, db); got != empty {
+				t.Fatalf("schema after MigrateDown(0):\n%s\nwant:\n%s", got, empty)
+			}
+		}
+
+		if err := db.Migrate(); err != nil {
+			t.Fatalf("Migrate from version %d: %v", target, err)
+		}
+		if got := schema(t, db); got != full {
+			t.Fatalf("schema after migrating down to %d and back up:\n%s\nwant:\n%s", target, got, full)
+		}
+		if err := db.MigrateDown(ctx, target); err != nil {
+			t.Fatalf("MigrateDown(%d): %v", target, err)
+		}
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// The store works on a database migrated down and back up
+	user, err := db.CreateUser(ctx, "ann@example.com", "hash", "")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if _, err := db.CreateTask(ctx, user.ID, &database.Task{Text: "report", Tags: []string{"q3"}}); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+}
+
+func TestMigrationChecksum(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	all, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("AppliedMigrations: %v", err)
+	}
+
+	// An applied migration whose SQL has since been edited
+	if _, err := db.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 2`); err != nil {
+		t.Fatalf("failed to edit checksum: %v", err)
+	}
+	if err := db.Migrate(); !errors.Is(err, database.ErrMigrationChecksum) {
+		t.Fatalf("Migrate with an edited migration = %v, want ErrMigrationChecksum", err)
+	}
+	if _, err := db.PendingMigrations(ctx); !errors.Is(err, database.ErrMigrationChecksum) {
+		t.Fatalf("PendingMigrations with an edited migration = %v, want ErrMigrationChecksum", err)
+	}
+	if err := db.MigrateDown(ctx, 0); !errors.Is(err, database.ErrMigrationChecksum) {
+		t.Fatalf("MigrateDown with an edited migration = %v, want ErrMigrationChecksum", err)
+	}
+	if applied, err := db.AppliedMigrations(ctx); err != nil || len(applied) != len(all) {
+		t.Fatalf("AppliedMigrations after a refused MigrateDown = %d, %v", len(applied), err)
+	}
+}
+
+func TestUnknownMigration(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	all, err := db.AppliedMigrations(ctx)
+	if err != nil {
+		t.Fatalf("AppliedMigrations: %v", err)
+	}
+
+	// A database migrated by a newer binary
+	if _, err := db.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, 'from_the_future', 'x')`, len(all)+1); err != nil {
+		t.Fatalf("failed to record migration: %v", err)
+	}
+	if err := db.Migrate(); !errors.Is(err, database.ErrUnknownMigration) {
+		t.Fatalf("Migrate of a newer database = %v, want ErrUnknownMigration", err)
+	}
+	if _, err := db.PendingMigrations(ctx); !errors.Is(err, database.ErrUnknownMigration) {
+		t.Fatalf("PendingMigrations of a newer database = %v, want ErrUnknownMigration", err)
+	}
+	if err := db.MigrateDown(ctx, 0); !errors.Is(err, database.ErrUnknownMigration) {
+		t.Fatalf("MigrateDown of a newer database = %v, want ErrUnknownMigration", err)
+	}
+	if applied, err := db.AppliedMigrations(ctx); err != nil || len(applied) != len(all)+1 {
+		t.Fatalf("AppliedMigrations of a newer database = %d, %v", len(applied), err)
+	}
+}
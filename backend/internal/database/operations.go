@@ -0,0 +1,403 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Operation actions, named after the API call that made the operation.
+const (
+	OperationTaskCreate     = "task.create"
+	OperationTaskUpdate     = "task.update"
+	OperationTaskDelete     = "task.delete"
+	OperationTaskReorder    = "task.reorder"
+	OperationTaskBatch      = "task.batch"
+	OperationListUpdate     = "list.update"
+	OperationListDelete     = "list.delete"
+	OperationSubtaskCreate  = "subtask.create"
+	OperationSubtaskUpdate  = "subtask.update"
+	OperationSubtaskDelete  = "subtask.delete"
+	OperationSubtaskReorder = "subtask.reorder"
+)
+
+// MaxOperations is how many operations are kept in each user's log. Older ones
+// can no longer be undone.
+const MaxOperations = 100
+
+// ErrStaleOperation is returned when the operation to undo or redo no longer
+// applies, for example because its task has been purged from the trash or one
+// of the fields it changed has been changed again since. The operation is
+// dropped from the log.
+var ErrStaleOperation = errors.New("operation no longer applies")
+
+// Operation is a user action in the undo log. The operations that have been
+// undone, and can be redone, are always the newest ones; recording a new
+// operation discards them.
+type Operation struct {
+	ID        int64             `json:"id"`
+	UserID    int64             `json:"userId"`
+	Action    string            `json:"action"`
+	Changes   []OperationChange `json:"changes"`
+	Undone    bool              `json:"undone"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// OperationChange is one change an operation made to a list, task or subtask.
+// Entity is SyncList, SyncTask or SyncSubtask and Action is HistoryCreate,
+// HistoryUpdate or HistoryDelete. An update's Before and After hold the fields
+// it changed, with their old and new values, as UpdateTask, UpdateSubtask or
+// UpdateList updates.
+//
+// Undoing an update applies only while its item still holds the After values,
+// and redoing it only while it holds the Before ones, so a field changed again
+// since makes the operation stale rather than being overwritten. Changes to
+// other fields, such as expanding a task, don't.
+type OperationChange struct {
+	Entity string                 `json:"entity"`
+	ID     int64                  `json:"id"`
+	Action string                 `json:"action"`
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
+
+// Effect returns what undoing or redoing the change does to its item:
+// HistoryUpdate with the updates to make, HistoryDelete or HistoryRestore.
+// Undoing a create moves the item to the trash, and redoing it restores it.
+func (c OperationChange) Effect(undo bool) (string, map[string]interface{}) {
+	switch {
+	case c.Action == HistoryUpdate && undo:
+		return HistoryUpdate, c.Before
+	case c.Action == HistoryUpdate:
+		return HistoryUpdate, c.After
+	case (c.Action == HistoryCreate) == undo:
+		return HistoryDelete, nil
+	default:
+		return HistoryRestore, nil
+	}
+}
+
+// Holds reports whether an item whose fields have the values given, as returned
+// by TaskValues, SubtaskValues or ListValues, is in the state an undo or redo of
+// the change applies to.
+func (c OperationChange) Holds(values map[string]interface{}, undo bool) bool {
+	want := c.Before
+	if undo {
+		want = c.After
+	}
+	for field, value := range want {
+		if field == "tags" {
+			a, errA := TaskTagsFromUpdate(values[field])
+			b, errB := TaskTagsFromUpdate(value)
+			if errA != nil || errB != nil || !sameTags(a, b) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(values[field], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// TaskValues returns the fields of a task an operation can change, with their
+// values decoded as a JSON request body would be, which is what updates hold.
+func TaskValues(task *Task) map[string]interface{} {
+	return decodedValues(map[string]interface{}{
+		"text":       task.Text,
+		"completed":  task.Completed,
+		"important":  task.Important,
+		"listId":     task.ListID,
+		"dueAt":      task.DueAt,
+		"startAt":    task.StartAt,
+		"recurrence": task.Recurrence,
+		"repeatFrom": task.RepeatFrom,
+		"tags":       nonNilTags(task.Tags),
+		"sortOrder":  task.SortOrder,
+	})
+}
+
+// SubtaskValues returns the fields of a subtask an operation can change, like
+// TaskValues.
+func SubtaskValues(subtask *Subtask) map[string]interface{} {
+	return decodedValues(map[string]interface{}{
+		"text":      subtask.Text,
+		"completed": subtask.Completed,
+		"sortOrder": subtask.SortOrder,
+	})
+}
+
+// ListValues returns the fields of a list an operation can change, like
+// TaskValues.
+func ListValues(list *List) map[string]interface{} {
+	return map[string]interface{}{"title": list.Title}
+}
+
+func decodedValues(values map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(values)
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	return decoded
+}
+
+// TaskChange returns the change between two versions of a task to the fields
+// DiffTask tracks, or false if none of them changed.
+func TaskChange(old, new *Task) (OperationChange, bool) {
+	return updateChange(SyncTask, new.ID, DiffTask(old, new))
+}
+
+// SubtaskChange returns the change between two versions of a subtask, or false
+// if they are the same.
+func SubtaskChange(old, new *Subtask) (OperationChange, bool) {
+	return updateChange(SyncSubtask, new.ID, DiffSubtask(old, new))
+}
+
+// ListChange returns the change between two versions of a list, or false if
+// they are the same.
+func ListChange(old, new *List) (OperationChange, bool) {
+	if old.Title == new.Title {
+		return OperationChange{}, false
+	}
+	return updateChange(SyncList, new.ID, []FieldChange{fieldChange("title", old.Title, new.Title)})
+}
+
+// ReorderChanges returns the changes a reorder of the tasks or subtasks ids made
+// to their sort orders, given the orders before and after it.
+func ReorderChanges(entity string, ids []int64, before, after map[int64]int) []OperationChange {
+	var changes []OperationChange
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		old, ok := before[id]
+		if !ok || seen[id] || old == after[id] {
+			continue
+		}
+		seen[id] = true
+		changes = append(changes, OperationChange{
+			Entity: entity,
+			ID:     id,
+			Action: HistoryUpdate,
+			Before: map[string]interface{}{"sortOrder": float64(old)},
+			After:  map[string]interface{}{"sortOrder": float64(after[id])},
+		})
+	}
+	return changes
+}
+
+func updateChange(entity string, id int64, changes []FieldChange) (OperationChange, bool) {
+	if len(changes) == 0 {
+		return OperationChange{}, false
+	}
+	change := OperationChange{
+		Entity: entity,
+		ID:     id,
+		Action: HistoryUpdate,
+		Before: make(map[string]interface{}, len(changes)),
+		After:  make(map[string]interface{}, len(changes)),
+	}
+	for _, c := range changes {
+		// Decoded as a JSON request body would be, which is what updates hold
+		var before, after interface{}
+		json.Unmarshal(c.OldValue, &before)
+		json.Unmarshal(c.NewValue, &after)
+		change.Before[c.Field] = before
+		change.After[c.Field] = after
+	}
+	return change, true
+}
+
+// operationColumns is the column list read by scanOperation, qualified with the "o" alias.
+const operationColumns = `o.id, o.user_id, o.action, o.changes, o.undone, o.created_at`
+
+// scanOperation scans a row selected with operationColumns.
+func scanOperation(row rowScanner) (*Operation, error) {
+	op := &Operation{}
+	var changes string
+	if err := row.Scan(&op.ID, &op.UserID, &op.Action, &changes, &op.Undone, &op.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(changes), &op.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode operation changes: %w", err)
+	}
+	return op, nil
+}
+
+// recordOperationTx adds an operation to the end of a user's undo log within
+// the transaction that made its changes, discarding the operations that were
+// undone and the oldest ones beyond MaxOperations. An operation without changes
+// is not recorded.
+func recordOperationTx(ctx context.Context, tx *sql.Tx, userID int64, action string, changes []OperationChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode operation changes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM operations WHERE user_id = ? AND undone`, userID); err != nil {
+		return fmt.Errorf("failed to discard undone operations: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO operations (user_id, action, changes) VALUES (?, ?, ?)`,
+		userID, action, string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record operation: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM operations WHERE user_id = ? AND id <= (
+		   SELECT id FROM operations WHERE user_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		 )`,
+		userID, userID, MaxOperations,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to trim operations: %w", err)
+	}
+	return nil
+}
+
+// UndoOperation undoes the user's newest operation that has not been undone,
+// applying the inverse of its changes in reverse order in a single transaction.
+// It returns ErrNotFound if there is nothing to undo.
+func (db *DB) UndoOperation(ctx context.Context, userID int64) (*Operation, error) {
+	return db.stepOperation(ctx, userID, true)
+}
+
+// RedoOperation applies the user's most recently undone operation again, in a
+// single transaction. It returns ErrNotFound if there is nothing to redo.
+func (db *DB) RedoOperation(ctx context.Context, userID int64) (*Operation, error) {
+	return db.stepOperation(ctx, userID, false)
+}
+
+// stepOperation undoes or redoes the operation at the top of the undo or redo
+// stack.
+func (db *DB) stepOperation(ctx context.Context, userID int64, undo bool) (*Operation, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The newest operation not undone, or the oldest undone one
+	query := `SELECT ` + operationColumns + ` FROM operations o
+		WHERE o.user_id = ? AND NOT o.undone ORDER BY o.id DESC LIMIT 1`
+	if !undo {
+		query = `SELECT ` + operationColumns + ` FROM operations o
+			WHERE o.user_id = ? AND o.undone ORDER BY o.id LIMIT 1`
+	}
+	op, err := scanOperation(tx.QueryRowContext(ctx, query, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+
+	for i := range op.Changes {
+		change := op.Changes[i]
+		if undo {
+			change = op.Changes[len(op.Changes)-1-i]
+		}
+		if err := applyChangeTx(ctx, tx, userID, change, undo); err != nil {
+			if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrStaleOperation) {
+				return nil, err
+			}
+			tx.Rollback()
+			if _, err := db.ExecContext(ctx, `DELETE FROM operations WHERE id = ?`, op.ID); err != nil {
+				return nil, fmt.Errorf("failed to drop operation: %w", err)
+			}
+			return nil, ErrStaleOperation
+		}
+	}
+
+	op.Undone = undo
+	if _, err := tx.ExecContext(ctx, `UPDATE operations SET undone = ? WHERE id = ?`, undo, op.ID); err != nil {
+		return nil, fmt.Errorf("failed to update operation: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return op, nil
+}
+
+// applyChangeTx undoes or redoes one change of an operation within a
+// transaction. It fails with ErrStaleOperation if the change's item no longer
+// holds the values the change expects.
+func applyChangeTx(ctx context.Context, tx *sql.Tx, userID int64, change OperationChange, undo bool) error {
+	effect, updates := change.Effect(undo)
+	if effect == HistoryUpdate {
+		values, err := itemValuesTx(ctx, tx, userID, change)
+		if err != nil {
+			return err
+		}
+		if !change.Holds(values, undo) {
+			return ErrStaleOperation
+		}
+	}
+
+	switch {
+	case change.Entity == SyncTask && effect == HistoryUpdate:
+		return updateTaskTx(ctx, tx, userID, change.ID, updates, 0)
+	case change.Entity == SyncTask && effect == HistoryDelete:
+		return deleteTaskTx(ctx, tx, userID, change.ID)
+	case change.Entity == SyncTask && effect == HistoryRestore:
+		return restoreTaskTx(ctx, tx, userID, change.ID)
+	case change.Entity == SyncSubtask && effect == HistoryUpdate:
+		// A reorder's changes hold only the sort order, which isn't an update
+		// UpdateSubtask takes
+		if sortOrder, ok := updates["sortOrder"].(float64); ok {
+			return moveSubtaskTx(ctx, tx, userID, change.ID, int(sortOrder))
+		}
+		_, err := updateSubtaskTx(ctx, tx, userID, change.ID, updates, 0)
+		return err
+	case change.Entity == SyncSubtask && effect == HistoryDelete:
+		return deleteSubtaskTx(ctx, tx, userID, change.ID)
+	case change.Entity == SyncSubtask && effect == HistoryRestore:
+		_, err := restoreSubtaskTx(ctx, tx, userID, change.ID)
+		return err
+	case change.Entity == SyncList && effect == HistoryUpdate:
+		title, _ := updates["title"].(string)
+		return updateListTx(ctx, tx, userID, change.ID, title, 0)
+	case change.Entity == SyncList && effect == HistoryDelete:
+		return deleteListTx(ctx, tx, userID, change.ID)
+	case change.Entity == SyncList && effect == HistoryRestore:
+		return restoreListTx(ctx, tx, userID, change.ID)
+	default:
+		return fmt.Errorf("unknown change %s to %s", change.Action, change.Entity)
+	}
+}
+
+// itemValuesTx returns the values of the fields of a change's live list, task
+// or subtask within a transaction.
+func itemValuesTx(ctx context.Context, tx *sql.Tx, userID int64, change OperationChange) (map[string]interface{}, error) {
+	switch change.Entity {
+	case SyncTask:
+		task, err := getTaskTx(ctx, tx, userID, change.ID)
+		if err != nil {
+			return nil, err
+		}
+		return TaskValues(task), nil
+	case SyncSubtask:
+		subtask, err := getSubtaskTx(ctx, tx, userID, change.ID)
+		if err != nil {
+			return nil, err
+		}
+		return SubtaskValues(subtask), nil
+	case SyncList:
+		list, err := getListTx(ctx, tx, userID, change.ID)
+		if err != nil {
+			return nil, err
+		}
+		return ListValues(list), nil
+	default:
+		return nil, fmt.Errorf("unknown change %s to %s", change.Action, change.Entity)
+	}
+}
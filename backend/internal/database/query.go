@@ -0,0 +1,220 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/query"
+)
+
+// TaskQuery is a parsed task query, evaluated at Now in the user's Location.
+// list: terms are resolved against Lists, the user's lists, by title.
+type TaskQuery struct {
+	Expr     query.Expr
+	Lists    []*List
+	Now      time.Time
+	Location *time.Location
+}
+
+// dueFilter returns a TaskFilter that evaluates due date conditions the way the
+// query does.
+func (q *TaskQuery) dueFilter() TaskFilter {
+	return TaskFilter{Now: q.Now, Location: q.Location}
+}
+
+// listIDs returns the IDs of the lists a list: term names.
+func (q *TaskQuery) listIDs(title string) []int64 {
+	var ids []int64
+	for _, list := range q.Lists {
+		if strings.EqualFold(list.Title, title) {
+			ids = append(ids, list.ID)
+		}
+	}
+	return ids
+}
+
+// Matches reports whether a task, with its tags and subtasks attached, matches
+// the query.
+func (q *TaskQuery) Matches(task *Task) bool {
+	return q.matches(q.Expr, task)
+}
+
+func (q *TaskQuery) matches(expr query.Expr, task *Task) bool {
+	switch e := expr.(type) {
+	case query.And:
+		for _, sub := range e {
+			if !q.matches(sub, task) {
+				return false
+			}
+		}
+		return true
+	case query.Or:
+		for _, sub := range e {
+			if q.matches(sub, task) {
+				return true
+			}
+		}
+		return false
+	case query.Not:
+		return !q.matches(e.Expr, task)
+	case query.Term:
+		return q.matchesTerm(e, task)
+	}
+	return false
+}
+
+func (q *TaskQuery) matchesTerm(term query.Term, task *Task) bool {
+	switch term.Field {
+	case query.FieldText:
+		return strings.Contains(asciiLower(task.Text), asciiLower(term.Value))
+	case query.FieldTag:
+		for _, tag := range task.Tags {
+			if tag == term.Value {
+				return true
+			}
+		}
+		return false
+	case query.FieldList:
+		for _, id := range q.listIDs(term.Value) {
+			if task.ListID != nil && *task.ListID == id {
+				return true
+			}
+		}
+		return false
+	case query.FieldIs:
+		switch term.Value {
+		case "important":
+			return task.Important
+		case "completed":
+			return task.Completed
+		case "overdue":
+			f := q.dueFilter()
+			f.Overdue = true
+			return f.matchesDue(task)
+		case "recurring":
+			return task.Recurrence != ""
+		}
+	case query.FieldHas:
+		switch term.Value {
+		case "list":
+			return task.ListID != nil
+		case "due":
+			return task.DueAt != nil
+		case "tags":
+			return len(task.Tags) > 0
+		case "subtasks":
+			return len(task.Subtasks) > 0
+		}
+	case query.FieldDue:
+		f := q.dueFilter()
+		f.DueAfter, f.DueBefore = term.Range(q.Now, f.location())
+		return f.matchesDue(task)
+	case query.FieldCreated, query.FieldUpdated:
+		after, before := term.Range(q.Now, q.dueFilter().location())
+		if term.Field == query.FieldCreated {
+			return inRange(task.CreatedAt, after, before)
+		}
+		return inRange(task.UpdatedAt, after, before)
+	}
+	return false
+}
+
+// sqlCondition returns a WHERE condition, on the "t" task alias, equivalent to
+// Matches.
+func (q *TaskQuery) sqlCondition() (string, []interface{}) {
+	return q.sqlExpr(q.Expr)
+}
+
+func (q *TaskQuery) sqlExpr(expr query.Expr) (string, []interface{}) {
+	switch e := expr.(type) {
+	case query.And:
+		return q.sqlJoin(e, " AND ")
+	case query.Or:
+		return q.sqlJoin(e, " OR ")
+	case query.Not:
+		part, args := q.sqlExpr(e.Expr)
+		return "NOT (" + part + ")", args
+	case query.Term:
+		return q.sqlTerm(e)
+	}
+	return "0", nil
+}
+
+func (q *TaskQuery) sqlJoin(exprs []query.Expr, op string) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+	for _, expr := range exprs {
+		part, exprArgs := q.sqlExpr(expr)
+		parts = append(parts, part)
+		args = append(args, exprArgs...)
+	}
+	return "(" + strings.Join(parts, op) + ")", args
+}
+
+func (q *TaskQuery) sqlTerm(term query.Term) (string, []interface{}) {
+	switch term.Field {
+	case query.FieldText:
+		// lower() only folds ASCII, as asciiLower does
+		return "instr(lower(t.text), ?) > 0", []interface{}{asciiLower(term.Value)}
+	case query.FieldTag:
+		return `EXISTS (SELECT 1 FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id
+			 WHERE tt.task_id = t.id AND tg.name = ?)`, []interface{}{term.Value}
+	case query.FieldList:
+		ids := q.listIDs(term.Value)
+		if len(ids) == 0 {
+			return "0", nil
+		}
+		args := make([]interface{}, len(ids))
+		for i, id := range ids {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		return "COALESCE(t.list_id, 0) IN (" + placeholders + ")", args
+	case query.FieldIs:
+		switch term.Value {
+		case "important":
+			return "t.important", nil
+		case "completed":
+			return "t.completed", nil
+		case "overdue":
+			f := q.dueFilter()
+			f.Overdue = true
+			where, args := f.dueConditions()
+			return "(1" + where + ")", args
+		case "recurring":
+			return "COALESCE(t.recurrence, '') != ''", nil
+		}
+	case query.FieldHas:
+		switch term.Value {
+		case "list":
+			return "t.list_id IS NOT NULL", nil
+		case "due":
+			return "t.due_at IS NOT NULL", nil
+		case "tags":
+			return "EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = t.id)", nil
+		case "subtasks":
+			return "EXISTS (SELECT 1 FROM subtasks s WHERE s.task_id = t.id AND s.deleted_at IS NULL)", nil
+		}
+	case query.FieldDue:
+		f := q.dueFilter()
+		f.DueAfter, f.DueBefore = term.Range(q.Now, f.location())
+		where, args := f.dueConditions()
+		return "(1" + where + ")", args
+	case query.FieldCreated, query.FieldUpdated:
+		after, before := term.Range(q.Now, q.dueFilter().location())
+		where, args := timeRangeConditions("t."+term.Field+"_at", after, before)
+		return "(1" + where + ")", args
+	}
+	return "0", nil
+}
+
+// asciiLower lowercases the ASCII letters in s, leaving other characters as they
+// are, the way SQLite's lower() does.
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
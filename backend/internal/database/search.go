@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Snippet markers wrapped around matched terms. Snippets are built from raw task
+// text, so clients must escape them before rendering as HTML.
+const (
+	SnippetOpen  = "<mark>"
+	SnippetClose = "</mark>"
+)
+
+// SearchQuery describes a full-text search over a user's tasks. A zero Limit
+// returns every match.
+type SearchQuery struct {
+	Text      string
+	ListID    *int64
+	Completed *bool
+	Tag       string
+	Limit     int
+}
+
+// SearchResult is a task matching a search, with its relevance and a highlighted
+// excerpt. Lower ranks are better matches.
+type SearchResult struct {
+	Task    *Task   `json:"task"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// SearchTerms splits a search string into the terms that are matched, dropping
+// punctuation so user input can't inject FTS query syntax.
+func SearchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// ftsQuery builds an FTS5 match expression requiring every term, each as a prefix.
+func ftsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + term + `"*`
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// SearchTasks returns the user's live tasks whose text, subtasks or tags match
+// every search term, best matches first. Task text weighs more than subtask
+// text, which weighs more than tags.
+func (db *DB) SearchTasks(ctx context.Context, userID int64, q SearchQuery) ([]*SearchResult, error) {
+	terms := SearchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + taskColumns + `,
+		   bm25(tasks_fts, 10.0, 5.0, 2.0) AS score,
+		   snippet(tasks_fts, -1, ?, ?, '…', 12)
+		 FROM tasks_fts
+		 JOIN tasks t ON t.id = tasks_fts.rowid
+		 WHERE tasks_fts MATCH ? AND t.user_id = ? AND t.deleted_at IS NULL`
+	args := []interface{}{SnippetOpen, SnippetClose, ftsQuery(terms), userID}
+
+	if q.ListID != nil {
+		query += ` AND t.list_id = ?`
+		args = append(args, *q.ListID)
+	}
+	if q.Completed != nil {
+		query += ` AND t.completed = ?`
+		args = append(args, *q.Completed)
+	}
+	if q.Tag != "" {
+		query += ` AND EXISTS (
+		   SELECT 1 FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id
+		   WHERE tt.task_id = t.id AND tg.name = ?)`
+		args = append(args, q.Tag)
+	}
+	query += ` ORDER BY score ASC, t.id ASC`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*SearchResult
+	var tasks []*Task
+	for rows.Next() {
+		result := &SearchResult{}
+		task, err := scanTask(rows, &result.Rank, &result.Snippet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		result.Task = task
+		results = append(results, result)
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+	rows.Close()
+
+	if err := db.attachTaskDetails(ctx, userID, tasks); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SmartList is a saved task query shown alongside a user's lists.
+type SmartList struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	Title     string    `json:"title"`
+	Query     string    `json:"query"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// smartListColumns is the column list read by scanSmartList, qualified with the "sl" alias.
+const smartListColumns = `sl.id, sl.user_id, sl.title, sl.query, sl.version, sl.created_at, sl.updated_at`
+
+// scanSmartList scans a row selected with smartListColumns.
+func scanSmartList(row rowScanner) (*SmartList, error) {
+	list := &SmartList{}
+	if err := row.Scan(&list.ID, &list.UserID, &list.Title, &list.Query, &list.Version, &list.CreatedAt, &list.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreateSmartList saves a query as a smart list.
+func (db *DB) CreateSmartList(ctx context.Context, userID int64, title, query string) (*SmartList, error) {
+	result, err := db.ExecContext(ctx,
+		`INSERT INTO smart_lists (user_id, title, query) VALUES (?, ?, ?)`,
+		userID, title, query,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create smart list: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get smart list id: %w", err)
+	}
+
+	return db.GetSmartList(ctx, userID, id)
+}
+
+// GetSmartLists retrieves a user's smart lists, ordered by title.
+func (db *DB) GetSmartLists(ctx context.Context, userID int64) ([]*SmartList, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+smartListColumns+` FROM smart_lists sl WHERE sl.user_id = ? ORDER BY sl.title ASC, sl.id ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query smart lists: %w", err)
+	}
+	defer rows.Close()
+
+	var lists []*SmartList
+	for rows.Next() {
+		list, err := scanSmartList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan smart list: %w", err)
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+// GetSmartList retrieves a single smart list by ID for a specific user.
+func (db *DB) GetSmartList(ctx context.Context, userID, listID int64) (*SmartList, error) {
+	list, err := scanSmartList(db.QueryRowContext(ctx,
+		`SELECT `+smartListColumns+` FROM smart_lists sl WHERE sl.id = ? AND sl.user_id = ?`,
+		listID, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get smart list: %w", err)
+	}
+
+	return list, nil
+}
+
+// UpdateSmartList replaces a smart list's title and query. A non-zero version
+// makes the update conditional: it fails with ErrVersionConflict unless the smart
+// list is at that version.
+func (db *DB) UpdateSmartList(ctx context.Context, userID, listID int64, title, query string, version int64) (*SmartList, error) {
+	result, err := db.ExecContext(ctx,
+		`UPDATE smart_lists SET title = ?, query = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE id = ? AND user_id = ? AND (? = 0 OR version = ?)`,
+		title, query, listID, userID, version, version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update smart list: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		if _, err := db.GetSmartList(ctx, userID, listID); err != nil {
+			return nil, err
+		}
+		return nil, ErrVersionConflict
+	}
+
+	return db.GetSmartList(ctx, userID, listID)
+}
+
+// DeleteSmartList deletes a smart list. The tasks it matches are not affected.
+func (db *DB) DeleteSmartList(ctx context.Context, userID, listID int64) error {
+	result, err := db.ExecContext(ctx,
+		`DELETE FROM smart_lists WHERE id = ? AND user_id = ?`,
+		listID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete smart list: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// UserStore manages user accounts.
+type UserStore interface {
+	CreateUser(ctx context.Context, email, passwordHash, displayName string) (*User, error)
+	GetUserByID(ctx context.Context, id int64) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdateUser(ctx context.Context, id int64, displayName string) (*User, error)
+	UpdateUserTimezone(ctx context.Context, id int64, timezone string) error
+	UpdateUserPassword(ctx context.Context, id int64, newPasswordHash string) error
+	DeleteUser(ctx context.Context, id int64) error
+}
+
+// SessionStore manages refresh token sessions.
+type SessionStore interface {
+	CreateSession(ctx context.Context, userID int64, token string, expiresAt time.Time) (*Session, error)
+	GetSessionByToken(ctx context.Context, token string) (*Session, error)
+	DeleteSession(ctx context.Context, token string) error
+	DeleteUserSessions(ctx context.Context, userID int64) error
+	CleanupExpiredSessions(ctx context.Context) (int64, error)
+}
+
+// ListStore manages user-created lists.
+type ListStore interface {
+	CreateList(ctx context.Context, userID int64, title string) (*List, error)
+	GetLists(ctx context.Context, userID int64, filter ListFilter) ([]*List, error)
+	GetList(ctx context.Context, userID, listID int64) (*List, error)
+	UpdateList(ctx context.Context, userID, listID int64, title string, version int64) (*List, error)
+	DeleteList(ctx context.Context, userID, listID int64) error
+}
+
+// SmartListStore manages saved task queries.
+type SmartListStore interface {
+	CreateSmartList(ctx context.Context, userID int64, title, query string) (*SmartList, error)
+	GetSmartLists(ctx context.Context, userID int64) ([]*SmartList, error)
+	GetSmartList(ctx context.Context, userID, listID int64) (*SmartList, error)
+	UpdateSmartList(ctx context.Context, userID, listID int64, title, query string, version int64) (*SmartList, error)
+	DeleteSmartList(ctx context.Context, userID, listID int64) error
+}
+
+// TaskStore manages tasks, their tags and their subtasks.
+type TaskStore interface {
+	CreateTask(ctx context.Context, userID int64, task *Task) (*Task, error)
+	GetTask(ctx context.Context, userID, taskID int64) (*Task, error)
+	GetUserTasks(ctx context.Context, userID int64, filter TaskFilter) ([]*Task, error)
+	UpdateTask(ctx context.Context, userID, taskID int64, updates map[string]interface{}, version int64) (*Task, error)
+	CompleteRecurringTask(ctx context.Context, userID, taskID int64, updates map[string]interface{}, version int64, next NextOccurrence) (*Task, *Task, error)
+	DeleteTask(ctx context.Context, userID, taskID int64) error
+	ReorderTasks(ctx context.Context, userID int64, taskIDs []int64) error
+	BatchUpdateTasks(ctx context.Context, userID int64, ops []TaskOperation, next NextOccurrence) (*BatchUpdate, error)
+
+	CreateSubtask(ctx context.Context, userID, taskID int64, text string) (*Subtask, error)
+	GetSubtask(ctx context.Context, userID, subtaskID int64) (*Subtask, error)
+	GetSubtasks(ctx context.Context, taskID int64) ([]*Subtask, error)
+	UpdateSubtask(ctx context.Context, userID, subtaskID int64, updates map[string]interface{}, version int64) (*Subtask, error)
+	DeleteSubtask(ctx context.Context, userID, subtaskID int64) error
+	ReorderSubtasks(ctx context.Context, userID, taskID int64, subtaskIDs []int64) error
+}
+
+// TagStore manages a user's tags.
+type TagStore interface {
+	GetTags(ctx context.Context, userID int64) ([]*Tag, error)
+	GetTag(ctx context.Context, userID, tagID int64) (*Tag, error)
+	CreateTag(ctx context.Context, userID int64, tag *Tag) (*Tag, error)
+	UpdateTag(ctx context.Context, userID, tagID int64, updates map[string]interface{}) (*Tag, []int64, error)
+	MergeTags(ctx context.Context, userID, sourceID, targetID int64) (*Tag, []int64, error)
+	DeleteTag(ctx context.Context, userID, tagID int64) ([]int64, error)
+}
+
+// TrashStore manages soft-deleted items.
+type TrashStore interface {
+	GetTrash(ctx context.Context, userID int64) (*Trash, error)
+	RestoreTask(ctx context.Context, userID, taskID int64) (*Task, error)
+	RestoreSubtask(ctx context.Context, userID, subtaskID int64) (*Subtask, error)
+	RestoreList(ctx context.Context, userID, listID int64) (*List, []*Task, error)
+	PurgeTask(ctx context.Context, userID, taskID int64) error
+	PurgeSubtask(ctx context.Context, userID, subtaskID int64) error
+	PurgeList(ctx context.Context, userID, listID int64) error
+	EmptyTrash(ctx context.Context, userID int64) (int64, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+}
+
+// HistoryStore reads the change history recorded for tasks and subtasks.
+type HistoryStore interface {
+	GetTaskHistory(ctx context.Context, userID, taskID, before int64, limit int) ([]*HistoryEntry, error)
+}
+
+// SearchStore runs full-text searches over tasks.
+type SearchStore interface {
+	SearchTasks(ctx context.Context, userID int64, q SearchQuery) ([]*SearchResult, error)
+}
+
+// SyncStore reads what changed in a user's data for delta sync.
+type SyncStore interface {
+	GetChanges(ctx context.Context, userID, cursor int64) (*SyncChanges, error)
+	GetSyncCursor(ctx context.Context) (int64, error)
+}
+
+// FeedStore manages the tokens that give access to calendar feeds.
+type FeedStore interface {
+	CreateFeedToken(ctx context.Context, userID int64, token, name string, listID *int64) (*FeedToken, error)
+	GetFeedTokens(ctx context.Context, userID int64) ([]*FeedToken, error)
+	GetFeedTokenByToken(ctx context.Context, token string) (*FeedToken, error)
+	DeleteFeedToken(ctx context.Context, userID, feedID int64) error
+}
+
+// ImportJobStore tracks imports running in the background.
+type ImportJobStore interface {
+	CreateImportJob(ctx context.Context, userID int64, source string, total int) (*ImportJob, error)
+	UpdateImportJob(ctx context.Context, job *ImportJob) error
+	GetImportJob(ctx context.Context, userID, jobID int64) (*ImportJob, error)
+	GetImportJobs(ctx context.Context, userID int64) ([]*ImportJob, error)
+	FailInterruptedImportJobs(ctx context.Context) (int64, error)
+}
+
+// OperationStore undoes and redoes the operations in each user's undo log. The
+// methods that make an undoable change log it in the same transaction.
+type OperationStore interface {
+	UndoOperation(ctx context.Context, userID int64) (*Operation, error)
+	RedoOperation(ctx context.Context, userID int64) (*Operation, error)
+}
+
+// AppPasswordStore manages the passwords third-party apps sign in with.
+type AppPasswordStore interface {
+	CreateAppPassword(ctx context.Context, userID int64, password, name string) (*AppPassword, error)
+	GetAppPasswords(ctx context.Context, userID int64) ([]*AppPassword, error)
+	CheckAppPassword(ctx context.Context, userID int64, password string) (*AppPassword, error)
+	DeleteAppPassword(ctx context.Context, userID, passwordID int64) error
+}
+
+// CalDAVStore keeps the resource names and UIDs CalDAV clients gave the tasks
+// and subtasks they created.
+type CalDAVStore interface {
+	CreateCalDAVTask(ctx context.Context, obj *CalDAVObject, task *Task) (*Task, error)
+	CreateCalDAVSubtask(ctx context.Context, obj *CalDAVObject, taskID int64, text string, completed bool) (*Subtask, error)
+	GetCalDAVObjects(ctx context.Context, userID int64) ([]*CalDAVObject, error)
+}
+
+// ArchiveStore restores account archives.
+type ArchiveStore interface {
+	ImportArchive(ctx context.Context, userID int64, archive *Archive, opts ImportOptions) (*ImportReport, error)
+}
+
+// Store is the full set of repository methods the API depends on.
+// *DB implements it on top of SQLite; the memory package provides an in-memory version.
+type Store interface {
+	UserStore
+	SessionStore
+	ListStore
+	SmartListStore
+	TaskStore
+	TagStore
+	TrashStore
+	HistoryStore
+	SearchStore
+	SyncStore
+	ArchiveStore
+	FeedStore
+	AppPasswordStore
+	CalDAVStore
+	ImportJobStore
+	OperationStore
+}
+
+var _ Store = (*DB)(nil)
@@ -0,0 +1,157 @@
+package storetest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testArchive(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+	old := createTask(t, s, ann.ID, &database.Task{Text: "old", Tags: []string{"home"}})
+	if _, err := s.CreateSmartList(ctx, ann.ID, "Urgent", "is:important"); err != nil {
+		t.Fatalf("CreateSmartList: %v", err)
+	}
+	theirs := createTask(t, s, bob.ID, &database.Task{Text: "bob's", Tags: []string{"home"}})
+
+	done := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	archive := &database.Archive{
+		Format:   database.ArchiveFormat,
+		Version:  database.ArchiveVersion,
+		Settings: database.ArchiveSettings{DisplayName: "Ann B", Timezone: "Europe/Paris"},
+		Lists: []*database.ArchiveList{
+			{ID: 10, Title: "Work"},
+			{ID: 11, Title: "Garden"},
+		},
+		Tags: []*database.ArchiveTag{
+			{Name: "home", Color: "#00ff00"},
+			{Name: "outdoor", Description: "Out and about"},
+		},
+		Tasks: []*database.ArchiveTask{
+			{ID: 100, ListID: ptr(int64(10)), Text: "report", Important: true, Tags: []string{"outdoor", "q3"},
+				DueAt: date(t, "2024-03-10"), Subtasks: []*database.ArchiveSubtask{{Text: "draft", Completed: true}, {Text: "send"}}},
+			{ID: 101, ListID: ptr(int64(11)), Text: "seeds", Completed: true, CompletedAt: &done},
+			{Text: "loose"},
+		},
+		SmartLists: []*database.ArchiveSmartList{
+			{Title: "Urgent", Query: "is:important"},
+			{Title: "Garden", Query: `list:Garden`},
+		},
+	}
+	if err := archive.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	// A dry run reports the import without making it
+	report, err := s.ImportArchive(ctx, ann.ID, archive, database.ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportArchive dry run: %v", err)
+	}
+	wantReport(t, "dry run", report, "lists 1/1/0 tags 2/1/0 tasks 3/0/0 subtasks 2/0/0 smartLists 1/1/0 settings false")
+	if report.ListIDs != nil || report.TaskIDs != nil {
+		t.Fatalf("dry run mapped IDs %v and %v", report.ListIDs, report.TaskIDs)
+	}
+	wantStrings(t, "tasks after a dry run", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{})), []string{"old"})
+	if got := tagSummary(getTags(t, s, ann.ID)); got != "home:1" {
+		t.Fatalf("tags after a dry run = %s", got)
+	}
+
+	// A merge matches lists by title and tags by name, and appends the tasks
+	report, err = s.ImportArchive(ctx, ann.ID, archive, database.ImportOptions{ListID: &work.ID})
+	if err != nil {
+		t.Fatalf("ImportArchive: %v", err)
+	}
+	wantReport(t, "merge", report, "lists 1/1/0 tags 2/1/0 tasks 3/0/0 subtasks 2/0/0 smartLists 1/1/0 settings false")
+	if report.ListIDs[10] != work.ID || report.ListIDs[11] == 0 || report.ListIDs[11] == work.ID {
+		t.Fatalf("merge mapped lists %v", report.ListIDs)
+	}
+	if len(report.TaskIDs) != 2 || report.TaskIDs[100] == 0 || report.TaskIDs[101] == 0 {
+		t.Fatalf("merge mapped tasks %v", report.TaskIDs)
+	}
+	tasks := getTasks(t, s, ann.ID, database.TaskFilter{})
+	wantStrings(t, "tasks after a merge", taskTexts(tasks), []string{"old", "report", "seeds", "loose"})
+	report1, seeds, loose := tasks[1], tasks[2], tasks[3]
+	if report1.ID != report.TaskIDs[100] || report1.ListID == nil || *report1.ListID != work.ID || !report1.Important ||
+		report1.DueAt == nil || report1.DueAt.String() != "2024-03-10" || report1.Version != 1 {
+		t.Fatalf("imported task = %+v", report1)
+	}
+	wantStrings(t, "tags of an imported task", report1.Tags, []string{"outdoor", "q3"})
+	wantStrings(t, "subtasks of an imported task", subtaskTexts(report1.Subtasks), []string{"draft", "send"})
+	if !report1.Subtasks[0].Completed || report1.Subtasks[1].Completed {
+		t.Fatalf("imported subtasks = %+v, %+v", report1.Subtasks[0], report1.Subtasks[1])
+	}
+	if seeds.ListID == nil || *seeds.ListID != report.ListIDs[11] || !seeds.Completed || seeds.CompletedAt == nil || !seeds.CompletedAt.Equal(done) {
+		t.Fatalf("imported completed task = %+v", seeds)
+	}
+	if loose.ListID == nil || *loose.ListID != work.ID {
+		t.Fatalf("imported task without a list went to %v, want list %d", loose.ListID, work.ID)
+	}
+	if entries := getHistory(t, s, ann.ID, report1.ID, 0, 10); len(entries) != 1 || entries[0].Action != database.HistoryCreate {
+		t.Fatalf("history of an imported task = %+v", entries)
+	}
+	if got := tagSummary(getTags(t, s, ann.ID)); got != "home:1 outdoor:1 q3:1" {
+		t.Fatalf("tags after a merge = %s", got)
+	}
+	if tag := getTags(t, s, ann.ID)[0]; tag.Color != "" {
+		t.Fatalf("a merged tag took the archive's color: %+v", tag)
+	}
+	smartLists, err := s.GetSmartLists(ctx, ann.ID)
+	if err != nil || len(smartLists) != 2 {
+		t.Fatalf("GetSmartLists after a merge = %+v, %v", smartLists, err)
+	}
+	if user, err := s.GetUserByID(ctx, ann.ID); err != nil || user.DisplayName != "" {
+		t.Fatalf("a merge applied the settings: %+v, %v", user, err)
+	}
+
+	// A replace deletes everything first, trashed rows included, and applies the
+	// settings
+	if err := s.DeleteTask(ctx, ann.ID, old.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	report, err = s.ImportArchive(ctx, ann.ID, archive, database.ImportOptions{Replace: true})
+	if err != nil {
+		t.Fatalf("ImportArchive replace: %v", err)
+	}
+	wantReport(t, "replace", report, "lists 2/0/2 tags 3/0/3 tasks 3/0/4 subtasks 2/0/2 smartLists 2/0/2 settings true")
+	wantStrings(t, "tasks after a replace", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{})), []string{"report", "seeds", "loose"})
+	if trash := getTrash(t, s, ann.ID); len(trash.Tasks) != 0 {
+		t.Fatalf("trash after a replace = %+v", trash.Tasks)
+	}
+	if tag := getTags(t, s, ann.ID)[0]; tag.Name != "home" || tag.Color != "#00ff00" {
+		t.Fatalf("tag after a replace = %+v", tag)
+	}
+	if user, err := s.GetUserByID(ctx, ann.ID); err != nil || user.DisplayName != "Ann B" || user.Timezone != "Europe/Paris" {
+		t.Fatalf("user after a replace = %+v, %v", user, err)
+	}
+
+	// Other users are left alone
+	if got := getTask(t, s, bob.ID, theirs.ID); got.Text != "bob's" {
+		t.Fatalf("another user's task after imports = %+v", got)
+	}
+	if got := tagSummary(getTags(t, s, bob.ID)); got != "home:1" {
+		t.Fatalf("another user's tags after imports = %s", got)
+	}
+}
+
+// wantReport fails the test unless an import report has the counts in want, as
+// created/merged/deleted per kind of row.
+func wantReport(t *testing.T, what string, report *database.ImportReport, want string) {
+	t.Helper()
+	counts := func(c database.ImportCounts) string {
+		return fmt.Sprintf("%d/%d/%d", c.Created, c.Merged, c.Deleted)
+	}
+	got := fmt.Sprintf("lists %s tags %s tasks %s subtasks %s smartLists %s settings %t",
+		counts(report.Lists), counts(report.Tags), counts(report.Tasks), counts(report.Subtasks), counts(report.SmartLists), report.Settings)
+	if got != want {
+		t.Fatalf("%s reported %s, want %s", what, got, want)
+	}
+}
+
+// ptr returns a pointer to v.
+func ptr[T any](v T) *T {
+	return &v
+}
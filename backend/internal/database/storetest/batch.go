@@ -0,0 +1,139 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testBatch(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+	theirs := createList(t, s, bob.ID, "Bob's")
+	weekly := createTask(t, s, ann.ID, &database.Task{
+		Text:       "timesheet",
+		Tags:       []string{"admin"},
+		DueAt:      date(t, "2024-03-08"),
+		Recurrence: "FREQ=WEEKLY",
+	})
+	createSubtask(t, s, ann.ID, weekly.ID, "hours")
+	weekly = getTask(t, s, ann.ID, weekly.ID)
+	report := createTask(t, s, ann.ID, &database.Task{Text: "report", Tags: []string{"q3"}})
+	call := createTask(t, s, ann.ID, &database.Task{Text: "call"})
+
+	scheduled := &database.Occurrence{DueAt: date(t, "2024-03-15"), Recurrence: "FREQ=WEEKLY"}
+	next := func(task *database.Task) (*database.Occurrence, error) {
+		if !task.Completed || task.Recurrence == "" {
+			t.Fatalf("NextOccurrence called with %+v", task)
+		}
+		return scheduled, nil
+	}
+	complete := map[string]interface{}{"completed": true}
+
+	// A failing operation fails the whole batch, checked against what the
+	// operations before it leave behind
+	for _, tt := range []struct {
+		name  string
+		ops   []database.TaskOperation
+		next  database.NextOccurrence
+		index int
+		want  error
+	}{
+		{"an unknown task", []database.TaskOperation{
+			{TaskID: report.ID, Updates: map[string]interface{}{"important": true}},
+			{TaskID: 9999, Delete: true},
+		}, next, 1, database.ErrNotFound},
+		{"another user's list", []database.TaskOperation{
+			{TaskID: report.ID, Updates: map[string]interface{}{"important": true}},
+			{TaskID: call.ID, Updates: map[string]interface{}{"listId": theirs.ID}},
+		}, next, 1, database.ErrListNotFound},
+		{"a stale version", []database.TaskOperation{
+			{TaskID: report.ID, Version: report.Version, Updates: map[string]interface{}{"important": true}},
+			{TaskID: report.ID, Version: report.Version, Delete: true},
+		}, next, 1, database.ErrVersionConflict},
+		{"a deleted task", []database.TaskOperation{
+			{TaskID: call.ID, Delete: true},
+			{TaskID: call.ID, Updates: complete},
+		}, next, 1, database.ErrNotFound},
+		{"a failed schedule", []database.TaskOperation{
+			{TaskID: report.ID, Updates: map[string]interface{}{"important": true}},
+			{TaskID: weekly.ID, Updates: complete},
+		}, func(*database.Task) (*database.Occurrence, error) { return nil, errors.New("no more") }, 1, nil},
+	} {
+		_, err := s.BatchUpdateTasks(ctx, ann.ID, tt.ops, tt.next)
+		var batchErr *database.BatchError
+		if !errors.As(err, &batchErr) || batchErr.Index != tt.index || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Fatalf("BatchUpdateTasks with %s = %v, want operation %d to fail with %v", tt.name, err, tt.index, tt.want)
+		}
+		for _, task := range []*database.Task{weekly, report, call} {
+			if got := getTask(t, s, ann.ID, task.ID); got.Version != task.Version {
+				t.Fatalf("BatchUpdateTasks with %s changed task %q to %+v", tt.name, task.Text, got)
+			}
+		}
+		if tasks := getTasks(t, s, ann.ID, database.TaskFilter{}); len(tasks) != 3 {
+			t.Fatalf("BatchUpdateTasks with %s left %d tasks", tt.name, len(tasks))
+		}
+	}
+
+	// Completing a recurring task spawns its next occurrence and moves the
+	// task's version on twice, once for the completion and once for its rule
+	batch, err := s.BatchUpdateTasks(ctx, ann.ID, []database.TaskOperation{
+		{TaskID: weekly.ID, Version: weekly.Version, Updates: complete},
+		{TaskID: report.ID, Updates: map[string]interface{}{"listId": work.ID}},
+		{TaskID: weekly.ID, Version: weekly.Version + 2, Updates: map[string]interface{}{"important": true}},
+		{TaskID: report.ID, AddTags: []string{"work"}, RemoveTags: []string{"q3"}},
+		{TaskID: call.ID, Delete: true},
+	}, next)
+	if err != nil {
+		t.Fatalf("BatchUpdateTasks: %v", err)
+	}
+	if len(batch.Tasks) != 5 || batch.Tasks[0] != batch.Tasks[2] || batch.Tasks[1] != batch.Tasks[3] || batch.Tasks[4] != nil {
+		t.Fatalf("BatchUpdateTasks returned tasks %+v", batch.Tasks)
+	}
+	if task := batch.Tasks[0]; !task.Completed || !task.Important || task.Recurrence != "" || task.Version != weekly.Version+3 {
+		t.Fatalf("completed task after BatchUpdateTasks = %+v", task)
+	}
+	if task := batch.Tasks[1]; task.ListID == nil || *task.ListID != work.ID {
+		t.Fatalf("moved task after BatchUpdateTasks = %+v", task)
+	}
+	wantStrings(t, "tags after BatchUpdateTasks", batch.Tasks[1].Tags, []string{"work"})
+
+	if len(batch.Created) != 1 {
+		t.Fatalf("BatchUpdateTasks spawned %d tasks, want 1", len(batch.Created))
+	}
+	spawned := batch.Created[0]
+	if spawned.Text != "timesheet" || spawned.Completed || !spawned.DueAt.Equal(scheduled.DueAt) || spawned.Recurrence != "FREQ=WEEKLY" {
+		t.Fatalf("BatchUpdateTasks spawned %+v", spawned)
+	}
+	wantStrings(t, "tags of the next occurrence", spawned.Tags, []string{"admin"})
+	wantStrings(t, "subtasks of the next occurrence", subtaskTexts(spawned.Subtasks), []string{"hours"})
+	wantStrings(t, "tasks after BatchUpdateTasks", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{})),
+		[]string{"timesheet", "report", "timesheet"})
+
+	// The changes, for the undo log, follow the order the batch first touched
+	// each task in, then the tasks it created
+	var changes []string
+	for _, change := range batch.Changes {
+		changes = append(changes, fmt.Sprintf("%s %s %d", change.Action, change.Entity, change.ID))
+	}
+	wantStrings(t, "changes of BatchUpdateTasks", changes, []string{
+		fmt.Sprintf("update task %d", weekly.ID),
+		fmt.Sprintf("update task %d", report.ID),
+		fmt.Sprintf("delete task %d", call.ID),
+		fmt.Sprintf("create task %d", spawned.ID),
+	})
+	if before := batch.Changes[0].Before; before["completed"] != false || before["recurrence"] != "FREQ=WEEKLY" {
+		t.Fatalf("change of the completed task = %+v", batch.Changes[0])
+	}
+
+	// Another user can't touch the tasks, and only the first completion spawns
+	_, err = s.BatchUpdateTasks(ctx, bob.ID, []database.TaskOperation{{TaskID: report.ID, Delete: true}}, next)
+	wantErr(t, "BatchUpdateTasks of another user's task", err, database.ErrNotFound)
+	batch, err = s.BatchUpdateTasks(ctx, ann.ID, []database.TaskOperation{{TaskID: weekly.ID, Updates: complete}}, next)
+	if err != nil || len(batch.Created) != 0 {
+		t.Fatalf("completing a finished occurrence again = %+v, %v", batch, err)
+	}
+}
@@ -0,0 +1,151 @@
+package storetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testCalDAV(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+	theirs := createTask(t, s, bob.ID, &database.Task{Text: "bob's"})
+
+	task, err := s.CreateCalDAVTask(ctx, &database.CalDAVObject{UserID: ann.ID, UID: "abc@client", Name: "abc.ics"}, &database.Task{
+		ListID:    &work.ID,
+		Text:      "report",
+		Tags:      []string{"q3"},
+		Completed: true,
+		DueAt:     date(t, "2024-03-10"),
+	})
+	if err != nil || task.ID == 0 || task.UserID != ann.ID || task.ListID == nil || *task.ListID != work.ID ||
+		!task.Completed || task.CompletedAt == nil || task.DueAt.String() != "2024-03-10" || task.Version != 1 {
+		t.Fatalf("CreateCalDAVTask = %+v, %v", task, err)
+	}
+	wantStrings(t, "tags of a CalDAV task", task.Tags, []string{"q3"})
+
+	draft, err := s.CreateCalDAVSubtask(ctx, &database.CalDAVObject{UserID: ann.ID, UID: "def@client", Name: "def.ics"}, task.ID, "draft", false)
+	if err != nil || draft.TaskID != task.ID || draft.Text != "draft" || draft.Completed || draft.Version != 1 {
+		t.Fatalf("CreateCalDAVSubtask = %+v, %v", draft, err)
+	}
+	sent, err := s.CreateCalDAVSubtask(ctx, &database.CalDAVObject{UserID: ann.ID, UID: "ghi@client", Name: "ghi.ics"}, task.ID, "send", true)
+	if err != nil || !sent.Completed {
+		t.Fatalf("CreateCalDAVSubtask of a completed subtask = %+v, %v", sent, err)
+	}
+	wantStrings(t, "subtasks of a CalDAV task", subtaskTexts(getTask(t, s, ann.ID, task.ID).Subtasks), []string{"draft", "send"})
+
+	// A subtask of a task the user can't see creates nothing
+	_, err = s.CreateCalDAVSubtask(ctx, &database.CalDAVObject{UserID: ann.ID, UID: "jkl@client", Name: "jkl.ics"}, theirs.ID, "sneaky", false)
+	wantErr(t, "CreateCalDAVSubtask on another user's task", err, database.ErrNotFound)
+	if got := getTask(t, s, bob.ID, theirs.ID); len(got.Subtasks) != 0 {
+		t.Fatalf("CreateCalDAVSubtask on another user's task added %+v", got.Subtasks)
+	}
+
+	objects := calDAVObjects(t, s, ann.ID)
+	wantStrings(t, "CalDAV objects", objects, []string{
+		fmt.Sprintf("abc.ics abc@client task %d", task.ID),
+		fmt.Sprintf("def.ics def@client subtask %d", draft.ID),
+		fmt.Sprintf("ghi.ics ghi@client subtask %d", sent.ID),
+	})
+	if objects := calDAVObjects(t, s, bob.ID); len(objects) != 0 {
+		t.Fatalf("another user's CalDAV objects = %v", objects)
+	}
+
+	// Objects go with the tasks and subtasks they name when those are purged,
+	// but not when they are only trashed
+	if err := s.DeleteSubtask(ctx, ann.ID, draft.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	if objects := calDAVObjects(t, s, ann.ID); len(objects) != 3 {
+		t.Fatalf("CalDAV objects after DeleteSubtask = %v", objects)
+	}
+	if err := s.PurgeSubtask(ctx, ann.ID, draft.ID); err != nil {
+		t.Fatalf("PurgeSubtask: %v", err)
+	}
+	wantStrings(t, "CalDAV objects after PurgeSubtask", calDAVObjects(t, s, ann.ID), []string{
+		fmt.Sprintf("abc.ics abc@client task %d", task.ID),
+		fmt.Sprintf("ghi.ics ghi@client subtask %d", sent.ID),
+	})
+	if err := s.DeleteTask(ctx, ann.ID, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := s.PurgeTask(ctx, ann.ID, task.ID); err != nil {
+		t.Fatalf("PurgeTask: %v", err)
+	}
+	if objects := calDAVObjects(t, s, ann.ID); len(objects) != 0 {
+		t.Fatalf("CalDAV objects after PurgeTask = %v", objects)
+	}
+}
+
+// calDAVObjects returns a user's CalDAV objects, each summarized as its name,
+// UID and the task or subtask it names.
+func calDAVObjects(t *testing.T, s database.Store, userID int64) []string {
+	t.Helper()
+	objects, err := s.GetCalDAVObjects(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetCalDAVObjects: %v", err)
+	}
+	var summaries []string
+	for _, obj := range objects {
+		switch {
+		case obj.TaskID != nil && obj.SubtaskID == nil:
+			summaries = append(summaries, fmt.Sprintf("%s %s task %d", obj.Name, obj.UID, *obj.TaskID))
+		case obj.SubtaskID != nil && obj.TaskID == nil:
+			summaries = append(summaries, fmt.Sprintf("%s %s subtask %d", obj.Name, obj.UID, *obj.SubtaskID))
+		default:
+			t.Fatalf("CalDAV object %+v doesn't name exactly one task or subtask", obj)
+		}
+		if obj.UserID != userID {
+			t.Fatalf("GetCalDAVObjects of user %d returned %+v", userID, obj)
+		}
+	}
+	return summaries
+}
+
+func testAppPasswords(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	phone, err := s.CreateAppPassword(ctx, ann.ID, "phone-secret", "Phone")
+	if err != nil || phone.ID == 0 || phone.UserID != ann.ID || phone.Name != "Phone" {
+		t.Fatalf("CreateAppPassword = %+v, %v", phone, err)
+	}
+	if phone.PasswordHash == "" || phone.PasswordHash == "phone-secret" {
+		t.Fatalf("CreateAppPassword stored the password as %q", phone.PasswordHash)
+	}
+	laptop, err := s.CreateAppPassword(ctx, ann.ID, "laptop-secret", "Laptop")
+	if err != nil {
+		t.Fatalf("CreateAppPassword: %v", err)
+	}
+	theirs, err := s.CreateAppPassword(ctx, bob.ID, "bob-secret", "Bob's")
+	if err != nil {
+		t.Fatalf("CreateAppPassword: %v", err)
+	}
+
+	passwords, err := s.GetAppPasswords(ctx, ann.ID)
+	if err != nil || len(passwords) != 2 || passwords[0].ID != phone.ID || passwords[1].ID != laptop.ID {
+		t.Fatalf("GetAppPasswords = %+v, %v", passwords, err)
+	}
+
+	// A password only checks out for the user it belongs to
+	if got, err := s.CheckAppPassword(ctx, ann.ID, "laptop-secret"); err != nil || got.ID != laptop.ID {
+		t.Fatalf("CheckAppPassword = %+v, %v", got, err)
+	}
+	_, err = s.CheckAppPassword(ctx, ann.ID, "bob-secret")
+	wantErr(t, "CheckAppPassword of another user's password", err, database.ErrNotFound)
+	_, err = s.CheckAppPassword(ctx, ann.ID, phone.PasswordHash)
+	wantErr(t, "CheckAppPassword by the password's hash", err, database.ErrNotFound)
+
+	wantErr(t, "DeleteAppPassword of another user's password", s.DeleteAppPassword(ctx, ann.ID, theirs.ID), database.ErrNotFound)
+	if err := s.DeleteAppPassword(ctx, ann.ID, phone.ID); err != nil {
+		t.Fatalf("DeleteAppPassword: %v", err)
+	}
+	_, err = s.CheckAppPassword(ctx, ann.ID, "phone-secret")
+	wantErr(t, "CheckAppPassword of a revoked password", err, database.ErrNotFound)
+	wantErr(t, "DeleteAppPassword of a revoked password", s.DeleteAppPassword(ctx, ann.ID, phone.ID), database.ErrNotFound)
+	if got, err := s.CheckAppPassword(ctx, bob.ID, "bob-secret"); err != nil || got.ID != theirs.ID {
+		t.Fatalf("another user's password after revoking = %+v, %v", got, err)
+	}
+}
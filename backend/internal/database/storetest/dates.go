@@ -0,0 +1,99 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testDates(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	if ann.Timezone != "UTC" {
+		t.Fatalf("new user has time zone %q, want UTC", ann.Timezone)
+	}
+	if err := s.UpdateUserTimezone(ctx, ann.ID, "America/New_York"); err != nil {
+		t.Fatalf("UpdateUserTimezone: %v", err)
+	}
+	if user, err := s.GetUserByID(ctx, ann.ID); err != nil || user.Timezone != "America/New_York" {
+		t.Fatalf("GetUserByID after UpdateUserTimezone = %+v, %v", user, err)
+	}
+
+	timed := createTask(t, s, ann.ID, &database.Task{
+		Text:    "timed",
+		DueAt:   date(t, "2024-03-10T15:00:00Z"),
+		StartAt: date(t, "2024-03-09"),
+	})
+	if !timed.DueAt.Equal(date(t, "2024-03-10T15:00:00Z")) || !timed.StartAt.Equal(date(t, "2024-03-09")) {
+		t.Fatalf("CreateTask returned dates %v and %v", timed.DueAt, timed.StartAt)
+	}
+	if got := getTask(t, s, ann.ID, timed.ID); !got.DueAt.Equal(timed.DueAt) || !got.StartAt.Equal(timed.StartAt) {
+		t.Fatalf("GetTask returned dates %v and %v", got.DueAt, got.StartAt)
+	}
+	createTask(t, s, ann.ID, &database.Task{Text: "all day", DueAt: date(t, "2024-03-10")})
+	createTask(t, s, ann.ID, &database.Task{Text: "next day", DueAt: date(t, "2024-03-11")})
+	createTask(t, s, ann.ID, &database.Task{Text: "undated"})
+	createTask(t, s, ann.ID, &database.Task{Text: "done", DueAt: date(t, "2024-03-01"), Completed: true})
+
+	// An all-day date covers its whole day in the user's time zone
+	est := time.FixedZone("EST", -5*60*60)
+	wantStrings(t, "tasks due in a range", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{
+		DueAfter:  instant(t, "2024-03-10T00:00:00Z"),
+		DueBefore: instant(t, "2024-03-10T12:00:00Z"),
+		Location:  est,
+	})), []string{"all day"})
+	wantStrings(t, "tasks due in a range in EST", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{
+		DueAfter:  instant(t, "2024-03-11T00:00:00Z"),
+		DueBefore: instant(t, "2024-03-11T04:00:00Z"),
+		Location:  est,
+	})), []string{"all day"})
+	wantStrings(t, "tasks due in a range in UTC", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{
+		DueAfter:  instant(t, "2024-03-11T00:00:00Z"),
+		DueBefore: instant(t, "2024-03-11T04:00:00Z"),
+	})), []string{"next day"})
+	wantStrings(t, "tasks due in a range with a timed date", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{
+		DueAfter:  instant(t, "2024-03-10T15:00:00Z"),
+		DueBefore: instant(t, "2024-03-10T15:00:01Z"),
+	})), []string{"timed", "all day"})
+
+	// Overdue skips completed tasks and waits for the end of an all-day date
+	wantStrings(t, "overdue tasks", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{
+		Overdue:  true,
+		Now:      *instant(t, "2024-03-11T04:00:00Z"),
+		Location: est,
+	})), []string{"timed"})
+	wantStrings(t, "overdue tasks a day later", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{
+		Overdue:  true,
+		Now:      *instant(t, "2024-03-11T06:00:00Z"),
+		Location: est,
+	})), []string{"timed", "all day"})
+
+	updated, err := s.UpdateTask(ctx, ann.ID, timed.ID, map[string]interface{}{"dueAt": "2024-04-01", "startAt": nil}, 0)
+	if err != nil || !updated.DueAt.Equal(date(t, "2024-04-01")) || updated.StartAt != nil {
+		t.Fatalf("UpdateTask of dates = %+v, %v", updated, err)
+	}
+	_, err = s.UpdateTask(ctx, ann.ID, timed.ID, map[string]interface{}{"dueAt": "next week"}, 0)
+	if err == nil {
+		t.Fatal("UpdateTask with an invalid date succeeded")
+	}
+}
+
+// date parses a task date in its API form.
+func date(t *testing.T, s string) *database.TaskDate {
+	t.Helper()
+	d, err := database.ParseTaskDate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// instant parses an RFC 3339 timestamp.
+func instant(t *testing.T, s string) *time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ts
+}
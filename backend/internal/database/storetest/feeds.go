@@ -0,0 +1,67 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testFeeds(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+
+	all, err := s.CreateFeedToken(ctx, ann.ID, "secret-all", "Everything", nil)
+	if err != nil || all.ID == 0 || all.UserID != ann.ID || all.Name != "Everything" || all.ListID != nil {
+		t.Fatalf("CreateFeedToken = %+v, %v", all, err)
+	}
+	if all.TokenHash == "" || all.TokenHash == "secret-all" {
+		t.Fatalf("CreateFeedToken stored the token as %q", all.TokenHash)
+	}
+	listed, err := s.CreateFeedToken(ctx, ann.ID, "secret-work", "", &work.ID)
+	if err != nil || listed.ListID == nil || *listed.ListID != work.ID {
+		t.Fatalf("CreateFeedToken for a list = %+v, %v", listed, err)
+	}
+	theirs, err := s.CreateFeedToken(ctx, bob.ID, "secret-bob", "", nil)
+	if err != nil {
+		t.Fatalf("CreateFeedToken: %v", err)
+	}
+
+	// Feeds are per user, oldest first, and found by the token itself
+	feeds, err := s.GetFeedTokens(ctx, ann.ID)
+	if err != nil || len(feeds) != 2 || feeds[0].ID != all.ID || feeds[1].ID != listed.ID {
+		t.Fatalf("GetFeedTokens = %+v, %v", feeds, err)
+	}
+	if got, err := s.GetFeedTokenByToken(ctx, "secret-work"); err != nil || got.ID != listed.ID || got.UserID != ann.ID {
+		t.Fatalf("GetFeedTokenByToken = %+v, %v", got, err)
+	}
+	_, err = s.GetFeedTokenByToken(ctx, listed.TokenHash)
+	wantErr(t, "GetFeedTokenByToken by the token's hash", err, database.ErrNotFound)
+
+	// Revoking a feed, or purging its list, stops its token working
+	wantErr(t, "DeleteFeedToken of another user's feed", s.DeleteFeedToken(ctx, ann.ID, theirs.ID), database.ErrNotFound)
+	if err := s.DeleteFeedToken(ctx, ann.ID, all.ID); err != nil {
+		t.Fatalf("DeleteFeedToken: %v", err)
+	}
+	_, err = s.GetFeedTokenByToken(ctx, "secret-all")
+	wantErr(t, "GetFeedTokenByToken of a revoked feed", err, database.ErrNotFound)
+	wantErr(t, "DeleteFeedToken of a revoked feed", s.DeleteFeedToken(ctx, ann.ID, all.ID), database.ErrNotFound)
+
+	if err := s.DeleteList(ctx, ann.ID, work.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	if _, err := s.GetFeedTokenByToken(ctx, "secret-work"); err != nil {
+		t.Fatalf("GetFeedTokenByToken of a trashed list's feed: %v", err)
+	}
+	if err := s.PurgeList(ctx, ann.ID, work.ID); err != nil {
+		t.Fatalf("PurgeList: %v", err)
+	}
+	_, err = s.GetFeedTokenByToken(ctx, "secret-work")
+	wantErr(t, "GetFeedTokenByToken of a purged list's feed", err, database.ErrNotFound)
+	if feeds, err := s.GetFeedTokens(ctx, ann.ID); err != nil || len(feeds) != 0 {
+		t.Fatalf("GetFeedTokens after revoking = %+v, %v", feeds, err)
+	}
+	if got, err := s.GetFeedTokenByToken(ctx, "secret-bob"); err != nil || got.ID != theirs.ID {
+		t.Fatalf("another user's feed after revoking = %+v, %v", got, err)
+	}
+}
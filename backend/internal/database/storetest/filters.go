@@ -0,0 +1,86 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testFilters(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+	home := createList(t, s, ann.ID, "Home")
+	createTask(t, s, ann.ID, &database.Task{Text: "report", ListID: &work.ID, Important: true, Tags: []string{"q3", "office"}, DueAt: date(t, "2024-03-10")})
+	createTask(t, s, ann.ID, &database.Task{Text: "call", ListID: &work.ID, Tags: []string{"office"}})
+	createTask(t, s, ann.ID, &database.Task{Text: "dishes", ListID: &home.ID, Completed: true, DueAt: date(t, "2024-03-08")})
+	createTask(t, s, ann.ID, &database.Task{Text: "bills", Important: true, Tags: []string{"q3"}, DueAt: date(t, "2024-03-09")})
+	createTask(t, s, ann.ID, &database.Task{Text: "attic"})
+	createTask(t, s, bob.ID, &database.Task{Text: "bob's", Tags: []string{"office"}})
+
+	yes, no := true, false
+	later := time.Now().Add(time.Hour)
+	for _, tt := range []struct {
+		name   string
+		filter database.TaskFilter
+		want   []string
+	}{
+		{"no filter", database.TaskFilter{}, []string{"report", "call", "dishes", "bills", "attic"}},
+		{"a list", database.TaskFilter{ListID: &work.ID}, []string{"report", "call"}},
+		{"no list", database.TaskFilter{NoList: true}, []string{"bills", "attic"}},
+		{"completed", database.TaskFilter{Completed: &yes}, []string{"dishes"}},
+		{"incomplete and important", database.TaskFilter{Completed: &no, Important: &yes}, []string{"report", "bills"}},
+		{"any tag", database.TaskFilter{Tags: []string{"q3", "office"}}, []string{"report", "call", "bills"}},
+		{"all tags", database.TaskFilter{Tags: []string{"q3", "office"}, AllTags: true}, []string{"report"}},
+		{"all of a repeated tag", database.TaskFilter{Tags: []string{"q3", "q3"}, AllTags: true}, []string{"report", "bills"}},
+		{"created later", database.TaskFilter{CreatedAfter: &later}, nil},
+		{"created before later", database.TaskFilter{CreatedBefore: &later, ListID: &home.ID}, []string{"dishes"}},
+		{"updated later", database.TaskFilter{UpdatedAfter: &later}, nil},
+
+		{"by text", database.TaskFilter{Sort: database.TaskSortText}, []string{"attic", "bills", "call", "dishes", "report"}},
+		{"by text descending", database.TaskFilter{Sort: database.TaskSortText, Desc: true}, []string{"report", "dishes", "call", "bills", "attic"}},
+		{"by due date", database.TaskFilter{Sort: database.TaskSortDue}, []string{"dishes", "bills", "report", "call", "attic"}},
+		{"by due date descending", database.TaskFilter{Sort: database.TaskSortDue, Desc: true}, []string{"report", "bills", "dishes", "attic", "call"}},
+		{"by creation descending", database.TaskFilter{Sort: database.TaskSortCreated, Desc: true}, []string{"attic", "bills", "dishes", "call", "report"}},
+		{"limited", database.TaskFilter{Sort: database.TaskSortText, Limit: 2}, []string{"attic", "bills"}},
+	} {
+		wantStrings(t, "tasks filtered by "+tt.name, taskTexts(getTasks(t, s, ann.ID, tt.filter)), tt.want)
+	}
+
+	// Paging with After picks up where the last page ended, in either direction
+	for _, desc := range []bool{false, true} {
+		filter := database.TaskFilter{Sort: database.TaskSortDue, Desc: desc, Limit: 2}
+		var paged []*database.Task
+		for {
+			page := getTasks(t, s, ann.ID, filter)
+			paged = append(paged, page...)
+			if len(page) < filter.Limit {
+				break
+			}
+			filter.After = page[len(page)-1]
+		}
+		all := getTasks(t, s, ann.ID, database.TaskFilter{Sort: database.TaskSortDue, Desc: desc})
+		wantStrings(t, "tasks paged by due date", taskTexts(paged), taskTexts(all))
+	}
+
+	// Lists page by title
+	createList(t, s, ann.ID, "Errands")
+	createList(t, s, bob.ID, "Bob's")
+	var titles []string
+	filter := database.ListFilter{Limit: 2}
+	for {
+		lists, err := s.GetLists(ctx, ann.ID, filter)
+		if err != nil {
+			t.Fatalf("GetLists: %v", err)
+		}
+		for _, list := range lists {
+			titles = append(titles, list.Title)
+		}
+		if len(lists) < filter.Limit {
+			break
+		}
+		filter.After = lists[len(lists)-1]
+	}
+	wantStrings(t, "lists paged by title", titles, []string{"Errands", "Home", "Work"})
+}
@@ -0,0 +1,93 @@
+package storetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testHistory(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	task := createTask(t, s, ann.ID, &database.Task{Text: "report"})
+
+	_, err := s.UpdateTask(ctx, ann.ID, task.ID, map[string]interface{}{"text": "final report", "important": true, "isExpanded": true}, 0)
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	step := createSubtask(t, s, ann.ID, task.ID, "outline")
+	if _, err := s.UpdateSubtask(ctx, ann.ID, step.ID, map[string]interface{}{"completed": true}, 0); err != nil {
+		t.Fatalf("UpdateSubtask: %v", err)
+	}
+	if err := s.DeleteSubtask(ctx, ann.ID, step.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	if err := s.DeleteTask(ctx, ann.ID, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+
+	// History stays readable in the trash
+	entries := getHistory(t, s, ann.ID, task.ID, 0, 50)
+	want := []string{
+		"delete",
+		"delete subtask",
+		"update subtask completed false→true",
+		`create subtask →"outline"`,
+		"update important false→true",
+		`update text "report"→"final report"`,
+		`create →"report"`,
+	}
+	wantStrings(t, "GetTaskHistory", historyLines(entries, step.ID), want)
+	for _, entry := range entries {
+		if entry.TaskID != task.ID || entry.ActorID != ann.ID || entry.CreatedAt.IsZero() {
+			t.Fatalf("GetTaskHistory returned %+v", entry)
+		}
+	}
+
+	if _, err := s.RestoreTask(ctx, ann.ID, task.ID); err != nil {
+		t.Fatalf("RestoreTask: %v", err)
+	}
+	page := getHistory(t, s, ann.ID, task.ID, 0, 2)
+	wantStrings(t, "first page of GetTaskHistory", historyLines(page, step.ID), []string{"restore", "delete"})
+	page = getHistory(t, s, ann.ID, task.ID, page[1].ID, 2)
+	wantStrings(t, "second page of GetTaskHistory", historyLines(page, step.ID), want[1:3])
+
+	_, err = s.GetTaskHistory(ctx, bob.ID, task.ID, 0, 50)
+	wantErr(t, "GetTaskHistory of another user's task", err, database.ErrNotFound)
+	_, err = s.GetTaskHistory(ctx, ann.ID, 9999, 0, 50)
+	wantErr(t, "GetTaskHistory of an unknown task", err, database.ErrNotFound)
+}
+
+// getHistory loads a page of a task's history.
+func getHistory(t *testing.T, s database.Store, userID, taskID, before int64, limit int) []*database.HistoryEntry {
+	t.Helper()
+	entries, err := s.GetTaskHistory(ctx, userID, taskID, before, limit)
+	if err != nil {
+		t.Fatalf("GetTaskHistory: %v", err)
+	}
+	return entries
+}
+
+// historyLines describes history entries as "action [subtask] [field] [old]→[new]",
+// with subtaskID the only subtask expected.
+func historyLines(entries []*database.HistoryEntry, subtaskID int64) []string {
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		line := entry.Action
+		if entry.SubtaskID != nil {
+			line += " subtask"
+			if *entry.SubtaskID != subtaskID {
+				line += fmt.Sprintf(" %d", *entry.SubtaskID)
+			}
+		}
+		if entry.Field != "" {
+			line += " " + entry.Field
+		}
+		if entry.OldValue != nil || entry.NewValue != nil {
+			line += " " + string(entry.OldValue) + "→" + string(entry.NewValue)
+		}
+		lines[i] = line
+	}
+	return lines
+}
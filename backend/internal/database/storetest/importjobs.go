@@ -0,0 +1,82 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testImportJobs(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	job, err := s.CreateImportJob(ctx, ann.ID, "todoist", 120)
+	if err != nil || job.ID == 0 || job.UserID != ann.ID || job.Source != "todoist" || job.Status != database.ImportJobRunning ||
+		job.Total != 120 || job.Processed != 0 || job.Errors == nil || len(job.Errors) != 0 || job.FinishedAt != nil {
+		t.Fatalf("CreateImportJob = %+v, %v", job, err)
+	}
+	interrupted, err := s.CreateImportJob(ctx, ann.ID, "google-tasks", 5)
+	if err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+	theirs, err := s.CreateImportJob(ctx, bob.ID, "microsoft-todo", 3)
+	if err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+
+	// Progress and errors are saved while the job runs
+	job.Processed = 100
+	job.Errors = append(job.Errors, database.ImportItemError{Item: "row 3", Text: "call", Message: "invalid date"})
+	if err := s.UpdateImportJob(ctx, job); err != nil {
+		t.Fatalf("UpdateImportJob: %v", err)
+	}
+	got, err := s.GetImportJob(ctx, ann.ID, job.ID)
+	if err != nil || got.Processed != 100 || got.Status != database.ImportJobRunning || got.FinishedAt != nil ||
+		len(got.Errors) != 1 || got.Errors[0] != job.Errors[0] {
+		t.Fatalf("GetImportJob after UpdateImportJob = %+v, %v", got, err)
+	}
+
+	// Finishing the job sets when it finished, once
+	job.Processed = 120
+	job.Status = database.ImportJobCompleted
+	job.Report = &database.ImportReport{Tasks: database.ImportCounts{Created: 120}}
+	if err := s.UpdateImportJob(ctx, job); err != nil {
+		t.Fatalf("UpdateImportJob: %v", err)
+	}
+	got, err = s.GetImportJob(ctx, ann.ID, job.ID)
+	if err != nil || got.Status != database.ImportJobCompleted || got.FinishedAt == nil || got.Report == nil ||
+		got.Report.Tasks.Created != 120 || got.Source != "todoist" || got.Total != 120 {
+		t.Fatalf("GetImportJob of a finished job = %+v, %v", got, err)
+	}
+	finishedAt := *got.FinishedAt
+	if err := s.UpdateImportJob(ctx, got); err != nil {
+		t.Fatalf("UpdateImportJob: %v", err)
+	}
+	if again, err := s.GetImportJob(ctx, ann.ID, job.ID); err != nil || !again.FinishedAt.Equal(finishedAt) {
+		t.Fatalf("GetImportJob after saving a finished job again = %+v, %v", again, err)
+	}
+
+	// Jobs are per user, newest first
+	wantErr(t, "UpdateImportJob of another user's job", s.UpdateImportJob(ctx, &database.ImportJob{ID: theirs.ID, UserID: ann.ID}), database.ErrNotFound)
+	_, err = s.GetImportJob(ctx, ann.ID, theirs.ID)
+	wantErr(t, "GetImportJob of another user's job", err, database.ErrNotFound)
+	jobs, err := s.GetImportJobs(ctx, ann.ID)
+	if err != nil || len(jobs) != 2 || jobs[0].ID != interrupted.ID || jobs[1].ID != job.ID {
+		t.Fatalf("GetImportJobs = %+v, %v", jobs, err)
+	}
+
+	// A restart fails every job still running
+	n, err := s.FailInterruptedImportJobs(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("FailInterruptedImportJobs = %d, %v, want 2", n, err)
+	}
+	for _, job := range []*database.ImportJob{interrupted, theirs} {
+		got, err := s.GetImportJob(ctx, job.UserID, job.ID)
+		if err != nil || got.Status != database.ImportJobFailed || got.Error != database.ImportJobInterrupted || got.FinishedAt == nil {
+			t.Fatalf("interrupted import job = %+v, %v", got, err)
+		}
+	}
+	if got, err := s.GetImportJob(ctx, ann.ID, job.ID); err != nil || got.Status != database.ImportJobCompleted {
+		t.Fatalf("finished import job after FailInterruptedImportJobs = %+v, %v", got, err)
+	}
+}
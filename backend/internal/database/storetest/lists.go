@@ -0,0 +1,56 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testLists(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	work := createList(t, s, ann.ID, "Work")
+	home := createList(t, s, ann.ID, "Home")
+	createList(t, s, bob.ID, "Bob's")
+	if work.ID == 0 || work.UserID != ann.ID || work.Title != "Work" || work.Version != 1 {
+		t.Fatalf("CreateList returned %+v", work)
+	}
+
+	lists, err := s.GetLists(ctx, ann.ID, database.ListFilter{})
+	if err != nil {
+		t.Fatalf("GetLists: %v", err)
+	}
+	if len(lists) != 2 || lists[0].ID != home.ID || lists[1].ID != work.ID {
+		t.Fatalf("GetLists = %+v, want Home then Work", lists)
+	}
+
+	_, err = s.GetList(ctx, bob.ID, work.ID)
+	wantErr(t, "GetList of another user's list", err, database.ErrNotFound)
+	_, err = s.UpdateList(ctx, bob.ID, work.ID, "Mine", 0)
+	wantErr(t, "UpdateList of another user's list", err, database.ErrNotFound)
+	wantErr(t, "DeleteList of another user's list", s.DeleteList(ctx, bob.ID, work.ID), database.ErrNotFound)
+
+	updated, err := s.UpdateList(ctx, ann.ID, work.ID, "Office", 0)
+	if err != nil || updated.Title != "Office" || updated.Version != 2 {
+		t.Fatalf("UpdateList = %+v, %v", updated, err)
+	}
+
+	// Deleting a list takes its tasks with it
+	inList := createTask(t, s, ann.ID, &database.Task{Text: "report", ListID: &work.ID})
+	outside := createTask(t, s, ann.ID, &database.Task{Text: "laundry"})
+	if err := s.DeleteList(ctx, ann.ID, work.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	_, err = s.GetList(ctx, ann.ID, work.ID)
+	wantErr(t, "GetList of a deleted list", err, database.ErrNotFound)
+	_, err = s.GetTask(ctx, ann.ID, inList.ID)
+	wantErr(t, "GetTask of a task in a deleted list", err, database.ErrNotFound)
+	getTask(t, s, ann.ID, outside.ID)
+	wantErr(t, "DeleteList of a deleted list", s.DeleteList(ctx, ann.ID, work.ID), database.ErrNotFound)
+
+	lists, _ = s.GetLists(ctx, ann.ID, database.ListFilter{})
+	if len(lists) != 1 || lists[0].ID != home.ID {
+		t.Fatalf("GetLists after DeleteList = %+v, want only Home", lists)
+	}
+}
@@ -0,0 +1,244 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testOperations(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+	report := createTask(t, s, ann.ID, &database.Task{Text: "report", ListID: &work.ID})
+	call := createTask(t, s, ann.ID, &database.Task{Text: "call", Tags: []string{"q3"}})
+	bills := createTask(t, s, ann.ID, &database.Task{Text: "bills"})
+
+	step := func(undo bool, want string) *database.Operation {
+		t.Helper()
+		step, what := s.RedoOperation, "RedoOperation"
+		if undo {
+			step, what = s.UndoOperation, "UndoOperation"
+		}
+		op, err := step(ctx, ann.ID)
+		if err != nil || op.Action != want || op.Undone != undo {
+			t.Fatalf("%s = %+v, %v, want %s", what, op, err, want)
+		}
+		return op
+	}
+	update := func(taskID int64, updates map[string]interface{}) {
+		t.Helper()
+		task := getTask(t, s, ann.ID, taskID)
+		if _, err := s.UpdateTask(ctx, ann.ID, taskID, updates, task.Version); err != nil {
+			t.Fatalf("UpdateTask(%d): %v", taskID, err)
+		}
+	}
+	wantTask := func(what string, taskID int64, text string, important bool) {
+		t.Helper()
+		if got := getTask(t, s, ann.ID, taskID); got.Text != text || got.Important != important {
+			t.Fatalf("task %s = %q important %t, want %q important %t", what, got.Text, got.Important, text, important)
+		}
+	}
+	ordered := func() []string {
+		t.Helper()
+		return taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{Sort: database.TaskSortOrder}))
+	}
+
+	_, err := s.UndoOperation(ctx, bob.ID)
+	wantErr(t, "UndoOperation of an empty log", err, database.ErrNotFound)
+	_, err = s.RedoOperation(ctx, bob.ID)
+	wantErr(t, "RedoOperation of an empty log", err, database.ErrNotFound)
+
+	// Undoing a task's creation moves it to the trash, and redoing it brings it
+	// back
+	op := step(true, database.OperationTaskCreate)
+	if len(op.Changes) != 1 || op.Changes[0].ID != bills.ID || op.Changes[0].Action != database.HistoryCreate {
+		t.Fatalf("create operation changes = %+v", op.Changes)
+	}
+	if trash := getTrash(t, s, ann.ID); len(trash.Tasks) != 1 || trash.Tasks[0].ID != bills.ID {
+		t.Fatalf("trash after undoing a create = %+v", trash.Tasks)
+	}
+	step(false, database.OperationTaskCreate)
+	wantTask("after redoing its create", bills.ID, "bills", false)
+
+	// The store logs an update itself, and undoing it puts back the fields it
+	// changed
+	update(report.ID, map[string]interface{}{"text": "Q3 report", "important": true})
+	op = step(true, database.OperationTaskUpdate)
+	if len(op.Changes) != 1 || op.Changes[0].ID != report.ID || op.Changes[0].Action != database.HistoryUpdate {
+		t.Fatalf("update operation changes = %+v", op.Changes)
+	}
+	wantTask("after undoing an update", report.ID, "report", false)
+	_, err = s.RedoOperation(ctx, bob.ID)
+	wantErr(t, "RedoOperation of another user's log", err, database.ErrNotFound)
+	step(false, database.OperationTaskUpdate)
+	wantTask("after redoing an update", report.ID, "Q3 report", true)
+	_, err = s.RedoOperation(ctx, ann.ID)
+	wantErr(t, "RedoOperation with nothing undone", err, database.ErrNotFound)
+
+	// Updates to the same task undo and redo one after another, and through a
+	// delete and its undo
+	update(report.ID, map[string]interface{}{"text": "final report"})
+	update(report.ID, map[string]interface{}{"important": false})
+	if err := s.DeleteTask(ctx, ann.ID, report.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	step(true, database.OperationTaskDelete)
+	wantTask("after undoing a delete", report.ID, "final report", false)
+	step(true, database.OperationTaskUpdate)
+	step(true, database.OperationTaskUpdate)
+	step(true, database.OperationTaskUpdate)
+	wantTask("after undoing three updates", report.ID, "report", false)
+	step(false, database.OperationTaskUpdate)
+	step(false, database.OperationTaskUpdate)
+	step(false, database.OperationTaskUpdate)
+	wantTask("after redoing three updates", report.ID, "final report", false)
+	step(false, database.OperationTaskDelete)
+	if trash := getTrash(t, s, ann.ID); len(trash.Tasks) != 1 || trash.Tasks[0].ID != report.ID {
+		t.Fatalf("trash after redoing a delete = %+v", trash.Tasks)
+	}
+	step(true, database.OperationTaskDelete)
+
+	// A reorder puts back the positions the tasks had
+	if err := s.ReorderTasks(ctx, ann.ID, []int64{bills.ID, report.ID, call.ID}); err != nil {
+		t.Fatalf("ReorderTasks: %v", err)
+	}
+	step(true, database.OperationTaskReorder)
+	wantStrings(t, "tasks after undoing a reorder", ordered(), []string{"final report", "call", "bills"})
+	step(false, database.OperationTaskReorder)
+	wantStrings(t, "tasks after redoing a reorder", ordered(), []string{"bills", "final report", "call"})
+
+	// Subtasks: creating one, updating it and deleting it
+	draft := createSubtask(t, s, ann.ID, call.ID, "draft")
+	step(true, database.OperationSubtaskCreate)
+	wantStrings(t, "subtasks after undoing a create", subtaskTexts(getTask(t, s, ann.ID, call.ID).Subtasks), nil)
+	step(false, database.OperationSubtaskCreate)
+	draft = getTask(t, s, ann.ID, call.ID).Subtasks[0]
+	if _, err := s.UpdateSubtask(ctx, ann.ID, draft.ID, map[string]interface{}{"text": "notes"}, draft.Version); err != nil {
+		t.Fatalf("UpdateSubtask: %v", err)
+	}
+	if err := s.DeleteSubtask(ctx, ann.ID, draft.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	step(true, database.OperationSubtaskDelete)
+	step(true, database.OperationSubtaskUpdate)
+	wantStrings(t, "subtasks after undoing an update", subtaskTexts(getTask(t, s, ann.ID, call.ID).Subtasks), []string{"draft"})
+	step(false, database.OperationSubtaskUpdate)
+	step(false, database.OperationSubtaskDelete)
+	wantStrings(t, "subtasks after redoing a delete", subtaskTexts(getTask(t, s, ann.ID, call.ID).Subtasks), nil)
+	step(true, database.OperationSubtaskDelete)
+
+	// Deleting a list takes its tasks with it, and undoing it brings them back
+	if err := s.DeleteList(ctx, ann.ID, work.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	step(true, database.OperationListDelete)
+	if _, err := s.GetList(ctx, ann.ID, work.ID); err != nil {
+		t.Fatalf("GetList after undoing a delete: %v", err)
+	}
+	wantTask("after undoing its list's delete", report.ID, "final report", false)
+
+	// Renaming a list
+	if _, err := s.UpdateList(ctx, ann.ID, work.ID, "Office", 0); err != nil {
+		t.Fatalf("UpdateList: %v", err)
+	}
+	op = step(true, database.OperationListUpdate)
+	if len(op.Changes) != 1 || op.Changes[0].Entity != database.SyncList || op.Changes[0].Before["title"] != "Work" {
+		t.Fatalf("list update operation changes = %+v", op.Changes)
+	}
+	if list, err := s.GetList(ctx, ann.ID, work.ID); err != nil || list.Title != "Work" {
+		t.Fatalf("GetList after undoing a rename = %+v, %v", list, err)
+	}
+	step(false, database.OperationListUpdate)
+	if list, err := s.GetList(ctx, ann.ID, work.ID); err != nil || list.Title != "Office" {
+		t.Fatalf("GetList after redoing a rename = %+v, %v", list, err)
+	}
+
+	// A batch is undone and redone as one
+	batch, err := s.BatchUpdateTasks(ctx, ann.ID, []database.TaskOperation{
+		{TaskID: call.ID, Updates: map[string]interface{}{"important": true}},
+		{TaskID: bills.ID, Delete: true},
+	}, nil)
+	if err != nil {
+		t.Fatalf("BatchUpdateTasks: %v", err)
+	}
+	if len(batch.Changes) != 2 {
+		t.Fatalf("batch changes = %+v", batch.Changes)
+	}
+	step(true, database.OperationTaskBatch)
+	wantTask("after undoing a batch", call.ID, "call", false)
+	wantTask("deleted by an undone batch", bills.ID, "bills", false)
+	step(false, database.OperationTaskBatch)
+	wantTask("after redoing a batch", call.ID, "call", true)
+	if trash := getTrash(t, s, ann.ID); len(trash.Tasks) != 1 || trash.Tasks[0].ID != bills.ID {
+		t.Fatalf("trash after redoing a batch = %+v", trash.Tasks)
+	}
+
+	// A new operation discards the ones undone
+	step(true, database.OperationTaskBatch)
+	update(call.ID, map[string]interface{}{"text": "call the bank"})
+	_, err = s.RedoOperation(ctx, ann.ID)
+	wantErr(t, "RedoOperation after a new operation", err, database.ErrNotFound)
+
+	// Changes the log doesn't record, such as expanding a task, and changes to
+	// fields an operation didn't touch leave it undoable
+	update(call.ID, map[string]interface{}{"completed": true})
+	update(call.ID, map[string]interface{}{"isExpanded": true})
+	renameTag(t, s, ann.ID, "q3", "q4")
+	step(true, database.OperationTaskUpdate)
+	if got := getTask(t, s, ann.ID, call.ID); got.Completed || !got.IsExpanded || got.Text != "call the bank" {
+		t.Fatalf("task after undoing a complete = %+v", got)
+	}
+	step(false, database.OperationTaskUpdate)
+	wantStrings(t, "tags after redoing a complete", getTask(t, s, ann.ID, call.ID).Tags, []string{"q4"})
+
+	// A field the operation changed that has been changed again since, outside the
+	// log, makes undoing it stale and drops it, leaving the change in place
+	update(call.ID, map[string]interface{}{"tags": []interface{}{"q4", "urgent"}})
+	renameTag(t, s, ann.ID, "urgent", "asap")
+	_, err = s.UndoOperation(ctx, ann.ID)
+	wantErr(t, "UndoOperation of an update to a field changed since", err, database.ErrStaleOperation)
+	wantStrings(t, "tags after a stale undo", getTask(t, s, ann.ID, call.ID).Tags, []string{"q4", "asap"})
+	step(true, database.OperationTaskUpdate)
+	if got := getTask(t, s, ann.ID, call.ID); got.Completed {
+		t.Fatalf("task after undoing a complete past a stale operation = %+v", got)
+	}
+
+	// And redoing one
+	update(call.ID, map[string]interface{}{"tags": []interface{}{"asap", "q4", "home"}})
+	step(true, database.OperationTaskUpdate)
+	renameTag(t, s, ann.ID, "asap", "soon")
+	_, err = s.RedoOperation(ctx, ann.ID)
+	wantErr(t, "RedoOperation of an update to a field changed since", err, database.ErrStaleOperation)
+	_, err = s.RedoOperation(ctx, ann.ID)
+	wantErr(t, "RedoOperation after dropping a stale operation", err, database.ErrNotFound)
+	wantStrings(t, "tags after a stale redo", getTask(t, s, ann.ID, call.ID).Tags, []string{"q4", "soon"})
+
+	// A subtask reorder puts back the positions the subtasks had
+	draft = getTask(t, s, ann.ID, call.ID).Subtasks[0]
+	agenda := createSubtask(t, s, ann.ID, call.ID, "agenda")
+	if err := s.ReorderSubtasks(ctx, ann.ID, call.ID, []int64{agenda.ID, draft.ID}); err != nil {
+		t.Fatalf("ReorderSubtasks: %v", err)
+	}
+	step(true, database.OperationSubtaskReorder)
+	wantStrings(t, "subtasks after undoing a reorder", subtaskTexts(getTask(t, s, ann.ID, call.ID).Subtasks), []string{"notes", "agenda"})
+	step(false, database.OperationSubtaskReorder)
+	wantStrings(t, "subtasks after redoing a reorder", subtaskTexts(getTask(t, s, ann.ID, call.ID).Subtasks), []string{"agenda", "notes"})
+	step(true, database.OperationSubtaskReorder)
+	step(true, database.OperationSubtaskCreate)
+}
+
+// renameTag renames one of a user's tags.
+func renameTag(t *testing.T, s database.Store, userID int64, from, to string) {
+	t.Helper()
+	for _, tag := range getTags(t, s, userID) {
+		if tag.Name != from {
+			continue
+		}
+		if _, _, err := s.UpdateTag(ctx, userID, tag.ID, map[string]interface{}{"name": to}); err != nil {
+			t.Fatalf("UpdateTag: %v", err)
+		}
+		return
+	}
+	t.Fatalf("no tag %q", from)
+}
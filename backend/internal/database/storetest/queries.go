@@ -0,0 +1,154 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+	"github.com/todomaster-2010/backend/internal/query"
+)
+
+// testQueries checks that a store filtering by a task query, which SQLite does by
+// compiling the query to SQL, selects exactly the tasks TaskQuery.Matches does.
+func testQueries(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	plan := createList(t, s, ann.ID, "Q3 Plan")
+	home := createList(t, s, ann.ID, "home")
+	createList(t, s, bob.ID, "Work")
+
+	est := time.FixedZone("EST", -5*60*60)
+	now := time.Now()
+	today := now.In(est).Format("2006-01-02")
+	soon := now.Add(2 * time.Hour).UTC().Format(time.RFC3339)
+
+	createTask(t, s, ann.ID, &database.Task{Text: "Quarterly report", ListID: &plan.ID, Important: true, Tags: []string{"work"}, DueAt: date(t, today)})
+	createTask(t, s, ann.ID, &database.Task{Text: "Call the bank", Tags: []string{"home", "Work"}, DueAt: date(t, soon)})
+	createTask(t, s, ann.ID, &database.Task{Text: "Résumé update", ListID: &home.ID, Completed: true, DueAt: date(t, "2024-03-09")})
+	createTask(t, s, ann.ID, &database.Task{Text: "water plants", Recurrence: "FREQ=WEEKLY", DueAt: date(t, "2024-03-10")})
+	createTask(t, s, ann.ID, &database.Task{Text: "plan the Q3 PLAN offsite", Important: true})
+	trip := createTask(t, s, ann.ID, &database.Task{Text: "trip", ListID: &home.ID})
+	createSubtask(t, s, ann.ID, trip.ID, "tickets")
+	gone := createTask(t, s, ann.ID, &database.Task{Text: "only a deleted subtask"})
+	step := createSubtask(t, s, ann.ID, gone.ID, "step")
+	if err := s.DeleteSubtask(ctx, ann.ID, step.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	createTask(t, s, bob.ID, &database.Task{Text: "bob's report", Tags: []string{"work"}, Important: true})
+
+	lists, err := s.GetLists(ctx, ann.ID, database.ListFilter{})
+	if err != nil {
+		t.Fatalf("GetLists: %v", err)
+	}
+	all := getTasks(t, s, ann.ID, database.TaskFilter{})
+
+	matched := 0
+	for _, q := range []string{
+		"report",
+		"REPORT",
+		"résumé",
+		"RÉSUMÉ",
+		`"q3 plan"`,
+		"tag:work",
+		"tag:Work",
+		"-tag:work",
+		"tag:work OR is:important",
+		"(tag:work OR tag:home) -is:completed",
+		`list:"q3 plan"`,
+		"list:HOME",
+		"list:Work",
+		"-list:home",
+		"is:important",
+		"is:completed",
+		"is:overdue",
+		"is:recurring",
+		"has:list",
+		"has:due",
+		"has:tags",
+		"has:subtasks",
+		"-has:subtasks",
+		"due:today",
+		"due:<today",
+		"due:>=2024-03-09",
+		"due:2024-03-10",
+		"due:<+1d",
+		"created:>7d",
+		"created:<7d",
+		"updated:today",
+		"is:important -has:due OR has:subtasks",
+	} {
+		expr, err := query.Parse(q)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", q, err)
+		}
+		tq := &database.TaskQuery{Expr: expr, Lists: lists, Now: now, Location: est}
+
+		var want []*database.Task
+		for _, task := range all {
+			if tq.Matches(task) {
+				want = append(want, task)
+			}
+		}
+		got := getTasks(t, s, ann.ID, database.TaskFilter{Query: tq, Now: now, Location: est})
+		wantStrings(t, "tasks matching "+q, taskTexts(got), taskTexts(want))
+		matched += len(want)
+	}
+	if matched == 0 {
+		t.Fatal("no query matched any task")
+	}
+}
+
+func testSmartLists(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	urgent, err := s.CreateSmartList(ctx, ann.ID, "Urgent", "is:important -is:completed")
+	if err != nil || urgent.ID == 0 || urgent.UserID != ann.ID || urgent.Title != "Urgent" ||
+		urgent.Query != "is:important -is:completed" || urgent.Version != 1 {
+		t.Fatalf("CreateSmartList = %+v, %v", urgent, err)
+	}
+	if _, err := s.CreateSmartList(ctx, ann.ID, "Errands", "tag:errand"); err != nil {
+		t.Fatalf("CreateSmartList: %v", err)
+	}
+	if _, err := s.CreateSmartList(ctx, bob.ID, "Bob's", "tag:bob"); err != nil {
+		t.Fatalf("CreateSmartList: %v", err)
+	}
+	cursor, err := s.GetSyncCursor(ctx)
+	if err != nil {
+		t.Fatalf("GetSyncCursor: %v", err)
+	}
+
+	// Smart lists are per user and ordered by title
+	lists, err := s.GetSmartLists(ctx, ann.ID)
+	if err != nil || len(lists) != 2 || lists[0].Title != "Errands" || lists[1].Title != "Urgent" {
+		t.Fatalf("GetSmartLists = %+v, %v", lists, err)
+	}
+	if got, err := s.GetSmartList(ctx, ann.ID, urgent.ID); err != nil || got.Query != urgent.Query {
+		t.Fatalf("GetSmartList = %+v, %v", got, err)
+	}
+	_, err = s.GetSmartList(ctx, bob.ID, urgent.ID)
+	wantErr(t, "GetSmartList of another user's smart list", err, database.ErrNotFound)
+
+	updated, err := s.UpdateSmartList(ctx, ann.ID, urgent.ID, "Now", "is:overdue", 1)
+	if err != nil || updated.Title != "Now" || updated.Query != "is:overdue" || updated.Version != 2 {
+		t.Fatalf("UpdateSmartList = %+v, %v", updated, err)
+	}
+	_, err = s.UpdateSmartList(ctx, ann.ID, urgent.ID, "Later", "is:overdue", 1)
+	wantErr(t, "UpdateSmartList of an old version", err, database.ErrVersionConflict)
+	_, err = s.UpdateSmartList(ctx, bob.ID, urgent.ID, "Mine", "tag:bob", 0)
+	wantErr(t, "UpdateSmartList of another user's smart list", err, database.ErrNotFound)
+
+	changes := getChanges(t, s, ann.ID, cursor)
+	if len(changes.SmartLists) != 1 || changes.SmartLists[0].Title != "Now" {
+		t.Fatalf("smart lists changed after UpdateSmartList = %+v", changes.SmartLists)
+	}
+
+	wantErr(t, "DeleteSmartList of another user's smart list", s.DeleteSmartList(ctx, bob.ID, urgent.ID), database.ErrNotFound)
+	if err := s.DeleteSmartList(ctx, ann.ID, urgent.ID); err != nil {
+		t.Fatalf("DeleteSmartList: %v", err)
+	}
+	wantErr(t, "DeleteSmartList of a deleted smart list", s.DeleteSmartList(ctx, ann.ID, urgent.ID), database.ErrNotFound)
+	_, err = s.GetSmartList(ctx, ann.ID, urgent.ID)
+	wantErr(t, "GetSmartList of a deleted smart list", err, database.ErrNotFound)
+	wantIDs(t, "smart lists deleted", getChanges(t, s, ann.ID, cursor).Deleted.SmartLists, urgent.ID)
+}
@@ -0,0 +1,102 @@
+package storetest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testRecurrence(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	work := createList(t, s, ann.ID, "Work")
+	weekly := createTask(t, s, ann.ID, &database.Task{
+		Text:       "timesheet",
+		ListID:     &work.ID,
+		Important:  true,
+		Tags:       []string{"admin", "weekly"},
+		DueAt:      date(t, "2024-03-08"),
+		Recurrence: "FREQ=WEEKLY;COUNT=3",
+		RepeatFrom: database.RepeatFromDue,
+	})
+	createSubtask(t, s, ann.ID, weekly.ID, "hours")
+	skipped := createSubtask(t, s, ann.ID, weekly.ID, "expenses")
+	createSubtask(t, s, ann.ID, weekly.ID, "submit")
+	if err := s.DeleteSubtask(ctx, ann.ID, skipped.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	weekly = getTask(t, s, ann.ID, weekly.ID)
+
+	scheduled := &database.Occurrence{DueAt: date(t, "2024-03-15"), Recurrence: "FREQ=WEEKLY;COUNT=2"}
+	calls := 0
+	next := func(task *database.Task) (*database.Occurrence, error) {
+		calls++
+		if !task.Completed || task.Recurrence == "" || task.DueAt == nil {
+			t.Fatalf("NextOccurrence called with %+v", task)
+		}
+		return scheduled, nil
+	}
+
+	// A failure to schedule leaves everything as it was
+	_, _, err := s.CompleteRecurringTask(ctx, ann.ID, weekly.ID, map[string]interface{}{"completed": true}, 0,
+		func(*database.Task) (*database.Occurrence, error) { return nil, errors.New("no more") })
+	if err == nil {
+		t.Fatal("CompleteRecurringTask succeeded when scheduling failed")
+	}
+	if got := getTask(t, s, ann.ID, weekly.ID); got.Completed || got.Version != weekly.Version || got.Recurrence == "" {
+		t.Fatalf("task after a failed CompleteRecurringTask = %+v", got)
+	}
+	if tasks := getTasks(t, s, ann.ID, database.TaskFilter{}); len(tasks) != 1 {
+		t.Fatalf("a failed CompleteRecurringTask left %d tasks", len(tasks))
+	}
+
+	_, _, err = s.CompleteRecurringTask(ctx, ann.ID, weekly.ID, map[string]interface{}{"completed": true}, weekly.Version+1, next)
+	wantErr(t, "CompleteRecurringTask of an old version", err, database.ErrVersionConflict)
+	_, _, err = s.CompleteRecurringTask(ctx, ann.ID, 9999, map[string]interface{}{"completed": true}, 0, next)
+	wantErr(t, "CompleteRecurringTask of an unknown task", err, database.ErrNotFound)
+
+	task, spawned, err := s.CompleteRecurringTask(ctx, ann.ID, weekly.ID, map[string]interface{}{"completed": true}, weekly.Version, next)
+	if err != nil {
+		t.Fatalf("CompleteRecurringTask: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("NextOccurrence called %d times, want 1", calls)
+	}
+	if !task.Completed || task.Recurrence != "" || task.Version <= weekly.Version {
+		t.Fatalf("CompleteRecurringTask returned the completed task %+v", task)
+	}
+	if spawned == nil || spawned.ID == weekly.ID || spawned.Text != "timesheet" || spawned.Completed || !spawned.Important ||
+		spawned.ListID == nil || *spawned.ListID != work.ID || !spawned.DueAt.Equal(scheduled.DueAt) || spawned.StartAt != nil ||
+		spawned.Recurrence != "FREQ=WEEKLY;COUNT=2" || spawned.RepeatFrom != database.RepeatFromDue {
+		t.Fatalf("CompleteRecurringTask spawned %+v", spawned)
+	}
+	wantStrings(t, "tags of the next occurrence", spawned.Tags, []string{"admin", "weekly"})
+	wantStrings(t, "subtasks of the next occurrence", subtaskTexts(spawned.Subtasks), []string{"hours", "submit"})
+	wantStrings(t, "tasks after CompleteRecurringTask", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{})),
+		[]string{"timesheet", "timesheet"})
+
+	// The rule moved on, so completing the old task again spawns nothing
+	if _, err := s.UpdateTask(ctx, ann.ID, weekly.ID, map[string]interface{}{"completed": false}, 0); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	_, spawned2, err := s.CompleteRecurringTask(ctx, ann.ID, weekly.ID, map[string]interface{}{"completed": true}, 0, next)
+	if err != nil || spawned2 != nil || calls != 1 {
+		t.Fatalf("completing a finished occurrence again = %+v, %v after %d calls", spawned2, err, calls)
+	}
+
+	// Other updates don't spawn
+	task, spawned2, err = s.CompleteRecurringTask(ctx, ann.ID, spawned.ID, map[string]interface{}{"text": "hours"}, 0, next)
+	if err != nil || spawned2 != nil || calls != 1 || task.Recurrence == "" {
+		t.Fatalf("CompleteRecurringTask without completing = %+v, %+v, %v", task, spawned2, err)
+	}
+
+	// The end of a series clears the rule without spawning
+	last, spawned2, err := s.CompleteRecurringTask(ctx, ann.ID, spawned.ID, map[string]interface{}{"completed": true}, 0,
+		func(*database.Task) (*database.Occurrence, error) { return nil, nil })
+	if err != nil || spawned2 != nil || !last.Completed || last.Recurrence != "" {
+		t.Fatalf("CompleteRecurringTask at the end of a series = %+v, %+v, %v", last, spawned2, err)
+	}
+	if tasks := getTasks(t, s, ann.ID, database.TaskFilter{}); len(tasks) != 2 {
+		t.Fatalf("%d tasks after the series ended, want 2", len(tasks))
+	}
+}
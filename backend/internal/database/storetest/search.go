@@ -0,0 +1,85 @@
+package storetest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testSearch(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+
+	quarterly := createTask(t, s, ann.ID, &database.Task{Text: "Quarterly report", Tags: []string{"finance"}})
+	accountant := createTask(t, s, ann.ID, &database.Task{Text: "Call accountant", ListID: &work.ID})
+	send := createSubtask(t, s, ann.ID, accountant.ID, "send report")
+	createTask(t, s, ann.ID, &database.Task{Text: "Plan", Tags: []string{"reports"}, Completed: true})
+	deleted := createTask(t, s, ann.ID, &database.Task{Text: "old report"})
+	if err := s.DeleteTask(ctx, ann.ID, deleted.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	createTask(t, s, bob.ID, &database.Task{Text: "bob's report"})
+
+	// Task text outranks subtasks, which outrank tags
+	results := search(t, s, ann.ID, database.SearchQuery{Text: "rep"})
+	wantStrings(t, "SearchTasks", resultTexts(results), []string{"Quarterly report", "Call accountant", "Plan"})
+	if !strings.Contains(results[0].Snippet, database.SnippetOpen) || !strings.Contains(results[0].Snippet, database.SnippetClose) {
+		t.Fatalf("snippet %q has no highlight", results[0].Snippet)
+	}
+	if results[0].Rank > results[1].Rank || results[1].Rank > results[2].Rank {
+		t.Fatalf("ranks %v, %v, %v are not best first", results[0].Rank, results[1].Rank, results[2].Rank)
+	}
+
+	wantStrings(t, "SearchTasks for every term", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "quart REP"})),
+		[]string{"Quarterly report"})
+	wantStrings(t, "SearchTasks with query syntax", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: `"report" OR*`})),
+		[]string{})
+	wantStrings(t, "SearchTasks with quotes", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: `"accountant"`})),
+		[]string{"Call accountant"})
+	if results := search(t, s, ann.ID, database.SearchQuery{Text: " ?! "}); len(results) != 0 {
+		t.Fatalf("SearchTasks without terms = %d results", len(results))
+	}
+
+	done := true
+	wantStrings(t, "SearchTasks in a list", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "report", ListID: &work.ID})),
+		[]string{"Call accountant"})
+	wantStrings(t, "SearchTasks of completed tasks", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "report", Completed: &done})),
+		[]string{"Plan"})
+	wantStrings(t, "SearchTasks by tag", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "report", Tag: "finance"})),
+		[]string{"Quarterly report"})
+	wantStrings(t, "SearchTasks with a limit", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "report", Limit: 1})),
+		[]string{"Quarterly report"})
+
+	// The index follows changes
+	if _, err := s.UpdateTask(ctx, ann.ID, quarterly.ID, map[string]interface{}{"text": "Annual summary"}, 0); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if err := s.DeleteSubtask(ctx, ann.ID, send.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	wantStrings(t, "SearchTasks after changes", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "quarterly"})), []string{})
+	wantStrings(t, "SearchTasks after changes", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "send"})), []string{})
+	wantStrings(t, "SearchTasks after changes", resultTexts(search(t, s, ann.ID, database.SearchQuery{Text: "summary finance"})),
+		[]string{"Annual summary"})
+}
+
+// search runs a search for a user.
+func search(t *testing.T, s database.Store, userID int64, q database.SearchQuery) []*database.SearchResult {
+	t.Helper()
+	results, err := s.SearchTasks(ctx, userID, q)
+	if err != nil {
+		t.Fatalf("SearchTasks(%q): %v", q.Text, err)
+	}
+	return results
+}
+
+// resultTexts returns the texts of the tasks found by a search, in order.
+func resultTexts(results []*database.SearchResult) []string {
+	texts := make([]string, len(results))
+	for i, result := range results {
+		texts[i] = result.Task.Text
+	}
+	return texts
+}
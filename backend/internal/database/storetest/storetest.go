@@ -0,0 +1,152 @@
+// Package storetest is a conformance suite for database.Store implementations.
+// The SQLite and in-memory stores both run it from their tests, so the API
+// behaves the same on either.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// Run runs the conformance suite against stores made by newStore. Every test gets
+// a new, empty store.
+func Run(t *testing.T, newStore func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s database.Store)
+	}{
+		{"Users", testUsers},
+		{"Sessions", testSessions},
+		{"Lists", testLists},
+		{"Tasks", testTasks},
+		{"Subtasks", testSubtasks},
+		{"Trash", testTrash},
+		{"History", testHistory},
+		{"Search", testSearch},
+		{"Dates", testDates},
+		{"Recurrence", testRecurrence},
+		{"Tags", testTags},
+		{"Versions", testVersions},
+		{"Sync", testSync},
+		{"Batch", testBatch},
+		{"Filters", testFilters},
+		{"Queries", testQueries},
+		{"SmartLists", testSmartLists},
+		{"Archive", testArchive},
+		{"Feeds", testFeeds},
+		{"AppPasswords", testAppPasswords},
+		{"CalDAV", testCalDAV},
+		{"ImportJobs", testImportJobs},
+		{"Operations", testOperations},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStore(t))
+		})
+	}
+}
+
+var ctx = context.Background()
+
+// createUser creates a user with the given email.
+func createUser(t *testing.T, s database.Store, email string) *database.User {
+	t.Helper()
+	user, err := s.CreateUser(ctx, email, "hash", "")
+	if err != nil {
+		t.Fatalf("CreateUser(%q): %v", email, err)
+	}
+	return user
+}
+
+// createList creates a list for a user.
+func createList(t *testing.T, s database.Store, userID int64, title string) *database.List {
+	t.Helper()
+	list, err := s.CreateList(ctx, userID, title)
+	if err != nil {
+		t.Fatalf("CreateList(%q): %v", title, err)
+	}
+	return list
+}
+
+// createTask creates a task for a user from the fields CreateTask reads.
+func createTask(t *testing.T, s database.Store, userID int64, task *database.Task) *database.Task {
+	t.Helper()
+	created, err := s.CreateTask(ctx, userID, task)
+	if err != nil {
+		t.Fatalf("CreateTask(%q): %v", task.Text, err)
+	}
+	return created
+}
+
+// createSubtask creates a subtask of one of a user's tasks.
+func createSubtask(t *testing.T, s database.Store, userID, taskID int64, text string) *database.Subtask {
+	t.Helper()
+	subtask, err := s.CreateSubtask(ctx, userID, taskID, text)
+	if err != nil {
+		t.Fatalf("CreateSubtask(%q): %v", text, err)
+	}
+	return subtask
+}
+
+// getTask loads one of a user's tasks.
+func getTask(t *testing.T, s database.Store, userID, taskID int64) *database.Task {
+	t.Helper()
+	task, err := s.GetTask(ctx, userID, taskID)
+	if err != nil {
+		t.Fatalf("GetTask(%d): %v", taskID, err)
+	}
+	return task
+}
+
+// getTasks loads a user's tasks that pass filter.
+func getTasks(t *testing.T, s database.Store, userID int64, filter database.TaskFilter) []*database.Task {
+	t.Helper()
+	tasks, err := s.GetUserTasks(ctx, userID, filter)
+	if err != nil {
+		t.Fatalf("GetUserTasks: %v", err)
+	}
+	return tasks
+}
+
+// taskTexts returns the texts of tasks, in order.
+func taskTexts(tasks []*database.Task) []string {
+	texts := make([]string, len(tasks))
+	for i, task := range tasks {
+		texts[i] = task.Text
+	}
+	return texts
+}
+
+// subtaskTexts returns the texts of subtasks, in order.
+func subtaskTexts(subtasks []*database.Subtask) []string {
+	texts := make([]string, len(subtasks))
+	for i, subtask := range subtasks {
+		texts[i] = subtask.Text
+	}
+	return texts
+}
+
+// wantErr fails the test unless err is target.
+func wantErr(t *testing.T, what string, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("%s: got error %v, want %v", what, err, target)
+	}
+}
+
+// wantStrings fails the test unless got and want hold the same strings in the
+// same order. A nil slice equals an empty one.
+func wantStrings(t *testing.T, what string, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %q, want %q", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %q, want %q", what, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testSync(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	list := createList(t, s, ann.ID, "Work")
+	report := createTask(t, s, ann.ID, &database.Task{Text: "report", ListID: &list.ID, Tags: []string{"q3"}})
+	call := createTask(t, s, ann.ID, &database.Task{Text: "call"})
+	createSubtask(t, s, ann.ID, report.ID, "outline")
+	theirs := createTask(t, s, bob.ID, &database.Task{Text: "bob's"})
+
+	// Without a cursor the changes are a snapshot
+	snapshot := getChanges(t, s, ann.ID, 0)
+	if !snapshot.Reset || len(snapshot.Lists) != 1 || len(snapshot.Tags) != 1 || snapshot.Cursor == 0 {
+		t.Fatalf("GetChanges without a cursor = %+v", snapshot)
+	}
+	wantStrings(t, "tasks of a snapshot", taskTexts(snapshot.Tasks), []string{"report", "call"})
+	wantStrings(t, "subtasks of a snapshot task", subtaskTexts(snapshot.Tasks[0].Subtasks), []string{"outline"})
+	cursor, err := s.GetSyncCursor(ctx)
+	if err != nil || cursor != snapshot.Cursor {
+		t.Fatalf("GetSyncCursor = %d, %v; want %d", cursor, err, snapshot.Cursor)
+	}
+
+	if changes := getChanges(t, s, ann.ID, cursor); changes.Reset || changes.Cursor != cursor || len(changes.Tasks) != 0 {
+		t.Fatalf("GetChanges without changes = %+v", changes)
+	}
+	if changes := getChanges(t, s, ann.ID, cursor+1000); !changes.Reset {
+		t.Fatalf("GetChanges from a cursor never issued = %+v, want a snapshot", changes)
+	}
+
+	if _, err := s.UpdateTask(ctx, ann.ID, call.ID, map[string]interface{}{"text": "call back"}, 0); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	step := createSubtask(t, s, ann.ID, call.ID, "dial")
+	tag, err := s.CreateTag(ctx, ann.ID, &database.Tag{Name: "phone"})
+	if err != nil {
+		t.Fatalf("CreateTag: %v", err)
+	}
+	if err := s.DeleteList(ctx, ann.ID, list.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	if _, err := s.UpdateTask(ctx, bob.ID, theirs.ID, map[string]interface{}{"text": "mine"}, 0); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+
+	// Only the user's own changes come back, with trashed rows as deletions
+	changes := getChanges(t, s, ann.ID, cursor)
+	if changes.Reset || changes.Cursor <= cursor {
+		t.Fatalf("GetChanges = %+v", changes)
+	}
+	wantStrings(t, "changed tasks", taskTexts(changes.Tasks), []string{"call back"})
+	wantStrings(t, "changed subtasks", subtaskTexts(changes.Subtasks), []string{step.Text})
+	if len(changes.Tags) != 1 || changes.Tags[0].ID != tag.ID || len(changes.Lists) != 0 {
+		t.Fatalf("GetChanges returned tags %+v and lists %+v", changes.Tags, changes.Lists)
+	}
+	wantIDs(t, "deleted lists", changes.Deleted.Lists, list.ID)
+	wantIDs(t, "deleted tasks", changes.Deleted.Tasks, report.ID)
+
+	next := changes.Cursor
+	if changes := getChanges(t, s, ann.ID, next); len(changes.Tasks)+len(changes.Deleted.Tasks)+len(changes.Deleted.Lists) != 0 {
+		t.Fatalf("GetChanges from the new cursor = %+v", changes)
+	}
+
+	// Restoring brings rows back and purging drops them for good
+	if _, _, err := s.RestoreList(ctx, ann.ID, list.ID); err != nil {
+		t.Fatalf("RestoreList: %v", err)
+	}
+	changes = getChanges(t, s, ann.ID, next)
+	if len(changes.Lists) != 1 || len(changes.Deleted.Lists) != 0 {
+		t.Fatalf("GetChanges after RestoreList = %+v", changes)
+	}
+	wantStrings(t, "tasks after RestoreList", taskTexts(changes.Tasks), []string{"report"})
+	if err := s.DeleteTask(ctx, ann.ID, call.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := s.PurgeTask(ctx, ann.ID, call.ID); err != nil {
+		t.Fatalf("PurgeTask: %v", err)
+	}
+	changes = getChanges(t, s, ann.ID, changes.Cursor)
+	wantIDs(t, "tasks deleted after a purge", changes.Deleted.Tasks, call.ID)
+	if len(changes.Tasks) != 0 {
+		t.Fatalf("GetChanges after PurgeTask returned tasks %+v", changes.Tasks)
+	}
+}
+
+// getChanges loads a user's changes after cursor.
+func getChanges(t *testing.T, s database.Store, userID, cursor int64) *database.SyncChanges {
+	t.Helper()
+	changes, err := s.GetChanges(ctx, userID, cursor)
+	if err != nil {
+		t.Fatalf("GetChanges: %v", err)
+	}
+	return changes
+}
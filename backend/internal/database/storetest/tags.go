@@ -0,0 +1,148 @@
+package storetest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testTags(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	chores := createTask(t, s, ann.ID, &database.Task{Text: "chores", Tags: []string{"home", "urgent"}})
+	garden := createTask(t, s, ann.ID, &database.Task{Text: "garden", Tags: []string{"home"}})
+	trashed := createTask(t, s, ann.ID, &database.Task{Text: "attic", Tags: []string{"home"}})
+	if err := s.DeleteTask(ctx, ann.ID, trashed.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	theirs := createTask(t, s, bob.ID, &database.Task{Text: "bob's", Tags: []string{"home"}})
+
+	// Tags are per user, and only live tasks count
+	tags := getTags(t, s, ann.ID)
+	if got := tagSummary(tags); got != "home:2 urgent:1" {
+		t.Fatalf("GetTags = %s, want home:2 urgent:1", got)
+	}
+	home, urgent := tags[0], tags[1]
+	if got := tagSummary(getTags(t, s, bob.ID)); got != "home:1" {
+		t.Fatalf("GetTags of another user = %s, want home:1", got)
+	}
+	_, err := s.GetTag(ctx, bob.ID, home.ID)
+	wantErr(t, "GetTag of another user's tag", err, database.ErrNotFound)
+
+	work, err := s.CreateTag(ctx, ann.ID, &database.Tag{Name: "work", Color: "#ff0000", Description: "Office"})
+	if err != nil || work.Name != "work" || work.Color != "#ff0000" || work.Description != "Office" || work.UserID != ann.ID {
+		t.Fatalf("CreateTag = %+v, %v", work, err)
+	}
+	_, err = s.CreateTag(ctx, ann.ID, &database.Tag{Name: "work"})
+	wantErr(t, "CreateTag with a taken name", err, database.ErrTagExists)
+	if _, err := s.CreateTag(ctx, bob.ID, &database.Tag{Name: "work"}); err != nil {
+		t.Fatalf("CreateTag with another user's name: %v", err)
+	}
+
+	// Renaming changes the tags of every task carrying it
+	_, _, err = s.UpdateTag(ctx, ann.ID, urgent.ID, map[string]interface{}{"name": "home"})
+	wantErr(t, "UpdateTag to a taken name", err, database.ErrTagExists)
+	_, _, err = s.UpdateTag(ctx, bob.ID, urgent.ID, map[string]interface{}{"name": "asap"})
+	wantErr(t, "UpdateTag of another user's tag", err, database.ErrNotFound)
+	renamed, taskIDs, err := s.UpdateTag(ctx, ann.ID, urgent.ID, map[string]interface{}{"name": "asap", "color": "#00ff00"})
+	if err != nil || renamed.Name != "asap" || renamed.Color != "#00ff00" || renamed.TaskCount != 1 {
+		t.Fatalf("UpdateTag = %+v, %v", renamed, err)
+	}
+	wantIDs(t, "tasks of a renamed tag", taskIDs, chores.ID)
+	task := getTask(t, s, ann.ID, chores.ID)
+	wantStrings(t, "tags after UpdateTag", task.Tags, []string{"home", "asap"})
+	if task.Version != chores.Version+1 {
+		t.Fatalf("renaming a tag left the task at version %d, want %d", task.Version, chores.Version+1)
+	}
+	if entries := getHistory(t, s, ann.ID, chores.ID, 0, 1); entries[0].Field != "tags" ||
+		string(entries[0].OldValue) != `["home","urgent"]` || string(entries[0].NewValue) != `["home","asap"]` {
+		t.Fatalf("history of a renamed tag = %+v", entries[0])
+	}
+	if _, taskIDs, err := s.UpdateTag(ctx, ann.ID, urgent.ID, map[string]interface{}{"description": "Soon"}); err != nil || len(taskIDs) != 0 {
+		t.Fatalf("UpdateTag of the description = %v, %v", taskIDs, err)
+	}
+
+	// Merging moves the source's tasks, live or trashed, onto the target
+	_, _, err = s.MergeTags(ctx, ann.ID, home.ID, 9999)
+	wantErr(t, "MergeTags into an unknown tag", err, database.ErrNotFound)
+	merged, taskIDs, err := s.MergeTags(ctx, ann.ID, home.ID, urgent.ID)
+	if err != nil || merged.ID != urgent.ID || merged.TaskCount != 2 {
+		t.Fatalf("MergeTags = %+v, %v", merged, err)
+	}
+	wantIDs(t, "tasks of a merged tag", taskIDs, chores.ID, garden.ID, trashed.ID)
+	wantStrings(t, "tags after MergeTags", getTask(t, s, ann.ID, chores.ID).Tags, []string{"asap"})
+	wantStrings(t, "tags after MergeTags", getTask(t, s, ann.ID, garden.ID).Tags, []string{"asap"})
+	_, err = s.GetTag(ctx, ann.ID, home.ID)
+	wantErr(t, "GetTag of a merged tag", err, database.ErrNotFound)
+	wantStrings(t, "another user's tags after MergeTags", getTask(t, s, bob.ID, theirs.ID).Tags, []string{"home"})
+
+	_, err = s.DeleteTag(ctx, bob.ID, urgent.ID)
+	wantErr(t, "DeleteTag of another user's tag", err, database.ErrNotFound)
+	taskIDs, err = s.DeleteTag(ctx, ann.ID, urgent.ID)
+	if err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	wantIDs(t, "tasks of a deleted tag", taskIDs, chores.ID, garden.ID, trashed.ID)
+	if tags := getTask(t, s, ann.ID, chores.ID).Tags; len(tags) != 0 {
+		t.Fatalf("tags after DeleteTag = %q", tags)
+	}
+	if got := tagSummary(getTags(t, s, ann.ID)); got != "work:0" {
+		t.Fatalf("GetTags after DeleteTag = %s, want work:0", got)
+	}
+
+	// Tagging a task with a new name creates the tag
+	if _, err := s.UpdateTask(ctx, ann.ID, garden.ID, map[string]interface{}{"tags": []interface{}{"outdoor", "work"}}, 0); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if got := tagSummary(getTags(t, s, ann.ID)); got != "outdoor:1 work:1" {
+		t.Fatalf("GetTags after tagging a task = %s, want outdoor:1 work:1", got)
+	}
+
+	// Tags that aren't names fail the update without changing the task
+	before := getTask(t, s, ann.ID, garden.ID)
+	for _, tags := range []interface{}{[]interface{}{"outdoor", 1.0}, "outdoor"} {
+		if _, err := s.UpdateTask(ctx, ann.ID, garden.ID, map[string]interface{}{"text": "weeding", "tags": tags}, 0); err == nil {
+			t.Fatalf("UpdateTask with tags %v succeeded", tags)
+		}
+	}
+	if got := getTask(t, s, ann.ID, garden.ID); got.Text != before.Text || got.Version != before.Version {
+		t.Fatalf("task after a failed update = %+v, want %+v", got, before)
+	}
+
+	// A []string works as well as a decoded JSON list
+	if _, err := s.UpdateTask(ctx, ann.ID, garden.ID, map[string]interface{}{"tags": []string{"work"}}, 0); err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	wantStrings(t, "tags set from a []string", getTask(t, s, ann.ID, garden.ID).Tags, []string{"work"})
+}
+
+// getTags loads a user's tags.
+func getTags(t *testing.T, s database.Store, userID int64) []*database.Tag {
+	t.Helper()
+	tags, err := s.GetTags(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	return tags
+}
+
+// tagSummary describes tags as "name:count ...".
+func tagSummary(tags []*database.Tag) string {
+	summary := ""
+	for i, tag := range tags {
+		if i > 0 {
+			summary += " "
+		}
+		summary += fmt.Sprintf("%s:%d", tag.Name, tag.TaskCount)
+	}
+	return summary
+}
+
+// wantIDs fails the test unless got holds exactly the IDs in want, in order.
+func wantIDs(t *testing.T, what string, got []int64, want ...int64) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("%s: got %v, want %v", what, got, want)
+	}
+}
@@ -0,0 +1,159 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testTasks(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	list := createList(t, s, ann.ID, "Work")
+
+	report := createTask(t, s, ann.ID, &database.Task{Text: "report", ListID: &list.ID, Important: true, Tags: []string{"q3", "writing"}})
+	if report.ID == 0 || report.UserID != ann.ID || report.ListID == nil || *report.ListID != list.ID ||
+		!report.Important || report.Completed || report.Version != 1 {
+		t.Fatalf("CreateTask returned %+v", report)
+	}
+	wantStrings(t, "tags of a new task", report.Tags, []string{"q3", "writing"})
+	done := createTask(t, s, ann.ID, &database.Task{Text: "done", Completed: true})
+	if !done.Completed || done.CompletedAt == nil {
+		t.Fatalf("CreateTask of a completed task returned %+v", done)
+	}
+	createTask(t, s, ann.ID, &database.Task{Text: "call"})
+	theirs := createTask(t, s, bob.ID, &database.Task{Text: "bob's", Tags: []string{"q3"}})
+
+	// New tasks go to the end
+	tasks := getTasks(t, s, ann.ID, database.TaskFilter{})
+	wantStrings(t, "GetUserTasks", taskTexts(tasks), []string{"report", "done", "call"})
+	wantStrings(t, "tags from GetUserTasks", tasks[0].Tags, []string{"q3", "writing"})
+
+	// Other users' tasks are invisible
+	_, err := s.GetTask(ctx, ann.ID, theirs.ID)
+	wantErr(t, "GetTask of another user's task", err, database.ErrNotFound)
+	_, err = s.UpdateTask(ctx, ann.ID, theirs.ID, map[string]interface{}{"text": "mine"}, 0)
+	wantErr(t, "UpdateTask of another user's task", err, database.ErrNotFound)
+	wantErr(t, "DeleteTask of another user's task", s.DeleteTask(ctx, ann.ID, theirs.ID), database.ErrNotFound)
+	wantStrings(t, "another user's tags", getTask(t, s, bob.ID, theirs.ID).Tags, []string{"q3"})
+
+	updated, err := s.UpdateTask(ctx, ann.ID, report.ID, map[string]interface{}{
+		"text":       "final report",
+		"completed":  true,
+		"important":  false,
+		"isExpanded": true,
+		"listId":     nil,
+		"tags":       []interface{}{"writing", "urgent"},
+	}, 0)
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if updated.Text != "final report" || !updated.Completed || updated.CompletedAt == nil || updated.Important ||
+		!updated.IsExpanded || updated.ListID != nil || updated.Version != 2 {
+		t.Fatalf("UpdateTask returned %+v", updated)
+	}
+	wantStrings(t, "tags after UpdateTask", updated.Tags, []string{"writing", "urgent"})
+	if got := getTask(t, s, ann.ID, report.ID); got.Text != "final report" || got.Version != 2 {
+		t.Fatalf("GetTask after UpdateTask = %+v", got)
+	}
+
+	// Moving into a list, by JSON number as the API passes it
+	updated, err = s.UpdateTask(ctx, ann.ID, report.ID, map[string]interface{}{"listId": float64(list.ID), "completed": false}, 0)
+	if err != nil || updated.ListID == nil || *updated.ListID != list.ID || updated.Completed || updated.CompletedAt != nil {
+		t.Fatalf("UpdateTask moving into a list = %+v, %v", updated, err)
+	}
+
+	if err := s.ReorderTasks(ctx, ann.ID, []int64{tasks[2].ID, tasks[0].ID, tasks[1].ID, theirs.ID}); err != nil {
+		t.Fatalf("ReorderTasks: %v", err)
+	}
+	wantStrings(t, "GetUserTasks after ReorderTasks", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{})),
+		[]string{"call", "final report", "done"})
+	if got := getTask(t, s, bob.ID, theirs.ID); got.SortOrder != theirs.SortOrder {
+		t.Fatalf("ReorderTasks moved another user's task to %d", got.SortOrder)
+	}
+
+	if err := s.DeleteTask(ctx, ann.ID, done.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	_, err = s.GetTask(ctx, ann.ID, done.ID)
+	wantErr(t, "GetTask of a deleted task", err, database.ErrNotFound)
+	_, err = s.UpdateTask(ctx, ann.ID, done.ID, map[string]interface{}{"text": "again"}, 0)
+	wantErr(t, "UpdateTask of a deleted task", err, database.ErrNotFound)
+	wantErr(t, "DeleteTask of a deleted task", s.DeleteTask(ctx, ann.ID, done.ID), database.ErrNotFound)
+	wantStrings(t, "GetUserTasks after DeleteTask", taskTexts(getTasks(t, s, ann.ID, database.TaskFilter{})),
+		[]string{"call", "final report"})
+}
+
+func testSubtasks(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	task := createTask(t, s, ann.ID, &database.Task{Text: "trip"})
+	theirs := createTask(t, s, bob.ID, &database.Task{Text: "bob's"})
+
+	tickets := createSubtask(t, s, ann.ID, task.ID, "tickets")
+	if tickets.ID == 0 || tickets.TaskID != task.ID || tickets.Text != "tickets" || tickets.Completed || tickets.Version != 1 {
+		t.Fatalf("CreateSubtask returned %+v", tickets)
+	}
+	hotel := createSubtask(t, s, ann.ID, task.ID, "hotel")
+	createSubtask(t, s, ann.ID, task.ID, "bags")
+
+	_, err := s.CreateSubtask(ctx, ann.ID, theirs.ID, "sneaky")
+	wantErr(t, "CreateSubtask on another user's task", err, database.ErrNotFound)
+	_, err = s.CreateSubtask(ctx, ann.ID, 9999, "orphan")
+	wantErr(t, "CreateSubtask on an unknown task", err, database.ErrNotFound)
+
+	subtasks, err := s.GetSubtasks(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("GetSubtasks: %v", err)
+	}
+	wantStrings(t, "GetSubtasks", subtaskTexts(subtasks), []string{"tickets", "hotel", "bags"})
+	wantStrings(t, "subtasks of GetTask", subtaskTexts(getTask(t, s, ann.ID, task.ID).Subtasks), []string{"tickets", "hotel", "bags"})
+
+	got, err := s.GetSubtask(ctx, ann.ID, hotel.ID)
+	if err != nil || got.Text != "hotel" {
+		t.Fatalf("GetSubtask = %+v, %v", got, err)
+	}
+	_, err = s.GetSubtask(ctx, bob.ID, hotel.ID)
+	wantErr(t, "GetSubtask of another user's subtask", err, database.ErrNotFound)
+
+	updated, err := s.UpdateSubtask(ctx, ann.ID, hotel.ID, map[string]interface{}{"text": "hostel", "completed": true}, 0)
+	if err != nil || updated.Text != "hostel" || !updated.Completed || updated.Version != 2 {
+		t.Fatalf("UpdateSubtask = %+v, %v", updated, err)
+	}
+	if _, err := s.UpdateSubtask(ctx, ann.ID, hotel.ID, map[string]interface{}{"sortOrder": 1.0}, 0); err == nil {
+		t.Fatal("UpdateSubtask without text or completed succeeded")
+	}
+	_, err = s.UpdateSubtask(ctx, bob.ID, hotel.ID, map[string]interface{}{"text": "mine"}, 0)
+	wantErr(t, "UpdateSubtask of another user's subtask", err, database.ErrNotFound)
+
+	wantErr(t, "DeleteSubtask of another user's subtask", s.DeleteSubtask(ctx, bob.ID, tickets.ID), database.ErrNotFound)
+	if err := s.DeleteSubtask(ctx, ann.ID, tickets.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	_, err = s.GetSubtask(ctx, ann.ID, tickets.ID)
+	wantErr(t, "GetSubtask of a deleted subtask", err, database.ErrNotFound)
+	wantErr(t, "DeleteSubtask of a deleted subtask", s.DeleteSubtask(ctx, ann.ID, tickets.ID), database.ErrNotFound)
+	subtasks, _ = s.GetSubtasks(ctx, task.ID)
+	wantStrings(t, "GetSubtasks after DeleteSubtask", subtaskTexts(subtasks), []string{"hostel", "bags"})
+
+	// Reordering skips subtasks of other tasks and deleted ones
+	wantErr(t, "ReorderSubtasks of another user's task", s.ReorderSubtasks(ctx, bob.ID, task.ID, []int64{hotel.ID}), database.ErrNotFound)
+	bags := subtasks[1]
+	if err := s.ReorderSubtasks(ctx, ann.ID, task.ID, []int64{bags.ID, tickets.ID, hotel.ID}); err != nil {
+		t.Fatalf("ReorderSubtasks: %v", err)
+	}
+	subtasks, _ = s.GetSubtasks(ctx, task.ID)
+	wantStrings(t, "GetSubtasks after ReorderSubtasks", subtaskTexts(subtasks), []string{"bags", "hostel"})
+	if subtasks[0].Version != bags.Version+1 || subtasks[1].Version != updated.Version+1 {
+		t.Fatalf("versions after ReorderSubtasks = %d and %d", subtasks[0].Version, subtasks[1].Version)
+	}
+
+	// Subtasks of a deleted task are out of reach
+	if err := s.DeleteTask(ctx, ann.ID, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	_, err = s.GetSubtask(ctx, ann.ID, hotel.ID)
+	wantErr(t, "GetSubtask of a deleted task's subtask", err, database.ErrNotFound)
+	_, err = s.UpdateSubtask(ctx, ann.ID, hotel.ID, map[string]interface{}{"text": "motel"}, 0)
+	wantErr(t, "UpdateSubtask of a deleted task's subtask", err, database.ErrNotFound)
+}
@@ -0,0 +1,157 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testTrash(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	work := createList(t, s, ann.ID, "Work")
+	report := createTask(t, s, ann.ID, &database.Task{Text: "report", ListID: &work.ID})
+	laundry := createTask(t, s, ann.ID, &database.Task{Text: "laundry"})
+	dry := createSubtask(t, s, ann.ID, laundry.ID, "dry")
+	call := createTask(t, s, ann.ID, &database.Task{Text: "call"})
+	email := createTask(t, s, ann.ID, &database.Task{Text: "email"})
+	theirs := createTask(t, s, bob.ID, &database.Task{Text: "bob's"})
+
+	for _, err := range []error{
+		s.DeleteSubtask(ctx, ann.ID, dry.ID),
+		s.DeleteTask(ctx, ann.ID, call.ID),
+		s.DeleteTask(ctx, ann.ID, email.ID),
+		s.DeleteList(ctx, ann.ID, work.ID),
+		s.DeleteTask(ctx, bob.ID, theirs.ID),
+	} {
+		if err != nil {
+			t.Fatalf("deleting: %v", err)
+		}
+	}
+
+	// Tasks deleted with their list only show up as part of the list
+	trash := getTrash(t, s, ann.ID)
+	if len(trash.Lists) != 1 || trash.Lists[0].ID != work.ID {
+		t.Fatalf("GetTrash lists = %+v, want Work", trash.Lists)
+	}
+	wantStrings(t, "GetTrash tasks", taskTexts(trash.Tasks), []string{"email", "call"})
+	wantStrings(t, "GetTrash subtasks", subtaskTexts(trash.Subtasks), []string{"dry"})
+
+	_, err := s.RestoreTask(ctx, bob.ID, call.ID)
+	wantErr(t, "RestoreTask of another user's task", err, database.ErrNotFound)
+	_, err = s.RestoreTask(ctx, ann.ID, laundry.ID)
+	wantErr(t, "RestoreTask of a live task", err, database.ErrNotFound)
+	restored, err := s.RestoreTask(ctx, ann.ID, call.ID)
+	if err != nil || restored.Text != "call" || restored.DeletedAt != nil || restored.Version != 3 {
+		t.Fatalf("RestoreTask = %+v, %v", restored, err)
+	}
+
+	_, err = s.RestoreSubtask(ctx, bob.ID, dry.ID)
+	wantErr(t, "RestoreSubtask of another user's subtask", err, database.ErrNotFound)
+	subtask, err := s.RestoreSubtask(ctx, ann.ID, dry.ID)
+	if err != nil || subtask.Text != "dry" || subtask.Version != 3 {
+		t.Fatalf("RestoreSubtask = %+v, %v", subtask, err)
+	}
+	wantStrings(t, "subtasks after RestoreSubtask", subtaskTexts(getTask(t, s, ann.ID, laundry.ID).Subtasks), []string{"dry"})
+
+	// Restoring a list brings back the tasks deleted with it
+	list, tasks, err := s.RestoreList(ctx, ann.ID, work.ID)
+	if err != nil || list.ID != work.ID || list.DeletedAt != nil || list.Version != 3 {
+		t.Fatalf("RestoreList = %+v, %v", list, err)
+	}
+	wantStrings(t, "tasks of RestoreList", taskTexts(tasks), []string{"report"})
+	if got := getTask(t, s, ann.ID, report.ID); got.ListID == nil || *got.ListID != work.ID {
+		t.Fatalf("task restored with its list has list %v", got.ListID)
+	}
+	_, _, err = s.RestoreList(ctx, ann.ID, work.ID)
+	wantErr(t, "RestoreList of a live list", err, database.ErrNotFound)
+
+	// A task restored while its list is still in the trash loses the list
+	temp := createList(t, s, ann.ID, "Temp")
+	draft := createTask(t, s, ann.ID, &database.Task{Text: "draft", ListID: &temp.ID})
+	kept := createTask(t, s, ann.ID, &database.Task{Text: "kept", ListID: &temp.ID})
+	if err := s.DeleteTask(ctx, ann.ID, draft.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := s.DeleteList(ctx, ann.ID, temp.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	restored, err = s.RestoreTask(ctx, ann.ID, draft.ID)
+	if err != nil || restored.ListID != nil {
+		t.Fatalf("RestoreTask with its list in the trash = %+v, %v", restored, err)
+	}
+	if err := s.DeleteTask(ctx, ann.ID, draft.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+
+	wantErr(t, "PurgeTask of a live task", s.PurgeTask(ctx, ann.ID, call.ID), database.ErrNotFound)
+	wantErr(t, "PurgeTask of another user's task", s.PurgeTask(ctx, bob.ID, email.ID), database.ErrNotFound)
+	if err := s.PurgeTask(ctx, ann.ID, email.ID); err != nil {
+		t.Fatalf("PurgeTask: %v", err)
+	}
+	_, err = s.RestoreTask(ctx, ann.ID, email.ID)
+	wantErr(t, "RestoreTask of a purged task", err, database.ErrNotFound)
+
+	if err := s.DeleteSubtask(ctx, ann.ID, dry.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	wantErr(t, "PurgeSubtask of another user's subtask", s.PurgeSubtask(ctx, bob.ID, dry.ID), database.ErrNotFound)
+	if err := s.PurgeSubtask(ctx, ann.ID, dry.ID); err != nil {
+		t.Fatalf("PurgeSubtask: %v", err)
+	}
+	_, err = s.RestoreSubtask(ctx, ann.ID, dry.ID)
+	wantErr(t, "RestoreSubtask of a purged subtask", err, database.ErrNotFound)
+
+	// Purging a list takes the tasks deleted with it and detaches the rest
+	wantErr(t, "PurgeList of a live list", s.PurgeList(ctx, ann.ID, work.ID), database.ErrNotFound)
+	if err := s.PurgeList(ctx, ann.ID, temp.ID); err != nil {
+		t.Fatalf("PurgeList: %v", err)
+	}
+	_, err = s.RestoreTask(ctx, ann.ID, kept.ID)
+	wantErr(t, "RestoreTask of a task purged with its list", err, database.ErrNotFound)
+	trash = getTrash(t, s, ann.ID)
+	if len(trash.Lists) != 0 || len(trash.Tasks) != 1 || trash.Tasks[0].ID != draft.ID || trash.Tasks[0].ListID != nil {
+		t.Fatalf("GetTrash after PurgeList = %+v", trash)
+	}
+
+	if err := s.DeleteTask(ctx, ann.ID, call.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := s.DeleteList(ctx, ann.ID, work.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	n, err := s.EmptyTrash(ctx, ann.ID)
+	if err != nil || n != 4 {
+		t.Fatalf("EmptyTrash = %d, %v; want 4", n, err)
+	}
+	trash = getTrash(t, s, ann.ID)
+	if len(trash.Lists)+len(trash.Tasks)+len(trash.Subtasks) != 0 {
+		t.Fatalf("GetTrash after EmptyTrash = %+v", trash)
+	}
+	if trash := getTrash(t, s, bob.ID); len(trash.Tasks) != 1 {
+		t.Fatalf("EmptyTrash emptied another user's trash: %+v", trash)
+	}
+
+	n, err = s.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+	if err != nil || n != 0 {
+		t.Fatalf("PurgeTrash of old items = %d, %v; want 0", n, err)
+	}
+	n, err = s.PurgeTrash(ctx, time.Now().Add(time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeTrash = %d, %v; want 1", n, err)
+	}
+	if trash := getTrash(t, s, bob.ID); len(trash.Tasks) != 0 {
+		t.Fatalf("GetTrash after PurgeTrash = %+v", trash)
+	}
+}
+
+// getTrash loads a user's trash.
+func getTrash(t *testing.T, s database.Store, userID int64) *database.Trash {
+	t.Helper()
+	trash, err := s.GetTrash(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetTrash: %v", err)
+	}
+	return trash
+}
@@ -0,0 +1,105 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testUsers(t *testing.T, s database.Store) {
+	user, err := s.CreateUser(ctx, "ann@example.com", "hash1", "Ann")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.ID == 0 || user.Email != "ann@example.com" || user.PasswordHash != "hash1" || user.DisplayName != "Ann" {
+		t.Fatalf("CreateUser returned %+v", user)
+	}
+
+	_, err = s.CreateUser(ctx, "ann@example.com", "hash2", "")
+	wantErr(t, "CreateUser with a taken email", err, database.ErrDuplicateEmail)
+
+	got, err := s.GetUserByEmail(ctx, "ann@example.com")
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("GetUserByEmail = %+v, %v", got, err)
+	}
+	_, err = s.GetUserByEmail(ctx, "nobody@example.com")
+	wantErr(t, "GetUserByEmail of an unknown email", err, database.ErrNotFound)
+
+	updated, err := s.UpdateUser(ctx, user.ID, "Annie")
+	if err != nil || updated.DisplayName != "Annie" {
+		t.Fatalf("UpdateUser = %+v, %v", updated, err)
+	}
+	if err := s.UpdateUserPassword(ctx, user.ID, "hash3"); err != nil {
+		t.Fatalf("UpdateUserPassword: %v", err)
+	}
+	got, err = s.GetUserByID(ctx, user.ID)
+	if err != nil || got.DisplayName != "Annie" || got.PasswordHash != "hash3" {
+		t.Fatalf("GetUserByID after updates = %+v, %v", got, err)
+	}
+
+	// Deleting a user takes their data with them
+	list := createList(t, s, user.ID, "Errands")
+	createTask(t, s, user.ID, &database.Task{Text: "milk", ListID: &list.ID})
+	if _, err := s.CreateSession(ctx, user.ID, "token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	_, err = s.GetUserByID(ctx, user.ID)
+	wantErr(t, "GetUserByID of a deleted user", err, database.ErrNotFound)
+	_, err = s.GetSessionByToken(ctx, "token")
+	wantErr(t, "GetSessionByToken of a deleted user", err, database.ErrNotFound)
+	wantErr(t, "DeleteUser of a deleted user", s.DeleteUser(ctx, user.ID), database.ErrNotFound)
+
+	// The email is free again
+	if _, err := s.CreateUser(ctx, "ann@example.com", "hash", ""); err != nil {
+		t.Fatalf("CreateUser with a freed email: %v", err)
+	}
+}
+
+func testSessions(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	session, err := s.CreateSession(ctx, ann.ID, "ann-1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if session.UserID != ann.ID || session.TokenHash == "" || session.TokenHash == "ann-1" {
+		t.Fatalf("CreateSession returned %+v", session)
+	}
+	s.CreateSession(ctx, ann.ID, "ann-2", time.Now().Add(time.Hour))
+	s.CreateSession(ctx, ann.ID, "ann-expired", time.Now().Add(-time.Hour))
+	s.CreateSession(ctx, bob.ID, "bob-1", time.Now().Add(time.Hour))
+
+	got, err := s.GetSessionByToken(ctx, "ann-1")
+	if err != nil || got.ID != session.ID || got.UserID != ann.ID {
+		t.Fatalf("GetSessionByToken = %+v, %v", got, err)
+	}
+	_, err = s.GetSessionByToken(ctx, "ann-expired")
+	wantErr(t, "GetSessionByToken of an expired session", err, database.ErrNotFound)
+	_, err = s.GetSessionByToken(ctx, "unknown")
+	wantErr(t, "GetSessionByToken of an unknown token", err, database.ErrNotFound)
+
+	if err := s.DeleteSession(ctx, "ann-1"); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	_, err = s.GetSessionByToken(ctx, "ann-1")
+	wantErr(t, "GetSessionByToken of a deleted session", err, database.ErrNotFound)
+
+	n, err := s.CleanupExpiredSessions(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("CleanupExpiredSessions = %d, %v; want 1", n, err)
+	}
+
+	if err := s.DeleteUserSessions(ctx, ann.ID); err != nil {
+		t.Fatalf("DeleteUserSessions: %v", err)
+	}
+	_, err = s.GetSessionByToken(ctx, "ann-2")
+	wantErr(t, "GetSessionByToken after DeleteUserSessions", err, database.ErrNotFound)
+	if _, err := s.GetSessionByToken(ctx, "bob-1"); err != nil {
+		t.Fatalf("DeleteUserSessions removed another user's session: %v", err)
+	}
+}
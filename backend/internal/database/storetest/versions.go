@@ -0,0 +1,67 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+func testVersions(t *testing.T, s database.Store) {
+	ann := createUser(t, s, "ann@example.com")
+	list := createList(t, s, ann.ID, "Work")
+	task := createTask(t, s, ann.ID, &database.Task{Text: "report"})
+	other := createTask(t, s, ann.ID, &database.Task{Text: "call"})
+	subtask := createSubtask(t, s, ann.ID, task.ID, "outline")
+
+	// An update of a given version fails once the item has moved on
+	updated, err := s.UpdateTask(ctx, ann.ID, task.ID, map[string]interface{}{"text": "draft"}, 1)
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("UpdateTask of the current version = %+v, %v", updated, err)
+	}
+	_, err = s.UpdateTask(ctx, ann.ID, task.ID, map[string]interface{}{"text": "final"}, 1)
+	wantErr(t, "UpdateTask of an old version", err, database.ErrVersionConflict)
+	if got := getTask(t, s, ann.ID, task.ID); got.Text != "draft" || got.Version != 2 {
+		t.Fatalf("a conflicting UpdateTask changed the task to %+v", got)
+	}
+
+	sub, err := s.UpdateSubtask(ctx, ann.ID, subtask.ID, map[string]interface{}{"completed": true}, 1)
+	if err != nil || sub.Version != 2 {
+		t.Fatalf("UpdateSubtask of the current version = %+v, %v", sub, err)
+	}
+	_, err = s.UpdateSubtask(ctx, ann.ID, subtask.ID, map[string]interface{}{"text": "notes"}, 1)
+	wantErr(t, "UpdateSubtask of an old version", err, database.ErrVersionConflict)
+
+	renamed, err := s.UpdateList(ctx, ann.ID, list.ID, "Office", 1)
+	if err != nil || renamed.Version != 2 {
+		t.Fatalf("UpdateList of the current version = %+v, %v", renamed, err)
+	}
+	_, err = s.UpdateList(ctx, ann.ID, list.ID, "Desk", 1)
+	wantErr(t, "UpdateList of an old version", err, database.ErrVersionConflict)
+
+	// Every change moves the version on, including reordering, deleting and restoring
+	if err := s.ReorderTasks(ctx, ann.ID, []int64{other.ID, task.ID}); err != nil {
+		t.Fatalf("ReorderTasks: %v", err)
+	}
+	if got := getTask(t, s, ann.ID, task.ID); got.Version != 3 {
+		t.Fatalf("version after ReorderTasks = %d, want 3", got.Version)
+	}
+	if err := s.DeleteTask(ctx, ann.ID, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	restored, err := s.RestoreTask(ctx, ann.ID, task.ID)
+	if err != nil || restored.Version != 5 {
+		t.Fatalf("RestoreTask = %+v, %v; want version 5", restored, err)
+	}
+	if err := s.DeleteSubtask(ctx, ann.ID, subtask.ID); err != nil {
+		t.Fatalf("DeleteSubtask: %v", err)
+	}
+	if sub, err := s.RestoreSubtask(ctx, ann.ID, subtask.ID); err != nil || sub.Version != 4 {
+		t.Fatalf("RestoreSubtask = %+v, %v; want version 4", sub, err)
+	}
+	if err := s.DeleteList(ctx, ann.ID, list.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	if restored, _, err := s.RestoreList(ctx, ann.ID, list.ID); err != nil || restored.Version != 4 {
+		t.Fatalf("RestoreList = %+v, %v; want version 4", restored, err)
+	}
+}
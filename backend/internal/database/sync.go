@@ -0,0 +1,287 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Kinds of rows whose changes are recorded for sync.
+const (
+	SyncList      = "list"
+	SyncTask      = "task"
+	SyncSubtask   = "subtask"
+	SyncTag       = "tag"
+	SyncSmartList = "smart_list"
+)
+
+// SyncChanges is what changed in a user's data since a sync cursor: the current
+// copy of every live list, task, subtask, tag and smart list that changed, and the IDs of the
+// ones that were deleted, trashed or purged. Cursor is the position to sync from
+// next time. When Reset is set the changes are a full snapshot of the user's data
+// that replaces whatever the client holds.
+type SyncChanges struct {
+	Cursor     int64
+	Reset      bool
+	Lists      []*List
+	Tasks      []*Task
+	Subtasks   []*Subtask
+	Tags       []*Tag
+	SmartLists []*SmartList
+	Deleted    SyncDeleted
+}
+
+// SyncDeleted holds the IDs of rows that are gone, by kind.
+type SyncDeleted struct {
+	Lists      []int64 `json:"lists"`
+	Tasks      []int64 `json:"tasks"`
+	Subtasks   []int64 `json:"subtasks"`
+	Tags       []int64 `json:"tags"`
+	SmartLists []int64 `json:"smartLists"`
+}
+
+// NewSyncChanges returns empty changes at a cursor, with non-nil slices.
+func NewSyncChanges(cursor int64) *SyncChanges {
+	return &SyncChanges{
+		Cursor:     cursor,
+		Lists:      []*List{},
+		Tasks:      []*Task{},
+		Subtasks:   []*Subtask{},
+		Tags:       []*Tag{},
+		SmartLists: []*SmartList{},
+		Deleted: SyncDeleted{
+			Lists:      []int64{},
+			Tasks:      []int64{},
+			Subtasks:   []int64{},
+			Tags:       []int64{},
+			SmartLists: []int64{},
+		},
+	}
+}
+
+// Add records a deleted row's ID under its kind.
+func (d *SyncDeleted) Add(entity string, id int64) {
+	switch entity {
+	case SyncList:
+		d.Lists = append(d.Lists, id)
+	case SyncTask:
+		d.Tasks = append(d.Tasks, id)
+	case SyncSubtask:
+		d.Subtasks = append(d.Subtasks, id)
+	case SyncTag:
+		d.Tags = append(d.Tags, id)
+	case SyncSmartList:
+		d.SmartLists = append(d.SmartLists, id)
+	}
+}
+
+// GetSyncCursor returns the latest sync cursor, the one GetChanges would return
+// now for any user.
+func (db *DB) GetSyncCursor(ctx context.Context) (int64, error) {
+	// sqlite_sequence holds the highest seq ever issued, even if that row has
+	// since been replaced or deleted with its user.
+	var latest int64
+	err := db.QueryRowContext(ctx,
+		`SELECT seq FROM sqlite_sequence WHERE name = 'sync_changes'`,
+	).Scan(&latest)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to get sync cursor: %w", err)
+	}
+	return latest, nil
+}
+
+// GetChanges returns the user's rows that changed after cursor. A zero cursor, or
+// one the server never issued, returns a full snapshot with Reset set; live tasks
+// carry their subtasks, so the snapshot's Subtasks is empty.
+func (db *DB) GetChanges(ctx context.Context, userID, cursor int64) (*SyncChanges, error) {
+	latest, err := db.GetSyncCursor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := NewSyncChanges(latest)
+	if cursor <= 0 || cursor > latest {
+		return db.syncSnapshot(ctx, userID, changes)
+	}
+
+	// Every query below joins the change rows in (cursor, latest]; rows changed
+	// after latest was read are left for the next sync.
+	const changed = ` JOIN sync_changes c ON c.entity = ? AND c.entity_id = `
+	const window = ` c.user_id = ? AND c.seq > ? AND c.seq <= ?`
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+listColumns+` FROM lists l`+changed+`l.id
+		 WHERE`+window+` AND l.deleted_at IS NULL
+		 ORDER BY l.title ASC, l.id ASC`,
+		SyncList, userID, cursor, latest,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed lists: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan list: %w", err)
+		}
+		changes.Lists = append(changes.Lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lists: %w", err)
+	}
+	rows.Close()
+
+	tasks, err := db.queryTasks(ctx, userID,
+		`SELECT `+taskColumns+` FROM tasks t`+changed+`t.id
+		 WHERE`+window+` AND t.deleted_at IS NULL
+		 ORDER BY t.sort_order ASC, t.id ASC`,
+		SyncTask, userID, cursor, latest,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tasks != nil {
+		changes.Tasks = tasks
+	}
+
+	rows, err = db.QueryContext(ctx,
+		`SELECT `+subtaskColumns+` FROM subtasks s
+		 JOIN tasks t ON t.id = s.task_id`+changed+`s.id
+		 WHERE`+window+` AND s.deleted_at IS NULL AND t.deleted_at IS NULL
+		 ORDER BY s.task_id, s.sort_order ASC, s.id ASC`,
+		SyncSubtask, userID, cursor, latest,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed subtasks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subtask, err := scanSubtask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subtask: %w", err)
+		}
+		changes.Subtasks = append(changes.Subtasks, subtask)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subtasks: %w", err)
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx,
+		`SELECT `+tagColumns+` FROM tags tg`+changed+`tg.id
+		 WHERE`+window+`
+		 ORDER BY tg.name ASC, tg.id ASC`,
+		SyncTag, userID, cursor, latest,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		changes.Tags = append(changes.Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+	rows.Close()
+
+	rows, err = db.QueryContext(ctx,
+		`SELECT `+smartListColumns+` FROM smart_lists sl`+changed+`sl.id
+		 WHERE`+window+`
+		 ORDER BY sl.title ASC, sl.id ASC`,
+		SyncSmartList, userID, cursor, latest,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changed smart lists: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		list, err := scanSmartList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan smart list: %w", err)
+		}
+		changes.SmartLists = append(changes.SmartLists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating smart lists: %w", err)
+	}
+	rows.Close()
+
+	// Whatever changed but is no longer live is reported as deleted
+	rows, err = db.QueryContext(ctx,
+		`SELECT c.entity, c.entity_id FROM sync_changes c
+		 WHERE`+window+` AND NOT CASE c.entity
+			WHEN 'list' THEN EXISTS (SELECT 1 FROM lists WHERE id = c.entity_id AND deleted_at IS NULL)
+			WHEN 'task' THEN EXISTS (SELECT 1 FROM tasks WHERE id = c.entity_id AND deleted_at IS NULL)
+			WHEN 'subtask' THEN EXISTS (SELECT 1 FROM subtasks s JOIN tasks t ON t.id = s.task_id
+				WHERE s.id = c.entity_id AND s.deleted_at IS NULL AND t.deleted_at IS NULL)
+			WHEN 'tag' THEN EXISTS (SELECT 1 FROM tags WHERE id = c.entity_id)
+			WHEN 'smart_list' THEN EXISTS (SELECT 1 FROM smart_lists WHERE id = c.entity_id)
+		 END
+		 ORDER BY c.entity_id ASC`,
+		userID, cursor, latest,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entity string
+		var id int64
+		if err := rows.Scan(&entity, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted row: %w", err)
+		}
+		changes.Deleted.Add(entity, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted rows: %w", err)
+	}
+
+	return changes, nil
+}
+
+// syncSnapshot fills changes with all of the user's live lists, tasks, tags and
+// smart lists.
+func (db *DB) syncSnapshot(ctx context.Context, userID int64, changes *SyncChanges) (*SyncChanges, error) {
+	changes.Reset = true
+
+	lists, err := db.GetLists(ctx, userID, ListFilter{})
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := db.GetUserTasks(ctx, userID, TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	tags, err := db.GetTags(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	smartLists, err := db.GetSmartLists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if lists != nil {
+		changes.Lists = lists
+	}
+	if tasks != nil {
+		changes.Tasks = tasks
+	}
+	if tags != nil {
+		changes.Tags = tags
+	}
+	if smartLists != nil {
+		changes.SmartLists = smartLists
+	}
+	return changes, nil
+}
@@ -0,0 +1,408 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrTagExists is returned when a tag would take a name the user already uses.
+var ErrTagExists = errors.New("tag already exists")
+
+// Tag is one of a user's tags. TaskCount is the number of live tasks that carry it.
+type Tag struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"userId"`
+	Name        string    `json:"name"`
+	Color       string    `json:"color,omitempty"`
+	Description string    `json:"description,omitempty"`
+	TaskCount   int       `json:"taskCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// TaskTagsFromUpdate reads a tags value from an UpdateTask updates map: a list
+// of tag names, decoded from JSON or built as a []string. nil clears the tags.
+func TaskTagsFromUpdate(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		tags := make([]string, len(v))
+		for i, tag := range v {
+			name, ok := tag.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid tag %v", tag)
+			}
+			tags[i] = name
+		}
+		return tags, nil
+	default:
+		return nil, fmt.Errorf("invalid tags %v", v)
+	}
+}
+
+// tagColumns is the column list read by scanTag, qualified with the "tg" alias.
+const tagColumns = `tg.id, tg.user_id, tg.name, tg.color, tg.description, tg.created_at,
+	(SELECT COUNT(*) FROM task_tags tt JOIN tasks t ON t.id = tt.task_id
+	 WHERE tt.tag_id = tg.id AND t.deleted_at IS NULL)`
+
+// scanTag scans a row selected with tagColumns.
+func scanTag(row rowScanner) (*Tag, error) {
+	tag := &Tag{}
+	var color, description sql.NullString
+	err := row.Scan(&tag.ID, &tag.UserID, &tag.Name, &color, &description, &tag.CreatedAt, &tag.TaskCount)
+	if err != nil {
+		return nil, err
+	}
+	tag.Color = color.String
+	tag.Description = description.String
+	return tag, nil
+}
+
+// GetTags returns the user's tags ordered by name.
+func (db *DB) GetTags(ctx context.Context, userID int64) ([]*Tag, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+tagColumns+` FROM tags tg WHERE tg.user_id = ? ORDER BY tg.name ASC, tg.id ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetTag retrieves a single tag by ID for a specific user.
+func (db *DB) GetTag(ctx context.Context, userID, tagID int64) (*Tag, error) {
+	tag, err := scanTag(db.QueryRowContext(ctx,
+		`SELECT `+tagColumns+` FROM tags tg WHERE tg.id = ? AND tg.user_id = ?`,
+		tagID, userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return tag, nil
+}
+
+// CreateTag creates a tag from the name, color and description set on tag.
+func (db *DB) CreateTag(ctx context.Context, userID int64, tag *Tag) (*Tag, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkTagNameTx(ctx, tx, userID, 0, tag.Name); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO tags (user_id, name, color, description) VALUES (?, ?, ?, ?)`,
+		userID, tag.Name, nullString(tag.Color), nullString(tag.Description),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	tagID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return db.GetTag(ctx, userID, tagID)
+}
+
+// UpdateTag changes a tag's name, color or description. Renaming a tag changes
+// the tags of every task carrying it; their IDs are returned so callers can
+// notify clients, and each task's history records the change.
+func (db *DB) UpdateTag(ctx context.Context, userID, tagID int64, updates map[string]interface{}) (*Tag, []int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRowContext(ctx,
+		`SELECT name FROM tags WHERE id = ? AND user_id = ?`,
+		tagID, userID,
+	).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+
+	for _, key := range []string{"color", "description"} {
+		if v, ok := updates[key]; ok {
+			s, _ := v.(string)
+			if _, err := tx.ExecContext(ctx, `UPDATE tags SET `+key+` = ? WHERE id = ?`, nullString(s), tagID); err != nil {
+				return nil, nil, fmt.Errorf("failed to update tag: %w", err)
+			}
+		}
+	}
+
+	var taskIDs []int64
+	if name, ok := updates["name"].(string); ok && name != oldName {
+		if err := checkTagNameTx(ctx, tx, userID, tagID, name); err != nil {
+			return nil, nil, err
+		}
+		if taskIDs, err = tagTaskIDsTx(ctx, tx, tagID); err != nil {
+			return nil, nil, err
+		}
+		err = changeTaskTagsTx(ctx, tx, userID, taskIDs, func() error {
+			_, err := tx.ExecContext(ctx, `UPDATE tags SET name = ? WHERE id = ?`, name, tagID)
+			return err
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to rename tag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	tag, err := db.GetTag(ctx, userID, tagID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tag, taskIDs, nil
+}
+
+// MergeTags moves every task tagged with source onto target and deletes source.
+// It returns the merged tag and the IDs of the tasks whose tags changed.
+func (db *DB) MergeTags(ctx context.Context, userID, sourceID, targetID int64) (*Tag, []int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM tags WHERE id IN (?, ?) AND user_id = ?`,
+		sourceID, targetID, userID,
+	).Scan(&count)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	if count != 2 {
+		return nil, nil, ErrNotFound
+	}
+
+	taskIDs, err := tagTaskIDsTx(ctx, tx, sourceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = changeTaskTagsTx(ctx, tx, userID, taskIDs, func() error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO task_tags (task_id, tag_id)
+			 SELECT task_id, ? FROM task_tags WHERE tag_id = ?
+			 ON CONFLICT DO NOTHING`,
+			targetID, sourceID,
+		)
+		if err != nil {
+			return err
+		}
+		return deleteTagTx(ctx, tx, sourceID)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge tags: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	tag, err := db.GetTag(ctx, userID, targetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tag, taskIDs, nil
+}
+
+// DeleteTag removes a tag from all of the user's tasks and deletes it. It returns
+// the IDs of the tasks that carried the tag.
+func (db *DB) DeleteTag(ctx context.Context, userID, tagID int64) ([]int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx,
+		`SELECT 1 FROM tags WHERE id = ? AND user_id = ?`,
+		tagID, userID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+
+	taskIDs, err := tagTaskIDsTx(ctx, tx, tagID)
+	if err != nil {
+		return nil, err
+	}
+	err = changeTaskTagsTx(ctx, tx, userID, taskIDs, func() error {
+		return deleteTagTx(ctx, tx, tagID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return taskIDs, nil
+}
+
+// checkTagNameTx returns ErrTagExists if another of the user's tags is called name.
+func checkTagNameTx(ctx context.Context, tx *sql.Tx, userID, tagID int64, name string) error {
+	var exists int
+	err := tx.QueryRowContext(ctx,
+		`SELECT 1 FROM tags WHERE user_id = ? AND name = ? AND id != ?`,
+		userID, name, tagID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check tag name: %w", err)
+	}
+	return ErrTagExists
+}
+
+// deleteTagTx unlinks a tag from its tasks before deleting it, so the search
+// triggers on task_tags see each removal.
+func deleteTagTx(ctx context.Context, tx *sql.Tx, tagID int64) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_tags WHERE tag_id = ?`, tagID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, tagID)
+	return err
+}
+
+// tagTaskIDsTx returns the IDs of the tasks, live or trashed, that carry a tag.
+func tagTaskIDsTx(ctx context.Context, tx *sql.Tx, tagID int64) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT task_id FROM task_tags WHERE tag_id = ? ORDER BY task_id`,
+		tagID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tagged tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var taskIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan task id: %w", err)
+		}
+		taskIDs = append(taskIDs, id)
+	}
+
+	return taskIDs, rows.Err()
+}
+
+// changeTaskTagsTx runs change, which alters the tags of the given tasks, then
+// records each task's old and new tags in its history and bumps its version.
+func changeTaskTagsTx(ctx context.Context, tx *sql.Tx, userID int64, taskIDs []int64, change func() error) error {
+	if len(taskIDs) == 0 {
+		return change()
+	}
+
+	before, err := taskTagNamesTx(ctx, tx, taskIDs)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	after, err := taskTagNamesTx(ctx, tx, taskIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range taskIDs {
+		if sameTags(before[id], after[id]) {
+			continue
+		}
+		change := fieldChange("tags", nonNilTags(before[id]), nonNilTags(after[id]))
+		if err := recordChanges(ctx, tx, userID, id, nil, []FieldChange{change}); err != nil {
+			return err
+		}
+	}
+
+	placeholders, args := int64Placeholders(taskIDs)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE tasks SET updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id IN (`+placeholders+`)`,
+		args...,
+	)
+	return err
+}
+
+// taskTagNamesTx returns the tag names of each of the given tasks, ordered by tag ID.
+func taskTagNamesTx(ctx context.Context, tx *sql.Tx, taskIDs []int64) (map[int64][]string, error) {
+	placeholders, args := int64Placeholders(taskIDs)
+	rows, err := tx.QueryContext(ctx,
+		`SELECT tt.task_id, tg.name FROM task_tags tt
+		 JOIN tags tg ON tg.id = tt.tag_id
+		 WHERE tt.task_id IN (`+placeholders+`)
+		 ORDER BY tt.task_id, tg.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int64][]string, len(taskIDs))
+	for rows.Next() {
+		var taskID int64
+		var name string
+		if err := rows.Scan(&taskID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		names[taskID] = append(names[taskID], name)
+	}
+
+	return names, rows.Err()
+}
+
+// int64Placeholders returns a "?, ?, ..." list for ids and the matching arguments.
+func int64Placeholders(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "?" + strings.Repeat(", ?", len(ids)-1), args
+}
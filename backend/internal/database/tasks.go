@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -17,76 +18,178 @@ type Task struct {
 	Important  bool       `json:"important"`
 	IsExpanded bool       `json:"isExpanded"`
 	SortOrder  int        `json:"sortOrder"`
+	Version    int64      `json:"version"`
+	DueAt      *TaskDate  `json:"dueAt,omitempty"`
+	StartAt    *TaskDate  `json:"startAt,omitempty"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	RepeatFrom string     `json:"repeatFrom,omitempty"`
 	Tags       []string   `json:"tags,omitempty"`
 	Subtasks   []*Subtask `json:"subtasks,omitempty"`
 	CreatedAt  time.Time  `json:"createdAt"`
 	UpdatedAt  time.Time  `json:"updatedAt"`
+	// CompletedAt is when the task was last marked complete; it is cleared when the
+	// task is reopened.
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
 }
 
+// Values for Task.RepeatFrom. A recurring task's next occurrence is computed from
+// its due date by default, or from the day it was completed.
+const (
+	RepeatFromDue        = "due"
+	RepeatFromCompletion = "completion"
+)
+
+// Occurrence schedules the next occurrence of a recurring task: its dates and
+// the rule it carries on with.
+type Occurrence struct {
+	DueAt      *TaskDate
+	StartAt    *TaskDate
+	Recurrence string
+}
+
+// NextOccurrence schedules the occurrence that follows a recurring task that was
+// just completed. It returns nil when the series has ended.
+type NextOccurrence func(task *Task) (*Occurrence, error)
+
 // Subtask represents a subtask within a task.
 type Subtask struct {
-	ID        int64     `json:"id"`
-	TaskID    int64     `json:"taskId"`
-	Text      string    `json:"text"`
-	Completed bool      `json:"completed"`
-	SortOrder int       `json:"sortOrder"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        int64      `json:"id"`
+	TaskID    int64      `json:"taskId"`
+	Text      string     `json:"text"`
+	Completed bool       `json:"completed"`
+	SortOrder int        `json:"sortOrder"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"createdAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
-// CreateTask creates a new task for a user.
-func (db *DB) CreateTask(ctx context.Context, userID int64, listID *int64, text string, tags []string, important, completed bool) (*Task, error) {
+// CreateTask creates a new task for a user from the list, text, flags, dates,
+// recurrence and tags set on task, and records it in the user's undo log. Other
+// fields are ignored.
+func (db *DB) CreateTask(ctx context.Context, userID int64, task *Task) (*Task, error) {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	taskID, err := createTaskTx(ctx, tx, userID, task)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationTaskCreate, []OperationChange{
+		{Entity: SyncTask, ID: taskID, Action: HistoryCreate},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return db.GetTask(ctx, userID, taskID)
+}
+
+// createTaskTx inserts a task at the end of the user's tasks within a transaction
+// and returns its ID.
+func createTaskTx(ctx context.Context, tx *sql.Tx, userID int64, task *Task) (int64, error) {
 	// Get the next sort order
 	var maxOrder sql.NullInt64
-	err = tx.QueryRowContext(ctx,
+	err := tx.QueryRowContext(ctx,
 		`SELECT MAX(sort_order) FROM tasks WHERE user_id = ?`,
 		userID,
 	).Scan(&maxOrder)
 	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("failed to get max sort order: %w", err)
+		return 0, fmt.Errorf("failed to get max sort order: %w", err)
 	}
 
 	sortOrder := int(maxOrder.Int64) + 1
 
 	result, err := tx.ExecContext(ctx,
-		`INSERT INTO tasks (user_id, list_id, text, sort_order, important, completed) VALUES (?, ?, ?, ?, ?, ?)`,
-		userID, listID, text, sortOrder, important, completed,
+		`INSERT INTO tasks (user_id, list_id, text, sort_order, important, completed, completed_at,
+		   due_at, due_all_day, start_at, start_all_day, recurrence, repeat_from)
+		 VALUES (?, ?, ?, ?, ?, ?, CASE WHEN ? THEN ? END, ?, ?, ?, ?, ?, ?)`,
+		userID, task.ListID, task.Text, sortOrder, task.Important, task.Completed,
+		task.Completed, sqlTime(time.Now()),
+		task.DueAt.sqlValue(), task.DueAt != nil && task.DueAt.AllDay,
+		task.StartAt.sqlValue(), task.StartAt != nil && task.StartAt.AllDay,
+		nullString(task.Recurrence), nullString(task.RepeatFrom),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create task: %w", err)
+		return 0, fmt.Errorf("failed to create task: %w", err)
 	}
 
 	taskID, err := result.LastInsertId()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get task id: %w", err)
+		return 0, fmt.Errorf("failed to get task id: %w", err)
 	}
 
 	// Add tags
-	if err := addTagsToTaskTx(ctx, tx, taskID, tags); err != nil {
-		return nil, err
+	if err := addTagsToTaskTx(ctx, tx, userID, taskID, task.Tags); err != nil {
+		return 0, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	if err := recordHistory(ctx, tx, userID, taskID, nil, HistoryCreate, HistoryValue(task.Text)); err != nil {
+		return 0, err
 	}
 
-	return db.GetTask(ctx, userID, taskID)
+	return taskID, nil
+}
+
+// taskColumns is the column list read by scanTask, qualified with the "t" alias.
+const taskColumns = `t.id, t.user_id, t.list_id, t.text, t.completed, t.important, t.is_expanded,
+	t.sort_order, t.created_at, t.updated_at, t.deleted_at,
+	t.due_at, t.due_all_day, t.start_at, t.start_all_day,
+	t.recurrence, t.repeat_from, t.completed_at, t.version`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask scans a row selected with taskColumns. Any extra destinations receive
+// columns selected after taskColumns.
+func scanTask(row rowScanner, extra ...interface{}) (*Task, error) {
+	task := &Task{}
+	var deletedAt, dueAt, startAt, completedAt sql.NullTime
+	var dueAllDay, startAllDay bool
+	var recurrence, repeatFrom sql.NullString
+	dest := []interface{}{&task.ID, &task.UserID, &task.ListID, &task.Text, &task.Completed, &task.Important,
+		&task.IsExpanded, &task.SortOrder, &task.CreatedAt, &task.UpdatedAt, &deletedAt,
+		&dueAt, &dueAllDay, &startAt, &startAllDay,
+		&recurrence, &repeatFrom, &completedAt, &task.Version}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		task.DeletedAt = &deletedAt.Time
+	}
+	task.DueAt = scanTaskDate(dueAt, dueAllDay)
+	task.StartAt = scanTaskDate(startAt, startAllDay)
+	task.Recurrence = recurrence.String
+	task.RepeatFrom = repeatFrom.String
+	if completedAt.Valid {
+		task.CompletedAt = &completedAt.Time
+	}
+	return task, nil
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
 }
 
 // GetTask retrieves a single task by ID for a specific user.
 func (db *DB) GetTask(ctx context.Context, userID, taskID int64) (*Task, error) {
-	task := &Task{}
-	err := db.QueryRowContext(ctx,
-		`SELECT id, user_id, list_id, text, completed, important, is_expanded, sort_order, created_at, updated_at 
-		 FROM tasks WHERE id = ? AND user_id = ?`,
+	task, err := scanTask(db.QueryRowContext(ctx,
+		`SELECT `+taskColumns+` FROM tasks t WHERE t.id = ? AND t.user_id = ? AND t.deleted_at IS NULL`,
 		taskID, userID,
-	).Scan(&task.ID, &task.UserID, &task.ListID, &task.Text, &task.Completed, &task.Important,
-		&task.IsExpanded, &task.SortOrder, &task.CreatedAt, &task.UpdatedAt)
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
@@ -95,30 +198,29 @@ func (db *DB) GetTask(ctx context.Context, userID, taskID int64) (*Task, error)
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
 
-	// Load tags
-	tags, err := db.getTaskTags(ctx, taskID)
-	if err != nil {
-		return nil, err
-	}
-	task.Tags = tags
-
-	// Load subtasks
-	subtasks, err := db.GetSubtasks(ctx, taskID)
-	if err != nil {
+	if err := db.attachTaskDetails(ctx, userID, []*Task{task}); err != nil {
 		return nil, err
 	}
-	task.Subtasks = subtasks
 
 	return task, nil
 }
 
-// GetUserTasks retrieves all tasks for a user.
-func (db *DB) GetUserTasks(ctx context.Context, userID int64) ([]*Task, error) {
-	rows, err := db.QueryContext(ctx,
-		`SELECT id, user_id, list_id, text, completed, important, is_expanded, sort_order, created_at, updated_at 
-		 FROM tasks WHERE user_id = ? ORDER BY sort_order ASC`,
-		userID,
+// GetUserTasks retrieves a user's tasks that pass the filter, in the filter's order.
+func (db *DB) GetUserTasks(ctx context.Context, userID int64, filter TaskFilter) ([]*Task, error) {
+	where, args := filter.sqlConditions()
+	order, orderArgs := filter.sqlOrder()
+	args = append(append([]interface{}{userID}, args...), orderArgs...)
+	return db.queryTasks(ctx, userID,
+		`SELECT `+taskColumns+` FROM tasks t
+		 WHERE t.user_id = ? AND t.deleted_at IS NULL`+where+order,
+		args...,
 	)
+}
+
+// queryTasks runs a query selecting taskColumns for one user's tasks and attaches
+// their tags and subtasks.
+func (db *DB) queryTasks(ctx context.Context, userID int64, query string, args ...interface{}) ([]*Task, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tasks: %w", err)
 	}
@@ -126,9 +228,7 @@ func (db *DB) GetUserTasks(ctx context.Context, userID int64) ([]*Task, error) {
 
 	var tasks []*Task
 	for rows.Next() {
-		task := &Task{}
-		err := rows.Scan(&task.ID, &task.UserID, &task.ListID, &task.Text, &task.Completed, &task.Important,
-			&task.IsExpanded, &task.SortOrder, &task.CreatedAt, &task.UpdatedAt)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan task: %w", err)
 		}
@@ -138,29 +238,192 @@ func (db *DB) GetUserTasks(ctx context.Context, userID int64) ([]*Task, error) {
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating tasks: %w", err)
 	}
+	rows.Close()
 
-	// Load tags and subtasks for each task
-	for _, task := range tasks {
-		tags, err := db.getTaskTags(ctx, task.ID)
+	if err := db.attachTaskDetails(ctx, userID, tasks); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// UpdateTask updates a task's properties and records the changed fields in the task's history
+// and the update in the user's undo log.
+// If version is non-zero the update only applies to that version of the task;
+// otherwise it fails with ErrVersionConflict.
+func (db *DB) UpdateTask(ctx context.Context, userID, taskID int64, updates map[string]interface{}, version int64) (*Task, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := getTaskTx(ctx, tx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := updateTaskTx(ctx, tx, userID, taskID, updates, version); err != nil {
+		return nil, err
+	}
+	if err := recordTaskUpdateTx(ctx, tx, userID, old, 0); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return db.GetTask(ctx, userID, taskID)
+}
+
+// CompleteRecurringTask applies updates to a task like UpdateTask. If they
+// complete a recurring task, it also creates the occurrence scheduled by next,
+// copying the task's list, text, importance, tags, repeat mode and live subtasks,
+// and clears the completed task's rule so completing it again does not spawn a
+// second copy. All of it happens in one transaction, with the undo log entry
+// for both. It returns the updated task and the new one, which is nil if nothing
+// was spawned.
+func (db *DB) CompleteRecurringTask(ctx context.Context, userID, taskID int64, updates map[string]interface{}, version int64, next NextOccurrence) (*Task, *Task, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	old, err := getTaskTx(ctx, tx, userID, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := updateTaskTx(ctx, tx, userID, taskID, updates, version); err != nil {
+		return nil, nil, err
+	}
+
+	nextID, err := spawnNextOccurrenceTx(ctx, tx, userID, taskID, updates, next)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := recordTaskUpdateTx(ctx, tx, userID, old, nextID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	task, err := db.GetTask(ctx, userID, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if nextID == 0 {
+		return task, nil, nil
+	}
+	spawned, err := db.GetTask(ctx, userID, nextID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return task, spawned, nil
+}
+
+// spawnNextOccurrenceTx creates the next occurrence of a task that updates just
+// completed, within a transaction, and clears the task's rule. It returns the new
+// task's ID, or zero if the task doesn't recur or its series has ended.
+func spawnNextOccurrenceTx(ctx context.Context, tx *sql.Tx, userID, taskID int64, updates map[string]interface{}, next NextOccurrence) (int64, error) {
+	if completed, _ := updates["completed"].(bool); !completed {
+		return 0, nil
+	}
+	task, err := getTaskTx(ctx, tx, userID, taskID)
+	if err != nil {
+		return 0, err
+	}
+	if task.Recurrence == "" {
+		return 0, nil
+	}
+
+	occurrence, err := next(task)
+	if err != nil {
+		return 0, fmt.Errorf("failed to schedule next occurrence: %w", err)
+	}
+
+	var nextID int64
+	if occurrence != nil {
+		nextID, err = createTaskTx(ctx, tx, userID, &Task{
+			ListID:     task.ListID,
+			Text:       task.Text,
+			Tags:       task.Tags,
+			Important:  task.Important,
+			DueAt:      occurrence.DueAt,
+			StartAt:    occurrence.StartAt,
+			Recurrence: occurrence.Recurrence,
+			RepeatFrom: task.RepeatFrom,
+		})
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
-		task.Tags = tags
 
-		subtasks, err := db.GetSubtasks(ctx, task.ID)
+		rows, err := tx.QueryContext(ctx,
+			`SELECT text FROM subtasks WHERE task_id = ? AND deleted_at IS NULL ORDER BY sort_order, id`,
+			taskID,
+		)
 		if err != nil {
-			return nil, err
+			return 0, fmt.Errorf("failed to query subtasks: %w", err)
+		}
+		var texts []string
+		for rows.Next() {
+			var text string
+			if err := rows.Scan(&text); err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("failed to scan subtask: %w", err)
+			}
+			texts = append(texts, text)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, fmt.Errorf("error iterating subtasks: %w", err)
+		}
+
+		for _, text := range texts {
+			if _, err := createSubtaskTx(ctx, tx, userID, nextID, text); err != nil {
+				return 0, err
+			}
 		}
-		task.Subtasks = subtasks
 	}
 
-	return tasks, nil
+	if err := updateTaskTx(ctx, tx, userID, taskID, map[string]interface{}{"recurrence": nil}, 0); err != nil {
+		return 0, err
+	}
+	return nextID, nil
 }
 
-// UpdateTask updates a task's properties.
-func (db *DB) UpdateTask(ctx context.Context, userID, taskID int64, updates map[string]interface{}) (*Task, error) {
+// recordTaskUpdateTx logs an update of a task, from old to how it is now, in
+// the user's undo log within the update's transaction, with the creation of the
+// next occurrence it spawned unless nextID is zero.
+func recordTaskUpdateTx(ctx context.Context, tx *sql.Tx, userID int64, old *Task, nextID int64) error {
+	task, err := getTaskTx(ctx, tx, userID, old.ID)
+	if err != nil {
+		return err
+	}
+	var changes []OperationChange
+	if change, ok := TaskChange(old, task); ok {
+		changes = append(changes, change)
+	}
+	if nextID != 0 {
+		changes = append(changes, OperationChange{Entity: SyncTask, ID: nextID, Action: HistoryCreate})
+	}
+	return recordOperationTx(ctx, tx, userID, OperationTaskUpdate, changes)
+}
+
+// updateTaskTx applies UpdateTask's changes within a transaction.
+func updateTaskTx(ctx context.Context, tx *sql.Tx, userID, taskID int64, updates map[string]interface{}, version int64) error {
+	old, err := getTaskTx(ctx, tx, userID, taskID)
+	if err != nil {
+		return err
+	}
+	if version != 0 && old.Version != version {
+		return ErrVersionConflict
+	}
+
 	// Build dynamic update query
-	setClause := "updated_at = CURRENT_TIMESTAMP"
+	setClause := "updated_at = CURRENT_TIMESTAMP, version = version + 1"
 	args := []interface{}{}
 
 	if text, ok := updates["text"].(string); ok {
@@ -168,8 +431,19 @@ func (db *DB) UpdateTask(ctx context.Context, userID, taskID int64, updates map[
 		args = append(args, text)
 	}
 	if completed, ok := updates["completed"].(bool); ok {
-		setClause += ", completed = ?"
-		args = append(args, completed)
+		// completed still holds the old value on the right-hand side
+		setClause += ", completed = ?, completed_at = CASE WHEN ? THEN COALESCE(CASE WHEN completed THEN completed_at END, ?) END"
+		args = append(args, completed, completed, sqlTime(time.Now()))
+	}
+	if recurrence, ok := updates["recurrence"]; ok {
+		s, _ := recurrence.(string)
+		setClause += ", recurrence = ?"
+		args = append(args, nullString(s))
+	}
+	if repeatFrom, ok := updates["repeatFrom"]; ok {
+		s, _ := repeatFrom.(string)
+		setClause += ", repeat_from = ?"
+		args = append(args, nullString(s))
 	}
 	if important, ok := updates["important"].(bool); ok {
 		setClause += ", important = ?"
@@ -199,41 +473,108 @@ func (db *DB) UpdateTask(ctx context.Context, userID, taskID int64, updates map[
 			}
 		}
 	}
+	for _, field := range []struct{ key, column string }{{"dueAt", "due"}, {"startAt", "start"}} {
+		value, ok := updates[field.key]
+		if !ok {
+			continue
+		}
+		date, err := TaskDateFromUpdate(value)
+		if err != nil {
+			return fmt.Errorf("failed to update task: %w", err)
+		}
+		setClause += fmt.Sprintf(", %[1]s_at = ?, %[1]s_all_day = ?", field.column)
+		args = append(args, date.sqlValue(), date != nil && date.AllDay)
+	}
 
 	args = append(args, taskID, userID)
 
-	result, err := db.ExecContext(ctx,
-		fmt.Sprintf(`UPDATE tasks SET %s WHERE id = ? AND user_id = ?`, setClause),
+	_, err = tx.ExecContext(ctx,
+		fmt.Sprintf(`UPDATE tasks SET %s WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, setClause),
 		args...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update task: %w", err)
+		return fmt.Errorf("failed to update task: %w", err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	// Handle tags update if provided
+	if value, ok := updates["tags"]; ok {
+		tags, err := TaskTagsFromUpdate(value)
+		if err != nil {
+			return fmt.Errorf("failed to update task: %w", err)
+		}
+		if err := setTaskTagsTx(ctx, tx, userID, taskID, tags); err != nil {
+			return err
+		}
+	}
+
+	updated, err := getTaskTx(ctx, tx, userID, taskID)
+	if err != nil {
+		return err
+	}
+	return recordChanges(ctx, tx, userID, taskID, nil, DiffTask(old, updated))
+}
+
+// getTaskTx loads a live task and its tags within a transaction.
+func getTaskTx(ctx context.Context, tx *sql.Tx, userID, taskID int64) (*Task, error) {
+	task, err := scanTask(tx.QueryRowContext(ctx,
+		`SELECT `+taskColumns+` FROM tasks t WHERE t.id = ? AND t.user_id = ? AND t.deleted_at IS NULL`,
+		taskID, userID,
+	))
+	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
 
-	// Handle tags update if provided
-	if tags, ok := updates["tags"].([]interface{}); ok {
-		tagStrings := make([]string, len(tags))
-		for i, t := range tags {
-			tagStrings[i] = t.(string)
-		}
-		if err := db.setTaskTags(ctx, taskID, tagStrings); err != nil {
-			return nil, err
+	rows, err := tx.QueryContext(ctx,
+		`SELECT tg.name FROM task_tags tt
+		 JOIN tags tg ON tg.id = tt.tag_id
+		 WHERE tt.task_id = ? ORDER BY tg.id`,
+		taskID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
 		}
+		task.Tags = append(task.Tags, tag)
 	}
 
-	return db.GetTask(ctx, userID, taskID)
+	return task, rows.Err()
 }
 
-// DeleteTask deletes a task.
+// DeleteTask moves a task to the trash and records it in the user's undo log.
 func (db *DB) DeleteTask(ctx context.Context, userID, taskID int64) error {
-	result, err := db.ExecContext(ctx,
-		`DELETE FROM tasks WHERE id = ? AND user_id = ?`,
-		taskID, userID,
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteTaskTx(ctx, tx, userID, taskID); err != nil {
+		return err
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationTaskDelete, []OperationChange{
+		{Entity: SyncTask, ID: taskID, Action: HistoryDelete},
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteTaskTx moves a task to the trash within a transaction.
+func deleteTaskTx(ctx context.Context, tx *sql.Tx, userID, taskID int64) error {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE tasks SET deleted_at = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE id = ? AND user_id = ? AND deleted_at IS NULL`,
+		sqlTime(time.Now()), taskID, userID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to delete task: %w", err)
@@ -244,10 +585,11 @@ func (db *DB) DeleteTask(ctx context.Context, userID, taskID int64) error {
 		return ErrNotFound
 	}
 
-	return nil
+	return recordHistory(ctx, tx, userID, taskID, nil, HistoryDelete, nil)
 }
 
-// ReorderTasks updates the sort order of tasks.
+// ReorderTasks updates the sort order of tasks, and records the reorder in the
+// user's undo log.
 func (db *DB) ReorderTasks(ctx context.Context, userID int64, taskIDs []int64) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
@@ -255,10 +597,16 @@ func (db *DB) ReorderTasks(ctx context.Context, userID int64, taskIDs []int64) e
 	}
 	defer tx.Rollback()
 
+	const sortOrderQuery = `SELECT sort_order FROM tasks WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
+	before, err := sortOrdersTx(ctx, tx, sortOrderQuery, taskIDs, userID)
+	if err != nil {
+		return err
+	}
+
 	for i, taskID := range taskIDs {
 		_, err := tx.ExecContext(ctx,
-			`UPDATE tasks SET sort_order = ?, updated_at = CURRENT_TIMESTAMP 
-			 WHERE id = ? AND user_id = ?`,
+			`UPDATE tasks SET sort_order = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1 
+			 WHERE id = ? AND user_id = ? AND deleted_at IS NULL`,
 			i, taskID, userID,
 		)
 		if err != nil {
@@ -266,63 +614,122 @@ func (db *DB) ReorderTasks(ctx context.Context, userID int64, taskIDs []int64) e
 		}
 	}
 
+	after, err := sortOrdersTx(ctx, tx, sortOrderQuery, taskIDs, userID)
+	if err != nil {
+		return err
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationTaskReorder, ReorderChanges(SyncTask, taskIDs, before, after)); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-// getTaskTags retrieves all tags for a task.
-func (db *DB) getTaskTags(ctx context.Context, taskID int64) ([]string, error) {
+// maxInlineTaskIDs is the largest task set attachTaskDetails filters with an IN clause.
+// Larger sets load the user's full tag and subtask tables instead, which is cheaper
+// than binding thousands of parameters and keeps the query count fixed.
+const maxInlineTaskIDs = 500
+
+// attachTaskDetails loads the tags and subtasks for a set of the user's tasks using
+// one query each and stitches them onto the tasks in memory.
+func (db *DB) attachTaskDetails(ctx context.Context, userID int64, tasks []*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	filter := ""
+	args := []interface{}{userID}
+	if len(tasks) <= maxInlineTaskIDs {
+		filter = " AND t.id IN (?" + strings.Repeat(", ?", len(tasks)-1) + ")"
+		for _, task := range tasks {
+			args = append(args, task.ID)
+		}
+	}
+
+	// Load tags
 	rows, err := db.QueryContext(ctx,
-		`SELECT t.name FROM tags t
-		 JOIN task_tags tt ON t.id = tt.tag_id
-		 WHERE tt.task_id = ?`,
-		taskID,
+		`SELECT tt.task_id, tg.name FROM task_tags tt
+		 JOIN tags tg ON tg.id = tt.tag_id
+		 JOIN tasks t ON t.id = tt.task_id
+		 WHERE t.user_id = ?`+filter+`
+		 ORDER BY tt.task_id, tg.id`,
+		args...,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query tags: %w", err)
+		return fmt.Errorf("failed to query tags: %w", err)
 	}
 	defer rows.Close()
 
-	var tags []string
 	for rows.Next() {
+		var taskID int64
 		var tag string
-		if err := rows.Scan(&tag); err != nil {
-			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		if err := rows.Scan(&taskID, &tag); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if task, ok := byID[taskID]; ok {
+			task.Tags = append(task.Tags, tag)
 		}
-		tags = append(tags, tag)
 	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating tags: %w", err)
+	}
+	rows.Close()
 
-	return tags, rows.Err()
-}
-
-// setTaskTags replaces all tags for a task.
-func (db *DB) setTaskTags(ctx context.Context, taskID int64, tags []string) error {
-	tx, err := db.BeginTx(ctx, nil)
+	// Load subtasks
+	rows, err = db.QueryContext(ctx,
+		`SELECT `+subtaskColumns+`
+		 FROM subtasks s
+		 JOIN tasks t ON t.id = s.task_id
+		 WHERE t.user_id = ? AND s.deleted_at IS NULL`+filter+`
+		 ORDER BY s.task_id, s.sort_order ASC, s.id ASC`,
+		args...,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to query subtasks: %w", err)
 	}
-	defer tx.Rollback()
+	defer rows.Close()
 
+	for rows.Next() {
+		subtask, err := scanSubtask(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan subtask: %w", err)
+		}
+		if task, ok := byID[subtask.TaskID]; ok {
+			task.Subtasks = append(task.Subtasks, subtask)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating subtasks: %w", err)
+	}
+
+	return nil
+}
+
+// setTaskTagsTx replaces all tags for a task within a transaction.
+func setTaskTagsTx(ctx context.Context, tx *sql.Tx, userID, taskID int64, tags []string) error {
 	// Remove existing tags
-	_, err = tx.ExecContext(ctx, `DELETE FROM task_tags WHERE task_id = ?`, taskID)
+	_, err := tx.ExecContext(ctx, `DELETE FROM task_tags WHERE task_id = ?`, taskID)
 	if err != nil {
 		return fmt.Errorf("failed to remove existing tags: %w", err)
 	}
 
 	// Add new tags
-	if err := addTagsToTaskTx(ctx, tx, taskID, tags); err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	return addTagsToTaskTx(ctx, tx, userID, taskID, tags)
 }
 
-// addTagsToTaskTx adds tags to a task within a transaction.
-func addTagsToTaskTx(ctx context.Context, tx *sql.Tx, taskID int64, tags []string) error {
+// addTagsToTaskTx adds tags to a task within a transaction, creating any of the
+// user's tags that don't exist yet.
+func addTagsToTaskTx(ctx context.Context, tx *sql.Tx, userID, taskID int64, tags []string) error {
 	for _, tag := range tags {
 		// Upsert tag
 		_, err := tx.ExecContext(ctx,
-			`INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`,
-			tag,
+			`INSERT INTO tags (user_id, name) VALUES (?, ?) ON CONFLICT(user_id, name) DO NOTHING`,
+			userID, tag,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert tag: %w", err)
@@ -330,7 +737,7 @@ func addTagsToTaskTx(ctx context.Context, tx *sql.Tx, taskID int64, tags []strin
 
 		// Get tag ID
 		var tagID int64
-		err = tx.QueryRowContext(ctx, `SELECT id FROM tags WHERE name = ?`, tag).Scan(&tagID)
+		err = tx.QueryRowContext(ctx, `SELECT id FROM tags WHERE user_id = ? AND name = ?`, userID, tag).Scan(&tagID)
 		if err != nil {
 			return fmt.Errorf("failed to get tag id: %w", err)
 		}
@@ -350,11 +757,38 @@ func addTagsToTaskTx(ctx context.Context, tx *sql.Tx, taskID int64, tags []strin
 
 // --- Subtask operations ---
 
-// CreateSubtask creates a new subtask for a task.
+// CreateSubtask creates a new subtask for a task and records it in the user's
+// undo log.
 func (db *DB) CreateSubtask(ctx context.Context, userID, taskID int64, text string) (*Subtask, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	subtask, err := createSubtaskTx(ctx, tx, userID, taskID, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationSubtaskCreate, []OperationChange{
+		{Entity: SyncSubtask, ID: subtask.ID, Action: HistoryCreate},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return subtask, nil
+}
+
+// createSubtaskTx adds a subtask to the end of one of the user's live tasks
+// within a transaction.
+func createSubtaskTx(ctx context.Context, tx *sql.Tx, userID, taskID int64, text string) (*Subtask, error) {
 	// Verify task ownership
 	var ownerID int64
-	err := db.QueryRowContext(ctx, `SELECT user_id FROM tasks WHERE id = ?`, taskID).Scan(&ownerID)
+	err := tx.QueryRowContext(ctx, `SELECT user_id FROM tasks WHERE id = ? AND deleted_at IS NULL`, taskID).Scan(&ownerID)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -367,7 +801,7 @@ func (db *DB) CreateSubtask(ctx context.Context, userID, taskID int64, text stri
 
 	// Get the next sort order
 	var maxOrder sql.NullInt64
-	err = db.QueryRowContext(ctx,
+	err = tx.QueryRowContext(ctx,
 		`SELECT MAX(sort_order) FROM subtasks WHERE task_id = ?`,
 		taskID,
 	).Scan(&maxOrder)
@@ -377,7 +811,7 @@ func (db *DB) CreateSubtask(ctx context.Context, userID, taskID int64, text stri
 
 	sortOrder := int(maxOrder.Int64) + 1
 
-	result, err := db.ExecContext(ctx,
+	result, err := tx.ExecContext(ctx,
 		`INSERT INTO subtasks (task_id, text, sort_order) VALUES (?, ?, ?)`,
 		taskID, text, sortOrder,
 	)
@@ -390,21 +824,61 @@ func (db *DB) CreateSubtask(ctx context.Context, userID, taskID int64, text stri
 		return nil, fmt.Errorf("failed to get subtask id: %w", err)
 	}
 
+	if err := recordHistory(ctx, tx, userID, taskID, &id, HistoryCreate, HistoryValue(text)); err != nil {
+		return nil, err
+	}
+
 	return &Subtask{
 		ID:        id,
 		TaskID:    taskID,
 		Text:      text,
 		Completed: false,
 		SortOrder: sortOrder,
+		Version:   1,
 		CreatedAt: time.Now(),
 	}, nil
 }
 
+// subtaskColumns is the column list read by scanSubtask, qualified with the "s" alias.
+const subtaskColumns = `s.id, s.task_id, s.text, s.completed, s.sort_order, s.version, s.created_at, s.deleted_at`
+
+// liveSubtaskQuery selects a subtask by ID and owner, if neither it nor its task is deleted.
+const liveSubtaskQuery = `SELECT ` + subtaskColumns + ` FROM subtasks s
+	JOIN tasks t ON t.id = s.task_id
+	WHERE s.id = ? AND t.user_id = ? AND s.deleted_at IS NULL AND t.deleted_at IS NULL`
+
+// scanSubtask scans a row selected with subtaskColumns.
+func scanSubtask(row rowScanner) (*Subtask, error) {
+	subtask := &Subtask{}
+	var deletedAt sql.NullTime
+	err := row.Scan(&subtask.ID, &subtask.TaskID, &subtask.Text,
+		&subtask.Completed, &subtask.SortOrder, &subtask.Version, &subtask.CreatedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		subtask.DeletedAt = &deletedAt.Time
+	}
+	return subtask, nil
+}
+
+// GetSubtask retrieves a live subtask of one of the user's live tasks.
+func (db *DB) GetSubtask(ctx context.Context, userID, subtaskID int64) (*Subtask, error) {
+	subtask, err := scanSubtask(db.QueryRowContext(ctx, liveSubtaskQuery, subtaskID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subtask: %w", err)
+	}
+	return subtask, nil
+}
+
 // GetSubtasks retrieves all subtasks for a task.
 func (db *DB) GetSubtasks(ctx context.Context, taskID int64) ([]*Subtask, error) {
 	rows, err := db.QueryContext(ctx,
-		`SELECT id, task_id, text, completed, sort_order, created_at 
-		 FROM subtasks WHERE task_id = ? ORDER BY sort_order ASC`,
+		`SELECT `+subtaskColumns+`
+		 FROM subtasks s WHERE s.task_id = ? AND s.deleted_at IS NULL ORDER BY s.sort_order ASC, s.id ASC`,
 		taskID,
 	)
 	if err != nil {
@@ -414,9 +888,7 @@ func (db *DB) GetSubtasks(ctx context.Context, taskID int64) ([]*Subtask, error)
 
 	var subtasks []*Subtask
 	for rows.Next() {
-		subtask := &Subtask{}
-		err := rows.Scan(&subtask.ID, &subtask.TaskID, &subtask.Text,
-			&subtask.Completed, &subtask.SortOrder, &subtask.CreatedAt)
+		subtask, err := scanSubtask(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan subtask: %w", err)
 		}
@@ -426,24 +898,46 @@ func (db *DB) GetSubtasks(ctx context.Context, taskID int64) ([]*Subtask, error)
 	return subtasks, rows.Err()
 }
 
-// UpdateSubtask updates a subtask's properties.
-func (db *DB) UpdateSubtask(ctx context.Context, userID, subtaskID int64, updates map[string]interface{}) (*Subtask, error) {
-	// Verify ownership through task
-	var ownerID int64
-	err := db.QueryRowContext(ctx,
-		`SELECT t.user_id FROM tasks t
-		 JOIN subtasks s ON s.task_id = t.id
-		 WHERE s.id = ?`,
-		subtaskID,
-	).Scan(&ownerID)
-	if err == sql.ErrNoRows {
-		return nil, ErrNotFound
+// UpdateSubtask updates a subtask's properties and records the changed fields in
+// the parent task's history and the update in the user's undo log. A non-zero
+// version makes the update conditional, as in UpdateTask.
+func (db *DB) UpdateSubtask(ctx context.Context, userID, subtaskID int64, updates map[string]interface{}, version int64) (*Subtask, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
+
+	old, err := getSubtaskTx(ctx, tx, userID, subtaskID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify ownership: %w", err)
+		return nil, err
 	}
-	if ownerID != userID {
-		return nil, ErrNotFound
+	subtask, err := updateSubtaskTx(ctx, tx, userID, subtaskID, updates, version)
+	if err != nil {
+		return nil, err
+	}
+	if change, ok := SubtaskChange(old, subtask); ok {
+		if err := recordOperationTx(ctx, tx, userID, OperationSubtaskUpdate, []OperationChange{change}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return subtask, nil
+}
+
+// updateSubtaskTx applies UpdateSubtask's changes within a transaction.
+func updateSubtaskTx(ctx context.Context, tx *sql.Tx, userID, subtaskID int64, updates map[string]interface{}, version int64) (*Subtask, error) {
+	// Verify ownership through task
+	old, err := getSubtaskTx(ctx, tx, userID, subtaskID)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && old.Version != version {
+		return nil, ErrVersionConflict
 	}
 
 	// Build dynamic update
@@ -471,8 +965,8 @@ func (db *DB) UpdateSubtask(ctx context.Context, userID, subtaskID int64, update
 
 	args = append(args, subtaskID)
 
-	_, err = db.ExecContext(ctx,
-		fmt.Sprintf(`UPDATE subtasks SET %s WHERE id = ?`, setClause),
+	_, err = tx.ExecContext(ctx,
+		fmt.Sprintf(`UPDATE subtasks SET %s, version = version + 1 WHERE id = ?`, setClause),
 		args...,
 	)
 	if err != nil {
@@ -480,42 +974,140 @@ func (db *DB) UpdateSubtask(ctx context.Context, userID, subtaskID int64, update
 	}
 
 	// Return updated subtask
-	subtask := &Subtask{}
-	err = db.QueryRowContext(ctx,
-		`SELECT id, task_id, text, completed, sort_order, created_at FROM subtasks WHERE id = ?`,
-		subtaskID,
-	).Scan(&subtask.ID, &subtask.TaskID, &subtask.Text, &subtask.Completed, &subtask.SortOrder, &subtask.CreatedAt)
+	subtask, err := getSubtaskTx(ctx, tx, userID, subtaskID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get updated subtask: %w", err)
 	}
 
+	if err := recordChanges(ctx, tx, userID, subtask.TaskID, &subtask.ID, DiffSubtask(old, subtask)); err != nil {
+		return nil, err
+	}
+
 	return subtask, nil
 }
 
-// DeleteSubtask deletes a subtask.
+// DeleteSubtask moves a subtask to the trash and records it in the user's undo
+// log.
 func (db *DB) DeleteSubtask(ctx context.Context, userID, subtaskID int64) error {
-	// Verify ownership
-	var ownerID int64
-	err := db.QueryRowContext(ctx,
-		`SELECT t.user_id FROM tasks t
-		 JOIN subtasks s ON s.task_id = t.id
-		 WHERE s.id = ?`,
-		subtaskID,
-	).Scan(&ownerID)
-	if err == sql.ErrNoRows {
-		return ErrNotFound
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteSubtaskTx(ctx, tx, userID, subtaskID); err != nil {
+		return err
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationSubtaskDelete, []OperationChange{
+		{Entity: SyncSubtask, ID: subtaskID, Action: HistoryDelete},
+	}); err != nil {
+		return err
 	}
+
+	return tx.Commit()
+}
+
+// ReorderSubtasks updates the sort order of a task's subtasks, and records the
+// reorder in the user's undo log.
+func (db *DB) ReorderSubtasks(ctx context.Context, userID, taskID int64, subtaskIDs []int64) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to verify ownership: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if ownerID != userID {
-		return ErrNotFound
+	defer tx.Rollback()
+
+	if _, err := getTaskTx(ctx, tx, userID, taskID); err != nil {
+		return err
 	}
 
-	_, err = db.ExecContext(ctx, `DELETE FROM subtasks WHERE id = ?`, subtaskID)
+	const sortOrderQuery = `SELECT sort_order FROM subtasks WHERE id = ? AND task_id = ? AND deleted_at IS NULL`
+	before, err := sortOrdersTx(ctx, tx, sortOrderQuery, subtaskIDs, taskID)
 	if err != nil {
-		return fmt.Errorf("failed to delete subtask: %w", err)
+		return err
 	}
 
+	for i, subtaskID := range subtaskIDs {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE subtasks SET sort_order = ?, version = version + 1
+			 WHERE id = ? AND task_id = ? AND deleted_at IS NULL`,
+			i, subtaskID, taskID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update sort order: %w", err)
+		}
+	}
+
+	after, err := sortOrdersTx(ctx, tx, sortOrderQuery, subtaskIDs, taskID)
+	if err != nil {
+		return err
+	}
+	if err := recordOperationTx(ctx, tx, userID, OperationSubtaskReorder, ReorderChanges(SyncSubtask, subtaskIDs, before, after)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// moveSubtaskTx sets the sort order of a live subtask within a transaction.
+func moveSubtaskTx(ctx context.Context, tx *sql.Tx, userID, subtaskID int64, sortOrder int) error {
+	if _, err := getSubtaskTx(ctx, tx, userID, subtaskID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`UPDATE subtasks SET sort_order = ?, version = version + 1 WHERE id = ?`,
+		sortOrder, subtaskID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update sort order: %w", err)
+	}
 	return nil
 }
+
+// sortOrdersTx returns the sort orders of the live tasks or subtasks among ids
+// within a transaction, reading each with query, which takes its ID and args.
+func sortOrdersTx(ctx context.Context, tx *sql.Tx, query string, ids []int64, args ...interface{}) (map[int64]int, error) {
+	found := make(map[int64]int, len(ids))
+	for _, id := range ids {
+		var sortOrder int
+		err := tx.QueryRowContext(ctx, query, append([]interface{}{id}, args...)...).Scan(&sortOrder)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sort order: %w", err)
+		}
+		found[id] = sortOrder
+	}
+	return found, nil
+}
+
+// deleteSubtaskTx moves a subtask to the trash within a transaction.
+func deleteSubtaskTx(ctx context.Context, tx *sql.Tx, userID, subtaskID int64) error {
+	// Verify ownership
+	subtask, err := getSubtaskTx(ctx, tx, userID, subtaskID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE subtasks SET deleted_at = ?, version = version + 1 WHERE id = ?`,
+		sqlTime(time.Now()), subtaskID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete subtask: %w", err)
+	}
+
+	return recordHistory(ctx, tx, userID, subtask.TaskID, &subtask.ID, HistoryDelete, nil)
+}
+
+// getSubtaskTx loads a live subtask of one of the user's live tasks within a transaction.
+func getSubtaskTx(ctx context.Context, tx *sql.Tx, userID, subtaskID int64) (*Subtask, error) {
+	subtask, err := scanSubtask(tx.QueryRowContext(ctx, liveSubtaskQuery, subtaskID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify ownership: %w", err)
+	}
+	return subtask, nil
+}
@@ -0,0 +1,161 @@
+package database_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/todomaster-2010/backend/internal/database"
+)
+
+// seedTasks gives a user n tasks with two of three tags each and three subtasks,
+// the last of which is in the trash. It writes the rows directly, as creating
+// thousands of tasks one transaction at a time would dominate the test.
+func seedTasks(t testing.TB, db *database.DB, userID int64, n int) {
+	t.Helper()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var tagIDs []int64
+	for _, name := range []string{"home", "work", "later"} {
+		result, err := tx.ExecContext(ctx, `INSERT INTO tags (user_id, name) VALUES (?, ?)`, userID, name)
+		if err != nil {
+			t.Fatalf("failed to insert tag: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		tagIDs = append(tagIDs, id)
+	}
+
+	for i := 0; i < n; i++ {
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO tasks (user_id, text, sort_order) VALUES (?, ?, ?)`,
+			userID, fmt.Sprintf("task %d", i), i,
+		)
+		if err != nil {
+			t.Fatalf("failed to insert task: %v", err)
+		}
+		taskID, _ := result.LastInsertId()
+
+		for _, tagID := range []int64{tagIDs[i%3], tagIDs[(i+1)%3]} {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO task_tags (task_id, tag_id) VALUES (?, ?)`, taskID, tagID); err != nil {
+				t.Fatalf("failed to tag task: %v", err)
+			}
+		}
+		for j := 0; j < 3; j++ {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO subtasks (task_id, text, sort_order, deleted_at) VALUES (?, ?, ?, CASE WHEN ? THEN CURRENT_TIMESTAMP END)`,
+				taskID, fmt.Sprintf("step %d", j), j, j == 2,
+			)
+			if err != nil {
+				t.Fatalf("failed to insert subtask: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+}
+
+// createUser creates a user with the given email.
+func createUser(t testing.TB, db *database.DB, email string) *database.User {
+	t.Helper()
+	user, err := db.CreateUser(context.Background(), email, "hash", "")
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+// TestGetUserTasksLargeAccount covers accounts with more tasks than
+// attachTaskDetails filters by ID, where it loads the user's whole tag and
+// subtask tables instead.
+func TestGetUserTasksLargeAccount(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	ann := createUser(t, db, "ann@example.com")
+	bob := createUser(t, db, "bob@example.com")
+	seedTasks(t, db, bob.ID, 10)
+	seedTasks(t, db, ann.ID, 600)
+
+	all, err := db.GetUserTasks(ctx, ann.ID, database.TaskFilter{})
+	if err != nil {
+		t.Fatalf("GetUserTasks: %v", err)
+	}
+	if len(all) != 600 {
+		t.Fatalf("GetUserTasks returned %d tasks, want 600", len(all))
+	}
+	want := [][]string{{"home", "work"}, {"work", "later"}, {"home", "later"}}
+	for i, task := range all {
+		if task.UserID != ann.ID {
+			t.Fatalf("task %d belongs to user %d", task.ID, task.UserID)
+		}
+		if fmt.Sprint(task.Tags) != fmt.Sprint(want[i%3]) {
+			t.Fatalf("task %d has tags %q, want %q", i, task.Tags, want[i%3])
+		}
+		if len(task.Subtasks) != 2 || task.Subtasks[0].Text != "step 0" || task.Subtasks[1].Text != "step 1" {
+			t.Fatalf("task %d has subtasks %+v, want steps 0 and 1", i, task.Subtasks)
+		}
+		for _, subtask := range task.Subtasks {
+			if subtask.TaskID != task.ID {
+				t.Fatalf("task %d has subtask %d of task %d", task.ID, subtask.ID, subtask.TaskID)
+			}
+		}
+	}
+
+	// A page small enough to filter by ID gets the same details
+	page, err := db.GetUserTasks(ctx, ann.ID, database.TaskFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("GetUserTasks with a limit: %v", err)
+	}
+	for i, task := range page {
+		if fmt.Sprint(task.Tags) != fmt.Sprint(all[i].Tags) || len(task.Subtasks) != len(all[i].Subtasks) {
+			t.Fatalf("task %d differs between the small and the large query: %+v and %+v", task.ID, task, all[i])
+		}
+	}
+}
+
+// BenchmarkGetUserTasks loads an account of 2,000 tasks with tags and subtasks,
+// both with GetUserTasks and the way it used to, one task at a time.
+func BenchmarkGetUserTasks(b *testing.B) {
+	db := newDB(b)
+	ctx := context.Background()
+	user := createUser(b, db, "ann@example.com")
+	seedTasks(b, db, user.ID, 2000)
+
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tasks, err := db.GetUserTasks(ctx, user.ID, database.TaskFilter{})
+			if err != nil || len(tasks) != 2000 {
+				b.Fatalf("GetUserTasks = %d tasks, %v", len(tasks), err)
+			}
+		}
+	})
+
+	b.Run("per-task", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rows, err := db.QueryContext(ctx,
+				`SELECT id FROM tasks WHERE user_id = ? AND deleted_at IS NULL ORDER BY sort_order`, user.ID)
+			if err != nil {
+				b.Fatalf("failed to query tasks: %v", err)
+			}
+			var ids []int64
+			for rows.Next() {
+				var id int64
+				rows.Scan(&id)
+				ids = append(ids, id)
+			}
+			rows.Close()
+			for _, id := range ids {
+				if _, err := db.GetTask(ctx, user.ID, id); err != nil {
+					b.Fatalf("GetTask: %v", err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Trash holds a user's soft-deleted lists, tasks and subtasks.
+type Trash struct {
+	Lists    []*List    `json:"lists"`
+	Tasks    []*Task    `json:"tasks"`
+	Subtasks []*Subtask `json:"subtasks"`
+}
+
+// GetTrash returns the user's deleted items, most recently deleted first.
+// Tasks that were deleted together with their list are not listed on their own;
+// they come back when the list is restored. Subtasks are only listed while their
+// task is not itself in the trash.
+func (db *DB) GetTrash(ctx context.Context, userID int64) (*Trash, error) {
+	trash := &Trash{
+		Lists:    []*List{},
+		Tasks:    []*Task{},
+		Subtasks: []*Subtask{},
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+listColumns+` FROM lists l
+		 WHERE l.user_id = ? AND l.deleted_at IS NOT NULL
+		 ORDER BY l.deleted_at DESC, l.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted lists: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan list: %w", err)
+		}
+		trash.Lists = append(trash.Lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted lists: %w", err)
+	}
+	rows.Close()
+
+	tasks, err := db.queryTasks(ctx, userID,
+		`SELECT `+taskColumns+` FROM tasks t
+		 WHERE t.user_id = ? AND t.deleted_at IS NOT NULL AND NOT t.deleted_with_list
+		 ORDER BY t.deleted_at DESC, t.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tasks != nil {
+		trash.Tasks = tasks
+	}
+
+	rows, err = db.QueryContext(ctx,
+		`SELECT `+subtaskColumns+` FROM subtasks s
+		 JOIN tasks t ON t.id = s.task_id
+		 WHERE t.user_id = ? AND t.deleted_at IS NULL AND s.deleted_at IS NOT NULL
+		 ORDER BY s.deleted_at DESC, s.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted subtasks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		subtask, err := scanSubtask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subtask: %w", err)
+		}
+		trash.Subtasks = append(trash.Subtasks, subtask)
+	}
+
+	return trash, rows.Err()
+}
+
+// RestoreTask brings a task back from the trash. If its list is still in the
+// trash, the task is restored without a list.
+func (db *DB) RestoreTask(ctx context.Context, userID, taskID int64) (*Task, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := restoreTaskTx(ctx, tx, userID, taskID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return db.GetTask(ctx, userID, taskID)
+}
+
+// restoreTaskTx brings a task back from the trash within a transaction.
+func restoreTaskTx(ctx context.Context, tx *sql.Tx, userID, taskID int64) error {
+	var oldListID *int64
+	err := tx.QueryRowContext(ctx,
+		`SELECT list_id FROM tasks WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`,
+		taskID, userID,
+	).Scan(&oldListID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE tasks SET deleted_at = NULL, deleted_with_list = FALSE, updated_at = CURRENT_TIMESTAMP, version = version + 1,
+		   list_id = CASE WHEN EXISTS (
+		     SELECT 1 FROM lists l WHERE l.id = tasks.list_id AND l.deleted_at IS NOT NULL
+		   ) THEN NULL ELSE list_id END
+		 WHERE id = ?`,
+		taskID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore task: %w", err)
+	}
+
+	if err := recordHistory(ctx, tx, userID, taskID, nil, HistoryRestore, nil); err != nil {
+		return err
+	}
+	if oldListID != nil {
+		var listID *int64
+		if err := tx.QueryRowContext(ctx, `SELECT list_id FROM tasks WHERE id = ?`, taskID).Scan(&listID); err != nil {
+			return fmt.Errorf("failed to get task: %w", err)
+		}
+		if listID == nil {
+			change := fieldChange("listId", oldListID, listID)
+			if err := recordChanges(ctx, tx, userID, taskID, nil, []FieldChange{change}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RestoreSubtask brings a subtask back from the trash. The parent task must not be deleted.
+func (db *DB) RestoreSubtask(ctx context.Context, userID, subtaskID int64) (*Subtask, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	subtask, err := restoreSubtaskTx(ctx, tx, userID, subtaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return subtask, nil
+}
+
+// restoreSubtaskTx brings a subtask back from the trash within a transaction.
+func restoreSubtaskTx(ctx context.Context, tx *sql.Tx, userID, subtaskID int64) (*Subtask, error) {
+	result, err := tx.ExecContext(ctx,
+		`UPDATE subtasks SET deleted_at = NULL, version = version + 1
+		 WHERE id = ? AND deleted_at IS NOT NULL
+		   AND task_id IN (SELECT id FROM tasks WHERE user_id = ? AND deleted_at IS NULL)`,
+		subtaskID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore subtask: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	subtask, err := getSubtaskTx(ctx, tx, userID, subtaskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get restored subtask: %w", err)
+	}
+
+	if err := recordHistory(ctx, tx, userID, subtask.TaskID, &subtask.ID, HistoryRestore, nil); err != nil {
+		return nil, err
+	}
+
+	return subtask, nil
+}
+
+// RestoreList brings a list back from the trash together with the tasks that
+// were deleted with it, and returns both.
+func (db *DB) RestoreList(ctx context.Context, userID, listID int64) (*List, []*Task, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := restoreListTx(ctx, tx, userID, listID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	list, err := db.GetList(ctx, userID, listID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tasks, err := db.queryTasks(ctx, userID,
+		`SELECT `+taskColumns+` FROM tasks t
+		 WHERE t.user_id = ? AND t.list_id = ? AND t.deleted_at IS NULL
+		 ORDER BY t.sort_order ASC`,
+		userID, listID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return list, tasks, nil
+}
+
+// restoreListTx brings a list and the tasks deleted with it back from the trash
+// within a transaction.
+func restoreListTx(ctx context.Context, tx *sql.Tx, userID, listID int64) error {
+	var exists int
+	err := tx.QueryRowContext(ctx,
+		`SELECT 1 FROM lists WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`,
+		listID, userID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get list: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO task_history (task_id, actor_id, action, created_at)
+		 SELECT id, ?, ?, ? FROM tasks WHERE list_id = ? AND deleted_with_list`,
+		userID, HistoryRestore, sqlTime(time.Now()), listID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE tasks SET deleted_at = NULL, deleted_with_list = FALSE, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE list_id = ? AND deleted_with_list`,
+		listID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore list tasks: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE lists SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ?`,
+		listID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore list: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeTask permanently deletes a task that is in the trash.
+func (db *DB) PurgeTask(ctx context.Context, userID, taskID int64) error {
+	result, err := db.ExecContext(ctx,
+		`DELETE FROM tasks WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`,
+		taskID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to purge task: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeSubtask permanently deletes a subtask that is in the trash.
+func (db *DB) PurgeSubtask(ctx context.Context, userID, subtaskID int64) error {
+	result, err := db.ExecContext(ctx,
+		`DELETE FROM subtasks
+		 WHERE id = ? AND deleted_at IS NOT NULL
+		   AND task_id IN (SELECT id FROM tasks WHERE user_id = ?)`,
+		subtaskID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to purge subtask: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// PurgeList permanently deletes a list that is in the trash along with the tasks
+// that were deleted with it. Any other task still pointing at the list is detached
+// first so the foreign key cascade can't take it along.
+func (db *DB) PurgeList(ctx context.Context, userID, listID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx,
+		`SELECT 1 FROM lists WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`,
+		listID, userID,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get list: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE tasks SET list_id = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE list_id = ? AND NOT deleted_with_list`,
+		listID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to detach list tasks: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM lists WHERE id = ?`, listID); err != nil {
+		return fmt.Errorf("failed to purge list: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// EmptyTrash permanently deletes everything in a user's trash and returns the
+// number of lists, tasks and subtasks removed.
+func (db *DB) EmptyTrash(ctx context.Context, userID int64) (int64, error) {
+	return db.purgeDeleted(ctx,
+		`DELETE FROM subtasks WHERE deleted_at IS NOT NULL
+		   AND task_id IN (SELECT id FROM tasks WHERE user_id = ?)`,
+		`DELETE FROM tasks WHERE deleted_at IS NOT NULL AND user_id = ?`,
+		`UPDATE tasks SET list_id = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE list_id IN (
+		   SELECT id FROM lists WHERE deleted_at IS NOT NULL AND user_id = ?)`,
+		`DELETE FROM lists WHERE deleted_at IS NOT NULL AND user_id = ?`,
+		userID,
+	)
+}
+
+// PurgeTrash permanently deletes all items, across all users, that were moved to
+// the trash before the cutoff. It returns the number of rows removed.
+func (db *DB) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	return db.purgeDeleted(ctx,
+		`DELETE FROM subtasks WHERE deleted_at < ?`,
+		`DELETE FROM tasks WHERE deleted_at < ?`,
+		`UPDATE tasks SET list_id = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+		 WHERE list_id IN (
+		   SELECT id FROM lists WHERE deleted_at < ?)`,
+		`DELETE FROM lists WHERE deleted_at < ?`,
+		sqlTime(before),
+	)
+}
+
+// purgeDeleted runs the subtask, task, detach and list statements of a purge in one
+// transaction, each bound to the same argument.
+func (db *DB) purgeDeleted(ctx context.Context, subtasksSQL, tasksSQL, detachSQL, listsSQL string, arg interface{}) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, stmt := range []string{subtasksSQL, tasksSQL, detachSQL, listsSQL} {
+		result, err := tx.ExecContext(ctx, stmt, arg)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge trash: %w", err)
+		}
+		if stmt == detachSQL {
+			continue
+		}
+		count, _ := result.RowsAffected()
+		total += count
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return total, nil
+}
@@ -11,6 +11,10 @@ import (
 // ErrNotFound is returned when a requested resource doesn't exist.
 var ErrNotFound = errors.New("resource not found")
 
+// ErrVersionConflict is returned when a conditional update names a version that is
+// no longer the current one.
+var ErrVersionConflict = errors.New("version conflict")
+
 // ErrDuplicateEmail is returned when trying to create a user with an existing email.
 var ErrDuplicateEmail = errors.New("email already exists")
 
@@ -20,6 +24,7 @@ type User struct {
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"` // Never expose password hash in JSON
 	DisplayName  string    `json:"displayName,omitempty"`
+	Timezone     string    `json:"timezone"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 }
@@ -50,10 +55,10 @@ func (db *DB) CreateUser(ctx context.Context, email, passwordHash, displayName s
 func (db *DB) GetUserByID(ctx context.Context, id int64) (*User, error) {
 	user := &User{}
 	err := db.QueryRowContext(ctx,
-		`SELECT id, email, password_hash, COALESCE(display_name, ''), created_at, updated_at 
+		`SELECT id, email, password_hash, COALESCE(display_name, ''), timezone, created_at, updated_at 
 		 FROM users WHERE id = ?`,
 		id,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.DisplayName, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.DisplayName, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
@@ -69,10 +74,10 @@ func (db *DB) GetUserByID(ctx context.Context, id int64) (*User, error) {
 func (db *DB) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	user := &User{}
 	err := db.QueryRowContext(ctx,
-		`SELECT id, email, password_hash, COALESCE(display_name, ''), created_at, updated_at 
+		`SELECT id, email, password_hash, COALESCE(display_name, ''), timezone, created_at, updated_at 
 		 FROM users WHERE email = ?`,
 		email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.DisplayName, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.DisplayName, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
@@ -97,6 +102,24 @@ func (db *DB) UpdateUser(ctx context.Context, id int64, displayName string) (*Us
 	return db.GetUserByID(ctx, id)
 }
 
+// UpdateUserTimezone sets the IANA time zone used to interpret a user's all-day dates.
+func (db *DB) UpdateUserTimezone(ctx context.Context, id int64, timezone string) error {
+	result, err := db.ExecContext(ctx,
+		`UPDATE users SET timezone = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		timezone, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update timezone: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 // UpdateUserPassword updates a user's password.
 func (db *DB) UpdateUserPassword(ctx context.Context, id int64, newPasswordHash string) error {
 	_, err := db.ExecContext(ctx,
@@ -0,0 +1,113 @@
+// Package ical reads and writes iCalendar data as defined by RFC 5545.
+//
+// Parse reads a component with its properties and nested components. A Writer
+// emits content lines, folding them at 75 octets and ending them with CRLF.
+// Property values are written as given, so TEXT values must first be escaped
+// with Text or TextList, and dates formatted with Date or DateTime.
+package ical
+
+import (
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest a content line may be, excluding its CRLF.
+const maxLineOctets = 75
+
+// Writer writes iCalendar content lines.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Begin starts a component such as VCALENDAR or VTODO.
+func (w *Writer) Begin(component string) {
+	w.Property("BEGIN", component)
+}
+
+// End ends a component.
+func (w *Writer) End(component string) {
+	w.Property("END", component)
+}
+
+// Property writes a property line. name may carry parameters, such as
+// "DUE;VALUE=DATE", and value must already be in the property's value format.
+func (w *Writer) Property(name, value string) {
+	w.writeLine(name + ":" + value)
+}
+
+// Err returns the first error encountered while writing.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// writeLine writes a content line, folded so that no line is longer than
+// maxLineOctets. Folds never split a UTF-8 sequence.
+func (w *Writer) writeLine(line string) {
+	if w.err != nil {
+		return
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		n := utf8.RuneLen(r)
+		if n < 0 {
+			n = len(string(utf8.RuneError))
+		}
+		if width+n > maxLineOctets {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	b.WriteString("\r\n")
+	_, w.err = io.WriteString(w.w, b.String())
+}
+
+// Text escapes a TEXT value. Newlines become \n and control characters, which
+// TEXT may not contain, are dropped.
+func Text(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToValidUTF8(s, "�") {
+		switch {
+		case r == '\\' || r == ';' || r == ',':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// TextList escapes a list of TEXT values, such as CATEGORIES.
+func TextList(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = Text(v)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// DateTime formats an instant as a DATE-TIME in UTC.
+func DateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// Date formats the calendar day of t as a DATE.
+func Date(t time.Time) string {
+	return t.Format("20060102")
+}
@@ -0,0 +1,151 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Begin("VCALENDAR")
+	w.Property("DESCRIPTION", strings.Repeat("x", 63))
+	w.Property("DESCRIPTION", strings.Repeat("x", 64))
+	w.Property("SUMMARY", strings.Repeat("a", 66)+"é")
+	w.Property("DUE;VALUE=DATE", "20240310")
+	w.End("VCALENDAR")
+	if err := w.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	want := "BEGIN:VCALENDAR\r\n" +
+		"DESCRIPTION:" + strings.Repeat("x", 63) + "\r\n" +
+		"DESCRIPTION:" + strings.Repeat("x", 63) + "\r\n x\r\n" +
+		"SUMMARY:" + strings.Repeat("a", 66) + "\r\n é\r\n" +
+		"DUE;VALUE=DATE:20240310\r\n" +
+		"END:VCALENDAR\r\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("Writer wrote %q, want %q", got, want)
+	}
+}
+
+func TestWriterFolding(t *testing.T) {
+	// Long enough to fold several times, with multi-byte runes to fall on folds
+	text := strings.Repeat("Réunion 会議 — ", 30)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Begin("VTODO")
+	w.Property("SUMMARY", Text(text))
+	w.End("VTODO")
+
+	out := buf.String()
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Fatalf("output %q doesn't end with CRLF", out)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	if len(lines) < 5 {
+		t.Fatalf("wrote %d lines, want the summary folded", len(lines))
+	}
+	for i, line := range lines {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %d is %d octets: %q", i+1, len(line), line)
+		}
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("line %d has a bare line break: %q", i+1, line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a UTF-8 sequence: %q", i+1, line)
+		}
+	}
+
+	c, err := Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := ParseText(c.Prop("SUMMARY").Value); got != text {
+		t.Fatalf("summary unfolded to %q, want %q", got, text)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"a, b; c", `a\, b\; c`},
+		{`C:\temp`, `C:\\temp`},
+		{"one\ntwo", `one\ntwo`},
+		{"tab\there", "tab\there"},
+		{"bell\a and\x7f del", "bell and del"},
+		{"bad \xff byte", "bad � byte"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.in); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got, want := TextList([]string{"home", "a,b", "c;d"}), `home,a\,b,c\;d`; got != want {
+		t.Errorf("TextList = %q, want %q", got, want)
+	}
+	for _, s := range []string{"a, b; c", `C:\temp`, "one\ntwo", `\n is not a newline`} {
+		if got := ParseText(Text(s)); got != s {
+			t.Errorf("ParseText(Text(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestDates(t *testing.T) {
+	paris := time.FixedZone("CET", 60*60)
+	at := time.Date(2024, 3, 10, 0, 30, 0, 0, paris)
+	if got, want := DateTime(at), "20240309T233000Z"; got != want {
+		t.Errorf("DateTime = %s, want %s", got, want)
+	}
+	if got, want := Date(at), "20240310"; got != want {
+		t.Errorf("Date = %s, want %s", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	// Bare LF endings, a folded line and quoted parameters
+	in := "BEGIN:VCALENDAR\n" +
+		"VERSION:2.0\n" +
+		"BEGIN:VTODO\n" +
+		"UID:task-1@todomaster\n" +
+		"SUMMARY:Call\\, then\n" +
+		"  write\n" +
+		"ATTENDEE;CN=\"Doe, Jo\";ROLE=REQ-PARTICIPANT,CHAIR:mailto:jo@example.com\n" +
+		"END:VTODO\n" +
+		"END:VCALENDAR\n"
+	c, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if c.Name != "VCALENDAR" || len(c.Children) != 1 || c.Prop("VERSION").Value != "2.0" {
+		t.Fatalf("Parse = %+v", c)
+	}
+	todo := c.Children[0]
+	if got := ParseText(todo.Prop("SUMMARY").Value); got != "Call, then write" {
+		t.Fatalf("summary = %q", got)
+	}
+	attendee := todo.Prop("ATTENDEE")
+	if attendee.Param("CN") != "Doe, Jo" || len(attendee.Params["ROLE"]) != 2 || attendee.Value != "mailto:jo@example.com" {
+		t.Fatalf("attendee = %+v", attendee)
+	}
+
+	for _, in := range []string{
+		"",
+		"VERSION:2.0\r\n",
+		"BEGIN:VCALENDAR\r\nEND:VTODO\r\n",
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\nBEGIN:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Component is a parsed component, such as a VCALENDAR or VTODO, with its
+// properties and nested components in the order they appeared.
+type Component struct {
+	Name     string
+	Props    []*Prop
+	Children []*Component
+}
+
+// Prop is a parsed property. Names are upper-cased; Value is left in the
+// property's value format, so TEXT values still need ParseText.
+type Prop struct {
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+// Prop returns the component's first property with the given name, or nil.
+func (c *Component) Prop(name string) *Prop {
+	for _, p := range c.Props {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// All returns all of the component's properties with the given name.
+func (c *Component) All(name string) []*Prop {
+	var props []*Prop
+	for _, p := range c.Props {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Param returns the first value of a parameter, or "" if it isn't set.
+func (p *Prop) Param(name string) string {
+	if values := p.Params[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Parse reads a single top-level component, usually a VCALENDAR. Folded lines
+// are unfolded and either CRLF or bare LF line endings are accepted.
+func Parse(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *Component
+	var stack []*Component
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if root != nil && len(stack) == 0 {
+			return nil, fmt.Errorf("line %d: content after the end of %s", i+1, root.Name)
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(prop.Value)}
+			if len(stack) == 0 {
+				root = c
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property outside of a component", i+1)
+			}
+			c := stack[len(stack)-1]
+			c.Props = append(c.Props, prop)
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("no component found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// unfold splits r into content lines, joining continuation lines, which start
+// with a space or tab, onto the line before.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseLine parses a content line: a name, any parameters and a value.
+func parseLine(line string) (*Prop, error) {
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return nil, errors.New("invalid content line")
+	}
+	prop := &Prop{Name: strings.ToUpper(line[:end])}
+	rest := line[end:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid parameter in %s", prop.Name)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var values []string
+		for {
+			var value string
+			if strings.HasPrefix(rest, `"`) {
+				closing := strings.IndexByte(rest[1:], '"')
+				if closing < 0 {
+					return nil, fmt.Errorf("unterminated quoted parameter in %s", prop.Name)
+				}
+				value, rest = rest[1:closing+1], rest[closing+2:]
+			} else {
+				n := strings.IndexAny(rest, ",;:")
+				if n < 0 {
+					return nil, fmt.Errorf("invalid parameter in %s", prop.Name)
+				}
+				value, rest = rest[:n], rest[n:]
+			}
+			values = append(values, value)
+			if !strings.HasPrefix(rest, ",") {
+				break
+			}
+			rest = rest[1:]
+		}
+
+		if prop.Params == nil {
+			prop.Params = make(map[string][]string)
+		}
+		prop.Params[name] = append(prop.Params[name], values...)
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return nil, fmt.Errorf("missing value in %s", prop.Name)
+	}
+	prop.Value = rest[1:]
+	return prop, nil
+}
+
+// ParseText unescapes a TEXT value.
+func ParseText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// ParseTextList splits a list of TEXT values, such as CATEGORIES, at its
+// unescaped commas and unescapes each value.
+func ParseTextList(value string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, ParseText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, ParseText(value[start:]))
+}
+
+// ParseDate parses a DATE or DATE-TIME property such as DUE. A DATE is returned
+// as midnight UTC with allDay set. A DATE-TIME is UTC when it ends in Z, in the
+// zone named by its TZID parameter if that zone is known, and otherwise a
+// floating time taken to be in loc.
+func ParseDate(p *Prop, loc *time.Location) (t time.Time, allDay bool, err error) {
+	value := p.Value
+	if strings.EqualFold(p.Param("VALUE"), "DATE") || len(value) == len("20060102") {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date in %s: %q", p.Name, value)
+		}
+		return t, true, nil
+	}
+
+	if utc, ok := strings.CutSuffix(value, "Z"); ok {
+		t, err = time.Parse("20060102T150405", utc)
+	} else {
+		if tzid := p.Param("TZID"); tzid != "" {
+			if zone, zoneErr := time.LoadLocation(strings.TrimPrefix(tzid, "/")); zoneErr == nil {
+				loc = zone
+			}
+		}
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date-time in %s: %q", p.Name, value)
+	}
+	return t.UTC(), false, nil
+}
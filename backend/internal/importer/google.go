@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type googleTaskLists struct {
+	Kind  string            `json:"kind"`
+	Items []*googleTaskList `json:"items"`
+}
+
+type googleTaskList struct {
+	Title string        `json:"title"`
+	Items []*googleTask `json:"items"`
+}
+
+type googleTask struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Notes     string `json:"notes"`
+	Status    string `json:"status"`
+	Due       string `json:"due"`
+	Completed string `json:"completed"`
+	Created   string `json:"created"`
+	Parent    string `json:"parent"`
+	Position  string `json:"position"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// readGoogleTasks reads the Tasks.json file of a Google Takeout archive. Each
+// task list becomes a list, tasks are ordered by position and deleted tasks are
+// left out. Google Tasks only keeps the day of a due date.
+func (r *reader) readGoogleTasks(data []byte) error {
+	var file googleTaskLists
+	if err := json.Unmarshal(trimBOM(data), &file); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if file.Kind != "tasks#taskLists" && file.Items == nil {
+		return errors.New("not a Google Tasks export: no task lists")
+	}
+
+	for _, list := range file.Items {
+		if list == nil {
+			continue
+		}
+		r.addList(list.Title)
+
+		var items []*googleTask
+		for _, item := range list.Items {
+			if item != nil && !item.Deleted {
+				items = append(items, item)
+			}
+		}
+		sort.SliceStable(items, func(i, j int) bool { return items[i].Position < items[j].Position })
+
+		// Top-level tasks first, so every subtask finds its task
+		tasks := make(map[string]*Task)
+		for _, item := range items {
+			if item.Parent != "" {
+				continue
+			}
+			task := &Task{
+				Ref:         item.ID,
+				List:        list.Title,
+				Text:        item.Title,
+				Completed:   item.Status == "completed",
+				CreatedAt:   parseTime(item.Created),
+				CompletedAt: parseTimePtr(item.Completed),
+			}
+			if item.Due != "" {
+				if due := parseTime(item.Due); !due.IsZero() {
+					task.Due, task.DueAllDay = allDay(due.UTC()), true
+				} else {
+					r.problem(item.ID, item.Title, fmt.Sprintf("due date %q was not understood and was not imported", item.Due))
+				}
+			}
+			if strings.TrimSpace(item.Notes) != "" {
+				r.problem(item.ID, item.Title, "notes were not imported")
+			}
+			if r.addTask(task) {
+				tasks[item.ID] = task
+			}
+		}
+		for _, item := range items {
+			if item.Parent == "" {
+				continue
+			}
+			task, ok := tasks[item.Parent]
+			if !ok {
+				r.problem(item.ID, item.Title, "subtask was not imported because its task was not")
+				continue
+			}
+			if item.Due != "" || strings.TrimSpace(item.Notes) != "" {
+				r.problem(item.ID, item.Title, "due dates and notes of subtasks were not imported")
+			}
+			r.addSubtask(task, item.ID, &Subtask{
+				Text:      item.Title,
+				Completed: item.Status == "completed",
+				CreatedAt: parseTime(item.Created),
+			})
+		}
+	}
+	return nil
+}
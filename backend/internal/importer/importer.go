@@ -0,0 +1,179 @@
+// Package importer reads the export files of other to-do apps:
+//
+//	todoist         a Todoist project CSV or a Todoist JSON backup
+//	microsoft-todo  Microsoft To Do or Outlook task lists as Microsoft Graph JSON
+//	google-tasks    the Tasks.json file of a Google Takeout archive
+//
+// Read turns a file into lists of tasks with their subtasks. Projects and task
+// lists become lists, and sections and labels become tags. Subtasks nested more
+// than one level deep are added to their top-level task. What a file holds that
+// tasks cannot, such as notes and recurring due dates, is left out and reported
+// as a problem with the item it belongs to.
+package importer
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Sources Read accepts.
+const (
+	SourceTodoist       = "todoist"
+	SourceMicrosoftToDo = "microsoft-todo"
+	SourceGoogleTasks   = "google-tasks"
+)
+
+// ErrUnknownSource is returned by Read for a source it does not know.
+var ErrUnknownSource = errors.New("unknown source")
+
+// Options is the context a file is read in.
+type Options struct {
+	// Now is the current time, which relative dates count from.
+	Now time.Time
+	// Location is the user's time zone, for dates and times written without
+	// one. Nil means UTC.
+	Location *time.Location
+	// List is the title of the list the tasks of a Todoist CSV file go into,
+	// since the file does not name its project.
+	List string
+}
+
+// Result is what was read from a file.
+type Result struct {
+	// Lists are the titles of the lists in the file, in order, including
+	// empty ones.
+	Lists    []string
+	Tasks    []*Task
+	Problems []Problem
+}
+
+// Task is a task read from a file.
+type Task struct {
+	// Ref identifies the task in the file, such as its ID or row.
+	Ref string
+	// List is the title of the task's list, or empty for the inbox.
+	List      string
+	Text      string
+	Completed bool
+	Important bool
+	// Due is the due date, or nil. An all-day date is midnight UTC of its day.
+	Due         *time.Time
+	DueAllDay   bool
+	Tags        []string
+	Subtasks    []*Subtask
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+}
+
+// Subtask is a subtask read from a file.
+type Subtask struct {
+	Text      string
+	Completed bool
+	CreatedAt time.Time
+}
+
+// Problem is something in a file that was left out or changed.
+type Problem struct {
+	// Ref identifies the item in the file, and Text is its title if it has one.
+	Ref     string
+	Text    string
+	Message string
+}
+
+// Read reads an export file from source.
+func Read(source string, data []byte, opts Options) (*Result, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	r := &reader{opts: opts, lists: make(map[string]bool)}
+	var err error
+	switch source {
+	case SourceTodoist:
+		if strings.HasPrefix(strings.TrimSpace(string(trimBOM(data))), "{") {
+			err = r.readTodoistJSON(data)
+		} else {
+			err = r.readTodoistCSV(data)
+		}
+	case SourceMicrosoftToDo:
+		err = r.readMicrosoftToDo(data)
+	case SourceGoogleTasks:
+		err = r.readGoogleTasks(data)
+	default:
+		return nil, ErrUnknownSource
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r.result, nil
+}
+
+// reader collects what is read from a file.
+type reader struct {
+	opts   Options
+	result Result
+	lists  map[string]bool
+}
+
+// addList adds a list title, once.
+func (r *reader) addList(title string) {
+	if title != "" && !r.lists[title] {
+		r.lists[title] = true
+		r.result.Lists = append(r.result.Lists, title)
+	}
+}
+
+// addTask adds a task, in its list. A task without a title is left out.
+func (r *reader) addTask(task *Task) bool {
+	task.Text = strings.TrimSpace(task.Text)
+	if task.Text == "" {
+		r.problem(task.Ref, "", "task has no title and was not imported")
+		return false
+	}
+	r.addList(task.List)
+	r.result.Tasks = append(r.result.Tasks, task)
+	return true
+}
+
+// addSubtask adds a subtask to a task. A subtask without a title is left out.
+func (r *reader) addSubtask(task *Task, ref string, subtask *Subtask) {
+	subtask.Text = strings.TrimSpace(subtask.Text)
+	if subtask.Text == "" {
+		r.problem(ref, "", "subtask has no title and was not imported")
+		return
+	}
+	task.Subtasks = append(task.Subtasks, subtask)
+}
+
+func (r *reader) problem(ref, text, message string) {
+	r.result.Problems = append(r.result.Problems, Problem{Ref: ref, Text: text, Message: message})
+}
+
+// addTag adds a tag to a task, once, ignoring blank names.
+func addTag(task *Task, tag string) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return
+	}
+	for _, t := range task.Tags {
+		if t == tag {
+			return
+		}
+	}
+	task.Tags = append(task.Tags, tag)
+}
+
+// allDay returns midnight UTC of the day of t.
+func allDay(t time.Time) *time.Time {
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return &day
+}
+
+// trimBOM strips a UTF-8 byte order mark from the start of a file.
+func trimBOM(data []byte) []byte {
+	if len(data) >= 3 && data[0] == 0xef && data[1] == 0xbb && data[2] == 0xbf {
+		return data[3:]
+	}
+	return data
+}
@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+// wantResult fails the test unless reading data from source gives want,
+// compared as JSON so that a difference shows where it is.
+func wantResult(t *testing.T, source, data string, opts Options, want *Result) {
+	t.Helper()
+	got, err := Read(source, []byte(data), opts)
+	if err != nil {
+		t.Fatalf("Read(%s): %v", source, err)
+	}
+	gotJSON, _ := json.MarshalIndent(got, "", "  ")
+	wantJSON, _ := json.MarshalIndent(want, "", "  ")
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("Read(%s) = %s\nwant %s", source, gotJSON, wantJSON)
+	}
+}
+
+func TestReadTodoistCSV(t *testing.T) {
+	data := "\xef\xbb\xbfTYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,DATE\n" +
+		"task,Pay rent @home @bills,,1,1,2024-03-08\n" +
+		"task,Find the lease,,4,2,\n" +
+		"note,Landlord is away,,,,\n" +
+		"section,Errands,,,,\n" +
+		"task,Post letters,Stamps in the drawer,4,1,every monday\n" +
+		"task,,,4,1,\n" +
+		"task,Book dentist,,4,1,sometime\n"
+	wantResult(t, SourceTodoist, data, Options{Now: time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC), List: "Home"}, &Result{
+		Lists: []string{"Home"},
+		Tasks: []*Task{
+			{Ref: "row 2", List: "Home", Text: "Pay rent", Important: true, Due: date("2024-03-08T00:00:00Z"), DueAllDay: true,
+				Tags: []string{"home", "bills"}, Subtasks: []*Subtask{{Text: "Find the lease"}}},
+			{Ref: "row 6", List: "Home", Text: "Post letters", Tags: []string{"Errands"}},
+			{Ref: "row 8", List: "Home", Text: "Book dentist", Tags: []string{"Errands"}},
+		},
+		Problems: []Problem{
+			{Ref: "row 4", Message: "comment was not imported"},
+			{Ref: "row 6", Text: "Post letters", Message: `recurring due date "every monday" was not imported`},
+			{Ref: "row 6", Text: "Post letters", Message: "description was not imported"},
+			{Ref: "row 7", Message: "task has no title and was not imported"},
+			{Ref: "row 8", Text: "Book dentist", Message: `due date "sometime" was not understood and was not imported`},
+		},
+	})
+}
+
+func TestReadTodoistJSON(t *testing.T) {
+	data := `{
+		"projects": [
+			{"id": "1", "name": "Inbox", "inbox_project": true},
+			{"id": 2, "name": "Work"},
+			{"id": "3", "name": "Someday"}
+		],
+		"sections": [{"id": "10", "name": "Q3"}],
+		"items": [
+			{"id": "101", "project_id": "2", "section_id": "10", "content": "Write report", "priority": 4,
+			 "labels": ["office"], "child_order": 2, "added_at": "2024-03-01T08:00:00Z",
+			 "due": {"date": "2024-03-08T17:00:00", "is_recurring": true, "string": "every friday at 5pm"}},
+			{"id": "102", "project_id": "2", "parent_id": "101", "content": "Draft", "checked": true, "child_order": 1},
+			{"id": "103", "project_id": "2", "parent_id": "102", "content": "Outline", "labels": ["office"], "child_order": 1},
+			{"id": "104", "project_id": "2", "content": "File expenses", "child_order": 1, "description": "Receipts",
+			 "checked": true, "completed_at": "2024-03-02T10:00:00Z", "due": {"date": "2024-03-04"}},
+			{"id": "105", "project_id": "1", "content": "Old", "is_deleted": true},
+			{"id": "106", "project_id": "1", "content": "Call mum", "due": {"date": "2024-03-05T18:00:00Z"}},
+			{"id": "107", "project_id": "1", "content": "Plan", "due": {"date": "soon"}}
+		]
+	}`
+	berlin := time.FixedZone("CET", 3600)
+	wantResult(t, SourceTodoist, data, Options{Location: berlin}, &Result{
+		Lists: []string{"Work", "Someday"},
+		Tasks: []*Task{
+			{Ref: "item 106", Text: "Call mum", Due: date("2024-03-05T18:00:00Z")},
+			{Ref: "item 107", Text: "Plan"},
+			{Ref: "item 104", List: "Work", Text: "File expenses", Completed: true, Due: date("2024-03-04T00:00:00Z"), DueAllDay: true,
+				CompletedAt: date("2024-03-02T10:00:00Z")},
+			{Ref: "item 101", List: "Work", Text: "Write report", Important: true, Due: date("2024-03-08T16:00:00Z"),
+				Tags: []string{"Q3", "office"}, CreatedAt: *date("2024-03-01T08:00:00Z"),
+				Subtasks: []*Subtask{{Text: "Draft", Completed: true}, {Text: "Outline"}}},
+		},
+		Problems: []Problem{
+			{Ref: "item 107", Text: "Plan", Message: `due date "soon" was not understood and was not imported`},
+			{Ref: "item 104", Text: "File expenses", Message: "description was not imported"},
+			{Ref: "item 101", Text: "Write report", Message: `recurrence "every friday at 5pm" was not imported; the next due date was`},
+			{Ref: "item 103", Text: "Outline", Message: "labels and due dates of subtasks were not imported"},
+		},
+	})
+}
+
+func TestReadMicrosoftToDo(t *testing.T) {
+	data := `{"value": [
+		{"displayName": "Tasks", "wellknownListName": "defaultList", "tasks": [
+			{"id": "a1", "title": "Renew passport", "importance": "high", "status": "notStarted",
+			 "createdDateTime": "2024-03-01T08:00:00Z",
+			 "dueDateTime": {"dateTime": "2024-04-01T00:00:00.0000000", "timeZone": "UTC"},
+			 "categories": ["Travel", "Travel", " "],
+			 "body": {"content": "<html><body><p></p></body></html>", "contentType": "html"},
+			 "checklistItems": [
+				{"displayName": "Photos", "isChecked": true, "createdDateTime": "2024-03-01T08:05:00Z"},
+				{"displayName": " "}
+			 ]}
+		]},
+		{"displayName": "Groceries", "tasks": []},
+		{"displayName": "Home", "tasks": [
+			{"title": "Fix tap", "status": "completed",
+			 "completedDateTime": {"dateTime": "2024-03-03T12:30:00", "timeZone": "Nowhere Standard Time"},
+			 "body": {"content": "Washer size 3/4", "contentType": "text"},
+			 "recurrence": {"pattern": {"type": "weekly"}}},
+			{"id": "c3", "title": "Paint", "dueDateTime": {"dateTime": "next week", "timeZone": "UTC"}}
+		]}
+	]}`
+	wantResult(t, SourceMicrosoftToDo, data, Options{}, &Result{
+		Lists: []string{"Groceries", "Home"},
+		Tasks: []*Task{
+			{Ref: "a1", Text: "Renew passport", Important: true, Due: date("2024-04-01T00:00:00Z"), DueAllDay: true,
+				Tags: []string{"Travel"}, CreatedAt: *date("2024-03-01T08:00:00Z"),
+				Subtasks: []*Subtask{{Text: "Photos", Completed: true, CreatedAt: *date("2024-03-01T08:05:00Z")}}},
+			{Ref: "lists[2].tasks[0]", List: "Home", Text: "Fix tap", Completed: true, CompletedAt: date("2024-03-03T12:30:00Z")},
+			{Ref: "c3", List: "Home", Text: "Paint"},
+		},
+		Problems: []Problem{
+			{Ref: "a1.checklistItems[1]", Message: "subtask has no title and was not imported"},
+			{Ref: "lists[2].tasks[0]", Text: "Fix tap", Message: "notes were not imported"},
+			{Ref: "lists[2].tasks[0]", Text: "Fix tap", Message: "recurrence was not imported"},
+			{Ref: "c3", Text: "Paint", Message: `due date "next week" was not understood and was not imported`},
+		},
+	})
+
+	// A bare array of lists is read too
+	wantResult(t, SourceMicrosoftToDo, `[{"displayName": "Home", "tasks": [{"id": "b1", "title": "Fix tap"}]}]`, Options{}, &Result{
+		Lists: []string{"Home"},
+		Tasks: []*Task{{Ref: "b1", List: "Home", Text: "Fix tap"}},
+	})
+}
+
+func TestReadGoogleTasks(t *testing.T) {
+	data := `{"kind": "tasks#taskLists", "items": [
+		{"title": "My Tasks", "items": [
+			{"id": "g2", "title": "Pack", "position": "00000000000000000002", "status": "needsAction",
+			 "due": "2024-03-09T00:00:00.000Z", "notes": "Check the weather"},
+			{"id": "g3", "title": "Socks", "parent": "g2", "position": "00000000000000000001", "status": "completed",
+			 "due": "2024-03-08T00:00:00.000Z", "created": "2024-03-01T09:00:00.000Z"},
+			{"id": "g1", "title": "Book hotel", "position": "00000000000000000001", "status": "completed",
+			 "created": "2024-03-01T08:00:00.000Z", "completed": "2024-03-02T08:00:00.000Z"},
+			{"id": "g4", "title": "Cancelled", "position": "00000000000000000003", "deleted": true},
+			{"id": "g5", "title": "Orphan", "parent": "g4", "position": "00000000000000000001"},
+			{"id": "g6", "title": "Someday", "position": "00000000000000000004", "due": "whenever"}
+		]},
+		{"title": "Empty"}
+	]}`
+	wantResult(t, SourceGoogleTasks, data, Options{}, &Result{
+		Lists: []string{"My Tasks", "Empty"},
+		Tasks: []*Task{
+			{Ref: "g1", List: "My Tasks", Text: "Book hotel", Completed: true, CreatedAt: *date("2024-03-01T08:00:00Z"),
+				CompletedAt: date("2024-03-02T08:00:00Z")},
+			{Ref: "g2", List: "My Tasks", Text: "Pack", Due: date("2024-03-09T00:00:00Z"), DueAllDay: true,
+				Subtasks: []*Subtask{{Text: "Socks", Completed: true, CreatedAt: *date("2024-03-01T09:00:00Z")}}},
+			{Ref: "g6", List: "My Tasks", Text: "Someday"},
+		},
+		Problems: []Problem{
+			{Ref: "g2", Text: "Pack", Message: "notes were not imported"},
+			{Ref: "g6", Text: "Someday", Message: `due date "whenever" was not understood and was not imported`},
+			{Ref: "g3", Text: "Socks", Message: "due dates and notes of subtasks were not imported"},
+			{Ref: "g5", Text: "Orphan", Message: "subtask was not imported because its task was not"},
+		},
+	})
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		source, data string
+		// want is the error, or its start if it ends in a space
+		want string
+	}{
+		{"evernote", "{}", "unknown source"},
+		{SourceTodoist, "", "invalid CSV: no header row"},
+		{SourceTodoist, "CONTENT\nPay rent\n", "invalid CSV: no TYPE column"},
+		{SourceTodoist, `{"labels": []}`, "not a Todoist backup: no projects or items"},
+		{SourceTodoist, `{"items": {}}`, "invalid JSON: "},
+		{SourceMicrosoftToDo, `{"@odata.context": "x"}`, "not a Microsoft To Do export: no lists"},
+		{SourceMicrosoftToDo, `[{"displayName": 1}]`, "invalid JSON: "},
+		{SourceGoogleTasks, `{"kind": "tasks#task"}`, "not a Google Tasks export: no task lists"},
+		{SourceGoogleTasks, `[]`, "invalid JSON: "},
+	} {
+		_, err := Read(tt.source, []byte(tt.data), Options{})
+		if err == nil || err.Error() != tt.want && !(strings.HasSuffix(tt.want, " ") && strings.HasPrefix(err.Error(), tt.want)) {
+			t.Errorf("Read(%s, %q) error = %v, want %q", tt.source, tt.data, err, tt.want)
+		}
+	}
+}
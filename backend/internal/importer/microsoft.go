@@ -0,0 +1,154 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type graphList struct {
+	DisplayName       string       `json:"displayName"`
+	WellknownListName string       `json:"wellknownListName"`
+	Tasks             []*graphTask `json:"tasks"`
+}
+
+type graphTask struct {
+	ID                string            `json:"id"`
+	Title             string            `json:"title"`
+	Status            string            `json:"status"`
+	Importance        string            `json:"importance"`
+	Body              *graphBody        `json:"body"`
+	Categories        []string          `json:"categories"`
+	DueDateTime       *graphDateTime    `json:"dueDateTime"`
+	CompletedDateTime *graphDateTime    `json:"completedDateTime"`
+	CreatedDateTime   string            `json:"createdDateTime"`
+	Recurrence        json.RawMessage   `json:"recurrence"`
+	ChecklistItems    []*graphChecklist `json:"checklistItems"`
+}
+
+type graphBody struct {
+	Content     string `json:"content"`
+	ContentType string `json:"contentType"`
+}
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphChecklist struct {
+	DisplayName     string `json:"displayName"`
+	IsChecked       bool   `json:"isChecked"`
+	CreatedDateTime string `json:"createdDateTime"`
+}
+
+// htmlTag matches an HTML tag in a task body.
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// readMicrosoftToDo reads Microsoft To Do or Outlook task lists in the form the
+// Microsoft Graph API returns todoTaskList resources, each with its tasks
+// expanded: a JSON array of lists, or an object holding them in "lists" or
+// "value". The default Tasks list goes into no list, categories become tags and
+// checklist items become subtasks.
+func (r *reader) readMicrosoftToDo(data []byte) error {
+	data = trimBOM(data)
+	var lists []*graphList
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		if err := json.Unmarshal(data, &lists); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		var wrapper struct {
+			Lists []*graphList `json:"lists"`
+			Value []*graphList `json:"value"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		if wrapper.Lists == nil && wrapper.Value == nil {
+			return errors.New("not a Microsoft To Do export: no lists")
+		}
+		lists = append(wrapper.Lists, wrapper.Value...)
+	}
+
+	for i, list := range lists {
+		if list == nil {
+			continue
+		}
+		title := list.DisplayName
+		if list.WellknownListName == "defaultList" {
+			title = ""
+		}
+		r.addList(title)
+		for j, item := range list.Tasks {
+			if item == nil {
+				continue
+			}
+			ref := item.ID
+			if ref == "" {
+				ref = fmt.Sprintf("lists[%d].tasks[%d]", i, j)
+			}
+			task := &Task{
+				Ref:       ref,
+				List:      title,
+				Text:      item.Title,
+				Completed: item.Status == "completed",
+				Important: item.Importance == "high",
+				CreatedAt: parseTime(item.CreatedDateTime),
+			}
+			if task.Completed && item.CompletedDateTime != nil {
+				if t, ok := item.CompletedDateTime.time(); ok {
+					task.CompletedAt = &t
+				}
+			}
+			if item.DueDateTime != nil {
+				// To Do due dates are days, stored as midnight in a time zone
+				if t, ok := item.DueDateTime.time(); ok {
+					task.Due, task.DueAllDay = allDay(t), true
+				} else {
+					r.problem(ref, item.Title, fmt.Sprintf("due date %q was not understood and was not imported", item.DueDateTime.DateTime))
+				}
+			}
+			for _, category := range item.Categories {
+				addTag(task, category)
+			}
+			if item.Body != nil && strings.TrimSpace(htmlTag.ReplaceAllString(item.Body.Content, "")) != "" {
+				r.problem(ref, item.Title, "notes were not imported")
+			}
+			if len(item.Recurrence) > 0 && string(item.Recurrence) != "null" {
+				r.problem(ref, item.Title, "recurrence was not imported")
+			}
+			if !r.addTask(task) {
+				continue
+			}
+			for k, checklist := range item.ChecklistItems {
+				if checklist == nil {
+					continue
+				}
+				r.addSubtask(task, fmt.Sprintf("%s.checklistItems[%d]", ref, k), &Subtask{
+					Text:      checklist.DisplayName,
+					Completed: checklist.IsChecked,
+					CreatedAt: parseTime(checklist.CreatedDateTime),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// time returns a Graph dateTimeTimeZone as a time. A time zone the server does
+// not know is read as UTC.
+func (d *graphDateTime) time() (time.Time, bool) {
+	loc, err := time.LoadLocation(d.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", d.DateTime, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,357 @@
+package importer
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/todomaster-2010/backend/internal/quickadd"
+)
+
+// defaultTodoistList is the list a Todoist CSV file's tasks go into when
+// Options.List is empty.
+const defaultTodoistList = "Todoist"
+
+// readTodoistCSV reads a project exported from Todoist as CSV. Rows have a TYPE
+// of task, section or note; a task's INDENT nests it under the task before it,
+// its PRIORITY is 1 for the highest and its labels are @words in its CONTENT.
+// DATE holds the due date as typed, which is read like a quick-add date.
+func (r *reader) readTodoistCSV(data []byte) error {
+	csvReader := csv.NewReader(bytes.NewReader(trimBOM(data)))
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return errors.New("invalid CSV: no header row")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["TYPE"]; !ok {
+		return errors.New("invalid CSV: no TYPE column")
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return errors.New("invalid CSV: no CONTENT column")
+	}
+
+	list := r.opts.List
+	if list == "" {
+		list = defaultTodoistList
+	}
+	r.addList(list)
+
+	var section string
+	var current *Task
+	for i, record := range records[1:] {
+		field := func(name string) string {
+			if j, ok := columns[name]; ok && j < len(record) {
+				return strings.TrimSpace(record[j])
+			}
+			return ""
+		}
+		ref := fmt.Sprintf("row %d", i+2)
+
+		switch kind := strings.ToLower(field("TYPE")); kind {
+		case "":
+		case "section":
+			section = field("CONTENT")
+			current = nil
+		case "note":
+			r.problem(ref, "", "comment was not imported")
+		case "task":
+			text, labels := todoistLabels(field("CONTENT"))
+			indent, _ := strconv.Atoi(field("INDENT"))
+			if indent > 1 && current != nil {
+				if len(labels) > 0 || field("DATE") != "" {
+					r.problem(ref, text, "labels and due dates of subtasks were not imported")
+				}
+				r.addSubtask(current, ref, &Subtask{Text: text})
+				continue
+			}
+
+			task := &Task{Ref: ref, List: list, Text: text, Important: field("PRIORITY") == "1"}
+			addTag(task, section)
+			for _, label := range labels {
+				addTag(task, label)
+			}
+			r.todoistCSVDate(task, field("DATE"))
+			if field("DESCRIPTION") != "" {
+				r.problem(ref, text, "description was not imported")
+			}
+			current = nil
+			if r.addTask(task) {
+				current = task
+			}
+		default:
+			r.problem(ref, "", fmt.Sprintf("row of type %q was not imported", kind))
+		}
+	}
+	return nil
+}
+
+// todoistLabels takes the @labels out of a task's content.
+func todoistLabels(content string) (string, []string) {
+	var words, labels []string
+	for _, word := range strings.Fields(content) {
+		if len(word) > 1 && word[0] == '@' {
+			labels = append(labels, word[1:])
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), labels
+}
+
+// todoistCSVDate sets a task's due date from a Todoist CSV DATE, reporting one
+// it cannot read.
+func (r *reader) todoistCSVDate(task *Task, date string) {
+	if date == "" {
+		return
+	}
+	if strings.HasPrefix(strings.ToLower(date), "every") {
+		r.problem(task.Ref, task.Text, fmt.Sprintf("recurring due date %q was not imported", date))
+		return
+	}
+	result := quickadd.Parse(date, quickadd.Options{Now: r.opts.Now, Location: r.opts.Location})
+	if result.Due == nil || result.Text != "" {
+		r.problem(task.Ref, task.Text, fmt.Sprintf("due date %q was not understood and was not imported", date))
+		return
+	}
+	task.DueAllDay = result.DueAllDay
+	if result.DueAllDay {
+		task.Due = allDay(*result.Due)
+	} else {
+		due := result.Due.UTC()
+		task.Due = &due
+	}
+}
+
+// todoistID is a Todoist ID, which older exports write as a number and newer
+// ones as a string.
+type todoistID string
+
+func (id *todoistID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*id = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = todoistID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = todoistID(n.String())
+	return nil
+}
+
+type todoistBackup struct {
+	Projects []todoistProject `json:"projects"`
+	Sections []todoistSection `json:"sections"`
+	Items    []*todoistItem   `json:"items"`
+	// Tasks is what REST API exports call items.
+	Tasks []*todoistItem `json:"tasks"`
+}
+
+type todoistProject struct {
+	ID             todoistID `json:"id"`
+	Name           string    `json:"name"`
+	InboxProject   bool      `json:"inbox_project"`
+	IsInboxProject bool      `json:"is_inbox_project"`
+}
+
+type todoistSection struct {
+	ID   todoistID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type todoistItem struct {
+	ID          todoistID   `json:"id"`
+	ProjectID   todoistID   `json:"project_id"`
+	SectionID   todoistID   `json:"section_id"`
+	ParentID    todoistID   `json:"parent_id"`
+	Content     string      `json:"content"`
+	Description string      `json:"description"`
+	Priority    int         `json:"priority"`
+	Due         *todoistDue `json:"due"`
+	Labels      []string    `json:"labels"`
+	Checked     bool        `json:"checked"`
+	IsCompleted bool        `json:"is_completed"`
+	IsDeleted   bool        `json:"is_deleted"`
+	CompletedAt string      `json:"completed_at"`
+	AddedAt     string      `json:"added_at"`
+	CreatedAt   string      `json:"created_at"`
+	ChildOrder  int         `json:"child_order"`
+	Order       int         `json:"order"`
+}
+
+type todoistDue struct {
+	Date        string `json:"date"`
+	IsRecurring bool   `json:"is_recurring"`
+	String      string `json:"string"`
+}
+
+// readTodoistJSON reads a Todoist JSON backup of projects, sections and items,
+// as the Sync and REST APIs return them. Items in the inbox project go into no
+// list, and an item's priority is 4 for the highest.
+func (r *reader) readTodoistJSON(data []byte) error {
+	var backup todoistBackup
+	if err := json.Unmarshal(trimBOM(data), &backup); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	items := append(backup.Items, backup.Tasks...)
+	if backup.Projects == nil && items == nil {
+		return errors.New("not a Todoist backup: no projects or items")
+	}
+
+	projects := make(map[todoistID]string)
+	projectOrder := make(map[todoistID]int)
+	for i, project := range backup.Projects {
+		projectOrder[project.ID] = i
+		if project.InboxProject || project.IsInboxProject {
+			continue
+		}
+		projects[project.ID] = project.Name
+		r.addList(project.Name)
+	}
+	sections := make(map[todoistID]string)
+	for _, section := range backup.Sections {
+		sections[section.ID] = section.Name
+	}
+
+	byID := make(map[todoistID]*todoistItem)
+	var live []*todoistItem
+	for _, item := range items {
+		if item != nil && !item.IsDeleted {
+			byID[item.ID] = item
+			live = append(live, item)
+		}
+	}
+	sort.SliceStable(live, func(i, j int) bool {
+		if pi, pj := projectOrder[live[i].ProjectID], projectOrder[live[j].ProjectID]; pi != pj {
+			return pi < pj
+		}
+		return cmp.Or(live[i].ChildOrder, live[i].Order) < cmp.Or(live[j].ChildOrder, live[j].Order)
+	})
+
+	// Top-level items first, so every subtask finds its task
+	tasks := make(map[todoistID]*Task)
+	var children []*todoistItem
+	for _, item := range live {
+		if root := todoistRoot(item, byID); root != item {
+			children = append(children, item)
+			continue
+		}
+		ref := "item " + string(item.ID)
+		task := &Task{
+			Ref:         ref,
+			List:        projects[item.ProjectID],
+			Text:        item.Content,
+			Completed:   item.Checked || item.IsCompleted,
+			Important:   item.Priority == 4,
+			CreatedAt:   parseTime(cmp.Or(item.AddedAt, item.CreatedAt)),
+			CompletedAt: parseTimePtr(item.CompletedAt),
+		}
+		addTag(task, sections[item.SectionID])
+		for _, label := range item.Labels {
+			addTag(task, label)
+		}
+		if item.Due != nil {
+			r.todoistJSONDue(task, item.Due)
+		}
+		if strings.TrimSpace(item.Description) != "" {
+			r.problem(ref, item.Content, "description was not imported")
+		}
+		if r.addTask(task) {
+			tasks[item.ID] = task
+		}
+	}
+	for _, item := range children {
+		ref := "item " + string(item.ID)
+		task, ok := tasks[todoistRoot(item, byID).ID]
+		if !ok {
+			r.problem(ref, item.Content, "subtask was not imported because its task was not")
+			continue
+		}
+		if len(item.Labels) > 0 || item.Due != nil {
+			r.problem(ref, item.Content, "labels and due dates of subtasks were not imported")
+		}
+		r.addSubtask(task, ref, &Subtask{
+			Text:      item.Content,
+			Completed: item.Checked || item.IsCompleted,
+			CreatedAt: parseTime(cmp.Or(item.AddedAt, item.CreatedAt)),
+		})
+	}
+	return nil
+}
+
+// todoistRoot returns the top-level item an item is nested under, or the item
+// itself if it is not nested under one in the backup.
+func todoistRoot(item *todoistItem, byID map[todoistID]*todoistItem) *todoistItem {
+	root := item
+	for i := 0; i < len(byID) && root.ParentID != ""; i++ {
+		parent, ok := byID[root.ParentID]
+		if !ok {
+			break
+		}
+		root = parent
+	}
+	return root
+}
+
+// todoistJSONDue sets a task's due date from a Todoist due object. Its date is
+// a day, a floating date and time, or a time in UTC. A recurring due date keeps
+// its next occurrence.
+func (r *reader) todoistJSONDue(task *Task, due *todoistDue) {
+	if due.IsRecurring {
+		r.problem(task.Ref, task.Text, fmt.Sprintf("recurrence %q was not imported; the next due date was", due.String))
+	}
+	if day, err := time.Parse("2006-01-02", due.Date); err == nil {
+		task.Due, task.DueAllDay = &day, true
+		return
+	}
+	if t, err := time.Parse(time.RFC3339, due.Date); err == nil {
+		t = t.UTC()
+		task.Due = &t
+		return
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", due.Date, r.opts.Location); err == nil {
+		t = t.UTC()
+		task.Due = &t
+		return
+	}
+	r.problem(task.Ref, task.Text, fmt.Sprintf("due date %q was not understood and was not imported", due.Date))
+}
+
+// parseTime parses an RFC 3339 time, returning the zero time if s is not one.
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseTimePtr parses an RFC 3339 time, returning nil if s is not one.
+func parseTimePtr(s string) *time.Time {
+	t := parseTime(s)
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,213 @@
+// Package markdown reads and writes tasks as GitHub-flavored Markdown task
+// lists, such as
+//
+//	## Action items
+//	- [ ] Send the notes #team
+//	  - [x] Draft
+//	  - [ ] Review
+//
+// Parse reads the checklist items in a document and ignores everything else. An
+// item indented under a task's item is one of its subtasks, however deeply it is
+// nested; any other item is a task. Items may use -, *, + or a number as their
+// marker, and [x] or [X] marks one completed. A task takes the #tags in its text
+// and the text of the ATX heading it falls under as tags; a word starting \#
+// keeps its # in the text instead. Punctuation at the end of a #tag is left out
+// of it, unless it is escaped with a backslash as in #wow\!. A line that is neither blank nor indented
+// under the current task ends it.
+//
+// Format writes tasks as items with their tags after the text, escaping the
+// punctuation they end in, and their subtasks indented under them, so a document it wrote reads back as the same tasks and
+// formats to the same document.
+package markdown
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Task is a task written as a top-level checklist item.
+type Task struct {
+	Text      string
+	Completed bool
+	Tags      []string
+	Subtasks  []Subtask
+}
+
+// Subtask is a subtask written as a checklist item nested under its task.
+type Subtask struct {
+	Text      string
+	Completed bool
+}
+
+// Options controls Parse.
+type Options struct {
+	// IgnoreHeadings stops headings from being added to tasks as tags.
+	IgnoreHeadings bool
+}
+
+var (
+	// item matches a checklist item: its indent, completion mark and text.
+	item = regexp.MustCompile(`^([ \t]*)(?:[-*+]|[0-9]{1,9}[.)])[ \t]+\[([ xX])\](?:[ \t]+(.*))?$`)
+	// heading matches an ATX heading and its text, without closing #s.
+	heading = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+	// escapedHash matches a word starting with a # escaped by a backslash.
+	escapedHash = regexp.MustCompile(`^\\+#`)
+)
+
+// trailingPunctuation is stripped from the end of #tags.
+const trailingPunctuation = ",.;:!?"
+
+// Parse reads the tasks in a document.
+func Parse(doc string, opts Options) []Task {
+	var tasks []Task
+	var section string
+	current := -1 // indent of the current task, or -1 if there is none
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := heading.FindStringSubmatch(line); m != nil {
+			section = strings.TrimSpace(m[1])
+			current = -1
+			continue
+		}
+
+		m := item.FindStringSubmatch(line)
+		indent := width(line)
+		if current >= 0 && indent > current {
+			if m != nil && strings.TrimSpace(m[3]) != "" {
+				task := &tasks[len(tasks)-1]
+				task.Subtasks = append(task.Subtasks, Subtask{
+					Text:      strings.Join(strings.Fields(m[3]), " "),
+					Completed: m[2] != " ",
+				})
+			}
+			continue
+		}
+		current = -1
+		if m == nil || strings.TrimSpace(m[3]) == "" {
+			continue
+		}
+
+		task := parseTask(m[3])
+		task.Completed = m[2] != " "
+		if section != "" && !opts.IgnoreHeadings && !slices.Contains(task.Tags, section) {
+			task.Tags = append(task.Tags, section)
+		}
+		tasks = append(tasks, task)
+		current = indent
+	}
+	return tasks
+}
+
+// parseTask reads a task item's text, taking out its #tags.
+func parseTask(text string) Task {
+	var task Task
+	var rest []string
+	words := strings.Fields(text)
+	for _, word := range words {
+		if len(word) > 1 && word[0] == '#' {
+			if tag := parseTag(word[1:]); tag != "" {
+				if !slices.Contains(task.Tags, tag) {
+					task.Tags = append(task.Tags, tag)
+				}
+				continue
+			}
+		}
+		if escapedHash.MatchString(word) {
+			word = word[1:]
+		}
+		rest = append(rest, word)
+	}
+	if len(rest) == 0 {
+		// Nothing but tags: keep them as the text
+		return Task{Text: strings.Join(words, " ")}
+	}
+	task.Text = strings.Join(rest, " ")
+	return task
+}
+
+// parseTag returns the tag written as name after a #, without the punctuation
+// it ends in unless that is escaped.
+func parseTag(name string) string {
+	punctuation := func(i int) bool {
+		return i >= 0 && strings.IndexByte(trailingPunctuation, name[i]) >= 0
+	}
+	escaped := func(i int) bool {
+		return i >= 1 && name[i-1] == '\\'
+	}
+	for n := len(name); punctuation(n-1) && !escaped(n-1); n = len(name) {
+		name = name[:n-1]
+	}
+	var tail string
+	for n := len(name); punctuation(n-1) && escaped(n-1); n = len(name) {
+		tail = name[n-1:n] + tail
+		name = name[:n-2]
+	}
+	return name + tail
+}
+
+// formatTag writes a tag after a #, escaping the punctuation it ends in so that
+// parseTag keeps it.
+func formatTag(tag string) string {
+	name := Name(tag)
+	n := len(strings.TrimRight(name, trailingPunctuation))
+	var b strings.Builder
+	b.WriteString(name[:n])
+	for i := n; i < len(name); i++ {
+		b.WriteString(`\` + name[i:i+1])
+	}
+	return b.String()
+}
+
+// Format writes tasks as a checklist.
+func Format(tasks []Task) string {
+	var b strings.Builder
+	for _, task := range tasks {
+		words := strings.Fields(task.Text)
+		for i, word := range words {
+			if word[0] == '#' || escapedHash.MatchString(word) {
+				words[i] = `\` + word
+			}
+		}
+		for _, tag := range task.Tags {
+			words = append(words, "#"+formatTag(tag))
+		}
+		b.WriteString("- " + checkbox(task.Completed) + " " + strings.Join(words, " ") + "\n")
+		for _, subtask := range task.Subtasks {
+			b.WriteString("  - " + checkbox(subtask.Completed) + " " + strings.Join(strings.Fields(subtask.Text), " ") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Name returns a tag name as it is written after #.
+func Name(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+func checkbox(completed bool) string {
+	if completed {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+// width returns the width of a line's indent, with tabs stopping every four
+// columns.
+func width(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4 - n%4
+		default:
+			return n
+		}
+	}
+	return n
+}
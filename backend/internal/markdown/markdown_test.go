@@ -0,0 +1,75 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc := "# Notes\r\n" +
+		"Some prose with a #hashtag.\n" +
+		"- [ ] Send the notes #team, #urgent! #team\n" +
+		"  - [x] Draft\n" +
+		"    * [X]   Deeply   nested\n" +
+		"  - [ ]\n" +
+		"  - plain bullet\n" +
+		"\n" +
+		"  indented paragraph\n" +
+		"1. [x] Book the room \\#101 #wow\\! #why\\?\\!.\n" +
+		"## Later ##\n" +
+		"+ [ ] #only #tags\n" +
+		"- [ ] Call \\\\#mum #Later\n" +
+		"- [] Not an item\n" +
+		"Paragraph\n" +
+		"  - [ ] Not a subtask\n"
+
+	want := []Task{
+		{Text: "Send the notes", Tags: []string{"team", "urgent", "Notes"},
+			Subtasks: []Subtask{{Text: "Draft", Completed: true}, {Text: "Deeply nested", Completed: true}}},
+		{Text: "Book the room #101", Completed: true, Tags: []string{"wow!", "why?!", "Notes"}},
+		{Text: "#only #tags", Tags: []string{"Later"}},
+		{Text: `Call \#mum`, Tags: []string{"Later"}},
+		{Text: "Not a subtask", Tags: []string{"Later"}},
+	}
+	if got := Parse(doc, Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v\nwant %+v", got, want)
+	}
+
+	want = []Task{{Text: "Call", Tags: []string{"home"}}}
+	if got := Parse("# Home\n- [ ] Call #home.\n", Options{IgnoreHeadings: true}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() ignoring headings = %+v, want %+v", got, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	got := Format([]Task{
+		{Text: "Send the\nnotes", Tags: []string{"team", "Action items"},
+			Subtasks: []Subtask{{Text: "Draft", Completed: true}, {Text: " Review "}}},
+		{Text: `#101 \#102 issue#3`, Completed: true, Tags: []string{"wow!", "why?!", "a.b"}},
+	})
+	want := "- [ ] Send the notes #team #Action_items\n" +
+		"  - [x] Draft\n" +
+		"  - [ ] Review\n" +
+		`- [x] \#101 \\#102 issue#3 #wow\! #why\?\! #a.b` + "\n"
+	if got != want {
+		t.Errorf("Format() = %q\nwant %q", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tasks := []Task{
+		{Text: "Send the notes", Tags: []string{"team", "Action_items"},
+			Subtasks: []Subtask{{Text: "Draft", Completed: true}, {Text: "Review"}}},
+		{Text: "Celebrate", Completed: true, Tags: []string{"wow!", "why?!", "a.b", "!", `back\`, `back\!`}},
+		{Text: `#101 \#102 \\#103 issue#3`},
+		{Text: "Done."},
+	}
+	doc := Format(tasks)
+	got := Parse(doc, Options{})
+	if !reflect.DeepEqual(got, tasks) {
+		t.Fatalf("Parse(%q) = %+v\nwant %+v", doc, got, tasks)
+	}
+	if again := Format(got); again != doc {
+		t.Errorf("Format(Parse(%q)) = %q", doc, again)
+	}
+}
@@ -0,0 +1,410 @@
+// Package query parses the task query language used by smart lists.
+//
+// A query is a sequence of terms that must all match, such as
+//
+//	tag:work is:important -is:completed list:"Q3 Plan" created:>7d
+//
+// Terms are joined with OR to match either side, grouped with parentheses and
+// negated with a leading "-". A term without a field matches text in the task;
+// values containing spaces are quoted. The fields are:
+//
+//	tag:NAME        the task has the tag
+//	list:TITLE      the task is in a list with that title, ignoring case
+//	is:STATE        important, completed, overdue or recurring
+//	has:PROPERTY    list, due, tags or subtasks
+//	due:DATE        the due date, compared with <, <=, > or >= or matched by day
+//	created:DATE    when the task was created
+//	updated:DATE    when the task last changed
+//
+// DATE is a YYYY-MM-DD day, today, yesterday or tomorrow, or a time relative to
+// now: 7d is seven days ago and +7d seven days ahead, in h, d or w units.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a node of a parsed query: And, Or, Not or Term.
+type Expr interface {
+	isExpr()
+}
+
+// And matches tasks that match every one of its expressions.
+type And []Expr
+
+// Or matches tasks that match any of its expressions.
+type Or []Expr
+
+// Not matches tasks that its expression does not.
+type Not struct {
+	Expr Expr
+}
+
+// Term is a single condition. Field is empty for a text term. Date terms have
+// Op, one of "", "<", "<=", ">" and ">=", and Date set.
+type Term struct {
+	Field string
+	Value string
+	Op    string
+	Date  *Date
+}
+
+func (And) isExpr()  {}
+func (Or) isExpr()   {}
+func (Not) isExpr()  {}
+func (Term) isExpr() {}
+
+// Fields terms can name.
+const (
+	FieldText    = ""
+	FieldTag     = "tag"
+	FieldList    = "list"
+	FieldIs      = "is"
+	FieldHas     = "has"
+	FieldDue     = "due"
+	FieldCreated = "created"
+	FieldUpdated = "updated"
+)
+
+var fieldValues = map[string][]string{
+	FieldIs:  {"important", "completed", "overdue", "recurring"},
+	FieldHas: {"list", "due", "tags", "subtasks"},
+}
+
+// Parse parses a query.
+func Parse(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty query")
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenTerm
+	tokenNot
+	tokenOr
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	pos   int
+	field string
+	value string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenNot:
+		return `"-"`
+	case tokenOr:
+		return "OR"
+	case tokenOpen:
+		return `"("`
+	case tokenClose:
+		return `")"`
+	case tokenTerm:
+		return "term"
+	}
+	return "end of query"
+}
+
+// lex splits a query into tokens. Positions are 1-based byte offsets.
+func lex(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen, pos: i + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose, pos: i + 1})
+			i++
+		case c == '-' && i+1 < len(s) && !isSpace(s[i+1]):
+			tokens = append(tokens, token{kind: tokenNot, pos: i + 1})
+			i++
+		default:
+			tok := token{kind: tokenTerm, pos: i + 1}
+			word, quoted, next, err := lexWord(s, i, true)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+			if !quoted && i < len(s) && s[i] == ':' {
+				if word == "" {
+					return nil, fmt.Errorf("missing field name at position %d", tok.pos)
+				}
+				tok.field = strings.ToLower(word)
+				if word, _, i, err = lexWord(s, i+1, false); err != nil {
+					return nil, err
+				}
+			} else if !quoted && word == "OR" {
+				tok.kind = tokenOr
+			}
+			tok.value = word
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens, nil
+}
+
+// lexWord reads a quoted string or a bare word starting at s[i], stopping a bare
+// word at a space, a closing parenthesis or, if field is set, a colon.
+func lexWord(s string, i int, field bool) (word string, quoted bool, next int, err error) {
+	if i < len(s) && s[i] == '"' {
+		end := strings.IndexByte(s[i+1:], '"')
+		if end < 0 {
+			return "", false, 0, fmt.Errorf("unterminated quote at position %d", i+1)
+		}
+		return s[i+1 : i+1+end], true, i + end + 2, nil
+	}
+	start := i
+	for i < len(s) && !isSpace(s[i]) && s[i] != ')' && s[i] != '"' && !(field && s[i] == ':') {
+		i++
+	}
+	return s[start:i], false, i, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		end := 1
+		if n := len(p.tokens); n > 0 {
+			end = p.tokens[n-1].pos + 1
+		}
+		return token{kind: tokenEOF, pos: end}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+// parseOr parses terms joined by OR, which binds looser than the implicit AND.
+func (p *parser) parseOr() (Expr, error) {
+	var or Or
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+		if p.peek().kind != tokenOr {
+			break
+		}
+		p.next()
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	var and And
+	for {
+		switch p.peek().kind {
+		case tokenEOF, tokenClose, tokenOr:
+			if len(and) == 0 {
+				tok := p.peek()
+				return nil, fmt.Errorf("expected a term at position %d, found %s", tok.pos, tok)
+			}
+			if len(and) == 1 {
+				return and[0], nil
+			}
+			return and, nil
+		}
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNot:
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: expr}, nil
+	case tokenOpen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenClose {
+			return nil, fmt.Errorf("missing \")\" for \"(\" at position %d", tok.pos)
+		}
+		return expr, nil
+	case tokenTerm:
+		term, err := newTerm(tok.field, tok.value)
+		if err != nil {
+			return nil, fmt.Errorf("%w at position %d", err, tok.pos)
+		}
+		return term, nil
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", tok, tok.pos)
+}
+
+// newTerm validates a term's field and value.
+func newTerm(field, value string) (Term, error) {
+	term := Term{Field: field, Value: value}
+	switch field {
+	case FieldText:
+		if value == "" {
+			return term, errors.New("empty text")
+		}
+	case FieldTag, FieldList:
+		if value == "" {
+			return term, fmt.Errorf("missing value for %s:", field)
+		}
+	case FieldIs, FieldHas:
+		term.Value = strings.ToLower(value)
+		for _, v := range fieldValues[field] {
+			if term.Value == v {
+				return term, nil
+			}
+		}
+		return term, fmt.Errorf("invalid value %q for %s:", value, field)
+	case FieldDue, FieldCreated, FieldUpdated:
+		for _, op := range []string{"<=", ">=", "<", ">"} {
+			if strings.HasPrefix(value, op) {
+				term.Op = op
+				break
+			}
+		}
+		date, err := parseDate(strings.TrimPrefix(value, term.Op))
+		if err != nil {
+			return term, fmt.Errorf("invalid date %q for %s:", value, field)
+		}
+		term.Date = date
+	default:
+		return term, fmt.Errorf("unknown field %q", field)
+	}
+	return term, nil
+}
+
+// Date is a date in a query: a calendar day, a day counted from today, or a time
+// relative to now.
+type Date struct {
+	// Day is a calendar day as midnight UTC, or zero.
+	Day time.Time
+	// Days counts days from today when Day is zero and Relative is not set.
+	Days int
+	// Offset is the distance from now of a relative time.
+	Offset   time.Duration
+	Relative bool
+}
+
+var dateKeywords = map[string]int{"yesterday": -1, "today": 0, "tomorrow": 1}
+
+var relativeUnits = map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+
+// parseDate parses a date value.
+func parseDate(s string) (*Date, error) {
+	if days, ok := dateKeywords[strings.ToLower(s)]; ok {
+		return &Date{Days: days}, nil
+	}
+	if day, err := time.Parse("2006-01-02", s); err == nil {
+		return &Date{Day: day}, nil
+	}
+
+	if len(s) < 2 {
+		return nil, errors.New("invalid date")
+	}
+	unit, ok := relativeUnits[s[len(s)-1]]
+	if !ok {
+		return nil, errors.New("invalid date")
+	}
+	number := s[:len(s)-1]
+	sign := time.Duration(-1)
+	if strings.HasPrefix(number, "+") {
+		sign, number = 1, number[1:]
+	} else {
+		number = strings.TrimPrefix(number, "-")
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 || n > 100000 {
+		return nil, errors.New("invalid date")
+	}
+	return &Date{Offset: sign * time.Duration(n) * unit, Relative: true}, nil
+}
+
+// Span returns the interval [start, end) the date covers in loc at now: the whole
+// day for a calendar day or a day counted from today, or the single instant of a
+// relative time, for which start and end are equal.
+func (d Date) Span(now time.Time, loc *time.Location) (start, end time.Time) {
+	if d.Relative {
+		t := now.Add(d.Offset)
+		return t, t
+	}
+	y, m, day := d.Day.Date()
+	if d.Day.IsZero() {
+		y, m, day = now.In(loc).Date()
+		day += d.Days
+	}
+	return time.Date(y, m, day, 0, 0, 0, 0, loc), time.Date(y, m, day+1, 0, 0, 0, 0, loc)
+}
+
+// Range returns the instants a date term matches as [after, before), either bound
+// being nil when open. "<" is before the date's start, "<=" before its end, ">"
+// from its end and ">=" from its start; without an operator a term matches the
+// date's day, and a relative time stands for the day it falls on.
+func (t Term) Range(now time.Time, loc *time.Location) (after, before *time.Time) {
+	start, end := t.Date.Span(now, loc)
+	switch t.Op {
+	case "<":
+		return nil, &start
+	case "<=":
+		return nil, &end
+	case ">":
+		return &end, nil
+	case ">=":
+		return &start, nil
+	}
+	if t.Date.Relative {
+		y, m, d := start.In(loc).Date()
+		start, end = time.Date(y, m, d, 0, 0, 0, 0, loc), time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+	}
+	return &start, &end
+}
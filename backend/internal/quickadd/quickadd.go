@@ -0,0 +1,397 @@
+// Package quickadd pulls task properties out of text typed into a quick-add box,
+// such as "Call dentist tomorrow 3pm #health !".
+//
+// It recognizes:
+//
+//	#tag            a tag; any number may be given
+//	!               marks the task important ("!!" and "!!!" do too)
+//	@List Name      one of the user's lists, matched by title ignoring case
+//	DATE [TIME]     a due date, optionally with a time of day
+//
+// Dates are today, tomorrow, a weekday (monday or mon, optionally after "this"
+// or "next"), next week, next month, "in N days", "in N weeks", "in N months",
+// a YYYY-MM-DD day or a month and day such as "oct 20" or "20 october". Times
+// are 3pm, 3:30pm, "3 pm", 15:00 or noon, before or after the date. A time on
+// its own means today, or tomorrow once it has passed. A leading "on", "by",
+// "due" or "at" is taken along with the date. Only the first date is used; the
+// words that were recognized are removed from the text and the rest is kept.
+package quickadd
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options is the context text is parsed in.
+type Options struct {
+	// Now is the current time, which relative dates count from.
+	Now time.Time
+	// Location is the user's time zone. Nil means UTC.
+	Location *time.Location
+	// Lists are the titles of the user's lists, which @ mentions are matched
+	// against. A mention that names no list is left in the text.
+	Lists []string
+}
+
+// Result is what was found in the text.
+type Result struct {
+	// Text is the input with the recognized words removed.
+	Text      string
+	Tags      []string
+	Important bool
+	// List is the title, as given in Options.Lists, of the mentioned list.
+	List string
+	// Due is the due date, or nil. An all-day date is midnight of its day in
+	// the user's time zone.
+	Due       *time.Time
+	DueAllDay bool
+}
+
+// Parse parses quick-add text.
+func Parse(text string, opts Options) Result {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	p := &parser{words: strings.Fields(text), now: opts.Now.In(loc), loc: loc}
+	p.used = make([]bool, len(p.words))
+
+	var result Result
+	for i, word := range p.words {
+		switch {
+		case word == "!" || word == "!!" || word == "!!!":
+			result.Important = true
+			p.used[i] = true
+		case len(word) > 1 && word[0] == '#':
+			tag := strings.TrimRight(word[1:], trailingPunctuation)
+			if tag == "" {
+				continue
+			}
+			p.used[i] = true
+			if !slices.Contains(result.Tags, tag) {
+				result.Tags = append(result.Tags, tag)
+			}
+		}
+	}
+
+	for i, word := range p.words {
+		if p.used[i] || len(word) < 2 || word[0] != '@' {
+			continue
+		}
+		if title, n := p.matchList(i, opts.Lists); n > 0 {
+			result.List = title
+			p.use(i, n)
+			break
+		}
+	}
+
+	for i := range p.words {
+		if p.used[i] {
+			continue
+		}
+		due, allDay, n := p.matchDue(p.free(i))
+		if n == 0 {
+			continue
+		}
+		result.Due, result.DueAllDay = &due, allDay
+		p.use(i, n)
+		if i > 0 && !p.used[i-1] && prepositions[clean(p.words[i-1])] {
+			p.used[i-1] = true
+		}
+		break
+	}
+
+	var rest []string
+	for i, word := range p.words {
+		if !p.used[i] {
+			rest = append(rest, word)
+		}
+	}
+	result.Text = strings.Join(rest, " ")
+	return result
+}
+
+// trailingPunctuation is stripped from the end of words before they are matched.
+const trailingPunctuation = ",.;:!?"
+
+var prepositions = map[string]bool{"on": true, "by": true, "due": true, "at": true}
+
+type parser struct {
+	words []string
+	used  []bool
+	now   time.Time
+	loc   *time.Location
+}
+
+// use marks n words from i as recognized.
+func (p *parser) use(i, n int) {
+	for j := i; j < i+n; j++ {
+		p.used[j] = true
+	}
+}
+
+// free returns the cleaned words from i up to the next recognized one.
+func (p *parser) free(i int) []string {
+	var words []string
+	for j := i; j < len(p.words) && !p.used[j]; j++ {
+		words = append(words, clean(p.words[j]))
+	}
+	return words
+}
+
+// clean lowercases a word and strips trailing punctuation.
+func clean(word string) string {
+	return strings.ToLower(strings.TrimRight(word, trailingPunctuation))
+}
+
+// matchList matches the @ mention at i against the list titles, preferring the
+// longest title, and returns the title and the number of words it spans.
+func (p *parser) matchList(i int, lists []string) (string, int) {
+	best, bestWords := "", 0
+	for _, title := range lists {
+		titleWords := strings.Fields(title)
+		n := len(titleWords)
+		if n == 0 || n <= bestWords || i+n > len(p.words) {
+			continue
+		}
+		mention := make([]string, n)
+		for j := 0; j < n; j++ {
+			if p.used[i+j] {
+				mention = nil
+				break
+			}
+			mention[j] = p.words[i+j]
+		}
+		if mention == nil {
+			continue
+		}
+		mention[0] = mention[0][1:]
+		mention[n-1] = strings.TrimRight(mention[n-1], trailingPunctuation)
+		if strings.EqualFold(strings.Join(mention, " "), strings.Join(titleWords, " ")) {
+			best, bestWords = title, n
+		}
+	}
+	return best, bestWords
+}
+
+// matchDue matches a date, a time, or both in either order at the start of
+// words, returning the due date and the number of words used.
+func (p *parser) matchDue(words []string) (time.Time, bool, int) {
+	if day, n := p.matchDate(words); n > 0 {
+		rest := words[n:]
+		skip := 0
+		if len(rest) > 0 && (rest[0] == "at" || rest[0] == "by") {
+			skip = 1
+		}
+		if hour, min, m := matchTime(rest[skip:]); m > 0 {
+			return p.at(day, hour, min), false, n + skip + m
+		}
+		return day, true, n
+	}
+
+	hour, min, n := matchTime(words)
+	if n == 0 {
+		return time.Time{}, false, 0
+	}
+	rest := words[n:]
+	skip := 0
+	if len(rest) > 0 && rest[0] == "on" {
+		skip = 1
+	}
+	if day, m := p.matchDate(rest[skip:]); m > 0 {
+		return p.at(day, hour, min), false, n + skip + m
+	}
+	due := p.at(p.today(), hour, min)
+	if !due.After(p.now) {
+		due = p.at(p.today().AddDate(0, 0, 1), hour, min)
+	}
+	return due, false, n
+}
+
+// at returns the time of day on a day.
+func (p *parser) at(day time.Time, hour, min int) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hour, min, 0, 0, p.loc)
+}
+
+// today returns midnight at the start of the current day.
+func (p *parser) today() time.Time {
+	y, m, d := p.now.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, p.loc)
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	// "sun" and "sat" are left out as they are common words
+	"mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "fri": time.Friday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "february": time.February, "march": time.March, "april": time.April,
+	"may": time.May, "june": time.June, "july": time.July, "august": time.August,
+	"september": time.September, "october": time.October, "november": time.November, "december": time.December,
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"jun": time.June, "jul": time.July, "aug": time.August, "sep": time.September, "sept": time.September,
+	"oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+var units = map[string]string{
+	"day": "day", "days": "day", "week": "week", "weeks": "week", "month": "month", "months": "month",
+}
+
+var dayOfMonth = regexp.MustCompile(`^([0-9]{1,2})(st|nd|rd|th)?$`)
+
+// matchDate matches a date at the start of words, returning midnight of the day
+// and the number of words used.
+func (p *parser) matchDate(words []string) (time.Time, int) {
+	if len(words) == 0 {
+		return time.Time{}, 0
+	}
+	today := p.today()
+	first, second := words[0], ""
+	if len(words) > 1 {
+		second = words[1]
+	}
+
+	switch first {
+	case "today":
+		return today, 1
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), 1
+	case "this", "next":
+		if wd, ok := weekdays[second]; ok {
+			return p.weekday(wd, first == "this"), 2
+		}
+		if first == "next" && second == "week" {
+			return p.weekday(time.Monday, false), 2
+		}
+		if first == "next" && second == "month" {
+			y, m, _ := today.Date()
+			return time.Date(y, m+1, 1, 0, 0, 0, 0, p.loc), 2
+		}
+		return time.Time{}, 0
+	case "in":
+		if len(words) < 3 {
+			return time.Time{}, 0
+		}
+		n, err := strconv.Atoi(second)
+		if second == "a" || second == "an" || second == "one" {
+			n, err = 1, nil
+		}
+		if err != nil || n < 1 || n > 1000 {
+			return time.Time{}, 0
+		}
+		switch units[words[2]] {
+		case "day":
+			return today.AddDate(0, 0, n), 3
+		case "week":
+			return today.AddDate(0, 0, 7*n), 3
+		case "month":
+			return today.AddDate(0, n, 0), 3
+		}
+		return time.Time{}, 0
+	}
+
+	if wd, ok := weekdays[first]; ok {
+		return p.weekday(wd, false), 1
+	}
+	if day, err := time.ParseInLocation("2006-01-02", first, p.loc); err == nil {
+		return day, 1
+	}
+	if month, ok := months[first]; ok {
+		if day, ok := p.monthDay(month, second); ok {
+			return day, 2
+		}
+	}
+	if month, ok := months[second]; ok {
+		if day, ok := p.monthDay(month, first); ok {
+			return day, 2
+		}
+	}
+	return time.Time{}, 0
+}
+
+// weekday returns the next day falling on wd after today, or from today if
+// includeToday is set.
+func (p *parser) weekday(wd time.Weekday, includeToday bool) time.Time {
+	days := (int(wd) - int(p.now.Weekday()) + 7) % 7
+	if days == 0 && !includeToday {
+		days = 7
+	}
+	return p.today().AddDate(0, 0, days)
+}
+
+// monthDay returns the next occurrence of a day of a month from today on.
+func (p *parser) monthDay(month time.Month, word string) (time.Time, bool) {
+	m := dayOfMonth.FindStringSubmatch(word)
+	if m == nil {
+		return time.Time{}, false
+	}
+	day, _ := strconv.Atoi(m[1])
+	year := p.now.Year()
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.loc)
+	if day < 1 || date.Month() != month {
+		// Out of range for the month, such as February 30th
+		return time.Time{}, false
+	}
+	if date.Before(p.today()) {
+		date = time.Date(year+1, month, day, 0, 0, 0, 0, p.loc)
+	}
+	return date, true
+}
+
+var (
+	clockTime  = regexp.MustCompile(`^([0-9]{1,2})(?::([0-9]{2}))?(am|pm)?$`)
+	twentyFour = regexp.MustCompile(`^[0-9]{1,2}:[0-9]{2}$`)
+)
+
+// matchTime matches a time of day at the start of words, returning the hour,
+// minute and number of words used.
+func matchTime(words []string) (hour, min, n int) {
+	if len(words) == 0 {
+		return 0, 0, 0
+	}
+	if words[0] == "noon" {
+		return 12, 0, 1
+	}
+	m := clockTime.FindStringSubmatch(words[0])
+	if m == nil {
+		return 0, 0, 0
+	}
+	suffix, n := m[3], 1
+	if suffix == "" && len(words) > 1 && (words[1] == "am" || words[1] == "pm") {
+		suffix, n = words[1], 2
+	}
+	if suffix == "" && !twentyFour.MatchString(words[0]) {
+		// A bare number is not a time
+		return 0, 0, 0
+	}
+
+	hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		min, _ = strconv.Atoi(m[2])
+	}
+	if min > 59 {
+		return 0, 0, 0
+	}
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, 0
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, 0
+		}
+	}
+	return hour, min, n
+}
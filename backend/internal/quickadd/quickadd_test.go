@@ -0,0 +1,99 @@
+package quickadd
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// Tuesday morning in a time zone five hours behind UTC
+	loc := time.FixedZone("EST", -5*60*60)
+	opts := Options{
+		Now:      time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC),
+		Location: loc,
+		Lists:    []string{"Work", "Side Projects", "Side"},
+	}
+
+	for _, tt := range []struct {
+		text string
+		want Result
+		// due is the due date as YYYY-MM-DD when it is all day, or with the
+		// time of day, in loc
+		due string
+	}{
+		// No tokens
+		{"Buy milk", Result{Text: "Buy milk"}, ""},
+		{"", Result{}, ""},
+		{"Room 101 at 4 o'clock", Result{Text: "Room 101 at 4 o'clock"}, ""},
+		{"wow! #", Result{Text: "wow! #"}, ""},
+
+		// Dates
+		{"Call dentist today", Result{Text: "Call dentist"}, "2024-03-05"},
+		{"Call dentist tomorrow", Result{Text: "Call dentist"}, "2024-03-06"},
+		{"Pay rent by Friday.", Result{Text: "Pay rent"}, "2024-03-08"},
+		{"Team lunch on tue", Result{Text: "Team lunch"}, "2024-03-12"},
+		{"Team lunch this tuesday", Result{Text: "Team lunch"}, "2024-03-05"},
+		{"Review next friday", Result{Text: "Review"}, "2024-03-08"},
+		{"Plan next week", Result{Text: "Plan"}, "2024-03-11"},
+		{"Invoice next month", Result{Text: "Invoice"}, "2024-04-01"},
+		{"Follow up in 3 days", Result{Text: "Follow up"}, "2024-03-08"},
+		{"Follow up in a week", Result{Text: "Follow up"}, "2024-03-12"},
+		{"Renew in 2 months", Result{Text: "Renew"}, "2024-05-05"},
+		{"Party 2024-12-25", Result{Text: "Party"}, "2024-12-25"},
+		{"Birthday oct 20", Result{Text: "Birthday"}, "2024-10-20"},
+		{"Birthday 20th October", Result{Text: "Birthday"}, "2024-10-20"},
+		{"Taxes march 1", Result{Text: "Taxes"}, "2025-03-01"},
+		{"Leap feb 30", Result{Text: "Leap feb 30"}, ""},
+		{"Decide today or tomorrow", Result{Text: "Decide or tomorrow"}, "2024-03-05"},
+
+		// Times
+		{"Call 3pm", Result{Text: "Call"}, "2024-03-05 15:00"},
+		{"Call at 3:30 pm", Result{Text: "Call"}, "2024-03-05 15:30"},
+		{"Call 9am", Result{Text: "Call"}, "2024-03-06 09:00"},
+		{"Call 10am", Result{Text: "Call"}, "2024-03-06 10:00"},
+		{"Lunch noon", Result{Text: "Lunch"}, "2024-03-05 12:00"},
+		{"Standup 09:15 tomorrow", Result{Text: "Standup"}, "2024-03-06 09:15"},
+		{"Call dentist tomorrow at 3pm", Result{Text: "Call dentist"}, "2024-03-06 15:00"},
+		{"Gym 6pm on friday", Result{Text: "Gym"}, "2024-03-08 18:00"},
+		{"Call 13pm", Result{Text: "Call 13pm"}, ""},
+
+		// Lists, tags and importance
+		{"Write docs @side projects", Result{Text: "Write docs", List: "Side Projects"}, ""},
+		{"Write docs @SIDE quickly", Result{Text: "Write docs quickly", List: "Side"}, ""},
+		{"Email boss @work, then @side", Result{Text: "Email boss then @side", List: "Work"}, ""},
+		{"Email @bob", Result{Text: "Email @bob"}, ""},
+		{"File taxes #money #home, #money", Result{Text: "File taxes", Tags: []string{"money", "home"}}, ""},
+		{"Fix leak !", Result{Text: "Fix leak", Important: true}, ""},
+		{"!!! Fix leak", Result{Text: "Fix leak", Important: true}, ""},
+		{
+			"Call dentist tomorrow 3pm #health ! @Side Projects",
+			Result{Text: "Call dentist", Tags: []string{"health"}, Important: true, List: "Side Projects"},
+			"2024-03-06 15:00",
+		},
+	} {
+		got := Parse(tt.text, opts)
+		var due string
+		if got.Due != nil {
+			if got.Due.Location() != loc {
+				t.Errorf("Parse(%q) due %v is not in the user's time zone", tt.text, got.Due)
+			}
+			due = got.Due.Format("2006-01-02 15:04")
+			if got.DueAllDay {
+				due = got.Due.Format("2006-01-02")
+			}
+		}
+		got.Due, got.DueAllDay = nil, false
+		if got.Text != tt.want.Text || !slices.Equal(got.Tags, tt.want.Tags) || got.Important != tt.want.Important ||
+			got.List != tt.want.List || due != tt.due {
+			t.Errorf("Parse(%q) = %+v due %q, want %+v due %q", tt.text, got, due, tt.want, tt.due)
+		}
+	}
+}
+
+func TestParseUTC(t *testing.T) {
+	got := Parse("Call 3pm", Options{Now: time.Date(2024, 3, 5, 16, 0, 0, 0, time.UTC)})
+	if want := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC); got.Due == nil || !got.Due.Equal(want) || got.DueAllDay {
+		t.Fatalf("Parse without a location = %+v, want due %v", got, want)
+	}
+}
@@ -0,0 +1,431 @@
+// Package recurrence parses RFC 5545 recurrence rules and computes occurrences.
+//
+// It supports the parts of RRULE that task recurrence needs: FREQ (DAILY,
+// WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY (with ordinals for
+// monthly and yearly rules), BYMONTHDAY, BYMONTH and WKST. Time-based parts such
+// as BYHOUR and BYSETPOS are rejected.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base unit a rule repeats in.
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = map[Frequency]string{
+	Daily:   "DAILY",
+	Weekly:  "WEEKLY",
+	Monthly: "MONTHLY",
+	Yearly:  "YEARLY",
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// maxPeriods bounds how many periods Next scans, so rules that can never match
+// (such as February 30th) terminate.
+const maxPeriods = 2000
+
+// Weekday is a BYDAY entry. N selects the Nth such weekday of the month or year,
+// counting from the end when negative; zero means every such weekday.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE". An "RRULE:"
+// prefix is accepted and ignored. Names and values are case-insensitive.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	hasFreq := false
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			hasFreq = true
+			r.Freq, err = parseFrequency(value)
+		case "INTERVAL":
+			r.Interval, err = parsePositive(value)
+		case "COUNT":
+			r.Count, err = parsePositive(value)
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value)
+			r.Until = &until
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, 31, true)
+		case "BYMONTH":
+			var months []int
+			months, err = parseIntList(value, 12, false)
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			r.WeekStart, err = parseWeekday(value)
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !hasFreq {
+		return nil, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, errors.New("BYDAY ordinals need FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == Weekly {
+		return nil, errors.New("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+
+	return r, nil
+}
+
+// String returns the rule in canonical RRULE form, without the "RRULE:" prefix.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + frequencyNames[r.Freq]}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = weekdayNames[wd.Day]
+			if wd.N != 0 {
+				days[i] = strconv.Itoa(wd.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = int(m)
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence of the series anchored at start that falls
+// strictly after after. Occurrences keep start's time of day and location. It
+// reports false when the series has no such occurrence before UNTIL. COUNT is not
+// applied here: callers that produce one occurrence at a time track it themselves.
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	for i := 0; i < maxPeriods; i++ {
+		for _, t := range r.candidates(start, i*interval) {
+			if t.Before(start) || !t.After(after) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return time.Time{}, false
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// candidates returns the sorted occurrences in the period offset periods after
+// the one containing start.
+func (r *Rule) candidates(start time.Time, offset int) []time.Time {
+	y, m, d := start.Date()
+	var days []time.Time
+
+	switch r.Freq {
+	case Daily:
+		day := date(y, m, d+offset, start)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+
+	case Weekly:
+		back := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := date(y, m, d-back+7*offset, start)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchesWeekday(day) && r.matchesMonth(day) {
+				days = append(days, day)
+			}
+		}
+
+	case Monthly:
+		first := date(y, m+time.Month(offset), 1, start)
+		if r.matchesMonth(first) {
+			days = r.monthDays(first, start)
+		}
+
+	case Yearly:
+		year := y + offset
+		switch {
+		case len(r.ByMonth) > 0:
+			for _, month := range r.ByMonth {
+				days = append(days, r.monthDays(date(year, month, 1, start), start)...)
+			}
+		case len(r.ByDay) > 0 && len(r.ByMonthDay) == 0:
+			days = r.yearWeekdays(year, start)
+		case len(r.ByMonthDay) > 0:
+			days = r.monthDays(date(year, m, 1, start), start)
+		default:
+			if day := date(year, m, d, start); day.Month() == m {
+				days = append(days, day)
+			}
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// monthDays returns the days in the month starting at first that match BYMONTHDAY
+// and BYDAY, defaulting to start's day of the month.
+func (r *Rule) monthDays(first, start time.Time) []time.Time {
+	length := daysIn(first)
+	var days []time.Time
+	for d := 1; d <= length; d++ {
+		day := first.AddDate(0, 0, d-1)
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+			if d == start.Day() {
+				days = append(days, day)
+			}
+			continue
+		}
+		if len(r.ByMonthDay) > 0 && !containsDay(r.ByMonthDay, d, length) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !matchesNth(r.ByDay, day, d, length) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// yearWeekdays returns the days of a year matching BYDAY, with ordinals counted
+// within the year.
+func (r *Rule) yearWeekdays(year int, start time.Time) []time.Time {
+	first := date(year, time.January, 1, start)
+	length := first.AddDate(1, 0, -1).YearDay()
+	var days []time.Time
+	for i := 1; i <= length; i++ {
+		day := first.AddDate(0, 0, i-1)
+		if matchesNth(r.ByDay, day, i, length) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func (r *Rule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if t.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	return len(r.ByMonthDay) == 0 || containsDay(r.ByMonthDay, t.Day(), daysIn(t))
+}
+
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNth reports whether day, the index-th day of a period of length days,
+// matches one of the weekdays, honoring ordinals.
+func matchesNth(weekdays []Weekday, day time.Time, index, length int) bool {
+	for _, wd := range weekdays {
+		if wd.Day != day.Weekday() {
+			continue
+		}
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && (index-1)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && (length-index)/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
+
+// containsDay reports whether day of a month with length days is in list, where
+// negative entries count from the end of the month.
+func containsDay(list []int, day, length int) bool {
+	for _, v := range list {
+		if v == day || (v < 0 && length+v+1 == day) {
+			return true
+		}
+	}
+	return false
+}
+
+// date builds a day at the time of day and location of ref, normalizing overflow.
+func date(y int, m time.Month, d int, ref time.Time) time.Time {
+	return time.Date(y, m, d, ref.Hour(), ref.Minute(), ref.Second(), 0, ref.Location())
+}
+
+// daysIn returns the number of days in t's month.
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func parseFrequency(s string) (Frequency, error) {
+	for f, name := range frequencyNames {
+		if strings.EqualFold(s, name) {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported FREQ %q", s)
+}
+
+func parsePositive(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes the whole day.
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", s)
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for i, name := range weekdayNames {
+		if strings.EqualFold(s, name) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+func parseByDay(s string) ([]Weekday, error) {
+	var days []Weekday
+	for _, item := range strings.Split(s, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		day, err := parseWeekday(item[len(item)-2:])
+		if err != nil {
+			return nil, err
+		}
+		wd := Weekday{Day: day}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(prefix, "+"))
+			if err != nil || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("invalid BYDAY %q", item)
+			}
+			wd.N = n
+		}
+		days = append(days, wd)
+	}
+	return days, nil
+}
+
+// parseIntList parses a comma-separated list of non-zero values up to max in
+// magnitude, allowing negative values only when negative is set.
+func parseIntList(s string, max int, negative bool) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(item, "+"))
+		if err != nil || n == 0 || n > max || n < -max || (n < 0 && !negative) {
+			return nil, fmt.Errorf("invalid value %q", item)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,245 @@
+// Package todotxt reads and writes tasks as lines of the todo.txt format
+// (https://github.com/todotxt/todo.txt), such as
+//
+//	x 2026-10-16 2026-10-01 Ship the release +Work @laptop due:2026-10-20
+//
+// A line is read as follows:
+//
+//	x DATE DATE     completed, with the completion and then creation date
+//	(A) DATE        important, with the creation date, on an incomplete task
+//	+project        the task's list; the last one given is used
+//	@context #tag   tags; any number may be given
+//	due:DATE        the due date; the last one given is used
+//	t:DATE          the start date; the last one given is used
+//	pri:A           important, as written on completed tasks
+//
+// Dates are YYYY-MM-DD. Everything else, including other priorities, other
+// key:value pairs such as rec:1w and malformed dates, is kept in the text as
+// written. A line whose description is nothing but tags keeps it all as text, so
+// no line is dropped.
+//
+// Format writes the recognized parts in a fixed order after the text, so a line
+// it wrote reads back as the same task and formats to the same line. Words of
+// the text that would read as one of them, such as "+1" or "@bob", a first word
+// that would read as the x, priority or date that can start a line, and words
+// that start with a backslash are written with a backslash before them, which
+// Parse removes from every word of the text.
+package todotxt
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dateLayout is the layout of todo.txt dates.
+const dateLayout = "2006-01-02"
+
+// Task is a task as written on a todo.txt line. Dates carry only a day: Format
+// writes the day of each time in its own location, and Parse returns midnight
+// UTC of the day written.
+type Task struct {
+	Text        string
+	Completed   bool
+	Important   bool
+	CompletedAt *time.Time
+	CreatedAt   *time.Time
+	// Project is the name of the task's list, or empty for none.
+	Project string
+	Tags    []string
+	Due     *time.Time
+	Start   *time.Time
+}
+
+// priority matches a todo.txt priority.
+var priority = regexp.MustCompile(`^\([A-Z]\)$`)
+
+// Parse reads a line. It returns false for a blank line.
+func Parse(line string) (Task, bool) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return Task{}, false
+	}
+
+	all := words
+	var task Task
+	if words[0] == "x" {
+		task.Completed = true
+		words = words[1:]
+		if date, ok := parseDate(first(words)); ok {
+			task.CompletedAt = &date
+			words = words[1:]
+			if date, ok := parseDate(first(words)); ok {
+				task.CreatedAt = &date
+				words = words[1:]
+			}
+		}
+	} else {
+		if words[0] == "(A)" {
+			task.Important = true
+			words = words[1:]
+		} else if len(words) > 1 && priority.MatchString(words[0]) {
+			// Other priorities stay in the text, ahead of the creation date
+			if date, ok := parseDate(words[1]); ok {
+				task.CreatedAt = &date
+				words = append([]string{words[0]}, words[2:]...)
+			}
+		}
+		if date, ok := parseDate(first(words)); ok && task.CreatedAt == nil {
+			task.CreatedAt = &date
+			words = words[1:]
+		}
+	}
+	if len(words) == 0 {
+		return Task{Text: strings.Join(all, " ")}, true
+	}
+
+	var parsed Task
+	used := make([]bool, len(words))
+	project, due, start := -1, -1, -1
+	for i, word := range words {
+		switch {
+		case len(word) > 1 && word[0] == '+':
+			project = i
+		case len(word) > 1 && (word[0] == '@' || word[0] == '#'):
+			used[i] = true
+			if tag := word[1:]; !slices.Contains(parsed.Tags, tag) {
+				parsed.Tags = append(parsed.Tags, tag)
+			}
+		case strings.HasPrefix(word, "due:"):
+			if date, ok := parseDate(word[len("due:"):]); ok {
+				due = i
+				parsed.Due = &date
+			}
+		case strings.HasPrefix(word, "t:"):
+			if date, ok := parseDate(word[len("t:"):]); ok {
+				start = i
+				parsed.Start = &date
+			}
+		case word == "pri:A":
+			used[i] = true
+			parsed.Important = true
+		}
+	}
+	if project >= 0 {
+		parsed.Project = words[project][1:]
+	}
+	for _, i := range []int{project, due, start} {
+		if i >= 0 {
+			used[i] = true
+		}
+	}
+
+	var rest []string
+	for i, word := range words {
+		if !used[i] {
+			rest = append(rest, strings.TrimPrefix(word, `\`))
+		}
+	}
+	if len(rest) == 0 {
+		task.Text = strings.Join(words, " ")
+		return task, true
+	}
+
+	task.Text = strings.Join(rest, " ")
+	task.Important = task.Important || parsed.Important
+	task.Project = parsed.Project
+	task.Tags = parsed.Tags
+	task.Due = parsed.Due
+	task.Start = parsed.Start
+	return task, true
+}
+
+// Format writes a task as a line, without a line ending. A completed task's
+// creation date is only written along with its completion date, as todo.txt
+// requires, and its importance as pri:A. Spaces in the project and tags are
+// written as underscores and line breaks in the text as spaces.
+func Format(task Task) string {
+	var words []string
+	if task.Completed {
+		words = append(words, "x")
+		if task.CompletedAt != nil {
+			words = append(words, task.CompletedAt.Format(dateLayout))
+			if task.CreatedAt != nil {
+				words = append(words, task.CreatedAt.Format(dateLayout))
+			}
+		}
+	} else {
+		if task.Important {
+			words = append(words, "(A)")
+		}
+		if task.CreatedAt != nil {
+			words = append(words, task.CreatedAt.Format(dateLayout))
+		}
+	}
+	for i, word := range strings.Fields(task.Text) {
+		if special(word) || (i == 0 && leading(word)) {
+			word = `\` + word
+		}
+		words = append(words, word)
+	}
+	if task.Project != "" {
+		words = append(words, "+"+Name(task.Project))
+	}
+	for _, tag := range task.Tags {
+		words = append(words, "@"+Name(tag))
+	}
+	if task.Due != nil {
+		words = append(words, "due:"+task.Due.Format(dateLayout))
+	}
+	if task.Start != nil {
+		words = append(words, "t:"+task.Start.Format(dateLayout))
+	}
+	if task.Completed && task.Important {
+		words = append(words, "pri:A")
+	}
+	return strings.Join(words, " ")
+}
+
+// special reports whether Parse would read a word of a line's description as
+// something other than text, or remove a backslash from it.
+func special(word string) bool {
+	switch {
+	case strings.HasPrefix(word, `\`):
+		return true
+	case len(word) > 1 && strings.ContainsRune("+@#", rune(word[0])):
+		return true
+	case strings.HasPrefix(word, "due:"):
+		_, ok := parseDate(word[len("due:"):])
+		return ok
+	case strings.HasPrefix(word, "t:"):
+		_, ok := parseDate(word[len("t:"):])
+		return ok
+	}
+	return word == "pri:A"
+}
+
+// leading reports whether Parse would read a word at the start of a line as
+// the completion mark, a priority or a date.
+func leading(word string) bool {
+	_, date := parseDate(word)
+	return word == "x" || priority.MatchString(word) || date
+}
+
+// Name returns a list or tag name as it is written after + or @.
+func Name(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// parseDate parses a YYYY-MM-DD date.
+func parseDate(s string) (time.Time, bool) {
+	if len(s) != len(dateLayout) {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateLayout, s)
+	return date, err == nil
+}
+
+// first returns the first word, or "" if there are none.
+func first(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	return words[0]
+}
@@ -0,0 +1,98 @@
+package todotxt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(s string) *time.Time {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want Task
+	}{
+		{
+			"x 2026-10-16 2026-10-01 Ship the release +Work @laptop due:2026-10-20",
+			Task{Text: "Ship the release", Completed: true, CompletedAt: day("2026-10-16"), CreatedAt: day("2026-10-01"),
+				Project: "Work", Tags: []string{"laptop"}, Due: day("2026-10-20")},
+		},
+		{
+			"(A) 2026-10-01 Call mum #family @phone @phone t:2026-10-02",
+			Task{Text: "Call mum", Important: true, CreatedAt: day("2026-10-01"), Tags: []string{"family", "phone"}, Start: day("2026-10-02")},
+		},
+		{"x Pay rent pri:A", Task{Text: "Pay rent", Completed: true, Important: true}},
+		{"(B) 2026-10-01 Water plants", Task{Text: "(B) Water plants", CreatedAt: day("2026-10-01")}},
+		{"Read +Home +Books", Task{Text: "Read +Home", Project: "Books"}},
+		{"Plan trip rec:1w due:someday", Task{Text: "Plan trip rec:1w due:someday"}},
+		{"+Work @laptop", Task{Text: "+Work @laptop"}},
+		{`\+1 for \@bob`, Task{Text: "+1 for @bob"}},
+		{"   ", Task{}},
+	} {
+		got, ok := Parse(tt.line)
+		if ok != (tt.line != "   ") {
+			t.Fatalf("Parse(%q) ok = %t", tt.line, ok)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range []struct {
+		task Task
+		want string
+	}{
+		{
+			Task{Text: "Ship the release", Completed: true, Important: true, CompletedAt: day("2026-10-16"), CreatedAt: day("2026-10-01"),
+				Project: "Side Projects", Tags: []string{"laptop", "deep work"}, Due: day("2026-10-20"), Start: day("2026-10-18")},
+			"x 2026-10-16 2026-10-01 Ship the release +Side_Projects @laptop @deep_work due:2026-10-20 t:2026-10-18 pri:A",
+		},
+		{Task{Text: "Call\nmum", Important: true, CreatedAt: day("2026-10-01")}, "(A) 2026-10-01 Call mum"},
+		{Task{Text: "Done", Completed: true, CreatedAt: day("2026-10-01")}, "x Done"},
+		{Task{Text: "Email @bob about +1 rating", Project: "Work"}, `Email \@bob about \+1 rating +Work`},
+		{Task{Text: "x marks the spot"}, `\x marks the spot`},
+		{Task{Text: "(A) is the grade", Completed: true}, `x \(A) is the grade`},
+		{Task{Text: "2026-10-20 deadline"}, `\2026-10-20 deadline`},
+		{Task{Text: "Move due:2026-10-20 t:2026-10-18 pri:A out"}, `Move \due:2026-10-20 \t:2026-10-18 \pri:A out`},
+		{Task{Text: `C:\temp \n due:soon`}, `C:\temp \\n due:soon`},
+	} {
+		if got := Format(tt.task); got != tt.want {
+			t.Errorf("Format(%+v) = %q, want %q", tt.task, got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, task := range []Task{
+		{Text: "Email @bob about +1 rating"},
+		{Text: "x marks the spot"},
+		{Text: "x marks the spot", Completed: true},
+		{Text: "(A) grade #1 essay", Important: true, CreatedAt: day("2026-10-01")},
+		{Text: "(B) 2026-10-02 notes", Tags: []string{"school"}},
+		{Text: "2026-10-20 deadline", Completed: true},
+		{Text: "2026-10-20 deadline", Completed: true, CompletedAt: day("2026-10-21")},
+		{Text: "due:2026-10-20 t:2026-10-18 pri:A", Due: day("2026-10-22")},
+		{Text: `\ \\ \+1 back\slash`},
+		{Text: "+Work", Project: "Work"},
+		{Text: "@ + # x"},
+	} {
+		line := Format(task)
+		got, ok := Parse(line)
+		if !ok || !reflect.DeepEqual(got, task) {
+			t.Errorf("Parse(Format(%+v)) = Parse(%q) = %+v", task, line, got)
+			continue
+		}
+		if again := Format(got); again != line {
+			t.Errorf("Format(Parse(%q)) = %q", line, again)
+		}
+	}
+}
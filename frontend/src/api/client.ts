@@ -1,5 +1,5 @@
 import axios, { type AxiosError, type InternalAxiosRequestConfig } from 'axios';
-import type { AuthResponse, Task, Subtask, List } from '../types';
+import type { AuthResponse, Task, Subtask, List, SmartList } from '../types';
 
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
 
@@ -164,3 +164,10 @@ export const lists = {
   delete: (id: number) => api.delete(`/api/lists/${id}`),
 };
 
+export const smartLists = {
+  getAll: () => api.get<SmartList[]>('/api/smart-lists').then((res) => res.data),
+  create: (title: string, query: string) =>
+    api.post<SmartList>('/api/smart-lists', { title, query }).then((res) => res.data),
+  delete: (id: number) => api.delete(`/api/smart-lists/${id}`),
+  getTasks: (id: number) => api.get<Task[]>(`/api/smart-lists/${id}/tasks`).then((res) => res.data),
+};
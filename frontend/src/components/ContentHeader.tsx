@@ -1,13 +1,14 @@
 import { MoreHorizontal } from 'lucide-react';
-import type { FilterType, List } from '../types';
+import type { FilterType, List, SmartList } from '../types';
 
 interface ContentHeaderProps {
   filter: FilterType;
   lists?: List[]; // Make optional to support legacy usage if needed, though we'll update parent
+  smartLists?: SmartList[];
   onClearCompleted: () => void;
 }
 
-export function ContentHeader({ filter, lists = [], onClearCompleted }: ContentHeaderProps) {
+export function ContentHeader({ filter, lists = [], smartLists = [], onClearCompleted }: ContentHeaderProps) {
   let title = filter;
   if (filter === 'all') {
     title = 'Inbox';
@@ -15,6 +16,10 @@ export function ContentHeader({ filter, lists = [], onClearCompleted }: ContentH
     const listId = parseInt(filter.replace('list-', ''), 10);
     const list = lists.find(l => l.id === listId);
     title = list ? list.title : 'Details'; // Fallback if list not found (e.g. deleted)
+  } else if (filter.startsWith('smart-')) {
+    const listId = parseInt(filter.replace('smart-', ''), 10);
+    const list = smartLists.find(l => l.id === listId);
+    title = list ? list.title : 'Details';
   }
 
   const formattedDate = new Date().toLocaleDateString('en-US', { 
@@ -1,6 +1,6 @@
 import React, { useState, useEffect, useRef } from 'react';
-import { Plus, List as ListIcon, CheckSquare, Check, Star, Settings, AlertCircle, Folder, PenLine, Trash2 } from 'lucide-react';
-import type { FilterType, List } from '../types';
+import { Plus, List as ListIcon, CheckSquare, Check, Star, Settings, AlertCircle, Folder, PenLine, Trash2, Search } from 'lucide-react';
+import type { FilterType, List, SmartList } from '../types';
 
 interface SidebarItemProps {
   id: FilterType;
@@ -129,14 +129,17 @@ interface SidebarProps {
   };
   tags: string[];
   lists: List[];
+  smartLists: SmartList[];
   getTagCount: (tag: string) => number;
   getListCount: (listId: number) => number;
   onCreateList: (title: string) => void;
   onDeleteList: (id: number) => void;
   onUpdateList: (id: number, title: string) => void;
+  onCreateSmartList: (title: string, query: string) => void;
+  onDeleteSmartList: (id: number) => void;
 }
 
-export function Sidebar({ filter, onFilterChange, counts, tags, lists, getTagCount, getListCount, onCreateList, onDeleteList, onUpdateList }: SidebarProps) {
+export function Sidebar({ filter, onFilterChange, counts, tags, lists, smartLists, getTagCount, getListCount, onCreateList, onDeleteList, onUpdateList, onCreateSmartList, onDeleteSmartList }: SidebarProps) {
   const [isCreatingList, setIsCreatingList] = useState(false);
   const [newListName, setNewListName] = useState('');
   const inputRef = useRef<HTMLInputElement>(null);
@@ -165,6 +168,13 @@ export function Sidebar({ filter, onFilterChange, counts, tags, lists, getTagCou
     }
   };
 
+  const handleCreateSmartList = () => {
+    const title = window.prompt('Smart list name:')?.trim();
+    if (!title) return;
+    const query = window.prompt('Show tasks matching (e.g. tag:work is:important -is:completed):')?.trim();
+    if (query) onCreateSmartList(title, query);
+  };
+
   return (
     <aside className="w-64 sidebar-gradient border-r border-gray-300 flex flex-col shadow-[inset_-3px_0_5px_rgba(0,0,0,0.03)] z-10 bg-[#f4f4f4]">
       <div className="p-3 border-b border-gray-200 bg-gradient-to-b from-white to-[#f0f0f0]">
@@ -255,6 +265,35 @@ export function Sidebar({ filter, onFilterChange, counts, tags, lists, getTagCou
            </nav>
         </div>
 
+        <div className="py-2">
+           <div className="px-4 mb-2 flex items-center justify-between">
+             <h3 className="text-xs font-bold text-gray-500 uppercase tracking-wider">Smart Lists</h3>
+             <button
+               onClick={handleCreateSmartList}
+               className="p-0.5 rounded text-gray-400 hover:text-blue-600 hover:bg-blue-100 transition-colors"
+               title="Create smart list"
+             >
+               <Plus size={12} strokeWidth={3} />
+             </button>
+           </div>
+           {smartLists.length === 0 && (
+               <div className="px-4 py-2 text-sm text-gray-400 italic text-shadow-white">No smart lists yet...</div>
+           )}
+           <nav className="flex flex-col select-none">
+              {smartLists.map(list => (
+                  <SidebarItem
+                    key={list.id}
+                    id={`smart-${list.id}`}
+                    label={list.title}
+                    icon={Search}
+                    isActive={filter === `smart-${list.id}`}
+                    onClick={() => onFilterChange(`smart-${list.id}`)}
+                    onDelete={() => onDeleteSmartList(list.id)}
+                  />
+              ))}
+           </nav>
+        </div>
+
         <div className="py-2">
           <h3 className="px-4 text-xs font-bold text-gray-500 uppercase tracking-wider mb-2">Tags</h3>
           {tags.length === 0 ? (
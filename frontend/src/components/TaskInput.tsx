@@ -19,7 +19,8 @@ export function TaskInput({ filter, lists = [], onAddTask }: TaskInputProps) {
   };
 
   const getFilterLabel = () => {
-    if (filter === 'all') {
+    // Tasks added to a smart list go to the inbox, and show up if they match
+    if (filter === 'all' || filter.startsWith('smart-')) {
       return 'Inbox';
     }
     
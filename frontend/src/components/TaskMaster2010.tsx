@@ -2,6 +2,7 @@ import React, { useState } from 'react';
 import { Header, Sidebar, TaskInput, TaskList, ContentHeader } from '.';
 import { useTasks, useCreateTask, useUpdateTask, useDeleteTask, useReorderTasks, useCreateSubtask, useUpdateSubtask, useDeleteSubtask } from '../hooks/useTasks';
 import { useLists, useCreateList, useDeleteList, useUpdateList } from '../hooks/useLists';
+import { useSmartLists, useSmartListTasks, useCreateSmartList, useDeleteSmartList } from '../hooks/useSmartLists';
 import { useWebSocket } from '../hooks/useWebSocket';
 import type { FilterType } from '../types';
 
@@ -13,6 +14,9 @@ export const TaskMaster2010: React.FC = () => {
   
   const { data: tasks, isLoading: isLoadingTasks, error: tasksError } = useTasks();
   const { data: lists, isLoading: isLoadingLists } = useLists();
+  const { data: smartLists } = useSmartLists();
+  const smartListId = filter.startsWith('smart-') ? parseInt(filter.replace('smart-', ''), 10) : null;
+  const { data: smartListTasks } = useSmartListTasks(smartListId);
   
   const createTask = useCreateTask();
   const updateTask = useUpdateTask();
@@ -27,6 +31,9 @@ export const TaskMaster2010: React.FC = () => {
   const updateList = useUpdateList();
   const deleteList = useDeleteList();
 
+  const createSmartList = useCreateSmartList();
+  const deleteSmartList = useDeleteSmartList();
+
   /* 
    * Simple arrayMove helper to avoid importing from dnd-kit which might not be exported in the way we expect 
    * or to avoid adding dependency if possible (though it is in package.json).
@@ -44,8 +51,15 @@ export const TaskMaster2010: React.FC = () => {
 
   const allTasks = tasks || [];
   const allLists = lists || [];
+  const allSmartLists = smartLists || [];
 
   const getFilteredTasks = (currentFilter: FilterType) => {
+    if (currentFilter.startsWith('smart-')) {
+        // Matched on the server; show the cached copies so edits appear at once
+        const ids = new Set((smartListTasks || []).map(t => t.id));
+        return allTasks.filter(t => ids.has(t.id));
+    }
+
     if (currentFilter.startsWith('list-')) {
         const listId = parseInt(currentFilter.replace('list-', ''), 10);
         return allTasks.filter(t => t.listId === listId);
@@ -107,6 +121,7 @@ export const TaskMaster2010: React.FC = () => {
           counts={counts}
           tags={tags}
           lists={allLists}
+          smartLists={allSmartLists}
           getTagCount={getTagCount}
           getListCount={getListCount}
           onCreateList={(title) => createList.mutate(title)}
@@ -115,12 +130,18 @@ export const TaskMaster2010: React.FC = () => {
               if (filter === `list-${id}`) setFilter('all');
           }}
           onUpdateList={(id, title) => updateList.mutate({ id, title })}
+          onCreateSmartList={(title, query) => createSmartList.mutate({ title, query })}
+          onDeleteSmartList={(id) => {
+              deleteSmartList.mutate(id);
+              if (filter === `smart-${id}`) setFilter('all');
+          }}
         />
 
         <main className="flex-1 flex flex-col bg-white relative paper-shadow min-w-0">
           <ContentHeader 
             filter={filter} 
             lists={allLists}
+            smartLists={allSmartLists}
             onClearCompleted={() => {
                 // Bulk delete not implemented in backend API yet, maybe loop?
                 // For now, let's skip or implement loop
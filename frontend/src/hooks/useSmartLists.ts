@@ -0,0 +1,65 @@
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { smartLists } from '../api/client';
+import type { SmartList } from '../types';
+
+export function useSmartLists() {
+  return useQuery({
+    queryKey: ['smartLists'],
+    queryFn: smartLists.getAll,
+    staleTime: 1000 * 60 * 5, // 5 minutes
+    // Only fetch if authenticated
+    enabled: !!localStorage.getItem('token'),
+  });
+}
+
+// The server evaluates smart list queries, so their tasks are fetched per list
+// rather than filtered out of the cached tasks.
+export function useSmartListTasks(id: number | null) {
+  return useQuery({
+    queryKey: ['smartListTasks', id],
+    queryFn: () => smartLists.getTasks(id as number),
+    enabled: id !== null && !!localStorage.getItem('token'),
+  });
+}
+
+export function useCreateSmartList() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ title, query }: { title: string; query: string }) => smartLists.create(title, query),
+    onSuccess: (newList) => {
+      // The WebSocket event may have added it already
+      const currentLists = queryClient.getQueryData<SmartList[]>(['smartLists']) || [];
+      queryClient.setQueryData<SmartList[]>(['smartLists'], [
+        ...currentLists.filter(list => list.id !== newList.id),
+        newList,
+      ]);
+    },
+  });
+}
+
+export function useDeleteSmartList() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (id: number) => smartLists.delete(id),
+    onMutate: async (id) => {
+      await queryClient.cancelQueries({ queryKey: ['smartLists'] });
+      const previousLists = queryClient.getQueryData<SmartList[]>(['smartLists']);
+
+      if (previousLists) {
+        queryClient.setQueryData<SmartList[]>(['smartLists'], previousLists.filter(list => list.id !== id));
+      }
+
+      return { previousLists };
+    },
+    onError: (_err, _id, context) => {
+      if (context?.previousLists) {
+        queryClient.setQueryData<SmartList[]>(['smartLists'], context.previousLists);
+      }
+    },
+    onSettled: () => {
+      queryClient.invalidateQueries({ queryKey: ['smartLists'] });
+    },
+  });
+}
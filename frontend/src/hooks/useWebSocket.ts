@@ -24,6 +24,10 @@ interface DeletedPayload {
 interface ReorderPayload {
   taskIds: number[];
 }
+interface SubtaskReorderPayload {
+  taskId: number;
+  subtaskIds: number[];
+}
 interface SubtaskPayload extends Subtask {}
 interface ListPayload extends List {}
 
@@ -102,6 +106,17 @@ export function useWebSocket() {
 
   const handleWebSocketEvent = useCallback((event: WebSocketEvent) => {
     console.log('WebSocket event received:', event.type);
+
+    // Smart lists are evaluated on the server, so refetch them rather than
+    // patching the cache
+    if (event.type.startsWith('smart_list_')) {
+      queryClient.invalidateQueries({ queryKey: ['smartLists'] });
+      queryClient.invalidateQueries({ queryKey: ['smartListTasks'] });
+      return;
+    }
+    if (/^(tasks?|subtasks?|lists?|tags)_/.test(event.type)) {
+      queryClient.invalidateQueries({ queryKey: ['smartListTasks'] });
+    }
     
     const tasks = queryClient.getQueryData<Task[]>(['tasks']);
     if (!tasks) return;
@@ -187,6 +202,21 @@ export function useWebSocket() {
         break;
       }
 
+      case 'subtasks_reordered': {
+        const { taskId, subtaskIds } = event.payload as SubtaskReorderPayload;
+        queryClient.setQueryData<Task[]>(['tasks'], tasks.map(task => {
+          if (task.id !== taskId || !task.subtasks) return task;
+          const subtasks = task.subtasks
+            .map(s => {
+              const index = subtaskIds.indexOf(s.id);
+              return index === -1 ? s : { ...s, sortOrder: index };
+            })
+            .sort((a, b) => a.sortOrder - b.sortOrder);
+          return { ...task, subtasks };
+        }));
+        break;
+      }
+
       case 'list_created': {
         const newList = event.payload as ListPayload;
         const lists = queryClient.getQueryData<List[]>(['lists']);
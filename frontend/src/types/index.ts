@@ -30,6 +30,16 @@ export interface List {
   updatedAt: string;
 }
 
+export interface SmartList {
+  id: number;
+  userId: number;
+  title: string;
+  query: string;
+  version: number;
+  createdAt: string;
+  updatedAt: string;
+}
+
 export interface Task {
   id: number;
   userId: number;
@@ -45,4 +55,6 @@ export interface Task {
   updatedAt: string;
 }
 
+// Besides the built-in filters, `list-<id>` shows a list, `smart-<id>` a smart list
+// and any other string a tag.
 export type FilterType = 'all' | 'active' | 'completed' | 'important' | string;